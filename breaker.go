@@ -0,0 +1,157 @@
+package dsn
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"time"
+)
+
+// ErrCircuitOpen Thrown when forwarding to an upstream host is suspended after repeated failures
+var ErrCircuitOpen = errors.New("sentry:  upstream circuit open")
+
+// BreakerState is the state of one upstream host in a CircuitBreaker.
+type BreakerState int
+
+const (
+	BreakerClosed   BreakerState = iota //forwarding normally
+	BreakerOpen                         //failing, requests are refused until the cooldown passed
+	BreakerHalfOpen                     //cooldown passed, a single probe is let through
+)
+
+func (s BreakerState) String() string {
+	switch s {
+	case BreakerClosed:
+		return "closed"
+	case BreakerOpen:
+		return "open"
+	case BreakerHalfOpen:
+		return "half_open"
+	default:
+		return "unknown"
+	}
+}
+
+// BreakerStatus is what a CircuitBreaker reports for one host.
+type BreakerStatus struct {
+	State    string `json:"state"`
+	Failures int    `json:"consecutive_failures"`
+}
+
+type breakerHost struct {
+	state    BreakerState
+	failures int
+	opened   time.Time
+	probing  bool
+}
+
+// CircuitBreaker tracks upstream hosts separately, so one unhealthy region does not hold up forwarding
+// to the others. A host opens after Threshold consecutive failures; after Cooldown one probe is let through,
+// which closes it again on success or reopens it on failure. It is safe for concurrent use.
+type CircuitBreaker struct {
+	Threshold int
+	Cooldown  time.Duration
+
+	config *config
+	mu     sync.Mutex
+	hosts  map[string]*breakerHost
+}
+
+func NewCircuitBreaker(threshold int, cooldown time.Duration, opts ...Option) *CircuitBreaker {
+	/*
+		Only WithClock applies, cooldowns are measured with it.
+	*/
+	return &CircuitBreaker{Threshold: threshold, Cooldown: cooldown, config: newConfig(opts), hosts: map[string]*breakerHost{}}
+}
+
+func (cb *CircuitBreaker) Allow(host string) error {
+	/*
+		Whether a request to host may be sent now. Every allowed request has to be followed by Report.
+	*/
+	cb.mu.Lock()
+	defer cb.mu.Unlock()
+	h, ok := cb.hosts[host]
+	if !ok {
+		return nil
+	}
+	switch h.state {
+	case BreakerOpen:
+		if cb.config.now().Sub(h.opened) < cb.Cooldown {
+			return ErrCircuitOpen
+		}
+		h.state, h.probing = BreakerHalfOpen, true
+		return nil
+	case BreakerHalfOpen:
+		if h.probing {
+			return ErrCircuitOpen
+		}
+		h.probing = true
+		return nil
+	default:
+		return nil
+	}
+}
+
+func (cb *CircuitBreaker) Report(host string, err error) {
+	/*
+		Records the outcome of a request to host. Only failures a retry might fix (network errors, 429, 5xx)
+		count against the host; a 400 means it is up, and a canceled context says nothing about it either.
+	*/
+	if errors.Is(err, context.Canceled) {
+		cb.mu.Lock()
+		if h, ok := cb.hosts[host]; ok {
+			h.probing = false
+		}
+		cb.mu.Unlock()
+		return
+	}
+	failed := err != nil && retryable(err)
+	cb.mu.Lock()
+	defer cb.mu.Unlock()
+	h, ok := cb.hosts[host]
+	if !ok {
+		if !failed {
+			return
+		}
+		h = &breakerHost{}
+		cb.hosts[host] = h
+	}
+	h.probing = false
+	if !failed {
+		h.state, h.failures = BreakerClosed, 0
+		return
+	}
+	h.failures++
+	if h.state == BreakerHalfOpen || h.failures >= cb.Threshold {
+		h.state, h.opened = BreakerOpen, cb.config.now()
+	}
+}
+
+func (cb *CircuitBreaker) State(host string) BreakerState {
+	cb.mu.Lock()
+	defer cb.mu.Unlock()
+	if h, ok := cb.hosts[host]; ok {
+		return h.state
+	}
+	return BreakerClosed
+}
+
+func (cb *CircuitBreaker) Snapshot() map[string]BreakerStatus {
+	/*
+		Status of every host that failed at least once.
+	*/
+	cb.mu.Lock()
+	defer cb.mu.Unlock()
+	out := make(map[string]BreakerStatus, len(cb.hosts))
+	for host, h := range cb.hosts {
+		out[host] = BreakerStatus{State: h.state.String(), Failures: h.failures}
+	}
+	return out
+}
+
+func (cb *CircuitBreaker) Register(s *Stats) {
+	/*
+		Exposes Snapshot as "circuit_breakers" in s.
+	*/
+	s.Register("circuit_breakers", func() interface{} { return cb.Snapshot() })
+}
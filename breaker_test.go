@@ -0,0 +1,92 @@
+package dsn
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+)
+
+var errTestDown = errors.New("connection refused")
+
+// testBreakerStep reports err for host (unless allow is checked only) and expects the resulting state.
+type testBreakerStep struct {
+	advance     time.Duration
+	report      error
+	description string
+	allowed     bool
+	state       BreakerState
+}
+
+var testTableBreaker = []testBreakerStep{
+	{0, errTestDown, "Testing first failure", true, BreakerClosed},
+	{0, &UpstreamError{StatusCode: http.StatusBadRequest}, "Testing 400 resets the count", true, BreakerClosed},
+	{0, errTestDown, "Testing failure 1 of 2", true, BreakerClosed},
+	{0, &UpstreamError{StatusCode: http.StatusBadGateway}, "Testing failure 2 of 2 opens", true, BreakerOpen},
+	{time.Second, nil, "Testing open within cooldown", false, BreakerOpen},
+	{time.Minute, errTestDown, "Testing failed probe reopens", true, BreakerOpen},
+	{time.Minute, context.Canceled, "Testing canceled probe is ignored", true, BreakerHalfOpen},
+	{0, nil, "Testing successful probe closes", true, BreakerClosed},
+}
+
+func TestCircuitBreaker(t *testing.T) {
+	now := time.Unix(1600000000, 0)
+	cb := NewCircuitBreaker(2, 30*time.Second, WithClock(ClockFunc(func() time.Time { return now })))
+	for _, test := range testTableBreaker {
+		now = now.Add(test.advance)
+		err := cb.Allow("o1.ingest.sentry.io")
+		if (err == nil) != test.allowed {
+			t.Errorf("%s: Expected -- allowed %v -- Got %v", test.description, test.allowed, err)
+		}
+		if err == nil {
+			cb.Report("o1.ingest.sentry.io", test.report)
+		}
+		if s := cb.State("o1.ingest.sentry.io"); s != test.state {
+			t.Errorf("%s: Expected -- %s -- Got %s", test.description, test.state, s)
+		}
+	}
+	if s := cb.State("o2.ingest.sentry.io"); s != BreakerClosed || cb.Allow("o2.ingest.sentry.io") != nil {
+		t.Errorf("Expected -- other hosts unaffected -- Got %s", s)
+	}
+}
+
+func TestCircuitBreakerHalfOpenSingleProbe(t *testing.T) {
+	now := time.Unix(1600000000, 0)
+	cb := NewCircuitBreaker(1, time.Second, WithClock(ClockFunc(func() time.Time { return now })))
+	cb.Report("a", errTestDown)
+	now = now.Add(time.Second)
+	if cb.Allow("a") != nil {
+		t.Errorf("Expected -- probe allowed -- Got refused")
+	}
+	if cb.Allow("a") != ErrCircuitOpen {
+		t.Errorf("Expected -- second request refused while probing -- Got allowed")
+	}
+}
+
+func TestHTTPSinkBreaker(t *testing.T) {
+	calls := 0
+	upstream := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		calls++
+		w.WriteHeader(http.StatusServiceUnavailable)
+	}))
+	defer upstream.Close()
+	up, _ := Parse(strings.Replace(upstream.URL, "://", "://"+testKeyA+"@", 1) + "/1")
+	cb := NewCircuitBreaker(2, time.Hour)
+	stats := NewStats()
+	cb.Register(stats)
+	sink := &HTTPSink{Client: upstream.Client(), Breaker: cb}
+	for i := 0; i < 4; i++ {
+		sink.Send(context.Background(), &Submission{DSN: up, Endpoint: EndpointStore, Headers: http.Header{}})
+	}
+	if calls != 2 {
+		t.Errorf("Expected -- 2 calls before the circuit opened -- Got %d", calls)
+	}
+	snap := stats.Snapshot().Sources["circuit_breakers"].(map[string]BreakerStatus)
+	host := strings.TrimPrefix(upstream.URL, "http://")
+	if snap[host].State != "open" || snap[host].Failures != 2 {
+		t.Errorf("Expected -- open after 2 failures -- Got %+v", snap)
+	}
+}
@@ -0,0 +1,94 @@
+package dsn
+
+import (
+	"errors"
+	"strconv"
+	"strings"
+)
+
+// ErrInvalidPort Thrown by New when the port is not a number between 1 and 65535
+var ErrInvalidPort = errors.New("sentry:  invalid DSN port")
+
+// BuildOption sets one component of a DSN built with New.
+type BuildOption func(*DSN)
+
+func WithPublicKey(key string) BuildOption {
+	return func(d *DSN) { d.PublicKey = key }
+}
+
+func WithSecret(secret string) BuildOption {
+	return func(d *DSN) { d.SecretKey = secret }
+}
+
+func WithHost(host string) BuildOption {
+	return func(d *DSN) { d.Host = host }
+}
+
+func WithPort(port int) BuildOption {
+	return func(d *DSN) { d.Port = strconv.Itoa(port) }
+}
+
+func WithScheme(scheme string) BuildOption {
+	return func(d *DSN) { d.Scheme = scheme }
+}
+
+func WithPath(path string) BuildOption {
+	/*
+		Path prefix in front of the project ID, e.g. "/sentry" for https://key@host/sentry/1.
+	*/
+	return func(d *DSN) {
+		path = strings.Trim(path, "/")
+		if len(path) > 0 {
+			path = "/" + path
+		}
+		d.Path = path
+	}
+}
+
+func WithProjectID(projectID string) BuildOption {
+	return func(d *DSN) { d.ProjectID = projectID }
+}
+
+func New(opts ...BuildOption) (*DSN, error) {
+	/*
+		Builds and validates a DSN, e.g. New(WithPublicKey(pk), WithHost("o1.ingest.sentry.io"), WithProjectID("1")).
+		Unlike CreateDSN, which is kept for request-derived values, every incomplete combination is an error
+		(the same errors Parse returns) instead of a DSN with an empty URL. Scheme defaults to https.
+	*/
+	d := &DSN{Scheme: "https"}
+	for _, opt := range opts {
+		opt(d)
+	}
+	if err := d.validate(); err != nil {
+		return nil, err
+	}
+	d.URL = d.String()
+	return d, nil
+}
+
+func (d *DSN) validate() error {
+	if d.Scheme != "http" && d.Scheme != "https" && d.Scheme != SchemeHTTPUnix {
+		return ErrInvalidScheme
+	}
+	if d.Scheme == SchemeHTTPUnix && len(d.Socket) == 0 {
+		return ErrMissingSocket
+	}
+	if d.Scheme != SchemeHTTPUnix && len(d.Socket) > 0 {
+		return ErrInvalidScheme
+	}
+	if len(d.Host) == 0 {
+		return ErrMissingHost
+	}
+	if len(d.Port) > 0 {
+		if p, err := strconv.Atoi(d.Port); err != nil || p < 1 || p > 65535 {
+			return ErrInvalidPort
+		}
+	}
+	if len(d.PublicKey) == 0 {
+		return ErrMissingUser
+	}
+	if !projectIDPattern.MatchString(d.ProjectID) {
+		return ErrMissingProjectID
+	}
+	return nil
+}
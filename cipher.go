@@ -0,0 +1,151 @@
+package dsn
+
+import (
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/rand"
+	"database/sql/driver"
+	"encoding/base64"
+	"errors"
+	"io"
+	"strings"
+)
+
+// ErrSealedSecret Thrown when a sealed secret key can not be decrypted, e.g. with the wrong cipher key
+var ErrSealedSecret = errors.New("sentry:  can not decrypt secret key")
+
+// sealedPrefix marks secret keys encrypted by the AES cipher, versioned for key or algorithm changes.
+const sealedPrefix = "enc:v1:"
+
+// SecretCipher encrypts secret keys before components persist them (Spool, FileKeystore, Registry, SealedDSN)
+// and decrypts them on load. Decrypt only sees values marked by IsSealed, so plaintext files keep working
+// while they are migrated.
+type SecretCipher interface {
+	Encrypt(secret string) (string, error) //result must start with "enc:"
+	Decrypt(sealed string) (string, error)
+}
+
+// AESCipher is an AES-GCM SecretCipher. Each Encrypt uses a fresh random nonce.
+type AESCipher struct {
+	aead cipher.AEAD
+}
+
+func NewAESCipher(key []byte) (*AESCipher, error) {
+	/*
+		key must be 16, 24 or 32 bytes for AES-128, AES-192 or AES-256.
+	*/
+	block, err := aes.NewCipher(key)
+	if err != nil {
+		return nil, err
+	}
+	aead, err := cipher.NewGCM(block)
+	if err != nil {
+		return nil, err
+	}
+	return &AESCipher{aead: aead}, nil
+}
+
+func (a *AESCipher) Encrypt(secret string) (string, error) {
+	/*
+		"enc:v1:" followed by base64url of nonce and ciphertext, safe inside a DSN's userinfo.
+	*/
+	nonce := make([]byte, a.aead.NonceSize(), a.aead.NonceSize()+len(secret)+a.aead.Overhead())
+	if _, err := io.ReadFull(rand.Reader, nonce); err != nil {
+		return "", err
+	}
+	return sealedPrefix + base64.RawURLEncoding.EncodeToString(a.aead.Seal(nonce, nonce, []byte(secret), nil)), nil
+}
+
+func (a *AESCipher) Decrypt(sealed string) (string, error) {
+	if !strings.HasPrefix(sealed, sealedPrefix) {
+		return "", ErrSealedSecret
+	}
+	b, err := base64.RawURLEncoding.DecodeString(sealed[len(sealedPrefix):])
+	if err != nil || len(b) < a.aead.NonceSize() {
+		return "", ErrSealedSecret
+	}
+	plain, err := a.aead.Open(nil, b[:a.aead.NonceSize()], b[a.aead.NonceSize():], nil)
+	if err != nil {
+		return "", ErrSealedSecret
+	}
+	return string(plain), nil
+}
+
+func WithSecretCipher(c SecretCipher) Option {
+	/*
+		Encrypts secret keys written by a Spool and decrypts those read by NewSpool, OpenFileKeystore and
+		LoadRegistry. Stored plaintext secrets are still accepted.
+	*/
+	return func(cfg *config) {
+		cfg.cipher = c
+	}
+}
+
+func IsSealed(secret string) bool {
+	/*
+		Whether secret was produced by a SecretCipher rather than being a plaintext key.
+	*/
+	return strings.HasPrefix(secret, "enc:")
+}
+
+func sealSecret(c SecretCipher, secret string) (string, error) {
+	if c == nil || len(secret) == 0 || IsSealed(secret) {
+		return secret, nil
+	}
+	return c.Encrypt(secret)
+}
+
+func openSecret(c SecretCipher, secret string) (string, error) {
+	if !IsSealed(secret) {
+		return secret, nil
+	}
+	if c == nil {
+		return "", ErrSealedSecret
+	}
+	return c.Decrypt(secret)
+}
+
+// SealedDSN stores a DSN in a database column with its secret key encrypted by Cipher.
+// Use it as a query argument or a Scan destination. A nil DSN is stored as NULL.
+type SealedDSN struct {
+	DSN    *DSN
+	Cipher SecretCipher
+}
+
+func (s SealedDSN) Value() (driver.Value, error) {
+	if s.DSN == nil {
+		return nil, nil
+	}
+	secret, err := sealSecret(s.Cipher, s.DSN.SecretKey)
+	if err != nil {
+		return nil, err
+	}
+	d := s.DSN.Clone()
+	d.SecretKey = secret
+	return d.String(), nil
+}
+
+func (s *SealedDSN) Scan(src interface{}) error {
+	var v string
+	switch src := src.(type) {
+	case nil:
+		s.DSN = nil
+		return nil
+	case string:
+		v = src
+	case []byte:
+		v = string(src)
+	default:
+		return ErrInvalidDSN
+	}
+	d, err := Parse(v)
+	if err != nil {
+		return err
+	}
+	if d.SecretKey, err = openSecret(s.Cipher, d.SecretKey); err != nil {
+		return err
+	}
+	d.URL = d.String()
+	s.DSN = d
+	return nil
+}
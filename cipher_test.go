@@ -0,0 +1,167 @@
+package dsn
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"io/ioutil"
+	"net/http"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+var testCipherKey = []byte("0123456789abcdef0123456789abcdef")
+
+func testCipher(t *testing.T, key []byte) *AESCipher {
+	c, err := NewAESCipher(key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return c
+}
+
+func TestAESCipher(t *testing.T) {
+	c := testCipher(t, testCipherKey)
+	sealed, err := c.Encrypt(testKeyB)
+	if err != nil || !IsSealed(sealed) || strings.Contains(sealed, testKeyB) {
+		t.Fatalf("Expected -- sealed secret -- Got %q %v", sealed, err)
+	}
+	if again, _ := c.Encrypt(testKeyB); again == sealed {
+		t.Errorf("Expected -- a fresh nonce per Encrypt -- Got %q twice", sealed)
+	}
+	if plain, err := c.Decrypt(sealed); plain != testKeyB || err != nil {
+		t.Errorf("Expected -- %s -- Got %q %v", testKeyB, plain, err)
+	}
+	other := testCipher(t, []byte("fedcba9876543210"))
+	for _, v := range []string{sealed, testKeyB, sealedPrefix + "!!", sealedPrefix + "AAAA", sealed[:len(sealed)-2]} {
+		if _, err := other.Decrypt(v); !errors.Is(err, ErrSealedSecret) {
+			t.Errorf("Decrypting %q: Expected -- %v -- Got %v", v, ErrSealedSecret, err)
+		}
+	}
+	if _, err := NewAESCipher([]byte("short")); err == nil {
+		t.Errorf("Expected -- invalid key size error -- Got nil")
+	}
+}
+
+func TestOpenSecret(t *testing.T) {
+	c := testCipher(t, testCipherKey)
+	sealed, _ := c.Encrypt(testKeyB)
+	if v, err := openSecret(c, testKeyB); v != testKeyB || err != nil {
+		t.Errorf("Expected -- plaintext passed through -- Got %q %v", v, err)
+	}
+	if _, err := openSecret(nil, sealed); !errors.Is(err, ErrSealedSecret) {
+		t.Errorf("Expected -- %v without a cipher -- Got %v", ErrSealedSecret, err)
+	}
+	if v, _ := sealSecret(c, sealed); v != sealed {
+		t.Errorf("Expected -- sealed secrets not sealed twice -- Got %q", v)
+	}
+}
+
+func TestSealedDSN(t *testing.T) {
+	c := testCipher(t, testCipherKey)
+	d, _ := Parse("https://" + testKeyA + ":" + testKeyB + "@sentry.io/1?timeout=2")
+	v, err := SealedDSN{d, c}.Value()
+	if err != nil {
+		t.Fatal(err)
+	}
+	stored, _ := v.(string)
+	if strings.Contains(stored, testKeyB) || !strings.HasPrefix(stored, "https://"+testKeyA+":enc:") {
+		t.Errorf("Expected -- sealed secret in the stored value -- Got %q", stored)
+	}
+	for _, src := range []interface{}{stored, []byte(stored), d.String()} {
+		var s SealedDSN
+		s.Cipher = c
+		if err := s.Scan(src); err != nil || s.DSN.String() != d.String() || s.DSN.URL != d.String() {
+			t.Errorf("Scanning %v: Expected -- %s -- Got %+v %v", src, d, s.DSN, err)
+		}
+	}
+	var s SealedDSN
+	if err := s.Scan(stored); !errors.Is(err, ErrSealedSecret) {
+		t.Errorf("Expected -- %v without a cipher -- Got %v", ErrSealedSecret, err)
+	}
+	if err := s.Scan(42); !errors.Is(err, ErrInvalidDSN) {
+		t.Errorf("Expected -- %v for an int -- Got %v", ErrInvalidDSN, err)
+	}
+	if v, err := (SealedDSN{}).Value(); v != nil || err != nil {
+		t.Errorf("Expected -- NULL for a nil DSN -- Got %v %v", v, err)
+	}
+}
+
+func TestSpoolSecretCipher(t *testing.T) {
+	dir := t.TempDir()
+	c := testCipher(t, testCipherKey)
+	up := &testUpstream{err: errors.New("connection refused")}
+	sp, _ := NewSpool(dir, up, WithSecretCipher(c))
+	d, _ := Parse("https://" + testKeyA + ":" + testKeyB + "@sentry.io/1")
+	auth := "Sentry sentry_key=" + testKeyA + ", sentry_secret=" + testKeyB
+	sp.Send(context.Background(), &Submission{DSN: d, Endpoint: EndpointStore, Headers: http.Header{"X-Sentry-Auth": {auth}}, Body: []byte(testEvent)})
+	files, _ := filepath.Glob(filepath.Join(dir, "*"+spoolExt))
+	if len(files) != 1 {
+		t.Fatalf("Expected -- 1 spooled file -- Got %d", len(files))
+	}
+	if b, _ := ioutil.ReadFile(files[0]); bytes.Contains(b, []byte(testKeyB)) {
+		t.Errorf("Expected -- no plaintext secret on disk -- Got %s", b)
+	}
+	if d.SecretKey != testKeyB {
+		t.Errorf("Expected -- the submission's DSN untouched -- Got %s", d.SecretKey)
+	}
+	up.err = nil
+	if sent, err := sp.Replay(context.Background()); sent != 1 || err != nil {
+		t.Fatalf("Expected -- 1 replayed -- Got %d %v", sent, err)
+	}
+	if s := up.sent[0]; s.DSN.SecretKey != testKeyB || s.DSN.URL != d.String() || s.Headers.Get("X-Sentry-Auth") != auth {
+		t.Errorf("Expected -- secrets decrypted -- Got %+v", s)
+	}
+}
+
+func TestSpoolWrongCipher(t *testing.T) {
+	dir := t.TempDir()
+	sp, _ := NewSpool(dir, &testUpstream{err: errors.New("connection refused")}, WithSecretCipher(testCipher(t, testCipherKey)))
+	d, _ := Parse("https://" + testKeyA + ":" + testKeyB + "@sentry.io/1")
+	sp.Send(context.Background(), &Submission{DSN: d, Endpoint: EndpointStore, Body: []byte(testEvent)})
+	// restarted without the cipher key
+	sp, _ = NewSpool(dir, &testUpstream{})
+	if sent, err := sp.Replay(context.Background()); sent != 0 || !errors.Is(err, ErrSealedSecret) {
+		t.Errorf("Expected -- %v -- Got %d %v", ErrSealedSecret, sent, err)
+	}
+	if n, _ := sp.Pending(); n != 1 {
+		t.Errorf("Expected -- file kept -- Got %d", n)
+	}
+}
+
+func TestKeystoreSecretCipher(t *testing.T) {
+	c := testCipher(t, testCipherKey)
+	sealed, _ := c.Encrypt(testKeyB)
+	path := filepath.Join(t.TempDir(), "keys.json")
+	ioutil.WriteFile(path, []byte(`{"keys": [{"public_key": "`+testKeyA+`", "secret_key": "`+sealed+`", "project_id": "1"}]}`), 0600)
+	if _, err := OpenFileKeystore(path); !errors.Is(err, ErrSealedSecret) {
+		t.Errorf("Expected -- %v without a cipher -- Got %v", ErrSealedSecret, err)
+	}
+	ks, err := OpenFileKeystore(path, WithSecretCipher(c))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if info, err := ks.ResolveKey(context.Background(), testKeyA); err != nil || info.SecretKey != testKeyB {
+		t.Errorf("Expected -- %s -- Got %+v %v", testKeyB, info, err)
+	}
+}
+
+func TestRegistrySecretCipher(t *testing.T) {
+	c := testCipher(t, testCipherKey)
+	sealed, _ := c.Encrypt(testKeyB)
+	upstream := "https://" + testKeyB + ":" + sealed + "@upstream.example.com/7"
+	path := filepath.Join(t.TempDir(), "tenants.json")
+	ioutil.WriteFile(path, []byte(`{"tenants": [{"public_key": "`+testKeyA+`", "upstream": "`+upstream+`"}]}`), 0600)
+	if _, err := LoadRegistry(path); !errors.Is(err, ErrSealedSecret) {
+		t.Errorf("Expected -- %v without a cipher -- Got %v", ErrSealedSecret, err)
+	}
+	reg, err := LoadRegistry(path, WithSecretCipher(c))
+	if err != nil {
+		t.Fatal(err)
+	}
+	tenant, _ := reg.Lookup(testKeyA)
+	if up := tenant.UpstreamDSN(); up.SecretKey != testKeyB || strings.Contains(up.URL, "enc:") || tenant.Upstream != upstream {
+		t.Errorf("Expected -- decrypted upstream, sealed Upstream -- Got %+v %s", up, tenant.Upstream)
+	}
+}
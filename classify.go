@@ -0,0 +1,122 @@
+package dsn
+
+import (
+	"bytes"
+	"encoding/json"
+	"net/http"
+)
+
+// CategoryMonitor is the data category of cron check-ins.
+const CategoryMonitor = "monitor"
+
+// DefaultClassifyBodyLimit bounds how much (encoded) body is read to classify it.
+var DefaultClassifyBodyLimit int64 = 1 << 20
+
+func ClassifyEvent(payload []byte) string {
+	/*
+		Sniffs the top level of an event payload and returns CategoryError, CategoryTransaction or CategoryMonitor.
+		Nested values are skipped token by token rather than decoded, and an explicit "type" ends the scan.
+		Without one, "spans" or "start_timestamp" mark a transaction and "check_in_id" or "monitor_slug" a check-in.
+		Anything that is not a JSON object classifies as CategoryError, which is what Sentry assumes for store requests.
+	*/
+	dec := json.NewDecoder(bytes.NewReader(payload))
+	if t, err := dec.Token(); err != nil || t != json.Delim('{') {
+		return CategoryError
+	}
+	category := CategoryError
+	for dec.More() {
+		t, err := dec.Token()
+		if err != nil {
+			return category
+		}
+		key, _ := t.(string)
+		switch key {
+		case "type":
+			t, err := dec.Token()
+			if err != nil {
+				return category
+			}
+			switch t {
+			case "transaction":
+				return CategoryTransaction
+			case "check_in":
+				return CategoryMonitor
+			default:
+				return CategoryError
+			}
+		case "spans", "start_timestamp":
+			category = CategoryTransaction
+		case "check_in_id", "monitor_slug":
+			category = CategoryMonitor
+		}
+		if err := skipValue(dec); err != nil {
+			return category
+		}
+	}
+	return category
+}
+
+func skipValue(dec *json.Decoder) error {
+	depth := 0
+	for {
+		t, err := dec.Token()
+		if err != nil {
+			return err
+		}
+		switch t {
+		case json.Delim('{'), json.Delim('['):
+			depth++
+		case json.Delim('}'), json.Delim(']'):
+			depth--
+		}
+		if depth == 0 {
+			return nil
+		}
+	}
+}
+
+func ClassifyEnvelope(body []byte) (string, error) {
+	/*
+		Category of the first event like item (event, transaction or check_in) of an envelope,
+		CategoryDefault if it holds none (e.g. sessions only).
+	*/
+	_, items, err := splitEnvelope(body)
+	if err != nil {
+		return "", err
+	}
+	for _, item := range items {
+		switch typ := itemType(item); typ {
+		case "event", "transaction", "check_in":
+			return ItemCategory(typ), nil
+		}
+	}
+	return CategoryDefault, nil
+}
+
+func WithClassification() Option {
+	/*
+		Fills ParseResult.Category for store and envelope requests, so category based rate limits also apply to
+		legacy store traffic. The body is read up to DefaultClassifyBodyLimit and restored for downstream handlers.
+	*/
+	return func(c *config) {
+		c.classify = NewLimitedPeeker(DefaultClassifyBodyLimit)
+	}
+}
+
+func (c *config) category(r *http.Request, res *ParseResult) string {
+	if res.Endpoint != EndpointStore && res.Endpoint != EndpointEnvelope {
+		return ""
+	}
+	body := res.payload
+	if body == nil {
+		var err error
+		if body, err = peekDecoded(r, c.classify); err != nil {
+			return ""
+		}
+	}
+	if res.Endpoint == EndpointStore {
+		return ClassifyEvent(body)
+	}
+	category, _ := ClassifyEnvelope(body)
+	return category
+}
@@ -0,0 +1,55 @@
+package dsn
+
+import (
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+type testClassify struct {
+	payload     string
+	description string
+	expected    string
+}
+
+var testTableClassify = []testClassify{
+	{testEvent, "Testing plain error", CategoryError},
+	{`{"type":"transaction","spans":[]}`, "Testing typed transaction", CategoryTransaction},
+	{`{"contexts":{"type":"transaction"},"type":"error"}`, "Testing nested type ignored", CategoryError},
+	{`{"extra":{"a":[1,{"b":2}]},"start_timestamp":1,"spans":[{"op":"db"}]}`, "Testing untyped transaction", CategoryTransaction},
+	{`{"check_in_id":"83a7c03ed0a04e1b97e2e3b18d38f244","monitor_slug":"nightly","status":"ok"}`, "Testing check-in", CategoryMonitor},
+	{`{"type":"check_in"}`, "Testing typed check-in", CategoryMonitor},
+	{`[1,2]`, "Testing non object", CategoryError},
+	{`{"spans":[`, "Testing truncated payload", CategoryTransaction},
+}
+
+func TestClassifyEvent(t *testing.T) {
+	for _, test := range testTableClassify {
+		if got := ClassifyEvent([]byte(test.payload)); got != test.expected {
+			t.Errorf("%s: Expected -- %s -- Got %s", test.description, test.expected, got)
+		}
+	}
+}
+
+func TestParseRequestCategory(t *testing.T) {
+	tests := []struct {
+		path        string
+		body        string
+		description string
+		expected    string
+	}{
+		{"/api/1/store/", `{"type":"transaction"}`, "Testing store transaction", CategoryTransaction},
+		{"/api/1/store/", testEvent, "Testing store error", CategoryError},
+		{"/api/1/envelope/", testSessionEnvelope, "Testing envelope with event", CategoryError},
+		{"/api/1/envelope/", "{}\n{\"type\":\"check_in\"}\n{}\n", "Testing envelope with check-in", CategoryMonitor},
+		{"/api/1/envelope/", "{}\n{\"type\":\"sessions\"}\n{}\n", "Testing sessions only envelope", CategoryDefault},
+		{"/api/1/minidump/", testEvent, "Testing minidump", ""},
+	}
+	for _, test := range tests {
+		r := httptest.NewRequest("POST", "https://sentry.io"+test.path+"?sentry_key="+testKeyA, strings.NewReader(test.body))
+		res, err := ParseRequest(r.Context(), r, WithClassification())
+		if err != nil || res.Category != test.expected {
+			t.Errorf("%s: Expected -- %s -- Got %s %v", test.description, test.expected, res.Category, err)
+		}
+	}
+}
@@ -0,0 +1,142 @@
+package dsn
+
+import (
+	"fmt"
+	"net"
+	"net/http"
+	"strings"
+)
+
+// TrustedProxies are the networks whose forwarding headers are believed, e.g. your load balancers.
+// Addresses in other networks could have set the headers themselves.
+type TrustedProxies []*net.IPNet
+
+func ParseTrustedProxies(cidrs ...string) (TrustedProxies, error) {
+	/*
+		Accepts CIDR ranges and single addresses, IPv4 or IPv6.
+	*/
+	nets, err := parseNets(cidrs)
+	if err != nil {
+		return nil, fmt.Errorf("dsn: trusted proxy %w", err)
+	}
+	return TrustedProxies(nets), nil
+}
+
+func parseNets(list []string) ([]*net.IPNet, error) {
+	/*
+		CIDR ranges or single addresses, the latter as /32 or /128 networks.
+	*/
+	nets := make([]*net.IPNet, 0, len(list))
+	for _, s := range list {
+		s = strings.TrimSpace(s)
+		if !strings.Contains(s, "/") {
+			if ip := net.ParseIP(s); ip != nil && ip.To4() != nil {
+				s += "/32"
+			} else {
+				s += "/128"
+			}
+		}
+		_, n, err := net.ParseCIDR(s)
+		if err != nil {
+			return nil, fmt.Errorf("%q: %w", s, err)
+		}
+		nets = append(nets, n)
+	}
+	return nets, nil
+}
+
+func MustParseTrustedProxies(cidrs ...string) TrustedProxies {
+	proxies, err := ParseTrustedProxies(cidrs...)
+	if err != nil {
+		panic(err)
+	}
+	return proxies
+}
+
+func (tp TrustedProxies) Contains(ip net.IP) bool {
+	for _, n := range tp {
+		if n.Contains(ip) {
+			return true
+		}
+	}
+	return false
+}
+
+func ClientIP(r *http.Request, trusted TrustedProxies) net.IP {
+	/*
+		The address of the client behind any trusted proxies. The peer address is used unless it is trusted;
+		then the hops of Forwarded, X-Forwarded-For or X-Real-IP (the first one present) are walked from the
+		nearest proxy outwards and the first untrusted hop is the client. If every hop is trusted the farthest one is.
+		Nil when the peer address is unparseable or a trusted proxy hides the client ("for=unknown").
+	*/
+	peer := parseHop(r.RemoteAddr)
+	if peer == nil || !trusted.Contains(peer) {
+		return peer
+	}
+	hops := forwardedHops(r.Header)
+	ip := peer
+	for i := len(hops) - 1; i >= 0; i-- {
+		if ip = parseHop(hops[i]); ip == nil || !trusted.Contains(ip) {
+			return ip
+		}
+	}
+	return ip
+}
+
+func forwardedHops(h http.Header) []string {
+	/*
+		Client side first, as the headers list them.
+	*/
+	if values := h.Values("Forwarded"); len(values) > 0 {
+		var hops []string
+		for _, v := range values {
+			for _, elem := range strings.Split(v, ",") {
+				for _, pair := range strings.Split(elem, ";") {
+					if k, v, ok := cut(strings.TrimSpace(pair), "="); ok && strings.EqualFold(k, "for") {
+						hops = append(hops, strings.Trim(v, `"`))
+					}
+				}
+			}
+		}
+		return hops
+	}
+	if values := h.Values("X-Forwarded-For"); len(values) > 0 {
+		var hops []string
+		for _, v := range values {
+			for _, hop := range strings.Split(v, ",") {
+				hops = append(hops, strings.TrimSpace(hop))
+			}
+		}
+		return hops
+	}
+	if v := h.Get("X-Real-IP"); len(v) > 0 {
+		return []string{strings.TrimSpace(v)}
+	}
+	return nil
+}
+
+func parseHop(s string) net.IP {
+	/*
+		An address with or without port, IPv6 possibly in brackets.
+	*/
+	if host, _, err := net.SplitHostPort(s); err == nil {
+		s = host
+	}
+	return net.ParseIP(strings.Trim(s, "[]"))
+}
+
+func cut(s, sep string) (string, string, bool) {
+	if i := strings.Index(s, sep); i >= 0 {
+		return s[:i], s[i+len(sep):], true
+	}
+	return s, "", false
+}
+
+func WithTrustedProxies(proxies TrustedProxies) Option {
+	/*
+		Fills ParseResult.ClientIP with ClientIP. Pass nil to use the peer address as is.
+	*/
+	return func(c *config) {
+		c.trustedProxies = append(TrustedProxies{}, proxies...)
+	}
+}
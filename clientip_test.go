@@ -0,0 +1,65 @@
+package dsn
+
+import (
+	"net/http/httptest"
+	"testing"
+)
+
+var testProxies = MustParseTrustedProxies("10.0.0.0/8", "2001:db8::/32", "192.0.2.1")
+
+type testClientIP struct {
+	remoteAddr  string
+	header      string
+	value       string
+	description string
+	expected    string
+}
+
+var testTableClientIP = []testClientIP{
+	{"203.0.113.9:5000", "", "", "Testing direct client", "203.0.113.9"},
+	{"203.0.113.9:5000", "X-Forwarded-For", "198.51.100.7", "Testing headers from untrusted peer ignored", "203.0.113.9"},
+	{"10.0.0.1:5000", "X-Forwarded-For", "198.51.100.7", "Testing X-Forwarded-For", "198.51.100.7"},
+	{"10.0.0.1:5000", "X-Forwarded-For", "6.6.6.6, 198.51.100.7, 10.0.0.2", "Testing spoofed hop left of the client", "198.51.100.7"},
+	{"10.0.0.1:5000", "X-Forwarded-For", "10.0.0.3, 10.0.0.2", "Testing only trusted hops", "10.0.0.3"},
+	{"10.0.0.1:5000", "X-Forwarded-For", "198.51.100.7:4711", "Testing hop with port", "198.51.100.7"},
+	{"10.0.0.1:5000", "X-Real-IP", "198.51.100.7", "Testing X-Real-IP", "198.51.100.7"},
+	{"10.0.0.1:5000", "Forwarded", `for=198.51.100.7;proto=https, for="[2001:db8:cafe::17]:4711"`, "Testing Forwarded", "198.51.100.7"},
+	{"[2001:db8::1]:5000", "Forwarded", `for="[2001:db9::17]:4711"`, "Testing IPv6 proxy and client", "2001:db9::17"},
+	{"192.0.2.1:5000", "Forwarded", "for=unknown", "Testing hidden client", "<nil>"},
+	{"10.0.0.1:5000", "", "", "Testing trusted peer without headers", "10.0.0.1"},
+	{"garbage", "", "", "Testing unparseable peer", "<nil>"},
+}
+
+func TestClientIP(t *testing.T) {
+	for _, test := range testTableClientIP {
+		r := httptest.NewRequest("POST", "https://relay.example.com/api/1/store/", nil)
+		r.RemoteAddr = test.remoteAddr
+		if len(test.header) > 0 {
+			r.Header.Set(test.header, test.value)
+		}
+		if ip := ClientIP(r, testProxies); ip.String() != test.expected {
+			t.Errorf("%s: Expected -- %s -- Got %s", test.description, test.expected, ip)
+		}
+	}
+}
+
+func TestWithTrustedProxies(t *testing.T) {
+	r := httptest.NewRequest("POST", "https://relay.example.com/api/1/store/?sentry_key="+testKeyA, nil)
+	r.RemoteAddr = "10.0.0.1:5000"
+	r.Header.Set("X-Forwarded-For", "198.51.100.7")
+	res, err := ParseRequest(r.Context(), r, WithTrustedProxies(testProxies))
+	if err != nil || res.ClientIP.String() != "198.51.100.7" {
+		t.Errorf("Expected -- 198.51.100.7 -- Got %s %v", res.ClientIP, err)
+	}
+	res, _ = ParseRequest(r.Context(), r, WithTrustedProxies(nil))
+	if res.ClientIP.String() != "10.0.0.1" {
+		t.Errorf("Testing no trusted proxies: Expected -- peer address -- Got %s", res.ClientIP)
+	}
+	res, _ = ParseRequest(r.Context(), r)
+	if res.ClientIP != nil {
+		t.Errorf("Testing without option: Expected -- nil -- Got %s", res.ClientIP)
+	}
+	if _, err := ParseTrustedProxies("10.0.0.0/40"); err == nil {
+		t.Errorf("Testing invalid CIDR: Expected -- error -- Got nil")
+	}
+}
@@ -0,0 +1,95 @@
+package dsn
+
+import (
+	"crypto/rand"
+	"encoding/hex"
+	"encoding/json"
+	"net/http"
+	"time"
+)
+
+// Clock tells the time wherever the package needs it: key validity, Stats uptime, client report timestamps.
+// Inject a fixed one with WithClock for deterministic tests.
+type Clock interface {
+	Now() time.Time
+}
+
+// ClockFunc adapts a plain function (such as time.Now) to Clock.
+type ClockFunc func() time.Time
+
+func (f ClockFunc) Now() time.Time {
+	return f()
+}
+
+// SystemClock is the default Clock.
+var SystemClock Clock = ClockFunc(time.Now)
+
+// IDGenerator makes event IDs for responses that need one. IDs are 32 lower case hex characters,
+// like a UUID without dashes, which is what SDKs expect.
+type IDGenerator interface {
+	NewID() string
+}
+
+// IDGeneratorFunc adapts a plain function to IDGenerator.
+type IDGeneratorFunc func() string
+
+func (f IDGeneratorFunc) NewID() string {
+	return f()
+}
+
+// RandomIDs is the default IDGenerator: random version 4 UUIDs.
+var RandomIDs IDGenerator = IDGeneratorFunc(func() string {
+	var b [16]byte
+	rand.Read(b[:])
+	b[6] = b[6]&0x0f | 0x40
+	b[8] = b[8]&0x3f | 0x80
+	return hex.EncodeToString(b[:])
+})
+
+func WithClock(clock Clock) Option {
+	/*
+		Replaces SystemClock for parsing (key validity) and for the constructors that take options
+		(NewStats, NewOutcomeRecorder).
+	*/
+	return func(c *config) {
+		c.clock = clock
+	}
+}
+
+func WithIDGenerator(g IDGenerator) Option {
+	/*
+		Replaces RandomIDs wherever an event ID has to be made up, see WriteAccepted.
+	*/
+	return func(c *config) {
+		c.ids = g
+	}
+}
+
+func (c *config) now() time.Time {
+	if c.clock == nil {
+		return SystemClock.Now()
+	}
+	return c.clock.Now()
+}
+
+func (c *config) newID() string {
+	if c.ids == nil {
+		return RandomIDs.NewID()
+	}
+	return c.ids.NewID()
+}
+
+func WriteAccepted(w http.ResponseWriter, eventID string, opts ...Option) {
+	/*
+		Answers an accepted submission the way Sentry does: 200 with {"id": "<event id>"}.
+		Without an eventID (e.g. the payload was not inspected) one is made up with the configured IDGenerator.
+	*/
+	if len(eventID) == 0 {
+		eventID = newConfig(opts).newID()
+	}
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusOK)
+	json.NewEncoder(w).Encode(struct {
+		ID string `json:"id"`
+	}{eventID})
+}
@@ -0,0 +1,52 @@
+package dsn
+
+import (
+	"context"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/dgbailey/dsn/internal/keys"
+)
+
+type fixedClock struct {
+	now time.Time
+}
+
+func (c *fixedClock) Now() time.Time {
+	return c.now
+}
+
+func TestWithClock(t *testing.T) {
+	clock := &fixedClock{now: time.Date(2020, 1, 1, 0, 0, 0, 0, time.UTC)}
+	resolver := KeyResolverFunc(func(ctx context.Context, publicKey string) (*KeyInfo, error) {
+		return &KeyInfo{PublicKey: publicKey, ProjectID: "1", Validity: Validity{NotAfter: time.Date(2021, 1, 1, 0, 0, 0, 0, time.UTC)}}, nil
+	})
+	r := httptest.NewRequest("POST", "https://sentry.io/api/1/store/?sentry_key="+testKeyA, nil)
+	if _, err := FromRequest(r, WithKeyResolver(resolver), WithClock(clock)); err != nil {
+		t.Errorf("Expected -- valid in 2020 -- Got %v", err)
+	}
+	clock.now = clock.now.AddDate(2, 0, 0)
+	if _, err := FromRequest(r, WithKeyResolver(resolver), WithClock(clock)); err != ErrKeyExpired {
+		t.Errorf("Expected -- %v in 2022 -- Got %v", ErrKeyExpired, err)
+	}
+
+	stats := NewStats(WithClock(clock))
+	clock.now = clock.now.Add(90 * time.Second)
+	if uptime := stats.Snapshot().Uptime; uptime != 90 {
+		t.Errorf("Expected -- 90s uptime -- Got %v", uptime)
+	}
+}
+
+func TestWriteAccepted(t *testing.T) {
+	w := httptest.NewRecorder()
+	WriteAccepted(w, "", WithIDGenerator(IDGeneratorFunc(func() string { return "9ec79c33ec9942ab8353589fcb2e04dc" })))
+	if expected := `{"id":"9ec79c33ec9942ab8353589fcb2e04dc"}` + "\n"; w.Code != 200 || w.Body.String() != expected {
+		t.Errorf("Expected -- %s -- Got %d %s", expected, w.Code, w.Body.String())
+	}
+	w = httptest.NewRecorder()
+	WriteAccepted(w, "")
+	if id := w.Body.String()[7:39]; !keys.IsHex(id) || id[12] != '4' {
+		t.Errorf("Expected -- random v4 id -- Got %s", w.Body.String())
+	}
+}
@@ -0,0 +1,174 @@
+// Command dsnctl parses, validates, builds and redacts Sentry DSNs and parses raw HTTP requests
+// the same way the dsn package does, printing JSON. Handy when debugging SDK configuration and proxies.
+//
+//	dsnctl parse <dsn>
+//	dsnctl validate <dsn>
+//	dsnctl build -key <public> [-secret <secret>] -host <host> -project <id> [-scheme http] [-port 9000] [-path /sentry]
+//	dsnctl redact <dsn>
+//	dsnctl snippet <sdk> <dsn>
+//	dsnctl request [-trace] <file>   (raw HTTP/1.x request, "-" reads stdin)
+//	dsnctl replay <files...> (HAR captures or raw HTTP/1.x requests)
+package main
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+
+	"github.com/dgbailey/dsn"
+)
+
+const usage = `usage: dsnctl <command> [arguments]
+
+commands:
+  parse <dsn>        parse a DSN string
+  validate <dsn>     parse a DSN string and exit non-zero if it is invalid
+  build [flags]      build a DSN from its components (see dsnctl build -h)
+  redact <dsn>       print a DSN with its secret key masked
+  snippet <sdk> <dsn>
+                     print SDK init code for a DSN (javascript, python, go, ...)
+  request [-trace] <file>
+                     parse a raw HTTP request ("-" reads stdin), -trace lists every decision
+  replay <files...>  run captured requests (HAR or raw HTTP) through the parser
+`
+
+// result is the JSON document printed by every command.
+type result struct {
+	Valid bool      `json:"valid"`
+	Error string    `json:"error,omitempty"`
+	DSN   *dsn.DSN  `json:"dsn,omitempty"`
+	Text  string    `json:"text,omitempty"`
+	Trace dsn.Trace `json:"trace,omitempty"`
+}
+
+func main() {
+	os.Exit(run(os.Args[1:], os.Stdin, os.Stdout, os.Stderr))
+}
+
+func run(args []string, stdin io.Reader, stdout io.Writer, stderr io.Writer) int {
+	/*
+		Returns the process exit code: 0 on success, 1 when the input is invalid and 2 on usage errors.
+	*/
+	if len(args) == 0 {
+		fmt.Fprint(stderr, usage)
+		return 2
+	}
+	cmd, args := args[0], args[1:]
+	switch cmd {
+	case "parse", "validate", "redact":
+		if len(args) != 1 {
+			fmt.Fprint(stderr, usage)
+			return 2
+		}
+		d, err := dsn.Parse(args[0])
+		if cmd == "redact" && err == nil {
+			return emit(stdout, result{Valid: true, Text: d.Redacted()})
+		}
+		return emit(stdout, newResult(d, err))
+	case "snippet":
+		if len(args) != 2 {
+			fmt.Fprint(stderr, usage)
+			return 2
+		}
+		d, err := dsn.Parse(args[1])
+		if err != nil {
+			return emit(stdout, newResult(d, err))
+		}
+		snippet, err := d.Snippet(args[0])
+		if err != nil {
+			return emit(stdout, result{Error: err.Error()})
+		}
+		return emit(stdout, result{Valid: true, Text: snippet})
+	case "build":
+		return build(args, stdout, stderr)
+	case "request":
+		trace := len(args) > 0 && args[0] == "-trace"
+		if trace {
+			args = args[1:]
+		}
+		if len(args) != 1 {
+			fmt.Fprint(stderr, usage)
+			return 2
+		}
+		return request(args[0], trace, stdin, stdout, stderr)
+	case "replay":
+		return replay(args, stdout, stderr)
+	case "help", "-h", "--help":
+		fmt.Fprint(stdout, usage)
+		return 0
+	}
+	fmt.Fprintf(stderr, "dsnctl: unknown command %q\n\n%s", cmd, usage)
+	return 2
+}
+
+func build(args []string, stdout io.Writer, stderr io.Writer) int {
+	fs := flag.NewFlagSet("build", flag.ContinueOnError)
+	fs.SetOutput(stderr)
+	key := fs.String("key", "", "public key")
+	secret := fs.String("secret", "", "secret key (optional)")
+	host := fs.String("host", "", "host name")
+	project := fs.String("project", "", "numeric project ID")
+	scheme := fs.String("scheme", "https", "http or https")
+	port := fs.Int("port", 0, "port (optional)")
+	path := fs.String("path", "", "path prefix before the project ID (optional)")
+	if err := fs.Parse(args); err != nil {
+		return 2
+	}
+	opts := []dsn.BuildOption{
+		dsn.WithPublicKey(*key), dsn.WithSecret(*secret), dsn.WithHost(*host),
+		dsn.WithProjectID(*project), dsn.WithScheme(*scheme), dsn.WithPath(*path),
+	}
+	if *port != 0 {
+		opts = append(opts, dsn.WithPort(*port))
+	}
+	return emit(stdout, newResult(dsn.New(opts...)))
+}
+
+func request(name string, trace bool, stdin io.Reader, stdout io.Writer, stderr io.Writer) int {
+	in := stdin
+	if name != "-" {
+		f, err := os.Open(name)
+		if err != nil {
+			fmt.Fprintf(stderr, "dsnctl: %s\n", err)
+			return 2
+		}
+		defer f.Close()
+		in = f
+	}
+	r, err := http.ReadRequest(bufio.NewReader(in))
+	if err != nil {
+		return emit(stdout, result{Error: err.Error()})
+	}
+	if !trace {
+		return emit(stdout, newResult(dsn.FromRequest(r)))
+	}
+	res, err := dsn.ParseRequest(context.Background(), r, dsn.WithTrace())
+	var terr *dsn.TraceError
+	if errors.As(err, &terr) {
+		return emit(stdout, result{Error: err.Error(), Trace: terr.Trace})
+	}
+	return emit(stdout, result{Valid: true, DSN: res.DSN, Trace: res.Trace})
+}
+
+func newResult(d *dsn.DSN, err error) result {
+	if err != nil {
+		return result{Error: err.Error()}
+	}
+	return result{Valid: true, DSN: d}
+}
+
+func emit(w io.Writer, res result) int {
+	enc := json.NewEncoder(w)
+	enc.SetIndent("", "  ")
+	enc.Encode(res)
+	if !res.Valid {
+		return 1
+	}
+	return 0
+}
@@ -0,0 +1,156 @@
+package main
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"strings"
+
+	"github.com/dgbailey/dsn"
+)
+
+// replayed is the outcome for one captured request.
+type replayed struct {
+	Source string   `json:"source"`
+	Index  int      `json:"index"`
+	Method string   `json:"method,omitempty"`
+	URL    string   `json:"url,omitempty"`
+	Valid  bool     `json:"valid"`
+	Error  string   `json:"error,omitempty"`
+	DSN    *dsn.DSN `json:"dsn,omitempty"`
+}
+
+type replayReport struct {
+	Requests []replayed `json:"requests"`
+	Accepted int        `json:"accepted"`
+	Rejected int        `json:"rejected"`
+}
+
+// harFile is the subset of the HAR 1.2 format needed to rebuild requests.
+type harFile struct {
+	Log struct {
+		Entries []struct {
+			Request struct {
+				Method  string `json:"method"`
+				URL     string `json:"url"`
+				Headers []struct {
+					Name  string `json:"name"`
+					Value string `json:"value"`
+				} `json:"headers"`
+				PostData *struct {
+					Text string `json:"text"`
+				} `json:"postData"`
+			} `json:"request"`
+		} `json:"entries"`
+	} `json:"log"`
+}
+
+func replay(files []string, stdout io.Writer, stderr io.Writer) int {
+	/*
+		Runs every captured request through dsn.FromRequest and reports each outcome.
+		Files ending in .har (or starting with '{') are read as HAR, anything else as one or more raw HTTP/1.x requests.
+	*/
+	if len(files) == 0 {
+		fmt.Fprint(stderr, usage)
+		return 2
+	}
+	report := replayReport{Requests: []replayed{}}
+	for _, name := range files {
+		data, err := ioutil.ReadFile(name)
+		if err != nil {
+			fmt.Fprintf(stderr, "dsnctl: %s\n", err)
+			return 2
+		}
+		var outcomes []replayed
+		if strings.HasSuffix(name, ".har") || bytes.HasPrefix(bytes.TrimSpace(data), []byte("{")) {
+			outcomes, err = replayHAR(name, data)
+		} else {
+			outcomes, err = replayRaw(name, data)
+		}
+		if err != nil {
+			fmt.Fprintf(stderr, "dsnctl: %s: %s\n", name, err)
+			return 2
+		}
+		for _, o := range outcomes {
+			if o.Valid {
+				report.Accepted++
+			} else {
+				report.Rejected++
+			}
+		}
+		report.Requests = append(report.Requests, outcomes...)
+	}
+	enc := json.NewEncoder(stdout)
+	enc.SetIndent("", "  ")
+	enc.Encode(report)
+	if report.Rejected > 0 {
+		return 1
+	}
+	return 0
+}
+
+func replayHAR(name string, data []byte) ([]replayed, error) {
+	var har harFile
+	if err := json.Unmarshal(data, &har); err != nil {
+		return nil, err
+	}
+	var out []replayed
+	for i, e := range har.Log.Entries {
+		var body io.Reader
+		if e.Request.PostData != nil {
+			body = strings.NewReader(e.Request.PostData.Text)
+		}
+		r, err := http.NewRequest(e.Request.Method, e.Request.URL, body)
+		if err != nil {
+			out = append(out, replayed{Source: name, Index: i, Method: e.Request.Method, URL: e.Request.URL, Error: err.Error()})
+			continue
+		}
+		for _, h := range e.Request.Headers {
+			// HTTP/2 captures list pseudo headers, they are not real headers
+			if !strings.HasPrefix(h.Name, ":") {
+				r.Header.Add(h.Name, h.Value)
+			}
+		}
+		out = append(out, outcome(name, i, r))
+	}
+	return out, nil
+}
+
+func replayRaw(name string, data []byte) ([]replayed, error) {
+	var out []replayed
+	br := bufio.NewReader(bytes.NewReader(data))
+	for i := 0; ; i++ {
+		if _, err := br.Peek(1); err == io.EOF {
+			return out, nil
+		}
+		r, err := http.ReadRequest(br)
+		if err != nil {
+			return out, fmt.Errorf("request %d: %s", i, err)
+		}
+		// drain the body so the next request starts at the right offset
+		io.Copy(ioutil.Discard, r.Body)
+		out = append(out, outcome(name, i, r))
+		for {
+			if b, err := br.Peek(1); err != nil || (b[0] != '\r' && b[0] != '\n') {
+				break
+			}
+			br.ReadByte()
+		}
+	}
+}
+
+func outcome(name string, i int, r *http.Request) replayed {
+	o := replayed{Source: name, Index: i, Method: r.Method, URL: r.URL.String()}
+	d, err := dsn.FromRequest(r)
+	if err != nil {
+		o.Error = err.Error()
+		return o
+	}
+	o.Valid = true
+	o.DSN = d
+	return o
+}
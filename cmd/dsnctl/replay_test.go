@@ -0,0 +1,50 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+const testRaw = "POST /api/1234/store/ HTTP/1.1\r\nHost: sentry.io\r\nX-Sentry-Auth: Sentry sentry_version=7, sentry_key=4784fbc50de2473f9977cfce8a9adce5\r\nContent-Length: 2\r\n\r\n{}\r\n" +
+	"POST /api/1234/store/ HTTP/1.1\r\nHost: sentry.io\r\nContent-Length: 0\r\n\r\n"
+
+const testHAR = `{"log":{"entries":[
+	{"request":{"method":"POST","url":"https://sentry.io/api/1234/envelope/?sentry_key=4784fbc50de2473f9977cfce8a9adce5","headers":[{"name":":authority","value":"sentry.io"}],"postData":{"text":"{}"}}},
+	{"request":{"method":"POST","url":"https://sentry.io/api/0/projects/?sentry_key=4784fbc50de2473f9977cfce8a9adce5","headers":[]}}
+]}}`
+
+func TestReplay(t *testing.T) {
+	dir, err := ioutil.TempDir("", "dsnctl")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	raw, har := filepath.Join(dir, "captured.http"), filepath.Join(dir, "captured.har")
+	ioutil.WriteFile(raw, []byte(testRaw), 0600)
+	ioutil.WriteFile(har, []byte(testHAR), 0600)
+
+	var stdout, stderr bytes.Buffer
+	code := run([]string{"replay", raw, har}, nil, &stdout, &stderr)
+	var report replayReport
+	json.Unmarshal(stdout.Bytes(), &report)
+	if code != 1 || report.Accepted != 2 || report.Rejected != 2 || len(report.Requests) != 4 {
+		t.Fatalf("Expected -- 2 accepted 2 rejected -- Got %d %s %s", code, stdout.String(), stderr.String())
+	}
+	expected := []string{"", "sentry:  missing public key", "", "sentry:  not an ingest endpoint"}
+	for i, r := range report.Requests {
+		if r.Error != expected[i] {
+			t.Errorf("request %d: Expected -- %q -- Got %q", i, expected[i], r.Error)
+		}
+	}
+}
+
+func TestReplayMissingFile(t *testing.T) {
+	var stdout, stderr bytes.Buffer
+	if code := run([]string{"replay", "does-not-exist.http"}, nil, &stdout, &stderr); code != 2 {
+		t.Errorf("Expected -- 2 -- Got %d", code)
+	}
+}
@@ -0,0 +1,110 @@
+package dsn
+
+import (
+	"context"
+	"errors"
+)
+
+// errorCodes gives every error this package returns a stable code, for responses, logs and metrics labels.
+// Checked in order with errors.Is. Codes are never renamed; add new ones at will.
+var errorCodes = []struct {
+	err  error
+	code string
+}{
+	{ErrMissingUser, "missing_public_key"},
+	{ErrMissingProjectID, "invalid_project_path"},
+	{ErrNotIngestEndpoint, "unsupported_endpoint"},
+	{ErrMethodNotAllowed, "method_not_allowed"},
+	{ErrMissingVersion, "missing_version"},
+	{ErrUnsupportedVersion, "unsupported_version"},
+	{ErrSecretRequired, "secret_required"},
+	{ErrSecretNotAllowed, "secret_not_allowed"},
+	{ErrSecretInQuery, "secret_in_query"},
+	{ErrUnknownKey, "unknown_key"},
+	{ErrInvalidKeyID, "invalid_key_id"},
+	{ErrKeyDisabled, "key_disabled"},
+	{ErrKeyExpired, "key_expired"},
+	{ErrKeyNotYetValid, "key_not_yet_valid"},
+	{ErrProjectMismatch, "project_mismatch"},
+	{ErrEndpointNotAllowed, "endpoint_not_allowed"},
+	{ErrOriginNotAllowed, "origin_not_allowed"},
+	{ErrDSNNotAllowed, "dsn_not_allowed"},
+	{ErrMissingSignature, "missing_signature"},
+	{ErrInvalidSignature, "invalid_signature"},
+	{ErrInvalidPayload, "invalid_payload"},
+	{ErrInvalidEnvelope, "invalid_envelope"},
+	{ErrBodyTruncated, "body_truncated"},
+	{ErrPayloadTooLarge, "payload_too_large"},
+	{ErrUnsupportedEncoding, "unsupported_encoding"},
+	{ErrNotMultipart, "not_multipart"},
+	{ErrNotUpgrade, "not_upgrade"},
+	{ErrInvalidDSN, "invalid_dsn"},
+	{ErrInvalidScheme, "invalid_scheme"},
+	{ErrMissingHost, "missing_host"},
+	{ErrMissingSocket, "missing_socket"},
+	{ErrSocketNotAllowed, "socket_not_allowed"},
+	{ErrInvalidPort, "invalid_port"},
+	{ErrInvalidPattern, "invalid_pattern"},
+	{ErrNoRoute, "no_route"},
+	{ErrNoRewrite, "no_rewrite"},
+	{ErrQueueFull, "queue_full"},
+	{ErrPipelineClosed, "pipeline_closed"},
+	{ErrNotForwardable, "not_forwardable"},
+	{ErrCircuitOpen, "circuit_open"},
+	{ErrConcurrencyLimit, "concurrency_limit"},
+	{ErrUpstream, "upstream_error"},
+	{ErrInvalidSpoolFile, "invalid_spool_file"},
+	{ErrWireVersion, "unsupported_wire_version"},
+	{ErrInvalidWire, "invalid_wire"},
+	{ErrProjectConfigPending, "config_pending"},
+	{ErrReplayed, "replayed"},
+	{ErrStaleTimestamp, "stale_timestamp"},
+	{ErrConflictingCredentials, "conflicting_credentials"},
+	{ErrUnknownSDK, "unknown_sdk"},
+	{ErrHostNotFound, "host_not_found"},
+	{ErrEndpointUnreachable, "endpoint_unreachable"},
+	{ErrInsecureTransport, "insecure_transport"},
+	{ErrInsecureScheme, "insecure_scheme"},
+	{ErrSealedSecret, "sealed_secret"},
+	{ErrSpikeLimited, "spike_limited"},
+	{context.DeadlineExceeded, "timeout"},
+	{context.Canceled, "canceled"},
+}
+
+// ErrorInfo is the machine readable form of an error, e.g. for JSON error bodies.
+type ErrorInfo struct {
+	Code    string `json:"code"`
+	Status  int    `json:"status"` //HTTP status hint, see ErrorStatus
+	Message string `json:"message"`
+}
+
+func ErrorCode(err error) string {
+	/*
+		Stable snake case code for err, such as "missing_public_key" or "unsupported_endpoint", so callers
+		never have to match error text. A *ValidationError reports its first error. Empty for nil,
+		"other" for errors that did not come from this package.
+	*/
+	if err == nil {
+		return ""
+	}
+	var verr *ValidationError
+	if errors.As(err, &verr) && len(verr.Errors) > 0 {
+		err = verr.Errors[0]
+	}
+	for _, c := range errorCodes {
+		if errors.Is(err, c.err) {
+			return c.code
+		}
+	}
+	return "other"
+}
+
+func DescribeError(err error) ErrorInfo {
+	/*
+		Code, HTTP status and message of err in one value.
+	*/
+	if err == nil {
+		return ErrorInfo{Status: ErrorStatus(nil)}
+	}
+	return ErrorInfo{Code: ErrorCode(err), Status: ErrorStatus(err), Message: err.Error()}
+}
@@ -0,0 +1,53 @@
+package dsn
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"testing"
+)
+
+type testErrorCode struct {
+	err         error
+	description string
+	code        string
+	status      int
+}
+
+var testTableErrorCode = []testErrorCode{
+	{nil, "Testing nil", "", http.StatusOK},
+	{ErrMissingUser, "Testing missing key", "missing_public_key", http.StatusUnauthorized},
+	{ErrMissingProjectID, "Testing bad project path", "invalid_project_path", http.StatusBadRequest},
+	{ErrNotIngestEndpoint, "Testing web API path", "unsupported_endpoint", http.StatusNotFound},
+	{fmt.Errorf("resolving: %w", ErrKeyDisabled), "Testing wrapped error", "key_disabled", http.StatusForbidden},
+	{&MethodError{Method: "PUT"}, "Testing typed error", "method_not_allowed", http.StatusMethodNotAllowed},
+	{&SizeError{Endpoint: EndpointStore, Limit: 1}, "Testing size error", "payload_too_large", http.StatusRequestEntityTooLarge},
+	{&ValidationError{Errors: []error{ErrUnknownKey, ErrMissingProjectID}}, "Testing first of collected errors", "unknown_key", http.StatusUnauthorized},
+	{&UpstreamError{StatusCode: 500}, "Testing upstream error", "upstream_error", http.StatusBadGateway},
+	{ErrCircuitOpen, "Testing open circuit", "circuit_open", http.StatusServiceUnavailable},
+	{context.DeadlineExceeded, "Testing timeout", "timeout", http.StatusServiceUnavailable},
+	{errors.New("boom"), "Testing foreign error", "other", http.StatusBadRequest},
+}
+
+func TestErrorCode(t *testing.T) {
+	for _, test := range testTableErrorCode {
+		info := DescribeError(test.err)
+		if info.Code != test.code || info.Status != test.status || ErrorCode(test.err) != test.code {
+			t.Errorf("%s: Expected -- %s %d -- Got %s %d", test.description, test.code, test.status, info.Code, info.Status)
+		}
+	}
+}
+
+func TestErrorCodesUnique(t *testing.T) {
+	seen := map[string]bool{}
+	for _, c := range errorCodes {
+		if seen[c.code] {
+			t.Errorf("Expected -- unique codes -- Got %s twice", c.code)
+		}
+		seen[c.code] = true
+		if ErrorCode(c.err) != c.code {
+			t.Errorf("Testing %v: Expected -- %s -- Got %s (shadowed by an earlier entry)", c.err, c.code, ErrorCode(c.err))
+		}
+	}
+}
@@ -0,0 +1,206 @@
+package dsn
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"strconv"
+	"sync"
+)
+
+// ErrConcurrencyLimit Thrown when a DSN, or the relay as a whole, has too many submissions in flight
+var ErrConcurrencyLimit = errors.New("sentry:  too many concurrent submissions")
+
+// dsnSlots is the semaphore of one DSN; refs counts holders and waiters so idle DSNs can be forgotten.
+type dsnSlots struct {
+	ch   chan struct{}
+	refs int
+}
+
+// ConcurrencyLimiter bounds submissions in flight, per DSN (by fingerprint) and overall. Where a rate limit
+// caps events over time, this keeps a single chatty tenant from occupying every forwarding worker or
+// upstream connection of a shared relay. A DSN's own slot is taken before a global one, so tenants waiting
+// on their own cap never hold global slots. A ConcurrencyLimiter is safe for concurrent use.
+type ConcurrencyLimiter struct {
+	perDSN int
+	global chan struct{} //nil without a global cap
+
+	mu   sync.Mutex
+	dsns map[string]*dsnSlots
+}
+
+func NewConcurrencyLimiter(perDSN int, global int) *ConcurrencyLimiter {
+	/*
+		perDSN submissions per DSN and global in total, 0 for no global cap. perDSN below 1 is treated as 1.
+	*/
+	if perDSN < 1 {
+		perDSN = 1
+	}
+	cl := &ConcurrencyLimiter{perDSN: perDSN, dsns: map[string]*dsnSlots{}}
+	if global > 0 {
+		cl.global = make(chan struct{}, global)
+	}
+	return cl
+}
+
+func (cl *ConcurrencyLimiter) slots(fp string) *dsnSlots {
+	cl.mu.Lock()
+	defer cl.mu.Unlock()
+	s, ok := cl.dsns[fp]
+	if !ok {
+		s = &dsnSlots{ch: make(chan struct{}, cl.perDSN)}
+		cl.dsns[fp] = s
+	}
+	s.refs++
+	return s
+}
+
+func (cl *ConcurrencyLimiter) unref(fp string, s *dsnSlots) {
+	cl.mu.Lock()
+	defer cl.mu.Unlock()
+	if s.refs--; s.refs == 0 {
+		delete(cl.dsns, fp)
+	}
+}
+
+func (cl *ConcurrencyLimiter) Acquire(ctx context.Context, d *DSN) (release func(), err error) {
+	/*
+		Waits for a slot of d and a global one. release must be called exactly once when the submission is done.
+		Fails with ctx.Err() when ctx is done first.
+	*/
+	return cl.acquire(ctx, true, d)
+}
+
+func (cl *ConcurrencyLimiter) TryAcquire(d *DSN) (release func(), err error) {
+	/*
+		Like Acquire but fails with ErrConcurrencyLimit instead of waiting.
+	*/
+	return cl.acquire(context.Background(), false, d)
+}
+
+func (cl *ConcurrencyLimiter) acquire(ctx context.Context, wait bool, d *DSN) (func(), error) {
+	fp := d.Fingerprint()
+	s := cl.slots(fp)
+	if err := take(ctx, wait, s.ch); err != nil {
+		cl.unref(fp, s)
+		return nil, err
+	}
+	if cl.global != nil {
+		if err := take(ctx, wait, cl.global); err != nil {
+			<-s.ch
+			cl.unref(fp, s)
+			return nil, err
+		}
+	}
+	var once sync.Once
+	return func() {
+		once.Do(func() {
+			if cl.global != nil {
+				<-cl.global
+			}
+			<-s.ch
+			cl.unref(fp, s)
+		})
+	}, nil
+}
+
+func take(ctx context.Context, wait bool, sem chan struct{}) error {
+	if !wait {
+		select {
+		case sem <- struct{}{}:
+			return nil
+		default:
+			return ErrConcurrencyLimit
+		}
+	}
+	select {
+	case sem <- struct{}{}:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+func (cl *ConcurrencyLimiter) InFlight(d *DSN) int {
+	cl.mu.Lock()
+	defer cl.mu.Unlock()
+	if s, ok := cl.dsns[d.Fingerprint()]; ok {
+		return len(s.ch)
+	}
+	return 0
+}
+
+func (cl *ConcurrencyLimiter) Total() int {
+	/*
+		Submissions in flight over all DSNs.
+	*/
+	cl.mu.Lock()
+	defer cl.mu.Unlock()
+	n := 0
+	for _, s := range cl.dsns {
+		n += len(s.ch)
+	}
+	return n
+}
+
+// limitedSink is the Sink returned by ConcurrencyLimiter.Sink.
+type limitedSink struct {
+	limiter *ConcurrencyLimiter
+	next    Sink
+}
+
+func (cl *ConcurrencyLimiter) Sink(next Sink) Sink {
+	/*
+		Sends through next within the limits. Sends over a DSN's limit fail with ErrConcurrencyLimit right away
+		instead of tying up the worker; wrap the result in a Spool to have them retried later.
+	*/
+	return &limitedSink{limiter: cl, next: next}
+}
+
+func (ls *limitedSink) Send(ctx context.Context, s *Submission) error {
+	release, err := ls.limiter.TryAcquire(s.DSN)
+	if err != nil {
+		return err
+	}
+	defer release()
+	return ls.next.Send(ctx, s)
+}
+
+func (ls *limitedSink) Accepts(endpoint Endpoint) bool {
+	return sinkAccepts(ls.next, endpoint)
+}
+
+func (ls *limitedSink) Shutdown(ctx context.Context) error {
+	if sd, ok := ls.next.(Shutdowner); ok {
+		return sd.Shutdown(ctx)
+	}
+	return nil
+}
+
+// ConcurrencyRetryAfter is the Retry-After, in seconds, sent with ErrConcurrencyLimit responses.
+var ConcurrencyRetryAfter = 1
+
+func (cl *ConcurrencyLimiter) Middleware(next http.Handler) http.Handler {
+	/*
+		Holds a slot of the request's DSN while next runs, answering 429 when there is none.
+		Mount it behind NewMiddleware; requests without a DSN in their context pass unlimited.
+	*/
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		d, ok := FromContext(r.Context())
+		if !ok {
+			next.ServeHTTP(w, r)
+			return
+		}
+		release, err := cl.TryAcquire(d)
+		if err != nil {
+			if res, ok := ResultFromContext(r.Context()); ok {
+				res.reject(err)
+			}
+			w.Header().Set("Retry-After", strconv.Itoa(ConcurrencyRetryAfter))
+			WriteError(w, err)
+			return
+		}
+		defer release()
+		next.ServeHTTP(w, r)
+	})
+}
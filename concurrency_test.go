@@ -0,0 +1,118 @@
+package dsn
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+type testConcurrency struct {
+	perDSN      int
+	global      int
+	held        []string //project of each slot taken before the attempt
+	attempt     string
+	description string
+	ok          bool
+}
+
+var testTableConcurrency = []testConcurrency{
+	{2, 0, []string{"1"}, "1", "Testing below DSN limit", true},
+	{2, 0, []string{"1", "1"}, "1", "Testing DSN limit reached", false},
+	{2, 0, []string{"1", "1"}, "2", "Testing other DSN unaffected", true},
+	{2, 3, []string{"1", "1", "2"}, "3", "Testing global cap", false},
+	{0, 0, []string{"1"}, "1", "Testing per DSN floor of one", false},
+}
+
+func TestConcurrencyLimiter(t *testing.T) {
+	for _, test := range testTableConcurrency {
+		cl := NewConcurrencyLimiter(test.perDSN, test.global)
+		var releases []func()
+		for _, p := range test.held {
+			d, _ := Parse("https://" + testKeyA + "@sentry.io/" + p)
+			release, err := cl.TryAcquire(d)
+			if err != nil {
+				t.Fatalf("%s: %v", test.description, err)
+			}
+			releases = append(releases, release)
+		}
+		d, _ := Parse("https://" + testKeyA + "@sentry.io/" + test.attempt)
+		release, err := cl.TryAcquire(d)
+		if (err == nil) != test.ok {
+			t.Errorf("%s: Expected -- acquired %v -- Got %v", test.description, test.ok, err)
+		}
+		if err == nil {
+			releases = append(releases, release)
+		} else if err != ErrConcurrencyLimit {
+			t.Errorf("%s: Expected -- %v -- Got %v", test.description, ErrConcurrencyLimit, err)
+		}
+		for _, release := range releases {
+			release()
+			release() // second call is a no-op
+		}
+		if n := cl.Total(); n != 0 || len(cl.dsns) != 0 {
+			t.Errorf("%s: Expected -- all released and forgotten -- Got %d in flight, %d DSNs", test.description, n, len(cl.dsns))
+		}
+	}
+}
+
+func TestConcurrencyAcquireWaits(t *testing.T) {
+	cl := NewConcurrencyLimiter(1, 0)
+	d, _ := Parse("https://" + testKeyA + "@sentry.io/1")
+	release, _ := cl.Acquire(context.Background(), d)
+	ctx, cancel := context.WithTimeout(context.Background(), 20*time.Millisecond)
+	defer cancel()
+	if _, err := cl.Acquire(ctx, d); err != context.DeadlineExceeded {
+		t.Errorf("Testing full DSN: Expected -- %v -- Got %v", context.DeadlineExceeded, err)
+	}
+	go func() {
+		time.Sleep(10 * time.Millisecond)
+		release()
+	}()
+	second, err := cl.Acquire(context.Background(), d)
+	if err != nil || cl.InFlight(d) != 1 {
+		t.Errorf("Testing slot freed: Expected -- acquired -- Got %v, %d in flight", err, cl.InFlight(d))
+	}
+	second()
+}
+
+func TestConcurrencySink(t *testing.T) {
+	cl := NewConcurrencyLimiter(1, 0)
+	d, _ := Parse("https://" + testKeyA + "@sentry.io/1")
+	block := make(chan struct{})
+	started := make(chan struct{})
+	sink := cl.Sink(SinkFunc(func(ctx context.Context, s *Submission) error {
+		close(started)
+		<-block
+		return nil
+	}))
+	done := make(chan error)
+	go func() { done <- sink.Send(context.Background(), &Submission{DSN: d}) }()
+	<-started
+	if err := sink.Send(context.Background(), &Submission{DSN: d}); err != ErrConcurrencyLimit {
+		t.Errorf("Testing busy DSN: Expected -- %v -- Got %v", ErrConcurrencyLimit, err)
+	}
+	close(block)
+	if err := <-done; err != nil {
+		t.Errorf("Testing first send: Expected -- nil -- Got %v", err)
+	}
+}
+
+func TestConcurrencyMiddleware(t *testing.T) {
+	cl := NewConcurrencyLimiter(1, 0)
+	d, _ := Parse("https://" + testKeyA + "@sentry.io/1")
+	release, _ := cl.TryAcquire(d)
+	handler := NewMiddleware()(cl.Middleware(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {})))
+	w := httptest.NewRecorder()
+	handler.ServeHTTP(w, httptest.NewRequest("POST", "https://sentry.io/api/1/store/?sentry_key="+testKeyA, nil))
+	if w.Code != http.StatusTooManyRequests || w.Header().Get("Retry-After") != "1" {
+		t.Errorf("Testing busy DSN: Expected -- 429 with Retry-After -- Got %d %q", w.Code, w.Header().Get("Retry-After"))
+	}
+	release()
+	w = httptest.NewRecorder()
+	handler.ServeHTTP(w, httptest.NewRequest("POST", "https://sentry.io/api/1/store/?sentry_key="+testKeyA, nil))
+	if w.Code != http.StatusOK {
+		t.Errorf("Testing free DSN: Expected -- 200 -- Got %d", w.Code)
+	}
+}
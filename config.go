@@ -0,0 +1,439 @@
+package dsn
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"path/filepath"
+	"time"
+)
+
+// Duration is a time.Duration written as "30s" or "5m" in configuration files. Plain numbers are seconds.
+type Duration time.Duration
+
+func (d Duration) MarshalJSON() ([]byte, error) {
+	return json.Marshal(time.Duration(d).String())
+}
+
+func (d *Duration) UnmarshalJSON(b []byte) error {
+	var s string
+	if err := json.Unmarshal(b, &s); err != nil {
+		var seconds float64
+		if err := json.Unmarshal(b, &seconds); err != nil {
+			return fmt.Errorf("dsn: duration must be a string like \"30s\" or a number of seconds, got %s", b)
+		}
+		*d = Duration(seconds * float64(time.Second))
+		return nil
+	}
+	parsed, err := time.ParseDuration(s)
+	if err != nil {
+		return err
+	}
+	*d = Duration(parsed)
+	return nil
+}
+
+// Config describes a whole relay: how requests are parsed, where keys and tenants come from, which requests
+// are filtered and where submissions are forwarded. Rate limits are per tenant, in the registry file.
+// DecodeConfig and LoadConfig read it from JSON (YAML through the dsnyaml module); Build wires it up.
+type Config struct {
+	Parsing   ParsingConfig   `json:"parsing"`
+	Keystore  string          `json:"keystore,omitempty"` //FileKeystore JSON file, see OpenFileKeystore
+	Registry  string          `json:"registry,omitempty"` //tenant JSON file, see LoadRegistry
+	Upstreams UpstreamConfig  `json:"upstreams"`
+	Limits    LimitsConfig    `json:"limits"`
+	Filters   FilterConfig    `json:"filters"`
+	Forwarder ForwarderConfig `json:"forwarder"`
+}
+
+// ParsingConfig selects the parse options. Zero values keep the package defaults.
+type ParsingConfig struct {
+	Sources         []string        `json:"sources,omitempty"` //"header", "query", "path", "body", "basic_auth", "multipart"
+	MergeSources    bool            `json:"merge_sources,omitempty"`
+	NormalizeKeys   bool            `json:"normalize_keys,omitempty"` //accept upper case and dashed UUID keys
+	AuthHeader      string          `json:"auth_header,omitempty"`    //default X-Sentry-Auth
+	QuerySecret     string          `json:"query_secret,omitempty"`   //"allow", "warn" or "reject"
+	Conflicts       string          `json:"conflicts,omitempty"`      //"ignore", "prefer_header", "prefer_query" or "reject"
+	TLS             string          `json:"tls,omitempty"`            //"allow", "warn" or "reject" plaintext requests
+	Protocol        *ProtocolPolicy `json:"protocol,omitempty"`
+	MethodCheck     bool            `json:"method_check,omitempty"`
+	GetSubmissions  bool            `json:"get_submissions,omitempty"` //also allows GET through the method check
+	ResolverTimeout Duration        `json:"resolver_timeout,omitempty"`
+	MaxKeyAge       Duration        `json:"max_key_age,omitempty"`
+	SizeLimits      bool            `json:"size_limits,omitempty"` //DefaultSizeLimits
+	Classify        bool            `json:"classify,omitempty"`
+	ItemCounts      bool            `json:"item_counts,omitempty"`
+	Idempotency     bool            `json:"idempotency,omitempty"`
+	TrustedProxies  []string        `json:"trusted_proxies,omitempty"`
+	AllowedDSNs     []string        `json:"allowed_dsns,omitempty"` //Matcher patterns, an empty list rejects every request
+	Trace           bool            `json:"trace,omitempty"`        //log decision traces of rejected requests
+}
+
+// UpstreamConfig maps inbound DSNs onto upstream DSNs, see Router.
+type UpstreamConfig struct {
+	Default  string            `json:"default,omitempty"`
+	Keys     map[string]string `json:"keys,omitempty"`     //public key to upstream DSN
+	Projects map[string]string `json:"projects,omitempty"` //project ID to upstream DSN
+	Patterns []PatternUpstream `json:"patterns,omitempty"` //tried in order
+	// UnixSockets are the directories whose sockets http+unix upstreams, here and in the registry, may use.
+	// See WithUnixSockets
+	UnixSockets []string `json:"unix_sockets,omitempty"`
+}
+
+// PatternUpstream routes DSNs matching a Matcher pattern.
+type PatternUpstream struct {
+	Match    string `json:"match"`
+	Upstream string `json:"upstream"`
+}
+
+// LimitsConfig bounds submissions in flight, see ConcurrencyLimiter. It needs Forwarder.SpoolDir so that
+// sends over the limit are retried rather than lost.
+type LimitsConfig struct {
+	ConcurrencyPerDSN int `json:"concurrency_per_dsn,omitempty"` //0 disables the limiter
+	ConcurrencyGlobal int `json:"concurrency_global,omitempty"`
+}
+
+// FilterConfig holds the default inbound filter rules and per project replacements.
+type FilterConfig struct {
+	FilterRules
+	Projects map[string]FilterRules `json:"projects,omitempty"`
+}
+
+func (fc FilterConfig) empty() bool {
+	r := fc.FilterRules
+	return len(r.Releases) == 0 && len(r.UserAgents) == 0 && !r.WebCrawlers && len(r.ClientIPs) == 0 && len(fc.Projects) == 0
+}
+
+// ForwarderConfig sizes the Pipeline and the HTTPSink behind it.
+type ForwarderConfig struct {
+	QueueSize          int      `json:"queue_size,omitempty"`
+	Workers            int      `json:"workers,omitempty"`
+	Timeout            Duration `json:"timeout,omitempty"` //per upstream request
+	SpoolDir           string   `json:"spool_dir,omitempty"`
+	SpoolMaxBytes      int64    `json:"spool_max_bytes,omitempty"`
+	SpoolMaxAge        Duration `json:"spool_max_age,omitempty"`
+	BreakerThreshold   int      `json:"breaker_threshold,omitempty"` //0 disables the circuit breaker
+	BreakerCooldown    Duration `json:"breaker_cooldown,omitempty"`
+	RewriteDSN         bool     `json:"rewrite_dsn,omitempty"`         //DSNRewriteTransformer, for tunneled envelopes sent to other upstreams
+	NormalizeEnvelopes bool     `json:"normalize_envelopes,omitempty"` //NewEnvelopeNormalizer: dsn and sent_at like Relay
+}
+
+// DefaultConfig is what DecodeConfig starts from before applying the file.
+var DefaultConfig = Config{
+	Forwarder: ForwarderConfig{
+		QueueSize:       1000,
+		Workers:         8,
+		Timeout:         Duration(30 * time.Second),
+		BreakerCooldown: Duration(30 * time.Second),
+	},
+}
+
+func DecodeConfig(data []byte) (*Config, error) {
+	/*
+		Reads a JSON Config over DefaultConfig and validates it. Unknown fields are rejected so typos do not go unnoticed.
+	*/
+	cfg := DefaultConfig
+	dec := json.NewDecoder(bytes.NewReader(data))
+	dec.DisallowUnknownFields()
+	if err := dec.Decode(&cfg); err != nil {
+		return nil, fmt.Errorf("dsn: config: %w", err)
+	}
+	if err := cfg.Validate(); err != nil {
+		return nil, err
+	}
+	return &cfg, nil
+}
+
+func LoadConfig(path string) (*Config, error) {
+	/*
+		DecodeConfig on the contents of a JSON file.
+	*/
+	b, err := ioutil.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	return DecodeConfig(b)
+}
+
+func parseSource(name string) (Source, bool) {
+	var s Source
+	if err := s.UnmarshalText([]byte(name)); err != nil || s == SourceNone {
+		return SourceNone, false
+	}
+	return s, true
+}
+
+var querySecretPolicies = map[string]QuerySecretPolicy{"": QuerySecretAllow, "allow": QuerySecretAllow, "warn": QuerySecretWarn, "reject": QuerySecretReject}
+
+var tlsPolicies = map[string]TLSPolicy{"": TLSAllow, "allow": TLSAllow, "warn": TLSWarn, "reject": TLSReject}
+
+var conflictPolicies = map[string]ConflictPolicy{"": ConflictIgnore, "ignore": ConflictIgnore,
+	"prefer_header": ConflictPreferHeader, "prefer_query": ConflictPreferQuery, "reject": ConflictReject}
+
+func (cfg *Config) Validate() error {
+	/*
+		Checks every field Build would trip over and reports all problems at once as a *ValidationError.
+	*/
+	var errs []error
+	fail := func(field string, err error) {
+		errs = append(errs, fmt.Errorf("dsn: config %s: %w", field, err))
+	}
+	for _, s := range cfg.Parsing.Sources {
+		if _, ok := parseSource(s); !ok {
+			fail("parsing.sources", fmt.Errorf("unknown source %q", s))
+		}
+	}
+	if _, ok := querySecretPolicies[cfg.Parsing.QuerySecret]; !ok {
+		fail("parsing.query_secret", fmt.Errorf("unknown policy %q", cfg.Parsing.QuerySecret))
+	}
+	if _, ok := tlsPolicies[cfg.Parsing.TLS]; !ok {
+		fail("parsing.tls", fmt.Errorf("unknown policy %q", cfg.Parsing.TLS))
+	}
+	if _, ok := conflictPolicies[cfg.Parsing.Conflicts]; !ok {
+		fail("parsing.conflicts", fmt.Errorf("unknown policy %q", cfg.Parsing.Conflicts))
+	}
+	if _, err := ParseTrustedProxies(cfg.Parsing.TrustedProxies...); err != nil {
+		fail("parsing.trusted_proxies", err)
+	}
+	for _, p := range cfg.Parsing.AllowedDSNs {
+		if _, err := CompileMatcher(p); err != nil {
+			fail("parsing.allowed_dsns", fmt.Errorf("%q: %w", p, err))
+		}
+	}
+	for _, dir := range cfg.Upstreams.UnixSockets {
+		if !filepath.IsAbs(dir) {
+			fail("upstreams.unix_sockets", fmt.Errorf("%q is not absolute", dir))
+		}
+	}
+	if _, err := cfg.Upstreams.router(); err != nil {
+		fail("upstreams", err)
+	}
+	if cfg.Limits.ConcurrencyPerDSN < 0 || cfg.Limits.ConcurrencyGlobal < 0 {
+		fail("limits", errors.New("concurrency must not be negative"))
+	}
+	if cfg.Limits.ConcurrencyPerDSN > 0 && len(cfg.Forwarder.SpoolDir) == 0 {
+		fail("limits", errors.New("concurrency limits need forwarder.spool_dir"))
+	}
+	if _, err := cfg.Filters.chain(); err != nil {
+		fail("filters", err)
+	}
+	if cfg.Forwarder.QueueSize < 1 || cfg.Forwarder.Workers < 1 {
+		fail("forwarder", errors.New("queue_size and workers must be positive"))
+	}
+	if cfg.Forwarder.BreakerThreshold < 0 {
+		fail("forwarder.breaker_threshold", errors.New("must not be negative"))
+	}
+	if len(errs) > 0 {
+		return &ValidationError{Errors: errs}
+	}
+	return nil
+}
+
+func (uc UpstreamConfig) router() (*Router, error) {
+	rt := NewRouter()
+	parse := func(what, s string) (*DSN, error) {
+		d, err := ParseUpstream(s, WithUnixSockets(uc.UnixSockets...))
+		if err != nil {
+			return nil, fmt.Errorf("%s: %w", what, err)
+		}
+		return d, nil
+	}
+	if len(uc.Default) > 0 {
+		d, err := parse("default", uc.Default)
+		if err != nil {
+			return nil, err
+		}
+		rt.SetDefault(d)
+	}
+	for k, s := range uc.Keys {
+		d, err := parse("key "+k, s)
+		if err != nil {
+			return nil, err
+		}
+		rt.AddKey(k, d)
+	}
+	for p, s := range uc.Projects {
+		d, err := parse("project "+p, s)
+		if err != nil {
+			return nil, err
+		}
+		rt.AddProject(p, d)
+	}
+	for _, pu := range uc.Patterns {
+		m, err := CompileMatcher(pu.Match)
+		if err != nil {
+			return nil, fmt.Errorf("pattern %q: %w", pu.Match, err)
+		}
+		d, err := parse("pattern "+pu.Match, pu.Upstream)
+		if err != nil {
+			return nil, err
+		}
+		rt.AddPattern(m, d)
+	}
+	return rt, nil
+}
+
+func (fc FilterConfig) chain() (*FilterChain, error) {
+	chain, err := NewFilterChain(fc.FilterRules)
+	if err != nil {
+		return nil, err
+	}
+	for p, rules := range fc.Projects {
+		if err := chain.SetProject(p, rules); err != nil {
+			return nil, fmt.Errorf("project %s: %w", p, err)
+		}
+	}
+	return chain, nil
+}
+
+// Relay is a Config wired up. Components the Config does not ask for are nil.
+type Relay struct {
+	Parser   *Parser
+	Keystore *FileKeystore
+	Registry *Registry
+	Router   *Router
+	Filters  *FilterChain
+	Limiter  *ConcurrencyLimiter
+	Spool    *Spool
+	Breaker  *CircuitBreaker
+	Pipeline *Pipeline
+}
+
+func (cfg *Config) Build(opts ...Option) (*Relay, error) {
+	/*
+		Opens the keystore and registry files and assembles parser, filters, limiter and forwarder.
+		opts go to every component and come after the ones derived from cfg, so WithLogger, WithClock or
+		extra parse options can be added. Without upstreams submissions go to the DSN they were sent with.
+	*/
+	if err := cfg.Validate(); err != nil {
+		return nil, err
+	}
+	rl := &Relay{}
+	var parse []Option
+	p := cfg.Parsing
+	if len(p.Sources) > 0 {
+		sources := make([]Source, len(p.Sources))
+		for i, s := range p.Sources {
+			sources[i], _ = parseSource(s)
+		}
+		parse = append(parse, WithCredentialSources(sources...))
+	}
+	if p.MergeSources {
+		parse = append(parse, WithCredentialMerge())
+	}
+	if p.NormalizeKeys {
+		parse = append(parse, WithKeyNormalization())
+	}
+	if len(p.AuthHeader) > 0 {
+		parse = append(parse, WithAuthHeader(p.AuthHeader))
+	}
+	if policy := querySecretPolicies[p.QuerySecret]; policy != QuerySecretAllow {
+		parse = append(parse, WithQuerySecretPolicy(policy))
+	}
+	if policy := tlsPolicies[p.TLS]; policy != TLSAllow {
+		parse = append(parse, WithTLSPolicy(policy))
+	}
+	if policy := conflictPolicies[p.Conflicts]; policy != ConflictIgnore {
+		parse = append(parse, WithConflictPolicy(policy))
+	}
+	if p.Protocol != nil {
+		parse = append(parse, WithProtocolPolicy(*p.Protocol))
+	}
+	if p.MethodCheck {
+		parse = append(parse, WithMethodCheck(p.GetSubmissions))
+	}
+	if p.GetSubmissions {
+		parse = append(parse, WithGetSubmissions())
+	}
+	if p.ResolverTimeout > 0 {
+		parse = append(parse, WithResolverTimeout(time.Duration(p.ResolverTimeout)))
+	}
+	if p.MaxKeyAge > 0 {
+		parse = append(parse, WithMaxKeyAge(time.Duration(p.MaxKeyAge)))
+	}
+	if p.SizeLimits {
+		parse = append(parse, WithSizeLimits(DefaultSizeLimits))
+	}
+	if p.Classify {
+		parse = append(parse, WithClassification())
+	}
+	if p.ItemCounts {
+		parse = append(parse, WithItemCounts())
+	}
+	if p.Idempotency {
+		parse = append(parse, WithIdempotencyKey())
+	}
+	if p.TrustedProxies != nil {
+		parse = append(parse, WithTrustedProxies(MustParseTrustedProxies(p.TrustedProxies...)))
+	}
+	if p.Trace {
+		parse = append(parse, WithTrace())
+	}
+	if p.AllowedDSNs != nil {
+		matchers := make([]*Matcher, len(p.AllowedDSNs))
+		for i, pattern := range p.AllowedDSNs {
+			matchers[i] = MustCompileMatcher(pattern)
+		}
+		parse = append(parse, WithAllowedDSNs(matchers...))
+	}
+	var err error
+	if len(cfg.Keystore) > 0 {
+		if rl.Keystore, err = OpenFileKeystore(cfg.Keystore, opts...); err != nil {
+			return nil, err
+		}
+		parse = append(parse, WithKeyResolver(rl.Keystore))
+	}
+	if len(cfg.Registry) > 0 {
+		sockets := append([]Option{WithUnixSockets(cfg.Upstreams.UnixSockets...)}, opts...)
+		if rl.Registry, err = LoadRegistry(cfg.Registry, sockets...); err != nil {
+			return nil, err
+		}
+		parse = append(parse, WithRegistry(rl.Registry))
+	}
+	if !cfg.Filters.empty() {
+		rl.Filters, _ = cfg.Filters.chain()
+		parse = append(parse, WithFilters(rl.Filters))
+	}
+	rl.Parser = NewParser(append(parse, opts...)...)
+
+	sink := &HTTPSink{Client: &http.Client{Timeout: time.Duration(cfg.Forwarder.Timeout)}}
+	if up := cfg.Upstreams; len(up.Default) > 0 || len(up.Keys) > 0 || len(up.Projects) > 0 || len(up.Patterns) > 0 {
+		rl.Router, _ = up.router()
+		sink.Router = rl.Router
+	}
+	f := cfg.Forwarder
+	if f.RewriteDSN {
+		sink.Transformers = append(sink.Transformers, DSNRewriteTransformer)
+	}
+	if f.NormalizeEnvelopes {
+		sink.Transformers = append(sink.Transformers, NewEnvelopeNormalizer(opts...))
+	}
+	if f.BreakerThreshold > 0 {
+		rl.Breaker = NewCircuitBreaker(f.BreakerThreshold, time.Duration(f.BreakerCooldown), opts...)
+		sink.Breaker = rl.Breaker
+	}
+	var out Sink = sink
+	if cfg.Limits.ConcurrencyPerDSN > 0 {
+		rl.Limiter = NewConcurrencyLimiter(cfg.Limits.ConcurrencyPerDSN, cfg.Limits.ConcurrencyGlobal)
+		out = rl.Limiter.Sink(out)
+	}
+	if len(f.SpoolDir) > 0 {
+		if rl.Spool, err = NewSpool(f.SpoolDir, out, opts...); err != nil {
+			return nil, err
+		}
+		rl.Spool.MaxBytes, rl.Spool.MaxAge = f.SpoolMaxBytes, time.Duration(f.SpoolMaxAge)
+		out = rl.Spool
+	}
+	rl.Pipeline = NewPipeline(out, f.QueueSize, f.Workers, opts...)
+	return rl, nil
+}
+
+func (rl *Relay) Handler() http.Handler {
+	/*
+		The ingest handler: parses, filters and queues submissions. Mount it on /api/.
+	*/
+	return rl.Parser.Middleware(rl.Pipeline.Handler())
+}
@@ -0,0 +1,127 @@
+package dsn
+
+import (
+	"context"
+	"errors"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+type testDecodeConfig struct {
+	config      string
+	description string
+	errors      int //number of validation errors, -1 for a decode error
+}
+
+var testTableDecodeConfig = []testDecodeConfig{
+	{`{}`, "Testing empty config", 0},
+	{`{"parsing": {"sources": ["query", "header"], "query_secret": "reject", "protocol": {"min_version": 7},
+		"resolver_timeout": "500ms", "conflicts": "reject", "max_key_age": 3600, "trusted_proxies": ["10.0.0.0/8"]},
+		"upstreams": {"default": "https://` + testKeyA + `@o1.ingest.sentry.io/1", "patterns": [{"match": "*@*/2", "upstream": "https://` + testKeyB + `@sentry.io/2"}]},
+		"filters": {"releases": ["*-dev"], "projects": {"3": {"web_crawlers": true}}},
+		"forwarder": {"workers": 2, "breaker_threshold": 5}}`, "Testing full config", 0},
+	{`{"parsing": {"sourcez": ["query"]}}`, "Testing unknown field", -1},
+	{`{"parsing": {"resolver_timeout": "soon"}}`, "Testing bad duration", -1},
+	{`{"parsing": {"sources": ["cookie"], "query_secret": "maybe", "conflicts": "coin_toss"}}`, "Testing all errors reported", 3},
+	{`{"upstreams": {"keys": {"abc": "not a dsn"}}, "limits": {"concurrency_per_dsn": 2}}`, "Testing bad upstream and limiter without spool", 2},
+	{`{"filters": {"client_ips": ["10.0.0.0/99"]}, "forwarder": {"workers": 0}}`, "Testing bad filter and forwarder", 2},
+}
+
+func TestDecodeConfig(t *testing.T) {
+	for _, test := range testTableDecodeConfig {
+		cfg, err := DecodeConfig([]byte(test.config))
+		var verr *ValidationError
+		switch {
+		case test.errors == 0 && err != nil:
+			t.Errorf("%s: Expected -- valid -- Got %v", test.description, err)
+		case test.errors > 0 && (!errors.As(err, &verr) || len(verr.Errors) != test.errors):
+			t.Errorf("%s: Expected -- %d validation errors -- Got %v", test.description, test.errors, err)
+		case test.errors < 0 && (err == nil || errors.As(err, &verr)):
+			t.Errorf("%s: Expected -- decode error -- Got %v", test.description, err)
+		case test.errors == 0 && (cfg.Forwarder.QueueSize != 1000 || cfg.Forwarder.Timeout != Duration(30*time.Second)):
+			t.Errorf("%s: Expected -- defaults kept -- Got %+v", test.description, cfg.Forwarder)
+		}
+	}
+}
+
+func TestDuration(t *testing.T) {
+	cfg, err := DecodeConfig([]byte(`{"parsing": {"resolver_timeout": "1m30s", "max_key_age": 1.5}}`))
+	if err != nil || cfg.Parsing.ResolverTimeout != Duration(90*time.Second) || cfg.Parsing.MaxKeyAge != Duration(1500*time.Millisecond) {
+		t.Errorf("Expected -- 1m30s and 1.5s -- Got %+v %v", cfg, err)
+	}
+	if b, _ := Duration(90 * time.Second).MarshalJSON(); string(b) != `"1m30s"` {
+		t.Errorf("Expected -- \"1m30s\" -- Got %s", b)
+	}
+}
+
+func TestConfigBuild(t *testing.T) {
+	forwarded := 0
+	upstream := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/api/9/store/" || !strings.Contains(r.Header.Get("X-Sentry-Auth"), testKeyB) {
+			t.Errorf("Expected -- routed to project 9 -- Got %s %s", r.URL.Path, r.Header.Get("X-Sentry-Auth"))
+		}
+		forwarded++
+	}))
+	defer upstream.Close()
+	dir := t.TempDir()
+	keys := filepath.Join(dir, "keys.json")
+	ioutil.WriteFile(keys, []byte(`{"keys": [{"public_key": "`+testKeyA+`", "project_id": "1"}]}`), 0o600)
+	cfg, err := DecodeConfig([]byte(`{"keystore": "` + keys + `",
+		"upstreams": {"default": "` + strings.Replace(upstream.URL, "://", "://"+testKeyB+"@", 1) + `/9"},
+		"filters": {"releases": ["app@1.*"]},
+		"limits": {"concurrency_per_dsn": 1}, "forwarder": {"spool_dir": "` + filepath.Join(dir, "spool") + `"}}`))
+	if err != nil {
+		t.Fatal(err)
+	}
+	rl, err := cfg.Build()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if rl.Keystore == nil || rl.Router == nil || rl.Filters == nil || rl.Limiter == nil || rl.Spool == nil || rl.Registry != nil || rl.Breaker != nil {
+		t.Errorf("Expected -- keystore, router, filters, limiter and spool -- Got %+v", rl)
+	}
+	handler := rl.Handler()
+	requests := []struct {
+		key, body string
+		status    int
+	}{
+		{testKeyA, testEvent, http.StatusOK},
+		{testKeyA, testMetaEvent, http.StatusOK}, //filtered by release
+		{testKeyB, testEvent, http.StatusUnauthorized},
+	}
+	for _, req := range requests {
+		w := httptest.NewRecorder()
+		handler.ServeHTTP(w, httptest.NewRequest("POST", "https://relay.example.com/api/1/store/?sentry_key="+req.key, strings.NewReader(req.body)))
+		if w.Code != req.status {
+			t.Errorf("Testing %s: Expected -- %d -- Got %d %s", req.key, req.status, w.Code, w.Body)
+		}
+	}
+	if _, err := rl.Pipeline.Shutdown(context.Background()); err != nil || forwarded != 1 {
+		t.Errorf("Expected -- 1 forwarded -- Got %d %v", forwarded, err)
+	}
+	if _, err := (&Config{Keystore: filepath.Join(dir, "missing.json"), Forwarder: DefaultConfig.Forwarder}).Build(); err == nil {
+		t.Errorf("Testing missing keystore: Expected -- error -- Got nil")
+	}
+}
+
+func TestConfigEmptyAllowedDSNs(t *testing.T) {
+	cfg, err := DecodeConfig([]byte(`{"parsing": {"allowed_dsns": []}, "upstreams": {"default": "https://` + testKeyB + `@sentry.io/9"}}`))
+	if err != nil {
+		t.Fatal(err)
+	}
+	rl, err := cfg.Build()
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer rl.Pipeline.Shutdown(context.Background())
+	w := httptest.NewRecorder()
+	rl.Handler().ServeHTTP(w, httptest.NewRequest("POST", "https://relay.example.com/api/1/store/?sentry_key="+testKeyA, strings.NewReader(testEvent)))
+	if w.Code != http.StatusForbidden {
+		t.Errorf("Expected -- %d -- Got %d %s", http.StatusForbidden, w.Code, w.Body)
+	}
+}
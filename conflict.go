@@ -0,0 +1,144 @@
+package dsn
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"net/url"
+
+	"github.com/dgbailey/dsn/internal/keys"
+)
+
+// ErrConflictingCredentials Thrown by ConflictReject when a request carries two different keys
+var ErrConflictingCredentials = errors.New("sentry:  conflicting credentials")
+
+// ConflictError describes the disagreeing values, redacted. errors.Is(err, ErrConflictingCredentials) matches it.
+type ConflictError struct {
+	Field   string    //"sentry_key" or "sentry_secret"
+	Sources [2]Source //the same source twice for repeated query parameters
+	Values  [2]string //first characters only
+}
+
+func (e *ConflictError) Error() string {
+	return fmt.Sprintf("%s: %s %s from %s, %s from %s", ErrConflictingCredentials, e.Field,
+		e.Values[0], e.Sources[0], e.Values[1], e.Sources[1])
+}
+
+func (e *ConflictError) Is(target error) bool {
+	return target == ErrConflictingCredentials
+}
+
+// ConflictPolicy decides what happens when sentry_key or sentry_secret is repeated in the query string with
+// different values, or when the header and the query string disagree.
+type ConflictPolicy int
+
+const (
+	ConflictIgnore       ConflictPolicy = iota //default, the first source with a key wins silently
+	ConflictPreferHeader                       //the header's credentials win, with a warning through the Logger
+	ConflictPreferQuery                        //the query string's credentials win, with a warning through the Logger
+	ConflictReject                             //rejected with a *ConflictError
+)
+
+func WithConflictPolicy(p ConflictPolicy) Option {
+	/*
+		Applies p to requests whose credentials disagree. Header and query string are only compared when
+		both are among the credential sources. Repeated query parameters are resolved to the first value
+		unless p is ConflictReject.
+	*/
+	return func(c *config) {
+		c.conflicts = p
+	}
+}
+
+func redactKey(key string) string {
+	/*
+		First four characters of key, enough to tell keys apart in logs.
+	*/
+	if len(key) <= 4 {
+		return "***"
+	}
+	return key[:4] + "***"
+}
+
+func (c *config) checkConflicts(r *http.Request, res *ParseResult) error {
+	/*
+		Looks for conflicts and, for the prefer policies, replaces res.Auth with the winning source.
+		Only runs when a policy is set, so it may allocate.
+	*/
+	repeated := c.repeatedQueryKey(r)
+	var mismatch *ConflictError
+	var header, query Auth
+	if c.hasSource(SourceHeader) && c.hasSource(SourceQuery) {
+		var hok, qok bool
+		header, hok = c.fromSource(r, SourceHeader)
+		query, qok = c.fromSource(r, SourceQuery)
+		if c.normalizeKeys {
+			query.PublicKey, query.SecretKey = keys.Canonical(query.PublicKey), keys.Canonical(query.SecretKey)
+		}
+		switch {
+		case !hok || !qok:
+		case header.PublicKey != query.PublicKey:
+			mismatch = newConflict("sentry_key", SourceHeader, SourceQuery, header.PublicKey, query.PublicKey)
+		case len(header.SecretKey) > 0 && len(query.SecretKey) > 0 && header.SecretKey != query.SecretKey:
+			mismatch = newConflict("sentry_secret", SourceHeader, SourceQuery, header.SecretKey, query.SecretKey)
+		}
+	}
+	for _, conflict := range []*ConflictError{repeated, mismatch} {
+		if conflict == nil {
+			continue
+		}
+		if c.conflicts == ConflictReject {
+			return conflict
+		}
+		c.logf("dsn: %v in request from %s (client %q)", conflict, r.RemoteAddr, r.UserAgent())
+	}
+	if mismatch == nil {
+		return nil
+	}
+	winner, source := header, SourceHeader
+	if c.conflicts == ConflictPreferQuery {
+		winner, source = query, SourceQuery
+	}
+	res.Auth, res.KeySource, res.SecretSource = winner, source, SourceNone
+	if len(winner.SecretKey) > 0 {
+		res.SecretSource = source
+	}
+	return nil
+}
+
+func (c *config) repeatedQueryKey(r *http.Request) *ConflictError {
+	if !c.hasSource(SourceQuery) || len(r.URL.RawQuery) == 0 {
+		return nil
+	}
+	values, _ := url.ParseQuery(r.URL.RawQuery) //malformed pairs are skipped, as by parseAuthQuery
+	for _, field := range []string{"sentry_key", "sentry_secret"} {
+		v := values[field]
+		for i := 1; i < len(v); i++ {
+			first, other := keys.Unquote(v[0]), keys.Unquote(v[i])
+			if c.normalizeKeys {
+				first, other = keys.Canonical(first), keys.Canonical(other)
+			}
+			if first != other {
+				return newConflict(field, SourceQuery, SourceQuery, first, other)
+			}
+		}
+	}
+	return nil
+}
+
+func (c *config) hasSource(s Source) bool {
+	sources := c.sources
+	if sources == nil {
+		sources = defaultSources
+	}
+	for _, source := range sources {
+		if source == s {
+			return true
+		}
+	}
+	return false
+}
+
+func newConflict(field string, a, b Source, x, y string) *ConflictError {
+	return &ConflictError{Field: field, Sources: [2]Source{a, b}, Values: [2]string{redactKey(x), redactKey(y)}}
+}
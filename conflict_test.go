@@ -0,0 +1,76 @@
+package dsn
+
+import (
+	"bytes"
+	"errors"
+	"log"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+type testConflict struct {
+	url         string
+	header      string
+	policy      ConflictPolicy
+	description string
+	expected    string //public key, empty on error
+	source      Source
+	err         error
+	warned      bool
+}
+
+var testTableConflict = []testConflict{
+	{"https://sentry.io/api/1/store/?sentry_key=" + testKeyB, "Sentry sentry_key=" + testKeyA, ConflictIgnore,
+		"Testing ignored by default", testKeyA, SourceHeader, nil, false},
+	{"https://sentry.io/api/1/store/?sentry_key=" + testKeyB, "Sentry sentry_key=" + testKeyA, ConflictPreferHeader,
+		"Testing prefer header", testKeyA, SourceHeader, nil, true},
+	{"https://sentry.io/api/1/store/?sentry_key=" + testKeyB, "Sentry sentry_key=" + testKeyA, ConflictPreferQuery,
+		"Testing prefer query", testKeyB, SourceQuery, nil, true},
+	{"https://sentry.io/api/1/store/?sentry_key=" + testKeyB, "Sentry sentry_key=" + testKeyA, ConflictReject,
+		"Testing rejected", "", SourceNone, ErrConflictingCredentials, false},
+	{"https://sentry.io/api/1/store/?sentry_key=" + testKeyA + "&sentry_key=" + testKeyB, "", ConflictReject,
+		"Testing repeated query key", "", SourceNone, ErrConflictingCredentials, false},
+	{"https://sentry.io/api/1/store/?sentry_key=" + testKeyA + "&sentry_key=" + testKeyB, "", ConflictPreferHeader,
+		"Testing repeated query key takes first", testKeyA, SourceQuery, nil, true},
+	{"https://sentry.io/api/1/store/?sentry_key=" + testKeyA + "&sentry_secret=" + testKeyB,
+		"Sentry sentry_key=" + testKeyA + ", sentry_secret=" + testKeyA, ConflictReject,
+		"Testing secrets disagree", "", SourceNone, ErrConflictingCredentials, false},
+	{"https://sentry.io/api/1/store/?sentry_key=" + testKeyA + "&sentry_key=" + testKeyA, "Sentry sentry_key=" + testKeyA, ConflictReject,
+		"Testing agreeing values", testKeyA, SourceHeader, nil, false},
+}
+
+func TestConflictPolicy(t *testing.T) {
+	for _, test := range testTableConflict {
+		r := httptest.NewRequest("POST", test.url, nil)
+		if len(test.header) > 0 {
+			r.Header.Set("X-SENTRY-AUTH", test.header)
+		}
+		var buf bytes.Buffer
+		res, err := ParseRequest(r.Context(), r, WithConflictPolicy(test.policy), WithLogger(log.New(&buf, "", 0)))
+		if !errors.Is(err, test.err) {
+			t.Errorf("%s: Expected -- %v -- Got %v", test.description, test.err, err)
+			continue
+		}
+		if err == nil && (res.Auth.PublicKey != test.expected || res.KeySource != test.source) {
+			t.Errorf("%s: Expected -- %s from %s -- Got %s from %s", test.description, test.expected, test.source,
+				res.Auth.PublicKey, res.KeySource)
+		}
+		if warned := buf.Len() > 0; warned != test.warned {
+			t.Errorf("%s: Expected -- warning %v -- Got %q", test.description, test.warned, buf.String())
+		}
+	}
+}
+
+func TestConflictErrorRedacted(t *testing.T) {
+	r := httptest.NewRequest("POST", "https://sentry.io/api/1/store/?sentry_key="+testKeyB, nil)
+	r.Header.Set("X-SENTRY-AUTH", "Sentry sentry_key="+testKeyA)
+	_, err := ParseRequest(r.Context(), r, WithConflictPolicy(ConflictReject))
+	var conflict *ConflictError
+	if !errors.As(err, &conflict) || conflict.Values != [2]string{"4784***", "aaaa***"} || conflict.Sources != [2]Source{SourceHeader, SourceQuery} {
+		t.Fatalf("Expected -- redacted header and query keys -- Got %+v", err)
+	}
+	if strings.Contains(err.Error(), testKeyA) || ErrorCode(err) != "conflicting_credentials" {
+		t.Errorf("Expected -- no full key in %q -- Got code %s", err, ErrorCode(err))
+	}
+}
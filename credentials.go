@@ -0,0 +1,211 @@
+package dsn
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+
+	"github.com/dgbailey/dsn/internal/keys"
+)
+
+// Source names a place in the request that credentials can come from.
+type Source int
+
+const (
+	SourceNone      Source = iota
+	SourceHeader           //X-SENTRY-AUTH header
+	SourceQuery            //sentry_* query parameters
+	SourcePath             //key segment of /api/<project_id>/unreal/<sentry_key>/ or /api/<project_id>/cron/<monitor_slug>/<sentry_key>/
+	SourceBody             //dsn in an envelope header
+	SourceBasicAuth        //Authorization: Basic <public key>:<secret key>
+	SourceMultipart        //sentry_* form fields of a multipart upload, see MultipartFields
+)
+
+var sourceNames = [...]string{"none", "header", "query", "path", "body", "basic_auth", "multipart"}
+
+func (s Source) String() string {
+	if s < 0 || int(s) >= len(sourceNames) {
+		return "unknown"
+	}
+	return sourceNames[s]
+}
+
+func (s Source) MarshalText() ([]byte, error) {
+	return []byte(s.String()), nil
+}
+
+func (s *Source) UnmarshalText(b []byte) error {
+	for i, n := range sourceNames {
+		if n == string(b) {
+			*s = Source(i)
+			return nil
+		}
+	}
+	return fmt.Errorf("dsn: unknown credential source %q", b)
+}
+
+// what FromRequest has always done: header first, then query string
+var defaultSources = []Source{SourceHeader, SourceQuery}
+
+// DefaultBodyPeekLimit bounds how much body SourceBody reads looking for the envelope header.
+var DefaultBodyPeekLimit int64 = 64 << 10
+
+func WithCredentialSources(sources ...Source) Option {
+	/*
+		Sources are tried in the given order and the first one supplying a public key wins, e.g.
+		WithCredentialSources(SourceQuery, SourceHeader) to prefer the query string.
+		The default is SourceHeader, SourceQuery. SourceBody and SourceMultipart peek at most DefaultBodyPeekLimit bytes and restore the body.
+	*/
+	return func(c *config) {
+		c.sources = sources
+	}
+}
+
+func WithCredentialMerge() Option {
+	/*
+		Instead of taking every field from the first source with a public key, each field is taken from the first
+		source that has it, so the key may come from the query and sentry_version from the header.
+	*/
+	return func(c *config) {
+		c.mergeSources = true
+	}
+}
+
+func (c *config) credentials(r *http.Request, res *ParseResult) bool {
+	/*
+		Fills res.Auth along with the sources that supplied the keys.
+	*/
+	sources := c.sources
+	if sources == nil {
+		sources = defaultSources
+	}
+	for _, s := range sources {
+		found, ok := c.fromSource(r, s)
+		if c.normalizeKeys {
+			found.PublicKey, found.SecretKey = keys.Canonical(found.PublicKey), keys.Canonical(found.SecretKey)
+		}
+		if c.trace {
+			c.traceSource(r, res, s, found, ok)
+		}
+		if !c.mergeSources {
+			if ok {
+				res.Auth, res.KeySource = found, s
+				if len(found.SecretKey) > 0 {
+					res.SecretSource = s
+				}
+				return true
+			}
+			continue
+		}
+		if len(res.Auth.PublicKey) == 0 && len(found.PublicKey) > 0 {
+			res.KeySource = s
+		}
+		if len(res.Auth.SecretKey) == 0 && len(found.SecretKey) > 0 {
+			res.SecretSource = s
+		}
+		res.Auth.merge(&found)
+	}
+	if c.trace && len(res.Auth.PublicKey) > 0 {
+		c.tracef(res, "credentials", "merged: sentry_key from %s, sentry_secret from %s", res.KeySource, res.SecretSource)
+	}
+	return len(res.Auth.PublicKey) > 0
+}
+
+func (a *Auth) merge(other *Auth) {
+	/*
+		Fills empty fields of a from other.
+	*/
+	fill := func(dst *string, src string) {
+		if len(*dst) == 0 {
+			*dst = src
+		}
+	}
+	fill(&a.PublicKey, other.PublicKey)
+	fill(&a.SecretKey, other.SecretKey)
+	fill(&a.Version, other.Version)
+	fill(&a.Client, other.Client)
+	fill(&a.Timestamp, other.Timestamp)
+	fill(&a.Signature, other.Signature)
+	for k, v := range other.extraFields() {
+		if _, ok := a.extraFields()[k]; !ok {
+			a.setExtra(k, v)
+		}
+	}
+}
+
+func (c *config) fromSource(r *http.Request, s Source) (Auth, bool) {
+	switch s {
+	case SourceHeader:
+		if h := c.authHeaderValues(r.Header); len(h) > 0 {
+			return parseAuthHeader(h[0], c.normalizeKeys)
+		}
+	case SourceQuery:
+		return parseAuthQuery(r.URL.RawQuery)
+	case SourcePath:
+		return parseAuthPath(r.URL.Path)
+	case SourceBody:
+		return parseAuthBody(r)
+	case SourceMultipart:
+		return parseAuthMultipart(r)
+	case SourceBasicAuth:
+		pk, sk, ok := r.BasicAuth()
+		if c.normalizeKeys {
+			pk, sk = keys.Canonical(pk), keys.Canonical(sk)
+		}
+		if ok && keys.IsHex(pk) {
+			if !keys.IsHex(sk) {
+				sk = ""
+			}
+			return Auth{PublicKey: pk, SecretKey: sk}, true
+		}
+	}
+	return Auth{}, false
+}
+
+func parseAuthPath(path string) (Auth, bool) {
+	/*
+		The Unreal Engine crash reporter and cron check-ins cannot set headers so their key travels in the path.
+	*/
+	var info PathInfo
+	if err := parsePathInfo(path, &info); err != nil || len(info.PublicKey) == 0 {
+		return Auth{}, false
+	}
+	return Auth{PublicKey: info.PublicKey}, true
+}
+
+func parseAuthBody(r *http.Request) (Auth, bool) {
+	/*
+		Envelopes (e.g. from a tunnel) carry the DSN in their first line: {"dsn":"https://key@host/1",...}
+		Only that line is read, through the Content-Encoding, so streamed and compressed envelopes work
+		without a Content-Length.
+	*/
+	if r.Body == nil || r.Body == http.NoBody {
+		return Auth{}, false
+	}
+	var header struct {
+		DSN string `json:"dsn"`
+	}
+	err := NewLimitedPeeker(DefaultBodyPeekLimit).PeekFunc(r, func(body io.Reader) error {
+		decoded, err := decodeBody(r.Header.Get("Content-Encoding"), body)
+		if err != nil {
+			return err
+		}
+		// a body without newline is a header only envelope, or cut off at the limit
+		line, err := bufio.NewReader(io.LimitReader(decoded, maxDecodedRatio*DefaultBodyPeekLimit)).ReadBytes('\n')
+		if err != nil && len(line) == 0 {
+			return err
+		}
+		return json.Unmarshal(bytes.TrimSpace(line), &header)
+	})
+	if err != nil || len(header.DSN) == 0 {
+		return Auth{}, false
+	}
+	d, err := Parse(header.DSN)
+	if err != nil {
+		return Auth{}, false
+	}
+	return Auth{PublicKey: d.PublicKey, SecretKey: d.SecretKey}, true
+}
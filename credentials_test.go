@@ -0,0 +1,191 @@
+package dsn
+
+import (
+	"bytes"
+	"compress/gzip"
+	"encoding/json"
+	"io"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+const (
+	testKeyA = "4784fbc50de2473f9977cfce8a9adce5"
+	testKeyB = "aaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaa"
+)
+
+type testSource struct {
+	url         string
+	header      string
+	basic       bool
+	body        string
+	opts        []Option
+	description string
+	expected    Auth
+	err         error
+}
+
+var testTableSources = []testSource{
+	{"https://sentry.io/api/1/store/?sentry_key=" + testKeyB, "Sentry sentry_version=7, sentry_key=" + testKeyA, false, "", nil,
+		"Testing default prefers header", Auth{PublicKey: testKeyA, Version: "7"}, nil},
+	{"https://sentry.io/api/1/store/?sentry_key=" + testKeyB, "Sentry sentry_version=7, sentry_key=" + testKeyA, false, "",
+		[]Option{WithCredentialSources(SourceQuery, SourceHeader)},
+		"Testing query first", Auth{PublicKey: testKeyB}, nil},
+	{"https://sentry.io/api/1/store/?sentry_key=" + testKeyB, "Sentry sentry_version=7, sentry_client=raven/1", false, "",
+		[]Option{WithCredentialSources(SourceQuery, SourceHeader), WithCredentialMerge()},
+		"Testing merged sources", Auth{PublicKey: testKeyB, Version: "7", Client: "raven/1"}, nil},
+	{"https://sentry.io/api/1/unreal/" + testKeyA + "/", "", false, "",
+		[]Option{WithCredentialSources(SourcePath)},
+		"Testing unreal path key", Auth{PublicKey: testKeyA}, nil},
+	{"https://sentry.io/api/1/envelope/", "", false, `{"event_id":"9ec79c33ec9942ab8353589fcb2e04dc","dsn":"https://` + testKeyA + `@sentry.io/1"}` + "\n{}\n",
+		[]Option{WithCredentialSources(SourceHeader, SourceBody)},
+		"Testing envelope header DSN", Auth{PublicKey: testKeyA}, nil},
+	{"https://sentry.io/api/1/store/", "", true, "",
+		[]Option{WithCredentialSources(SourceBasicAuth)},
+		"Testing basic auth", Auth{PublicKey: testKeyA, SecretKey: testKeyB}, nil},
+	{"https://sentry.io/api/1/store/?sentry_key=%22" + testKeyA + "%22&sentry_secret='" + testKeyB + "'", "", false, "", nil,
+		"Testing quoted query keys", Auth{PublicKey: testKeyA, SecretKey: testKeyB}, nil},
+	{"https://sentry.io/api/1/store/", "Sentry sentry_key=4784FBC50DE2473F9977CFCE8A9ADCE5", false, "", nil,
+		"Testing strict upper case key", Auth{}, ErrMissingUser},
+	{"https://sentry.io/api/1/store/", "Sentry sentry_key=4784FBC50DE2473F9977CFCE8A9ADCE5", false, "",
+		[]Option{WithKeyNormalization()},
+		"Testing normalized upper case key", Auth{PublicKey: testKeyA}, nil},
+	{"https://sentry.io/api/1/store/?sentry_key=4784fbc5-0de2-473f-9977-cfce8a9adce5", "", false, "",
+		[]Option{WithKeyNormalization()},
+		"Testing normalized UUID query key", Auth{PublicKey: testKeyA}, nil},
+	{"https://sentry.io/api/1/store/?sentry_key=" + testKeyB, "", false, "",
+		[]Option{WithCredentialSources(SourceHeader)},
+		"Testing query disabled", Auth{}, ErrMissingUser},
+}
+
+func TestCredentialSources(t *testing.T) {
+	for _, test := range testTableSources {
+		r := httptest.NewRequest("POST", test.url, strings.NewReader(test.body))
+		if len(test.header) > 0 {
+			r.Header.Set("X-SENTRY-AUTH", test.header)
+		}
+		if test.basic {
+			r.SetBasicAuth(testKeyA, testKeyB)
+		}
+		var res ParseResult
+		ok := newConfig(test.opts).credentials(r, &res)
+		got := res.Auth
+		if test.err != nil {
+			if _, err := FromRequest(r, test.opts...); ok || err != test.err {
+				t.Errorf("%s: Expected -- %v -- Got %v", test.description, test.err, err)
+			}
+			continue
+		}
+		if !ok || got != test.expected {
+			t.Errorf("%s: Expected -- %+v -- Got %+v", test.description, test.expected, got)
+		}
+		if rest, _ := io.ReadAll(r.Body); string(rest) != test.body {
+			t.Errorf("%s: Expected -- body restored -- Got %s", test.description, rest)
+		}
+	}
+}
+
+func TestUnrealPath(t *testing.T) {
+	r := httptest.NewRequest("POST", "https://sentry.io/api/1/unreal/"+testKeyA+"/", nil)
+	got, err := FromRequest(r, WithCredentialSources(SourceHeader, SourceQuery, SourcePath))
+	if expected := "https://" + testKeyA + "@sentry.io/1"; err != nil || got.URL != expected {
+		t.Errorf("Expected -- %s -- Got %v %v", expected, got, err)
+	}
+}
+
+type testProvenance struct {
+	url         string
+	header      string
+	opts        []Option
+	description string
+	key         Source
+	secret      Source
+	endpoint    Endpoint
+}
+
+var testTableProvenance = []testProvenance{
+	{"https://sentry.io/api/1/store/", "Sentry sentry_key=" + testKeyA + ", sentry_secret=" + testKeyB, nil,
+		"Testing header key and secret", SourceHeader, SourceHeader, EndpointStore},
+	{"https://sentry.io/api/1/envelope/?sentry_key=" + testKeyA, "", nil,
+		"Testing query key without secret", SourceQuery, SourceNone, EndpointEnvelope},
+	{"https://sentry.io/api/1/store/?sentry_secret=" + testKeyB, "Sentry sentry_key=" + testKeyA, []Option{WithCredentialMerge()},
+		"Testing merged key and secret", SourceHeader, SourceQuery, EndpointStore},
+	{"https://sentry.io/api/1/unreal/" + testKeyA + "/", "", []Option{WithCredentialSources(SourcePath)},
+		"Testing unreal path key", SourcePath, SourceNone, EndpointUnreal},
+	{"https://sentry.io/api/my-project/envelope/?sentry_key=" + testKeyA, "", []Option{WithProjectSlugs()},
+		"Testing slug envelope", SourceQuery, SourceNone, EndpointEnvelope},
+}
+
+func TestParseRequestProvenance(t *testing.T) {
+	for _, test := range testTableProvenance {
+		r := httptest.NewRequest("POST", test.url, nil)
+		if len(test.header) > 0 {
+			r.Header.Set("X-SENTRY-AUTH", test.header)
+		}
+		res, err := ParseRequest(r.Context(), r, test.opts...)
+		if err != nil {
+			t.Errorf("%s: Expected -- no error -- Got %v", test.description, err)
+			continue
+		}
+		if res.KeySource != test.key || res.SecretSource != test.secret || res.Endpoint != test.endpoint {
+			t.Errorf("%s: Expected -- %v %v %v -- Got %v %v %v", test.description,
+				test.key, test.secret, test.endpoint, res.KeySource, res.SecretSource, res.Endpoint)
+		}
+		if res.DSN == nil || res.DSN.PublicKey != testKeyA {
+			t.Errorf("%s: Expected -- %s -- Got %+v", test.description, testKeyA, res.DSN)
+		}
+	}
+}
+
+func TestSourceBodyStreaming(t *testing.T) {
+	header := `{"dsn":"https://` + testKeyA + `@sentry.io/1"}` + "\n"
+	var gz bytes.Buffer
+	w := gzip.NewWriter(&gz)
+	w.Write([]byte(header + `{"type":"event"}` + "\n{}\n"))
+	w.Close()
+
+	// chunked, compressed and still streaming: only the header line is waited for
+	pr, pw := io.Pipe()
+	go func() {
+		w := gzip.NewWriter(pw)
+		w.Write([]byte(header))
+		w.Flush()
+	}()
+	for _, test := range []struct {
+		body        io.Reader
+		encoding    string
+		description string
+	}{
+		{bytes.NewReader(gz.Bytes()), "gzip", "Testing gzip envelope"},
+		{pr, "gzip", "Testing streamed gzip envelope"},
+		{strings.NewReader(header), "", "Testing chunked envelope"},
+	} {
+		r := httptest.NewRequest("POST", "https://sentry.io/api/1/envelope/", test.body)
+		r.ContentLength, r.TransferEncoding = -1, []string{"chunked"}
+		r.Header.Set("Content-Encoding", test.encoding)
+		res, err := ParseRequest(r.Context(), r, WithCredentialSources(SourceBody))
+		if err != nil || res.Auth.PublicKey != testKeyA || res.KeySource != SourceBody {
+			t.Errorf("%s: Expected -- key from body -- Got %+v %v", test.description, res, err)
+		}
+	}
+	pw.Close()
+}
+
+func TestAuthExtra(t *testing.T) {
+	got, err := ParseAuthHeaderValue("Sentry sentry_key=" + testKeyA + ", sentry_flavor=mint")
+	if err != nil || got.Extra()["sentry_flavor"] != "mint" {
+		t.Fatalf("Expected -- sentry_flavor kept -- Got %v %v", got, err)
+	}
+	got.Extra()["sentry_flavor"] = "changed"
+	if got.Extra()["sentry_flavor"] != "mint" {
+		t.Errorf("Expected -- Extra returns a copy -- Got %v", got.Extra())
+	}
+	// Auth stays comparable, a copy shares the extra fields
+	if c := *got; c != *got || *got == (Auth{PublicKey: testKeyA}) {
+		t.Errorf("Expected -- copies equal, no extras different -- Got %+v", got)
+	}
+	if b, _ := json.Marshal(got); !strings.Contains(string(b), `"extra":{"sentry_flavor":"mint"}`) {
+		t.Errorf("Expected -- extra in JSON -- Got %s", b)
+	}
+}
@@ -0,0 +1,68 @@
+package dsn
+
+import (
+	"fmt"
+)
+
+// FieldDiff is one component that differs between two DSNs. Field uses the DSN's JSON names.
+type FieldDiff struct {
+	Field string
+	A     string
+	B     string
+}
+
+func (fd FieldDiff) String() string {
+	/*
+		"host: sentry.io != example.com". Secret keys are masked so diffs can be logged.
+	*/
+	a, b := fd.A, fd.B
+	if fd.Field == "secret_key" {
+		a, b = maskSecret(a), maskSecret(b)
+	}
+	return fmt.Sprintf("%s: %q != %q", fd.Field, a, b)
+}
+
+func maskSecret(s string) string {
+	if len(s) == 0 {
+		return s
+	}
+	return "***"
+}
+
+func Diff(a, b *DSN) []FieldDiff {
+	/*
+		Lists the components of a and b that differ, in DSN string order. nil diffs like an empty DSN.
+		URL is derived from the components so it is not compared; Options are compared in encoded form.
+		An empty scheme counts as https, as everywhere else.
+	*/
+	if a == nil {
+		a = &DSN{}
+	}
+	if b == nil {
+		b = &DSN{}
+	}
+	var diffs []FieldDiff
+	add := func(field, x, y string) {
+		if x != y {
+			diffs = append(diffs, FieldDiff{field, x, y})
+		}
+	}
+	add("scheme", schemeOrDefault(a.Scheme), schemeOrDefault(b.Scheme))
+	add("public_key", a.PublicKey, b.PublicKey)
+	add("secret_key", a.SecretKey, b.SecretKey)
+	add("host", a.Host, b.Host)
+	add("port", a.Port, b.Port)
+	add("path", a.Path, b.Path)
+	add("socket", a.Socket, b.Socket)
+	add("project_id", a.ProjectID, b.ProjectID)
+	add("project_slug", a.ProjectSlug, b.ProjectSlug)
+	add("options", a.Options.Encode(), b.Options.Encode())
+	return diffs
+}
+
+func schemeOrDefault(s string) string {
+	if len(s) == 0 {
+		return "https"
+	}
+	return s
+}
@@ -0,0 +1,48 @@
+package dsn
+
+import (
+	"testing"
+)
+
+type testDiff struct {
+	a           string
+	b           string
+	description string
+	expected    []string
+}
+
+var testTableDiff = []testDiff{
+	{"https://" + testKeyA + "@sentry.io/1", "https://" + testKeyA + "@sentry.io/1", "Testing equal", nil},
+	{"https://" + testKeyA + "@sentry.io/1", "http://" + testKeyA + "@sentry.io:9000/2",
+		"Testing scheme, port and project", []string{`scheme: "https" != "http"`, `port: "" != "9000"`, `project_id: "1" != "2"`}},
+	{"https://" + testKeyA + ":" + testKeyB + "@sentry.io/sentry/1", "https://" + testKeyA + "@sentry.io/1?timeout=5",
+		"Testing masked secret, path and options", []string{`secret_key: "***" != ""`, `path: "/sentry" != ""`, `options: "" != "timeout=5"`}},
+}
+
+func TestDiff(t *testing.T) {
+	for _, test := range testTableDiff {
+		a, _ := Parse(test.a)
+		b, _ := Parse(test.b)
+		got := Diff(a, b)
+		if len(got) != len(test.expected) {
+			t.Errorf("%s: Expected -- %v -- Got %v", test.description, test.expected, got)
+			continue
+		}
+		for i := range got {
+			if got[i].String() != test.expected[i] {
+				t.Errorf("%s: Expected -- %s -- Got %s", test.description, test.expected[i], got[i])
+			}
+		}
+	}
+}
+
+func TestDiffNilAndDefaultScheme(t *testing.T) {
+	a := &DSN{Host: "sentry.io", ProjectID: "1", PublicKey: testKeyA}
+	b := &DSN{Scheme: "https", Host: "sentry.io", ProjectID: "1", PublicKey: testKeyA}
+	if got := Diff(a, b); len(got) != 0 {
+		t.Errorf("Expected -- no diff -- Got %v", got)
+	}
+	if got := Diff(nil, a); len(got) != 3 {
+		t.Errorf("Expected -- public_key, host and project_id -- Got %v", got)
+	}
+}
@@ -0,0 +1,14 @@
+// Package dsn parses Sentry DSNs and the credentials of Sentry ingest requests, and builds relays on top.
+//
+// The stable surface is DSN (Parse, New, String), Auth, Parser (NewParser with Options, FromRequest,
+// ParseRequest, Middleware) and ParseResult with its versioned wire format (see Schema). Everything else
+// builds on those and follows the same rules: errors are sentinels matched with errors.Is and named by
+// ErrorCode, values returned are not modified afterwards, and options are applied once.
+//
+// The parsers themselves live in internal packages behind that surface: internal/authheader reads
+// X-Sentry-Auth headers and sentry_* query strings, internal/ingestpath splits ingest paths and
+// internal/keys recognizes key spellings. They can change freely, the types above are what callers see.
+//
+// Adapters for other routers and stores live in separate modules (dsngin, dsnecho, dsnredis, dsnkafka,
+// dsnyaml, sentrygo) so this one has no dependencies; examples/relay shows a complete relay.
+package dsn
@@ -1,11 +1,14 @@
 package dsn
 
 import (
+	"bytes"
+	"context"
+	"encoding/json"
 	"errors"
 	"net/http"
 	"net/url"
-	"regexp"
-	"strings"
+
+	"github.com/dgbailey/dsn/internal/authheader"
 )
 
 var HTTP_X_SENTRY_AUTH = "X-SENTRY-AUTH"
@@ -14,14 +17,43 @@ var (
 	// ErrMissing User Thrown if we are missing the public key that comprises {PROTOCOL}://{PUBLIC_KEY}:{SECRET_KEY}@{HOST}{PATH}/{PROJECT_ID}
 	ErrMissingUser      = errors.New("sentry:  missing public key")
 	ErrMissingProjectID = errors.New("sentry:  Failed attempt to parse project ID from path --")
+	// ErrNotIngestEndpoint Thrown for Sentry Web API paths (/api/0/...) which never carry events
+	ErrNotIngestEndpoint = errors.New("sentry:  not an ingest endpoint")
 )
 
+// DSN values returned by this package are fully populated before they are returned and never modified afterwards,
+// so they may be shared freely across goroutines (e.g. stored in a request context or a Router).
+// Treat them as read-only and call Clone before changing a field.
 type DSN struct {
-	URL       string //original dsn for incoming request
-	Host      string
-	ProjectID string
-	PublicKey string
-	SecretKey string
+	URL         string     `json:"url"`              //original dsn for incoming request
+	Scheme      string     `json:"scheme,omitempty"` //empty means https
+	Host        string     `json:"host"`
+	Port        string     `json:"port,omitempty"` //empty means the scheme default
+	Path        string     `json:"path,omitempty"` //prefix before the project ID, e.g. /sentry for https://key@host/sentry/1
+	Socket      string     `json:"socket,omitempty"` //unix socket of http+unix DSNs, see SchemeHTTPUnix
+	ProjectID   string     `json:"project_id"`
+	ProjectSlug string     `json:"project_slug,omitempty"` //set when the request path named the project by slug, see WithProjectSlugs
+	PublicKey   string     `json:"public_key"`
+	SecretKey   string     `json:"secret_key,omitempty"`
+	Options     url.Values `json:"options,omitempty"` //unknown query parameters carried in a DSN string
+}
+
+func (d *DSN) Clone() *DSN {
+	/*
+		Returns a copy that can be modified without affecting d or anyone sharing it.
+		Reference-typed fields are deep copied.
+	*/
+	if d == nil {
+		return nil
+	}
+	c := *d
+	if d.Options != nil {
+		c.Options = make(url.Values, len(d.Options))
+		for k, v := range d.Options {
+			c.Options[k] = append([]string(nil), v...)
+		}
+	}
+	return &c
 }
 type User struct {
 	PublicKey string //public key for DSN
@@ -31,21 +63,15 @@ type User struct {
 
 func CreateDSN(d *User, host string, projectID string) *DSN {
 	/*
+	Builds a DSN from request-derived values. Prefer New for DSNs assembled from configuration as it validates its input.
+
 	In the case where we encounter the legacy /api/store/ the returned DNS struct will have len(url) == 0
 	This will allow for optional checks in case the other parts of the struct (publicKey) are used for projectID lookups
 	Remaining conditions assume either both keys are present or just public key. 
 	*/
-	var url string
-	prefix := "https://"
-	if len(projectID) == 0{
-		url = ""
-	}else if len(d.PublicKey) > 0 && len(d.SecretKey) == 0 {
-		url = prefix + d.PublicKey + "@" + host + "/" + projectID
-	}else if len(d.PublicKey) > 0 && len(d.SecretKey) > 0 {
-		url = prefix + d.PublicKey + ":" + d.SecretKey + "@" + host + "/" + projectID
-	}
-	
-	return &DSN{URL: url, ProjectID: projectID, Host: host, PublicKey: d.PublicKey, SecretKey: d.SecretKey}
+	dsn := &DSN{ProjectID: projectID, Host: host, PublicKey: d.PublicKey, SecretKey: d.SecretKey}
+	dsn.URL = dsn.String()
+	return dsn
 }
 func ParseHeaders(h []string) (*User, error) {
 	/*
@@ -53,33 +79,109 @@ func ParseHeaders(h []string) (*User, error) {
 		Throws error if nothing is found for pk as this is critical.
 		Returns user struct with appropriate values or empty strings.
 	*/
-	var sentryPublic string
-	var sentrySecret string
-	
 	if len(h) == 0 {
 		return nil, ErrMissingUser
 	}
-	
-	toArray := strings.Split(strings.Split(h[0]," ")[1],",")
-	//Anticipates header: Sentry <start-header-values,...>
+	auth, ok := parseAuthHeader(h[0], false)
+	if !ok {
+		return nil, ErrMissingUser
+	}
+	return auth.User(), nil
 
-	for _, v := range toArray {
+}
 
-		foundPublic, _ := regexp.MatchString(`sentry_key=([a-f0-9]{32})`, v)
-		foundPrivate, _ := regexp.MatchString(`sentry_secret=([a-f0-9]{32})`, v)
-		if foundPublic {
-			sentryPublic = strings.Split(v, "=")[1]
-		}
-		if foundPrivate {
-			sentrySecret = strings.Split(v, "=")[1]
-		}
-	}
-	if len(sentryPublic) == 0 {
+func ParseAuthHeaderValue(s string) (*Auth, error) {
+	/*
+		Parses a single raw X-SENTRY-AUTH value, with or without the "Sentry " prefix, using the same parser as requests.
+		Meant for log processing and test tooling that only has the header string.
+		Throws ErrMissingUser when no valid sentry_key is present.
+	*/
+	auth, ok := parseAuthHeader(s, false)
+	if !ok {
 		return nil, ErrMissingUser
+	}
+	return &auth, nil
+}
+
+// Auth holds the fields of an X-SENTRY-AUTH header, or the equivalent sentry_* query parameters.
+type Auth struct {
+	PublicKey string `json:"public_key,omitempty"`
+	SecretKey string `json:"secret_key,omitempty"`
+	Version   string `json:"version,omitempty"`   //sentry_version, the protocol version
+	Client    string `json:"client,omitempty"`    //sentry_client, SDK name and version
+	Timestamp string `json:"timestamp,omitempty"` //sentry_timestamp, sent by older SDKs
+	Signature string `json:"signature,omitempty"` //sentry_signature, legacy HMAC signature (protocol <= 4)
+
+	extra *authExtra //other sentry_* fields, see Extra; behind a pointer so Auth stays comparable
+}
+
+// authExtra holds the sentry_* fields of a header this package does not know.
+type authExtra struct {
+	fields map[string]string
+}
+
+func (a *Auth) User() *User {
+	return &User{PublicKey: a.PublicKey, SecretKey: a.SecretKey}
+}
 
+func (a *Auth) Extra() map[string]string {
+	/*
+		The other sentry_* fields of the header keyed by their full name, e.g. sentry_flavor, with the value verbatim.
+		nil when there are none. The map is a copy.
+	*/
+	fields := a.extraFields()
+	if fields == nil {
+		return nil
+	}
+	c := make(map[string]string, len(fields))
+	for k, v := range fields {
+		c[k] = v
+	}
+	return c
+}
+
+func (a *Auth) extraFields() map[string]string {
+	if a.extra == nil {
+		return nil
 	}
-	return &User{PublicKey: sentryPublic, SecretKey: sentrySecret}, nil
+	return a.extra.fields
+}
 
+func (a *Auth) setExtra(key, value string) {
+	if a.extra == nil {
+		a.extra = &authExtra{fields: map[string]string{}}
+	}
+	a.extra.fields[key] = value
+}
+
+func (a Auth) MarshalJSON() ([]byte, error) {
+	return json.Marshal(wireAuth{a.PublicKey, a.SecretKey, a.Version, a.Client, a.Timestamp, a.Signature, a.extraFields()})
+}
+
+func (a *Auth) UnmarshalJSON(b []byte) error {
+	var w wireAuth
+	if err := json.Unmarshal(b, &w); err != nil {
+		return err
+	}
+	*a = w.auth()
+	return nil
+}
+
+func parseAuthHeader(v string, normalize bool) (Auth, bool) {
+	/*
+		See authheader.Parse. Keys must be 32 lower case hex characters unless normalize allows them to be repaired,
+		unknown sentry_* fields are kept (see Auth.Extra) so newer protocol fields survive forwarding.
+	*/
+	f, ok := authheader.Parse(v, normalize)
+	return authFromFields(f), ok
+}
+
+func authFromFields(f authheader.Fields) Auth {
+	auth := Auth{PublicKey: f.PublicKey, SecretKey: f.SecretKey, Version: f.Version, Client: f.Client, Timestamp: f.Timestamp, Signature: f.Signature}
+	if f.Extra != nil {
+		auth.extra = &authExtra{fields: f.Extra}
+	}
+	return auth
 }
 
 func ParseQueryString(u *url.URL) (*User, error) {
@@ -89,15 +191,21 @@ func ParseQueryString(u *url.URL) (*User, error) {
 	   Throws if we are missing pk as this is critical.
 	   Returns user struct with appropriate values or empty strings.
 	*/
-	
-	pk := u.Query().Get("sentry_key")
-	if len(pk) == 0 {
+	auth, ok := parseAuthQuery(u.RawQuery)
+	if !ok {
 		return nil, ErrMissingUser
 	}
-	sk := u.Query().Get("sentry_secret")
+	return auth.User(), nil
 
-	return &User{PublicKey: pk, SecretKey: sk}, nil
+}
 
+func parseAuthQuery(q string) (Auth, bool) {
+	/*
+		Same result as url.Values.Get for the sentry_* keys without building the whole url.Values map,
+		see authheader.ParseQuery.
+	*/
+	f, ok := authheader.ParseQuery(q)
+	return authFromFields(f), ok
 }
 
 func CheckPath(u *url.URL) (string, error) {
@@ -115,37 +223,85 @@ func CheckPath(u *url.URL) (string, error) {
 	Given the test have a higher degree of certainty that we will not encounter the legacy api.
 	We currently throw below if we do.
 
+	Requests for the Web API (/api/0/...) are not ingest requests at all and return ErrNotIngestEndpoint
+	so callers can 404 or proxy them elsewhere.
+
 	** Anticipates leading and trailing slashes **
 	https://develop.sentry.dev/sdk/store
+
+	ParsePath does the same on a plain string and returns the endpoint and everything else found in the path.
 	*/
-	path := u.Path
-	isValid, _ := regexp.MatchString(`\/api\/\d+\/store\/`, path)
-	isValidLegacy, _ := regexp.MatchString(`\/api\/store\/`, path)
+	projectID, _, err := parseIngestPath(u.Path)
+	return projectID, err
 
-	if !isValid {
-		if isValidLegacy {
-			return "", nil
-		}
-		return "", ErrMissingProjectID
-	}
-	pathItems := strings.Split(path, "/")
+}
 
-	//with leading + trailing splits array has deterministic length of 5
-	return pathItems[2], nil
+func parseIngestPath(path string) (projectID string, endpoint Endpoint, err error) {
+	/*
+		Hand written equivalent of matching \/api\/\d+\/(store|envelope|unreal|...)\/ so parsing does not allocate.
+		Anything before /api/ is treated as a path prefix (e.g. /sentry/api/1/store/). See ParsePath for the details.
+	*/
+	var info PathInfo
+	err = parsePathInfo(path, &info)
+	return info.ProjectID, info.Endpoint, err
+}
+
+// canonical form of the default HTTP_X_SENTRY_AUTH, looked up directly to skip header key canonicalization
+const defaultAuthHeader, defaultAuthHeaderKey = "X-SENTRY-AUTH", "X-Sentry-Auth"
 
+func authHeaderValues(h http.Header) []string {
+	if HTTP_X_SENTRY_AUTH == defaultAuthHeader {
+		return h[defaultAuthHeaderKey]
+	}
+	return h.Values(HTTP_X_SENTRY_AUTH)
 }
-func FromRequest(r *http.Request) (*DSN, error) {
+
+func FromRequest(r *http.Request, opts ...Option) (*DSN, error) {
+	/*
+		Context-free variant of FromRequestContext kept for backward compatibility.
+		Lookups run under context.Background() bounded only by the resolver timeout, so when a
+		KeyResolver is configured prefer FromRequestContext to respect caller deadlines and cancellation.
+	*/
+	return FromRequestContext(context.Background(), r, opts...)
+}
+
+func FromRequestContext(ctx context.Context, r *http.Request, opts ...Option) (*DSN, error) {
 	/*
 		Critical assumption here is that User information (sentry_key and optionally sentry_secret) will come from either
 		request headers or the request query string. You will never use both to fill each of these values.
 
 		We parse headers first to find User info. This will return pk, sk, both or err if no pk is found.
 		If we err using headers we proceed to the QS. An Err here throws for the entire parse request operation.
+		WithCredentialSources and WithCredentialMerge change which sources are used and how they combine.
+		Any configured KeyResolver runs under ctx.
 		Returns the DSN struct which offers the original DSN with myDSN.URL
+		Use ParseRequest for everything else learned along the way (auth fields, endpoint, credential sources).
+
+		Performance budget (see BenchmarkFromRequest*): < 2µs and <= 3 allocations per parse without options.
+	*/
+	return parserFor(opts).FromRequestContext(ctx, r)
+}
+
+func ParseRequest(ctx context.Context, r *http.Request, opts ...Option) (*ParseResult, error) {
+	/*
+		Same as FromRequestContext but returns the full ParseResult.
 	*/
+	return parserFor(opts).ParseRequest(ctx, r)
+}
+
+func parseRequest(ctx context.Context, r *http.Request, c *config, res *ParseResult) (err error) {
+	if c.stats != nil {
+		defer func() { c.stats.record(res, err) }()
+	}
+	if c.projectStats != nil {
+		defer func() { c.projectStats.record(r, res, err) }()
+	}
+	if c.trace {
+		defer c.finishTrace(res, &err)
+		c.tracef(res, "method", "%s %s from %s", r.Method, r.URL.Path, r.RemoteAddr)
+	}
 	var user *User
 	u := r.URL //represents a fully parsed url
-	h := r.Header.Values(HTTP_X_SENTRY_AUTH)
 
 	host := u.Hostname()
 	if len(host) == 0{
@@ -154,27 +310,135 @@ func FromRequest(r *http.Request) (*DSN, error) {
 	//some routers/proxies may strip the host from http.Request.URL so http.Request.Host is useful.
 
 	
-	usingHeader, err := ParseHeaders(h)
-	if err != nil {
-	
-		usingQs, qerr := ParseQueryString(u)
-
-		if qerr != nil {
-			return nil, ErrMissingUser
-		} else {
-			user = usingQs
+	errs := &errorCollector{all: c.collectAll}
+	if err := c.checkMethod(r); err != nil && errs.add(err) {
+		return errs.err()
+	}
+	if err := c.checkTLS(r, res); err != nil && errs.add(err) {
+		return errs.err()
+	}
+	ok := c.credentials(r, res)
+	if ok && c.conflicts != ConflictIgnore {
+		if err := c.checkConflicts(r, res); err != nil && errs.add(err) {
+			return errs.err()
+		}
+		if c.trace {
+			c.tracef(res, "conflicts", "sentry_key %s taken from %s", redactKey(res.Auth.PublicKey), res.KeySource)
+		}
+	}
+	auth := &res.Auth
+	if ok {
+		user = &User{PublicKey: auth.PublicKey, SecretKey: auth.SecretKey}
+	} else if errs.add(ErrMissingUser) {
+		return errs.err()
+	}
+	if user != nil {
+		if err := c.checkQuerySecret(r); err != nil && errs.add(err) {
+			return errs.err()
+		}
+	}
+	if user != nil && c.protocol != nil {
+		if err := c.protocol.check(auth); err != nil && errs.add(err) {
+			return errs.err()
 		}
-	} else {
-		user = usingHeader
 	}
 	// parse project
-	p, err := CheckPath(u)
-	if err != nil {
-		return nil, err
+	p, slug, endpoint, err := c.checkPath(ctx, u)
+	if c.trace {
+		c.tracePath(res, u.Path, p, slug, endpoint, err)
+	}
+	if err != nil && errs.add(err) {
+		return errs.err()
+	}
+	res.Endpoint = endpoint
+	res.BodySize = r.ContentLength
+	if err := c.checkSize(r, endpoint); err != nil && errs.add(err) {
+		return errs.err()
+	}
+	payload, perr := c.getPayload(r, endpoint)
+	if perr != nil && errs.add(perr) {
+		return errs.err()
+	}
+	if payload != nil {
+		res.Payload, res.payload = bytes.NewReader(payload), payload
+	}
+	var info *KeyInfo
+	if user != nil && err == nil {
+		var resolved string
+		info, resolved, err = c.resolve(ctx, user, p)
+		if err != nil && errs.add(err) {
+			return errs.err()
+		}
+		if len(p) > 0 || len(slug) == 0 {
+			p = resolved //an unresolved slug stays the project, the key is still checked
+		}
+		if info != nil {
+			res.KeyExpires = info.Expires(c.maxKeyAge)
+			if c.trace {
+				c.tracef(res, "key", "resolver accepted %s for project %s", redactKey(user.PublicKey), p)
+			}
+		}
+	}
+	if user != nil && c.registry != nil {
+		t, err := c.tenant(r, user.PublicKey, endpoint)
+		if err != nil && errs.add(err) {
+			return errs.err()
+		}
+		res.Tenant = t
+		if t != nil && c.trace {
+			c.tracef(res, "tenant", "registry allows %s to %s", redactKey(t.PublicKey), endpoint)
+		}
+	}
+	if user != nil && c.signatures != nil {
+		err := c.signatures.verify(r, auth, info)
+		if c.trace {
+			c.traceSignature(res, auth, err)
+		}
+		if err != nil && errs.add(err) {
+			return errs.err()
+		}
+	}
+	if err := errs.err(); err != nil {
+		return err
 	}
 	// complete DSN
 	dsn := CreateDSN(user, host, p)
-
-	return dsn, nil
+	if len(slug) > 0 {
+		dsn.ProjectSlug = slug
+		dsn.URL = dsn.String()
+	}
+	if err := c.checkAllowed(dsn); err != nil {
+		return err
+	}
+	res.DSN = dsn
+	if c.replay != nil {
+		if err := c.replay.checkRequest(r, res); err != nil {
+			return err
+		}
+	}
+	if c.spikes != nil {
+		if err := c.spikes.Admit(dsn); err != nil {
+			return res.reject(err)
+		}
+	}
+	if c.idempotency != nil {
+		res.IdempotencyKey = c.idempotencyKey(r, res)
+	}
+	if c.itemCounts != nil {
+		res.ItemCounts = c.countItems(r, res.Endpoint)
+	}
+	if c.classify != nil {
+		res.Category = c.category(r, res)
+	}
+	if c.trustedProxies != nil {
+		res.ClientIP = ClientIP(r, c.trustedProxies)
+	}
+	c.warnings(r, res)
+	if c.trace {
+		for _, w := range res.Warnings {
+			c.tracef(res, "warning", "%s", w)
+		}
+	}
+	return nil
 
 }
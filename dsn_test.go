@@ -3,7 +3,9 @@ package dsn
 import (
 	"bytes"
 	"encoding/json"
+	"net/http"
 	"net/http/httptest"
+	"reflect"
 	"strings"
 	"testing"
 
@@ -154,3 +156,87 @@ func TestLegacyStoreAPI(t *testing.T){
 
 
 
+
+func TestClone(t *testing.T){
+	d := CreateDSN(&User{PublicKey: "4784fbc50de2473f9977cfce8a9adce5"}, "sentry.io", "1234")
+	c := d.Clone()
+	if c == d || !c.Equal(d, false) || c.URL != d.URL {
+		t.Errorf("Expected -- equal copy -- Got %v", c)
+	}
+	c.Host = "example.com"
+	if d.Host != "sentry.io" {
+		t.Errorf("Expected -- original untouched -- Got %s", d.Host)
+	}
+	var empty *DSN
+	if empty.Clone() != nil {
+		t.Errorf("Expected -- nil -- Got non nil")
+	}
+}
+
+func TestWebAPIPath(t *testing.T){
+	for _, path := range []string{"https://sentry.io/api/0/projects/org/proj/", "https://sentry.io/api/0/store/"} {
+		r := httptest.NewRequest("GET", path+"?sentry_key=4784fbc50de2473f9977cfce8a9adce5", nil)
+		got, err := FromRequest(r)
+		if err != ErrNotIngestEndpoint {
+			t.Errorf("Expected -- %s -- Got %v %v", ErrNotIngestEndpoint, got, err)
+		}
+	}
+}
+
+func TestEnvelopeAndSpacedHeader(t *testing.T){
+	r := httptest.NewRequest("POST", "https://sentry.io/api/1234/envelope/", nil)
+	r.Header.Set("X-SENTRY-AUTH", "Sentry sentry_version=7, sentry_client=sentry.python/1.0, sentry_key=4784fbc50de2473f9977cfce8a9adce5")
+	got, err := FromRequest(r)
+	if expected := "https://4784fbc50de2473f9977cfce8a9adce5@sentry.io/1234"; err != nil || got.URL != expected {
+		t.Errorf("Expected -- %s -- Got %v %v", expected, got, err)
+	}
+}
+
+func TestParseAuthHeaderValue(t *testing.T){
+	for _, test := range TestVectors().AuthHeaders {
+		got, err := ParseAuthHeaderValue(test.Input)
+		if ErrorCode(err) != test.Error || (err == nil && !reflect.DeepEqual(*got, test.Expected)) {
+			t.Errorf("Testing %s: Expected -- %+v %s -- Got %+v %v", test.Description, test.Expected, test.Error, got, err)
+		}
+	}
+}
+
+func TestAllocationBudget(t *testing.T){
+	r := httptest.NewRequest("POST", "https://sentry.io/api/1234/store/?sentry_key=4784fbc50de2473f9977cfce8a9adce5", nil)
+	r.Header.Set("X-SENTRY-AUTH", "Sentry sentry_version=7, sentry_key=4784fbc50de2473f9977cfce8a9adce5")
+	if allocs := testing.AllocsPerRun(100, func() { FromRequest(r) }); allocs > 3 {
+		t.Errorf("Expected -- <= 3 allocs -- Got %.0f", allocs)
+	}
+}
+
+//benchmarks
+
+func benchmarkRequest(b *testing.B, r *http.Request) {
+	b.ReportAllocs()
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		FromRequest(r)
+	}
+}
+
+func BenchmarkFromRequestHeader(b *testing.B) {
+	r := httptest.NewRequest("POST", "https://o87286.ingest.sentry.io/api/1234/store/", nil)
+	r.Header.Set("X-SENTRY-AUTH", "Sentry sentry_version=7, sentry_client=raven-go/1.0, sentry_key=4784fbc50de2473f9977cfce8a9adce5, sentry_secret=4784fbc50de2473f9977cfce8a9adce5")
+	benchmarkRequest(b, r)
+}
+
+func BenchmarkFromRequestQuery(b *testing.B) {
+	r := httptest.NewRequest("POST", "https://o87286.ingest.sentry.io/api/1234/store/?sentry_version=7&sentry_key=4784fbc50de2473f9977cfce8a9adce5&sentry_secret=4784fbc50de2473f9977cfce8a9adce5", nil)
+	benchmarkRequest(b, r)
+}
+
+func BenchmarkFromRequestEnvelope(b *testing.B) {
+	r := httptest.NewRequest("POST", "https://o87286.ingest.sentry.io/api/1234/envelope/?sentry_key=4784fbc50de2473f9977cfce8a9adce5&sentry_version=7", nil)
+	benchmarkRequest(b, r)
+}
+
+func BenchmarkFromRequestMalformed(b *testing.B) {
+	r := httptest.NewRequest("POST", "https://o87286.ingest.sentry.io//api//1234///store//?sentry_version=7", nil)
+	r.Header.Set("X-SENTRY-AUTH", "Sentry sentry_version=7,sentry_secret=4784fbc50de2473f9977cfce8a9adce5")
+	benchmarkRequest(b, r)
+}
@@ -0,0 +1,46 @@
+// Package dsnecho exposes the dsn middleware as an echo.MiddlewareFunc.
+package dsnecho
+
+import (
+	"net/http"
+
+	"github.com/dgbailey/dsn"
+	"github.com/labstack/echo/v4"
+)
+
+// ContextKey is the echo.Context key the parsed DSN is stored under.
+const ContextKey = "github.com/dgbailey/dsn"
+
+func Middleware(opts ...dsn.Option) echo.MiddlewareFunc {
+	/*
+		Parses every request with dsn.FromRequestContext using opts. Failures are answered with dsn.WriteError and stop the chain.
+		The DSN is stored on the echo.Context and on the request context so both FromContext and dsn.FromContext work.
+		CORS preflights are answered with dsn.Preflight.
+	*/
+	return func(next echo.HandlerFunc) echo.HandlerFunc {
+		return func(c echo.Context) error {
+			r := c.Request()
+			if r.Method == http.MethodOptions {
+				dsn.Preflight(c.Response(), r)
+				return nil
+			}
+			dsn.SetCORSHeaders(c.Response(), r)
+			d, err := dsn.FromRequestContext(r.Context(), r, opts...)
+			if err != nil {
+				dsn.WriteError(c.Response(), err)
+				return nil
+			}
+			c.Set(ContextKey, d)
+			c.SetRequest(r.WithContext(dsn.NewContext(r.Context(), d)))
+			return next(c)
+		}
+	}
+}
+
+func FromContext(c echo.Context) (*dsn.DSN, bool) {
+	/*
+		Returns the DSN stored by Middleware.
+	*/
+	d, ok := c.Get(ContextKey).(*dsn.DSN)
+	return d, ok && d != nil
+}
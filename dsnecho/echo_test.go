@@ -0,0 +1,37 @@
+package dsnecho
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/dgbailey/dsn"
+	"github.com/labstack/echo/v4"
+)
+
+func TestMiddleware(t *testing.T) {
+	e := echo.New()
+	e.Use(Middleware())
+	e.POST("/api/:project/store/", func(c echo.Context) error {
+		d, ok := FromContext(c)
+		if _, rok := dsn.FromContext(c.Request().Context()); !ok || !rok {
+			t.Errorf("Expected -- DSN in context -- Got none")
+			return nil
+		}
+		return c.String(http.StatusOK, d.URL)
+	})
+
+	w := httptest.NewRecorder()
+	r := httptest.NewRequest("POST", "https://sentry.io/api/1234/store/?sentry_key=4784fbc50de2473f9977cfce8a9adce5", nil)
+	e.ServeHTTP(w, r)
+	if expected := "https://4784fbc50de2473f9977cfce8a9adce5@sentry.io/1234"; w.Body.String() != expected {
+		t.Errorf("Expected -- %s -- Got %s", expected, w.Body.String())
+	}
+
+	w = httptest.NewRecorder()
+	r = httptest.NewRequest("POST", "https://sentry.io/api/1234/store/", nil)
+	e.ServeHTTP(w, r)
+	if w.Code != http.StatusUnauthorized {
+		t.Errorf("Expected -- %d -- Got %d", http.StatusUnauthorized, w.Code)
+	}
+}
@@ -0,0 +1,45 @@
+// Package dsngin exposes the dsn middleware as a gin.HandlerFunc.
+package dsngin
+
+import (
+	"net/http"
+
+	"github.com/dgbailey/dsn"
+	"github.com/gin-gonic/gin"
+)
+
+// ContextKey is the gin.Context key the parsed DSN is stored under.
+const ContextKey = "github.com/dgbailey/dsn"
+
+func Middleware(opts ...dsn.Option) gin.HandlerFunc {
+	/*
+		Parses every request with dsn.FromRequestContext using opts. Failures are answered with dsn.WriteError and abort the chain.
+		The DSN is stored on the gin.Context and on the request context so both FromContext and dsn.FromContext work.
+		CORS preflights are answered with dsn.Preflight.
+	*/
+	return func(c *gin.Context) {
+		if c.Request.Method == http.MethodOptions {
+			dsn.Preflight(c.Writer, c.Request)
+			c.Abort()
+			return
+		}
+		dsn.SetCORSHeaders(c.Writer, c.Request)
+		d, err := dsn.FromRequestContext(c.Request.Context(), c.Request, opts...)
+		if err != nil {
+			dsn.WriteError(c.Writer, err)
+			c.Abort()
+			return
+		}
+		c.Set(ContextKey, d)
+		c.Request = c.Request.WithContext(dsn.NewContext(c.Request.Context(), d))
+		c.Next()
+	}
+}
+
+func FromContext(c *gin.Context) (*dsn.DSN, bool) {
+	/*
+		Returns the DSN stored by Middleware.
+	*/
+	d, ok := c.Value(ContextKey).(*dsn.DSN)
+	return d, ok && d != nil
+}
@@ -0,0 +1,46 @@
+package dsngin
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/dgbailey/dsn"
+	"github.com/gin-gonic/gin"
+)
+
+func TestMiddleware(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	router := gin.New()
+	router.Use(Middleware())
+	router.POST("/api/:project/store/", func(c *gin.Context) {
+		d, ok := FromContext(c)
+		if _, rok := dsn.FromContext(c.Request.Context()); !ok || !rok {
+			t.Errorf("Expected -- DSN in context -- Got none")
+			return
+		}
+		c.String(http.StatusOK, d.URL)
+	})
+
+	w := httptest.NewRecorder()
+	r := httptest.NewRequest("POST", "https://sentry.io/api/1234/store/?sentry_key=4784fbc50de2473f9977cfce8a9adce5", nil)
+	router.ServeHTTP(w, r)
+	if expected := "https://4784fbc50de2473f9977cfce8a9adce5@sentry.io/1234"; w.Body.String() != expected {
+		t.Errorf("Expected -- %s -- Got %s", expected, w.Body.String())
+	}
+
+	w = httptest.NewRecorder()
+	r = httptest.NewRequest("POST", "https://sentry.io/api/1234/store/", nil)
+	router.ServeHTTP(w, r)
+	if w.Code != http.StatusUnauthorized {
+		t.Errorf("Expected -- %d -- Got %d", http.StatusUnauthorized, w.Code)
+	}
+
+	w = httptest.NewRecorder()
+	r = httptest.NewRequest("OPTIONS", "https://sentry.io/api/1234/store/", nil)
+	r.Header.Set("Origin", "https://example.com")
+	router.ServeHTTP(w, r)
+	if w.Code != http.StatusOK || w.Header().Get("Access-Control-Allow-Origin") != "https://example.com" {
+		t.Errorf("Expected -- preflight answered -- Got %d %v", w.Code, w.Header())
+	}
+}
@@ -0,0 +1,15 @@
+module github.com/dgbailey/dsn/dsnkafka
+
+go 1.25.0
+
+replace github.com/dgbailey/dsn => ../
+
+require (
+	github.com/dgbailey/dsn v0.0.0
+	github.com/segmentio/kafka-go v0.4.47
+)
+
+require (
+	github.com/klauspost/compress v1.15.9 // indirect
+	github.com/pierrec/lz4/v4 v4.1.15 // indirect
+)
@@ -0,0 +1,76 @@
+// Package dsnkafka publishes parsed submissions to Kafka, for pipelines that process
+// events downstream before they reach Sentry.
+package dsnkafka
+
+import (
+	"context"
+
+	"github.com/dgbailey/dsn"
+	"github.com/segmentio/kafka-go"
+)
+
+// Message header names. The secret key is never published.
+const (
+	HeaderFingerprint     = "dsn-fingerprint"
+	HeaderPublicKey       = "dsn-public-key"
+	HeaderHost            = "dsn-host"
+	HeaderProjectID       = "dsn-project-id"
+	HeaderEndpoint        = "dsn-endpoint"
+	HeaderContentType     = "content-type"
+	HeaderContentEncoding = "content-encoding"
+)
+
+// Writer is the part of *kafka.Writer a Sink needs.
+type Writer interface {
+	WriteMessages(ctx context.Context, msgs ...kafka.Message) error
+}
+
+// Sink is a dsn.Sink publishing every submission as one message keyed by the DSN fingerprint,
+// so with a hashing balancer a project's traffic stays on one partition and in order.
+type Sink struct {
+	Topic string //per message topic, leave empty when the Writer has one
+
+	writer Writer
+}
+
+func NewSink(w Writer) *Sink {
+	/*
+		w is typically a *kafka.Writer from NewWriter.
+	*/
+	return &Sink{writer: w}
+}
+
+func NewWriter(brokers []string, topic string) *kafka.Writer {
+	/*
+		Writer partitioning by message key, which is what keeps a DSN on one partition.
+	*/
+	return &kafka.Writer{Addr: kafka.TCP(brokers...), Topic: topic, Balancer: &kafka.Hash{}}
+}
+
+func (s *Sink) Send(ctx context.Context, sub *dsn.Submission) error {
+	return s.writer.WriteMessages(ctx, Message(s.Topic, sub))
+}
+
+func Message(topic string, sub *dsn.Submission) kafka.Message {
+	/*
+		Message for sub: the body as value, the DSN fingerprint as key and DSN metadata in headers.
+	*/
+	headers := []kafka.Header{
+		{Key: HeaderFingerprint, Value: []byte(sub.DSN.Fingerprint())},
+		{Key: HeaderPublicKey, Value: []byte(sub.DSN.PublicKey)},
+		{Key: HeaderHost, Value: []byte(sub.DSN.Host)},
+		{Key: HeaderProjectID, Value: []byte(sub.DSN.ProjectID)},
+		{Key: HeaderEndpoint, Value: []byte(sub.Endpoint)},
+	}
+	for _, h := range []struct{ key, name string }{{HeaderContentType, "Content-Type"}, {HeaderContentEncoding, "Content-Encoding"}} {
+		if v := sub.Headers.Get(h.name); len(v) > 0 {
+			headers = append(headers, kafka.Header{Key: h.key, Value: []byte(v)})
+		}
+	}
+	return kafka.Message{
+		Topic:   topic,
+		Key:     []byte(sub.DSN.Fingerprint()),
+		Value:   sub.Body,
+		Headers: headers,
+	}
+}
@@ -0,0 +1,67 @@
+package dsnkafka
+
+import (
+	"context"
+	"net/http"
+	"testing"
+
+	"github.com/dgbailey/dsn"
+	"github.com/segmentio/kafka-go"
+)
+
+const testKey = "4784fbc50de2473f9977cfce8a9adce5"
+
+type testWriter struct {
+	msgs []kafka.Message
+}
+
+func (w *testWriter) WriteMessages(ctx context.Context, msgs ...kafka.Message) error {
+	w.msgs = append(w.msgs, msgs...)
+	return nil
+}
+
+func TestSink(t *testing.T) {
+	d, _ := dsn.Parse("https://" + testKey + ":secret@sentry.io/42")
+	w := &testWriter{}
+	s := NewSink(w)
+	s.Topic = "events"
+	sub := &dsn.Submission{DSN: d, Endpoint: dsn.EndpointEnvelope, Headers: http.Header{"Content-Encoding": {"gzip"}}, Body: []byte("{}\n")}
+	if err := s.Send(context.Background(), sub); err != nil || len(w.msgs) != 1 {
+		t.Fatalf("Expected -- 1 message -- Got %d %v", len(w.msgs), err)
+	}
+	m := w.msgs[0]
+	if m.Topic != "events" || string(m.Key) != d.Fingerprint() || string(m.Value) != "{}\n" {
+		t.Errorf("Expected -- keyed by fingerprint -- Got %s %s %q", m.Topic, m.Key, m.Value)
+	}
+	expected := map[string]string{
+		HeaderFingerprint:     d.Fingerprint(),
+		HeaderPublicKey:       testKey,
+		HeaderHost:            "sentry.io",
+		HeaderProjectID:       "42",
+		HeaderEndpoint:        "envelope",
+		HeaderContentEncoding: "gzip",
+	}
+	got := map[string]string{}
+	for _, h := range m.Headers {
+		got[h.Key] = string(h.Value)
+		if string(h.Value) == "secret" {
+			t.Errorf("Expected -- no secret key -- Got it in %s", h.Key)
+		}
+	}
+	for k, v := range expected {
+		if got[k] != v {
+			t.Errorf("Testing header %s: Expected -- %s -- Got %s", k, v, got[k])
+		}
+	}
+	if _, ok := got[HeaderContentType]; ok {
+		t.Errorf("Expected -- no content-type header -- Got %s", got[HeaderContentType])
+	}
+}
+
+func TestNewWriter(t *testing.T) {
+	w := NewWriter([]string{"localhost:9092"}, "events")
+	if _, ok := w.Balancer.(*kafka.Hash); !ok || w.Topic != "events" {
+		t.Errorf("Expected -- hashing writer for events -- Got %T %s", w.Balancer, w.Topic)
+	}
+	var _ Writer = w
+}
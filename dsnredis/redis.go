@@ -0,0 +1,164 @@
+// Package dsnredis keeps key mappings and rate-limit counters in Redis,
+// so horizontally scaled relays share them.
+package dsnredis
+
+import (
+	"context"
+	"strconv"
+	"time"
+
+	"github.com/dgbailey/dsn"
+	"github.com/redis/go-redis/v9"
+)
+
+// DefaultPrefix namespaces every key written by this package.
+var DefaultPrefix = "dsn:"
+
+// Keystore is a dsn.KeyResolver reading keys from Redis hashes at <prefix>key:<public key>
+// with the fields project_id, secret_key, disabled and optionally key_id and not_before/not_after/issued_at (unix seconds).
+// Keys with a key ID are also indexed at <prefix>keyid:<key ID>, holding the public key.
+// Disabled keys are rejected with dsn.ErrKeyDisabled, like dsn.FileKeystore.
+type Keystore struct {
+	client redis.Cmdable
+	prefix string
+}
+
+func NewKeystore(client redis.Cmdable) *Keystore {
+	/*
+		client may be a *redis.Client, *redis.ClusterClient or any other redis.Cmdable.
+	*/
+	return &Keystore{client: client, prefix: DefaultPrefix}
+}
+
+func (ks *Keystore) hashKey(publicKey string) string {
+	return ks.prefix + "key:" + publicKey
+}
+
+func (ks *Keystore) idKey(keyID string) string {
+	return ks.prefix + "keyid:" + keyID
+}
+
+func (ks *Keystore) ResolveKey(ctx context.Context, publicKey string) (*dsn.KeyInfo, error) {
+	fields, err := ks.client.HGetAll(ctx, ks.hashKey(publicKey)).Result()
+	if err != nil {
+		return nil, err
+	}
+	if len(fields) == 0 {
+		return nil, dsn.ErrUnknownKey
+	}
+	return &dsn.KeyInfo{
+		PublicKey: publicKey,
+		SecretKey: fields["secret_key"],
+		ProjectID: fields["project_id"],
+		KeyID:     fields["key_id"],
+		Disabled:  fields["disabled"] == "1",
+		Validity: dsn.Validity{
+			NotBefore: unixField(fields["not_before"]),
+			NotAfter:  unixField(fields["not_after"]),
+			IssuedAt:  unixField(fields["issued_at"]),
+		},
+	}, nil
+}
+
+func (ks *Keystore) ResolveKeyID(ctx context.Context, keyID string) (*dsn.KeyInfo, error) {
+	/*
+		Like ResolveKey, through the key ID index. Index entries whose key is gone or changed its ID are unknown.
+	*/
+	publicKey, err := ks.client.Get(ctx, ks.idKey(keyID)).Result()
+	if err == redis.Nil {
+		return nil, dsn.ErrUnknownKey
+	}
+	if err != nil {
+		return nil, err
+	}
+	info, err := ks.ResolveKey(ctx, publicKey)
+	if err != nil {
+		return nil, err
+	}
+	if info.KeyID != keyID {
+		return nil, dsn.ErrUnknownKey
+	}
+	return info, nil
+}
+
+func unixField(v string) time.Time {
+	n, err := strconv.ParseInt(v, 10, 64)
+	if err != nil || n == 0 {
+		return time.Time{}
+	}
+	return time.Unix(n, 0)
+}
+
+func unixValue(t time.Time) int64 {
+	if t.IsZero() {
+		return 0
+	}
+	return t.Unix()
+}
+
+func (ks *Keystore) Put(ctx context.Context, e dsn.KeyEntry) error {
+	/*
+		Adds or replaces e. Visible to every relay on its next lookup.
+	*/
+	disabled := "0"
+	if e.Disabled {
+		disabled = "1"
+	}
+	_, err := ks.client.TxPipelined(ctx, func(p redis.Pipeliner) error {
+		p.HSet(ctx, ks.hashKey(e.PublicKey),
+			"project_id", e.ProjectID, "secret_key", e.SecretKey, "key_id", e.KeyID, "disabled", disabled,
+			"not_before", unixValue(e.NotBefore), "not_after", unixValue(e.NotAfter), "issued_at", unixValue(e.IssuedAt))
+		if len(e.KeyID) > 0 {
+			p.Set(ctx, ks.idKey(e.KeyID), e.PublicKey, 0)
+		}
+		return nil
+	})
+	return err
+}
+
+func (ks *Keystore) Delete(ctx context.Context, publicKey string) error {
+	keyID, err := ks.client.HGet(ctx, ks.hashKey(publicKey), "key_id").Result()
+	if err != nil && err != redis.Nil {
+		return err
+	}
+	keys := []string{ks.hashKey(publicKey)}
+	if len(keyID) > 0 {
+		keys = append(keys, ks.idKey(keyID))
+	}
+	return ks.client.Del(ctx, keys...).Err()
+}
+
+// RateLimiter counts requests per key in fixed windows shared through Redis.
+type RateLimiter struct {
+	Clock dsn.Clock //decides the current window, dsn.SystemClock by default
+
+	client redis.Cmdable
+	prefix string
+}
+
+func NewRateLimiter(client redis.Cmdable) *RateLimiter {
+	return &RateLimiter{Clock: dsn.SystemClock, client: client, prefix: DefaultPrefix}
+}
+
+func (rl *RateLimiter) Allow(ctx context.Context, key string, limit int, window time.Duration) (bool, error) {
+	/*
+		Counts one request for key (typically a public key or project ID) and reports whether it is within
+		limit for the current window. limit <= 0 allows everything without touching Redis.
+		Counters expire with their window, so nothing needs cleaning up.
+	*/
+	if limit <= 0 {
+		return true, nil
+	}
+	slot := rl.Clock.Now().UnixNano() / int64(window)
+	counter := rl.prefix + "rl:" + key + ":" + strconv.FormatInt(slot, 10)
+	var incr *redis.IntCmd
+	_, err := rl.client.TxPipelined(ctx, func(p redis.Pipeliner) error {
+		incr = p.Incr(ctx, counter)
+		p.Expire(ctx, counter, window)
+		return nil
+	})
+	if err != nil {
+		return false, err
+	}
+	return incr.Val() <= int64(limit), nil
+}
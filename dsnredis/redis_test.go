@@ -0,0 +1,98 @@
+package dsnredis
+
+import (
+	"context"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/alicebob/miniredis/v2"
+	"github.com/dgbailey/dsn"
+	"github.com/redis/go-redis/v9"
+)
+
+const testKey = "4784fbc50de2473f9977cfce8a9adce5"
+
+func newTestClient(t *testing.T) (*miniredis.Miniredis, *redis.Client) {
+	mr := miniredis.RunT(t)
+	return mr, redis.NewClient(&redis.Options{Addr: mr.Addr()})
+}
+
+func TestKeystore(t *testing.T) {
+	_, client := newTestClient(t)
+	ks := NewKeystore(client)
+	ctx := context.Background()
+	r := httptest.NewRequest("POST", "https://sentry.io/api/store/?sentry_key="+testKey, nil)
+	if _, err := dsn.FromRequest(r, dsn.WithKeyResolver(ks)); err != dsn.ErrUnknownKey {
+		t.Errorf("Expected -- %v -- Got %v", dsn.ErrUnknownKey, err)
+	}
+	if err := ks.Put(ctx, dsn.KeyEntry{PublicKey: testKey, ProjectID: "42"}); err != nil {
+		t.Fatal(err)
+	}
+	d, err := dsn.FromRequest(r, dsn.WithKeyResolver(ks))
+	if err != nil || d.ProjectID != "42" {
+		t.Errorf("Expected -- project 42 -- Got %v %v", d, err)
+	}
+	expired := dsn.Validity{NotAfter: time.Now().Add(-time.Hour)}
+	ks.Put(ctx, dsn.KeyEntry{PublicKey: testKey, ProjectID: "42", Validity: expired})
+	if _, err := dsn.FromRequest(r, dsn.WithKeyResolver(ks)); err != dsn.ErrKeyExpired {
+		t.Errorf("Expected -- %v -- Got %v", dsn.ErrKeyExpired, err)
+	}
+	ks.Put(ctx, dsn.KeyEntry{PublicKey: testKey, ProjectID: "42", Disabled: true})
+	if _, err := dsn.FromRequest(r, dsn.WithKeyResolver(ks)); err != dsn.ErrKeyDisabled {
+		t.Errorf("Expected -- %v -- Got %v", dsn.ErrKeyDisabled, err)
+	}
+	ks.Delete(ctx, testKey)
+	if _, err := ks.ResolveKey(ctx, testKey); err != dsn.ErrUnknownKey {
+		t.Errorf("Expected -- %v -- Got %v", dsn.ErrUnknownKey, err)
+	}
+}
+
+func TestKeystoreKeyID(t *testing.T) {
+	mr, client := newTestClient(t)
+	ks := NewKeystore(client)
+	ctx := context.Background()
+	if err := ks.Put(ctx, dsn.KeyEntry{PublicKey: testKey, ProjectID: "42", KeyID: "7"}); err != nil {
+		t.Fatal(err)
+	}
+	if info, err := dsn.LookupKey(ctx, ks, "7"); err != nil || info.PublicKey != testKey || info.KeyID != "7" {
+		t.Errorf("Expected -- %s by key ID -- Got %v %v", testKey, info, err)
+	}
+	if info, err := dsn.LookupKey(ctx, ks, testKey); err != nil || info.KeyID != "7" {
+		t.Errorf("Expected -- key ID 7 by public key -- Got %v %v", info, err)
+	}
+	// an index entry left behind by a key that changed its ID
+	ks.Put(ctx, dsn.KeyEntry{PublicKey: testKey, ProjectID: "42", KeyID: "8"})
+	if _, err := ks.ResolveKeyID(ctx, "7"); err != dsn.ErrUnknownKey {
+		t.Errorf("Expected -- %v -- Got %v", dsn.ErrUnknownKey, err)
+	}
+	ks.Delete(ctx, testKey)
+	if mr.Exists(DefaultPrefix + "keyid:8") {
+		t.Errorf("Expected -- key ID index removed -- Got %v", mr.Keys())
+	}
+}
+
+func TestRateLimiter(t *testing.T) {
+	mr, client := newTestClient(t)
+	now := time.Unix(1000, 0)
+	rl := NewRateLimiter(client)
+	rl.Clock = dsn.ClockFunc(func() time.Time { return now })
+	ctx := context.Background()
+	for i := 1; i <= 4; i++ {
+		ok, err := rl.Allow(ctx, testKey, 3, time.Minute)
+		if err != nil || ok != (i <= 3) {
+			t.Errorf("Request %d: Expected -- %v -- Got %v %v", i, i <= 3, ok, err)
+		}
+	}
+	now = now.Add(time.Minute)
+	if ok, _ := rl.Allow(ctx, testKey, 3, time.Minute); !ok {
+		t.Errorf("Expected -- new window allowed -- Got limited")
+	}
+	if ok, _ := rl.Allow(ctx, testKey, 0, time.Minute); !ok {
+		t.Errorf("Expected -- unlimited -- Got limited")
+	}
+	mr.Close()
+	if _, err := rl.Allow(ctx, testKey, 3, time.Minute); err == nil {
+		t.Errorf("Expected -- connection error -- Got nil")
+	}
+}
@@ -0,0 +1,9 @@
+module github.com/dgbailey/dsn/dsnyaml
+
+go 1.25.0
+
+replace github.com/dgbailey/dsn => ../
+
+require github.com/dgbailey/dsn v0.0.0
+
+require gopkg.in/yaml.v3 v3.0.1
@@ -0,0 +1,67 @@
+// Package dsnyaml reads dsn.Config from YAML files, keeping the YAML dependency out of the core package.
+package dsnyaml
+
+import (
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+
+	"github.com/dgbailey/dsn"
+	"gopkg.in/yaml.v3"
+)
+
+func DecodeConfig(data []byte) (*dsn.Config, error) {
+	/*
+		Reads a YAML Config. Keys are the JSON field names; the document is converted to JSON and handed to
+		dsn.DecodeConfig, so defaults, unknown field checks and validation are the same.
+	*/
+	var doc interface{}
+	if err := yaml.Unmarshal(data, &doc); err != nil {
+		return nil, fmt.Errorf("dsn: config: %w", err)
+	}
+	if doc == nil {
+		doc = map[string]interface{}{}
+	}
+	b, err := json.Marshal(stringKeys(doc))
+	if err != nil {
+		return nil, fmt.Errorf("dsn: config: %w", err)
+	}
+	return dsn.DecodeConfig(b)
+}
+
+func stringKeys(v interface{}) interface{} {
+	/*
+		YAML allows keys JSON does not, like unquoted project IDs (42:), which decode as numbers.
+	*/
+	switch v := v.(type) {
+	case map[string]interface{}:
+		for k, e := range v {
+			v[k] = stringKeys(e)
+		}
+		return v
+	case map[interface{}]interface{}:
+		m := make(map[string]interface{}, len(v))
+		for k, e := range v {
+			m[fmt.Sprint(k)] = stringKeys(e)
+		}
+		return m
+	case []interface{}:
+		for i, e := range v {
+			v[i] = stringKeys(e)
+		}
+		return v
+	default:
+		return v
+	}
+}
+
+func LoadConfig(path string) (*dsn.Config, error) {
+	/*
+		DecodeConfig on the contents of a YAML file.
+	*/
+	b, err := ioutil.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	return DecodeConfig(b)
+}
@@ -0,0 +1,69 @@
+package dsnyaml
+
+import (
+	"errors"
+	"io/ioutil"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/dgbailey/dsn"
+)
+
+const testConfig = `
+parsing:
+  sources: [header, query]
+  query_secret: warn
+  resolver_timeout: 500ms
+upstreams:
+  default: https://4784fbc50de2473f9977cfce8a9adce5@o1.ingest.sentry.io/1
+filters:
+  releases: ["*-dev"]
+  projects:
+    42:
+      web_crawlers: true
+forwarder:
+  workers: 4
+`
+
+type testDecode struct {
+	config      string
+	description string
+	valid       bool
+}
+
+var testTableDecode = []testDecode{
+	{testConfig, "Testing full config", true},
+	{"", "Testing empty document", true},
+	{"parsing:\n  sourcez: [query]\n", "Testing unknown field", false},
+	{"parsing:\n  sources: [cookie]\n", "Testing validation", false},
+	{"parsing: [", "Testing broken YAML", false},
+}
+
+func TestDecodeConfig(t *testing.T) {
+	for _, test := range testTableDecode {
+		_, err := DecodeConfig([]byte(test.config))
+		if (err == nil) != test.valid {
+			t.Errorf("%s: Expected -- valid %v -- Got %v", test.description, test.valid, err)
+		}
+	}
+}
+
+func TestLoadConfig(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "relay.yaml")
+	ioutil.WriteFile(path, []byte(testConfig), 0o600)
+	cfg, err := LoadConfig(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if cfg.Parsing.ResolverTimeout != dsn.Duration(500*time.Millisecond) || cfg.Forwarder.Workers != 4 || cfg.Forwarder.QueueSize != 1000 {
+		t.Errorf("Expected -- values and defaults -- Got %+v", cfg)
+	}
+	if !cfg.Filters.Projects["42"].WebCrawlers {
+		t.Errorf("Testing numeric key: Expected -- project 42 -- Got %+v", cfg.Filters.Projects)
+	}
+	var verr *dsn.ValidationError
+	if _, err := DecodeConfig([]byte("limits:\n  concurrency_per_dsn: 2\n")); !errors.As(err, &verr) {
+		t.Errorf("Expected -- *dsn.ValidationError -- Got %v", err)
+	}
+}
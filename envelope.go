@@ -0,0 +1,92 @@
+package dsn
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"strconv"
+)
+
+// ErrInvalidEnvelope Thrown when an envelope body cannot be split into its header and items
+var ErrInvalidEnvelope = errors.New("sentry:  invalid envelope")
+
+// envelopeItem is one item of an envelope: its JSON header line and the raw payload.
+type envelopeItem struct {
+	header  []byte
+	payload []byte
+}
+
+func splitEnvelope(b []byte) (header []byte, items []envelopeItem, err error) {
+	/*
+		Splits an envelope (https://develop.sentry.dev/sdk/envelopes/) into its header line and items.
+		Payloads are sized by the "length" of their item header, or run to the next newline without one.
+	*/
+	header, b = cutLine(b)
+	if !json.Valid(header) {
+		return nil, nil, ErrInvalidEnvelope
+	}
+	for len(bytes.TrimSpace(b)) > 0 {
+		var item envelopeItem
+		item.header, b = cutLine(b)
+		var h struct {
+			Length *int `json:"length"`
+		}
+		if err := json.Unmarshal(item.header, &h); err != nil {
+			return nil, nil, ErrInvalidEnvelope
+		}
+		if h.Length == nil {
+			item.payload, b = cutLine(b)
+		} else {
+			if *h.Length < 0 || *h.Length > len(b) {
+				return nil, nil, ErrInvalidEnvelope
+			}
+			item.payload, b = b[:*h.Length], b[*h.Length:]
+			if len(b) > 0 && b[0] == '\n' {
+				b = b[1:]
+			}
+		}
+		items = append(items, item)
+	}
+	return header, items, nil
+}
+
+func cutLine(b []byte) (line []byte, rest []byte) {
+	if i := bytes.IndexByte(b, '\n'); i >= 0 {
+		return b[:i], b[i+1:]
+	}
+	return b, nil
+}
+
+func (item *envelopeItem) setPayload(payload []byte) error {
+	/*
+		Replaces the payload and keeps an explicit "length" in the item header in sync.
+	*/
+	item.payload = payload
+	var h map[string]json.RawMessage
+	if err := json.Unmarshal(item.header, &h); err != nil {
+		return ErrInvalidEnvelope
+	}
+	if _, ok := h["length"]; !ok {
+		return nil
+	}
+	h["length"] = json.RawMessage(strconv.Itoa(len(payload)))
+	header, err := json.Marshal(h)
+	if err != nil {
+		return err
+	}
+	item.header = header
+	return nil
+}
+
+func joinEnvelope(header []byte, items []envelopeItem) []byte {
+	var buf bytes.Buffer
+	buf.Write(header)
+	buf.WriteByte('\n')
+	for _, item := range items {
+		buf.Write(item.header)
+		buf.WriteByte('\n')
+		buf.Write(item.payload)
+		buf.WriteByte('\n')
+	}
+	return buf.Bytes()
+}
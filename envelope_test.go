@@ -0,0 +1,57 @@
+package dsn
+
+import (
+	"testing"
+)
+
+type testEnvelope struct {
+	body        string
+	description string
+	payloads    []string
+	err         error
+}
+
+var testTableEnvelope = []testEnvelope{
+	{"{}\n{\"type\":\"event\"}\n{\"a\":1}\n", "Testing newline delimited item", []string{`{"a":1}`}, nil},
+	{"{}\n{\"type\":\"attachment\",\"length\":3}\na\nb\n{\"type\":\"event\"}\n{}", "Testing sized item with newline", []string{"a\nb", "{}"}, nil},
+	{"{}\n", "Testing no items", nil, nil},
+	{"{}\n{\"type\":\"attachment\",\"length\":30}\nab\n", "Testing length past end", nil, ErrInvalidEnvelope},
+	{"not json\n", "Testing invalid header", nil, ErrInvalidEnvelope},
+}
+
+func TestSplitEnvelope(t *testing.T) {
+	for _, test := range testTableEnvelope {
+		header, items, err := splitEnvelope([]byte(test.body))
+		if err != test.err {
+			t.Errorf("%s: Expected -- %v -- Got %v", test.description, test.err, err)
+			continue
+		}
+		if err != nil {
+			continue
+		}
+		if len(items) != len(test.payloads) {
+			t.Errorf("%s: Expected -- %d items -- Got %d", test.description, len(test.payloads), len(items))
+			continue
+		}
+		for i, item := range items {
+			if string(item.payload) != test.payloads[i] {
+				t.Errorf("%s: Expected -- %q -- Got %q", test.description, test.payloads[i], item.payload)
+			}
+		}
+		again, _, _ := splitEnvelope(joinEnvelope(header, items))
+		if string(again) != string(header) {
+			t.Errorf("%s: Expected -- round trip -- Got %q", test.description, again)
+		}
+	}
+}
+
+func TestSetPayloadLength(t *testing.T) {
+	item := envelopeItem{header: []byte(`{"type":"attachment","length":3}`), payload: []byte("abc")}
+	if err := item.setPayload([]byte("abcdef")); err != nil || string(item.header) != `{"length":6,"type":"attachment"}` {
+		t.Errorf("Expected -- length 6 -- Got %s %v", item.header, err)
+	}
+	item = envelopeItem{header: []byte(`{"type":"event"}`)}
+	if err := item.setPayload([]byte("{}")); err != nil || string(item.header) != `{"type":"event"}` {
+		t.Errorf("Expected -- header unchanged -- Got %s %v", item.header, err)
+	}
+}
@@ -0,0 +1,78 @@
+package dsn
+
+import (
+	"errors"
+	"strings"
+)
+
+// ValidationError lists every problem found in a request when CollectAllErrors is set.
+// errors.Is and errors.As see through it to the individual errors.
+type ValidationError struct {
+	Errors []error
+}
+
+func (e *ValidationError) Error() string {
+	msgs := make([]string, len(e.Errors))
+	for i, err := range e.Errors {
+		msgs[i] = err.Error()
+	}
+	return strings.Join(msgs, "; ")
+}
+
+func (e *ValidationError) Unwrap() []error {
+	return e.Errors
+}
+
+// Is and As walk Errors themselves, Go before 1.20 does not look at Unwrap() []error.
+
+func (e *ValidationError) Is(target error) bool {
+	for _, err := range e.Errors {
+		if errors.Is(err, target) {
+			return true
+		}
+	}
+	return false
+}
+
+func (e *ValidationError) As(target interface{}) bool {
+	for _, err := range e.Errors {
+		if errors.As(err, target) {
+			return true
+		}
+	}
+	return false
+}
+
+func CollectAllErrors() Option {
+	/*
+		Keep validating after the first failure and return a *ValidationError listing everything that is wrong.
+		Meant for SDK developers debugging against a relay; production setups usually want the cheaper fail-fast default.
+	*/
+	return func(c *config) {
+		c.collectAll = true
+	}
+}
+
+type errorCollector struct {
+	all  bool
+	errs []error
+}
+
+func (ec *errorCollector) add(err error) (stop bool) {
+	/*
+		Records err and reports whether parsing should stop right away.
+	*/
+	ec.errs = append(ec.errs, err)
+	return !ec.all
+}
+
+func (ec *errorCollector) err() error {
+	switch {
+	case len(ec.errs) == 0:
+		return nil
+	case !ec.all:
+		return ec.errs[0]
+	default:
+		return &ValidationError{Errors: ec.errs}
+	}
+}
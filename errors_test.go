@@ -0,0 +1,47 @@
+package dsn
+
+import (
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func TestCollectAllErrors(t *testing.T) {
+	r := httptest.NewRequest("POST", "https://sentry.io/apistore/?sentry_secret=4784fbc50de2473f9977cfce8a9adce5", nil)
+
+	_, err := FromRequest(r)
+	if err != ErrMissingUser {
+		t.Errorf("Expected -- %s -- Got %v", ErrMissingUser, err)
+	}
+
+	_, err = FromRequest(r, CollectAllErrors())
+	var verr *ValidationError
+	if !errors.As(err, &verr) || len(verr.Errors) != 2 {
+		t.Fatalf("Expected -- 2 errors -- Got %v", err)
+	}
+	if !errors.Is(err, ErrMissingUser) || !errors.Is(err, ErrMissingProjectID) {
+		t.Errorf("Expected -- %s and %s -- Got %v", ErrMissingUser, ErrMissingProjectID, err)
+	}
+	// what errors.Is and errors.As rely on before Go 1.20
+	var serr *SizeError
+	verr = &ValidationError{Errors: []error{ErrMissingUser, &SizeError{Limit: 1}}}
+	if !verr.Is(ErrMissingUser) || !verr.Is(ErrPayloadTooLarge) || verr.Is(ErrMissingProjectID) || !verr.As(&serr) || serr.Limit != 1 {
+		t.Errorf("Expected -- Is and As to see the listed errors -- Got %v", verr)
+	}
+	if ErrorStatus(err) != http.StatusUnauthorized {
+		t.Errorf("Expected -- %d -- Got %d", http.StatusUnauthorized, ErrorStatus(err))
+	}
+}
+
+func TestCollectAllErrorsValid(t *testing.T) {
+	for _, test := range testTableLegacyUserInfo {
+		r := httptest.NewRequest("POST", test.url, nil)
+		r.Header.Set("X-SENTRY-AUTH", strings.Join(test.header, ","))
+		got, err := FromRequest(r, CollectAllErrors())
+		if err != nil || got.URL != test.expected {
+			t.Errorf("%s: Expected -- %s -- Got %v %v", test.description, test.expected, got, err)
+		}
+	}
+}
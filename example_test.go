@@ -0,0 +1,72 @@
+package dsn_test
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+
+	"github.com/dgbailey/dsn"
+)
+
+func ExampleParse() {
+	d, err := dsn.Parse("https://4784fbc50de2473f9977cfce8a9adce5@o1.ingest.sentry.io/42")
+	if err != nil {
+		panic(err)
+	}
+	fmt.Println(d.Host, d.ProjectID, d.PublicKey)
+	_, err = dsn.Parse("https://o1.ingest.sentry.io/42")
+	fmt.Println(errors.Is(err, dsn.ErrMissingUser), dsn.ErrorCode(err))
+	// Output:
+	// o1.ingest.sentry.io 42 4784fbc50de2473f9977cfce8a9adce5
+	// true missing_public_key
+}
+
+func ExampleNew() {
+	d, err := dsn.New(dsn.WithPublicKey("4784fbc50de2473f9977cfce8a9adce5"), dsn.WithHost("localhost"), dsn.WithPort(9000),
+		dsn.WithScheme("http"), dsn.WithProjectID("1"))
+	fmt.Println(d, err)
+	// Output: http://4784fbc50de2473f9977cfce8a9adce5@localhost:9000/1 <nil>
+}
+
+func ExampleParseAuthHeaderValue() {
+	a, err := dsn.ParseAuthHeaderValue("Sentry sentry_version=7, sentry_client=sentry.go/0.20.0, sentry_key=4784fbc50de2473f9977cfce8a9adce5")
+	if err != nil {
+		panic(err)
+	}
+	fmt.Println(a.PublicKey, a.Version, a.Client)
+	// Output: 4784fbc50de2473f9977cfce8a9adce5 7 sentry.go/0.20.0
+}
+
+func ExampleParser_ParseRequest() {
+	p := dsn.NewParser(dsn.WithAuthHeader("X-Relay-Auth"))
+	r := httptest.NewRequest("POST", "https://relay.example.com/api/42/envelope/", nil)
+	r.Header.Set("X-Relay-Auth", "Sentry sentry_key=4784fbc50de2473f9977cfce8a9adce5, sentry_version=7")
+	res, err := p.ParseRequest(context.Background(), r)
+	if err != nil {
+		panic(err)
+	}
+	fmt.Println(res.DSN, res.Endpoint, res.KeySource)
+	// Output: https://4784fbc50de2473f9977cfce8a9adce5@relay.example.com/42 envelope header
+}
+
+func ExampleNewMiddleware() {
+	handler := dsn.NewMiddleware()(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		d, _ := dsn.FromContext(r.Context())
+		fmt.Println("accepted", d.ProjectID)
+	}))
+	for _, u := range []string{
+		"https://sentry.io/api/1/store/?sentry_key=4784fbc50de2473f9977cfce8a9adce5",
+		"https://sentry.io/api/1/store/",
+	} {
+		w := httptest.NewRecorder()
+		handler.ServeHTTP(w, httptest.NewRequest("POST", u, nil))
+		if w.Code != http.StatusOK {
+			fmt.Println("rejected", w.Code, w.Header().Get(dsn.X_SENTRY_ERROR))
+		}
+	}
+	// Output:
+	// accepted 1
+	// rejected 401 sentry:  missing public key
+}
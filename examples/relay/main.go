@@ -0,0 +1,203 @@
+// Command relay is a minimal Sentry relay assembled from the dsn package: a Config file wires up
+// parser, keystore, tenant registry and forwarder, and this program adds what the package leaves to its
+// callers, enforcing the per tenant rate limits of the registry, and runs the HTTP server.
+//
+//	cd examples/relay && go run . -config relay.json -listen :3000
+//
+// SDKs then use DSNs pointing at the relay, e.g. http://<public key>@localhost:3000/1.
+// Stats are served on /_dsn/stats. SIGHUP reloads the registry, SIGINT and SIGTERM drain the queue and exit.
+package main
+
+import (
+	"context"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"log"
+	"net/http"
+	"os"
+	"os/signal"
+	"sync"
+	"syscall"
+	"time"
+
+	"github.com/dgbailey/dsn"
+)
+
+// errRateLimited is answered to tenants over their rate limit.
+var errRateLimited = errors.New("sentry:  rate limit exceeded")
+
+// relay is the running program: the components Build made plus the ones added here.
+type relay struct {
+	*dsn.Relay
+	stats   *dsn.Stats
+	limiter *rateLimiter
+	handler http.Handler
+}
+
+func newRelay(cfg *dsn.Config, logger dsn.Logger, opts ...dsn.Option) (*relay, error) {
+	/*
+		Builds cfg and mounts the ingest handler on /api/ and the stats on dsn.DEBUG_STATS_PATH.
+		The rate limiter sits between the parser, which puts the tenant into the request context,
+		and the pipeline, so limited submissions are never queued.
+	*/
+	stats := dsn.NewStats(opts...)
+	opts = append([]dsn.Option{dsn.WithLogger(logger), dsn.WithStats(stats)}, opts...)
+	built, err := cfg.Build(opts...)
+	if err != nil {
+		return nil, err
+	}
+	limiter := newRateLimiter(dsn.SystemClock)
+	limiter.Register(stats)
+	if built.Breaker != nil {
+		built.Breaker.Register(stats)
+	}
+	mux := http.NewServeMux()
+	mux.Handle("/api/", built.Parser.Middleware(limiter.Middleware(built.Pipeline.Handler())))
+	mux.Handle(dsn.DEBUG_STATS_PATH, stats.Handler())
+	return &relay{Relay: built, stats: stats, limiter: limiter, handler: mux}, nil
+}
+
+func (rl *relay) run(ctx context.Context) {
+	/*
+		Background work until ctx is done: keystore reloads and spool replays.
+	*/
+	if rl.Keystore != nil {
+		go rl.Keystore.Watch(ctx, 10*time.Second)
+	}
+	if rl.Spool != nil {
+		go rl.Spool.Run(ctx, 30*time.Second)
+	}
+}
+
+func (rl *relay) shutdown(ctx context.Context) error {
+	/*
+		Drains the queue; undelivered submissions are logged by count, a spool keeps them on disk.
+	*/
+	dropped, err := rl.Pipeline.Shutdown(ctx)
+	if len(dropped) > 0 {
+		return fmt.Errorf("relay: %d submissions not delivered: %v", len(dropped), err)
+	}
+	return err
+}
+
+// rateLimiter enforces Tenant.RateLimit, submissions per minute and public key, in fixed windows.
+// Relays sharing tenants across processes would use dsnredis.RateLimiter instead.
+type rateLimiter struct {
+	clock  dsn.Clock
+	mu     sync.Mutex
+	window int64            //Unix minute of counts
+	counts map[string]int64 //per public key
+	denied int64
+}
+
+func newRateLimiter(clock dsn.Clock) *rateLimiter {
+	return &rateLimiter{clock: clock, counts: map[string]int64{}}
+}
+
+func (l *rateLimiter) allow(t *dsn.Tenant) (bool, time.Duration) {
+	/*
+		Counts a submission of t. When it is over the limit, also says how long until the next window.
+	*/
+	now := l.clock.Now()
+	minute := now.Unix() / 60
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	if minute != l.window {
+		l.window, l.counts = minute, map[string]int64{}
+	}
+	if l.counts[t.PublicKey] >= int64(t.RateLimit) {
+		l.denied++
+		return false, time.Unix((minute+1)*60, 0).Sub(now)
+	}
+	l.counts[t.PublicKey]++
+	return true, 0
+}
+
+func (l *rateLimiter) Middleware(next http.Handler) http.Handler {
+	/*
+		Answers 429 with X-Sentry-Rate-Limits to tenants over their limit. Requests without a tenant
+		(no registry configured) or with RateLimit 0 pass.
+	*/
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		t, ok := dsn.TenantFromContext(r.Context())
+		if ok && t.RateLimit > 0 {
+			if allowed, retry := l.allow(t); !allowed {
+				dsn.WriteRateLimits(w, dsn.RateLimits{{RetryAfter: retry, Scope: "key", ReasonCode: "rate_limited"}})
+				w.Header().Set(dsn.X_SENTRY_ERROR, errRateLimited.Error())
+				http.Error(w, errRateLimited.Error(), http.StatusTooManyRequests)
+				return
+			}
+		}
+		next.ServeHTTP(w, r)
+	})
+}
+
+func (l *rateLimiter) Register(s *dsn.Stats) {
+	s.Register("rate_limiter", func() interface{} {
+		l.mu.Lock()
+		defer l.mu.Unlock()
+		return map[string]int64{"keys": int64(len(l.counts)), "denied": l.denied}
+	})
+}
+
+func main() {
+	os.Exit(run(os.Args[1:], os.Stderr))
+}
+
+func run(args []string, stderr io.Writer) int {
+	fs := flag.NewFlagSet("relay", flag.ContinueOnError)
+	fs.SetOutput(stderr)
+	path := fs.String("config", "relay.json", "relay config file, see dsn.Config")
+	listen := fs.String("listen", ":3000", "address to serve SDKs on")
+	if err := fs.Parse(args); err != nil {
+		return 2
+	}
+	logger := log.New(stderr, "relay: ", log.LstdFlags)
+	cfg, err := dsn.LoadConfig(*path)
+	if err != nil {
+		logger.Print(err)
+		return 1
+	}
+	rl, err := newRelay(cfg, logger)
+	if err != nil {
+		logger.Print(err)
+		return 1
+	}
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	rl.run(ctx)
+
+	srv := &http.Server{Addr: *listen, Handler: rl.handler}
+	signals := make(chan os.Signal, 1)
+	signal.Notify(signals, syscall.SIGHUP, syscall.SIGINT, syscall.SIGTERM)
+	drained := make(chan struct{})
+	go func() {
+		defer close(drained)
+		for sig := range signals {
+			if sig == syscall.SIGHUP {
+				if rl.Registry != nil {
+					if err := rl.Registry.Reload(); err != nil {
+						logger.Printf("registry not reloaded: %v", err)
+					}
+				}
+				continue
+			}
+			shutdown, done := context.WithTimeout(context.Background(), 30*time.Second)
+			srv.Shutdown(shutdown)
+			if err := rl.shutdown(shutdown); err != nil {
+				logger.Print(err)
+			}
+			done()
+			return
+		}
+	}()
+	logger.Printf("listening on %s", *listen)
+	if err := srv.ListenAndServe(); err != http.ErrServerClosed {
+		logger.Print(err)
+		return 1
+	}
+	<-drained
+	return 0
+}
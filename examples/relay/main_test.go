@@ -0,0 +1,150 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"io/ioutil"
+	"log"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/dgbailey/dsn"
+)
+
+const (
+	testKeyA = "4784fbc50de2473f9977cfce8a9adce5"
+	testKeyB = "aaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaa"
+)
+
+func TestExampleConfig(t *testing.T) {
+	cfg, err := dsn.LoadConfig("relay.json")
+	if err != nil {
+		t.Fatalf("Expected -- shipped config to load -- Got %v", err)
+	}
+	rl, err := newRelay(cfg, log.New(ioutil.Discard, "", 0))
+	if err != nil {
+		t.Fatalf("Expected -- shipped config to build -- Got %v", err)
+	}
+	rl.shutdown(context.Background())
+}
+
+// testUpstream records what the relay forwards.
+type testUpstream struct {
+	mu       sync.Mutex
+	received []*http.Request
+	bodies   []string
+}
+
+func (u *testUpstream) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	b, _ := ioutil.ReadAll(r.Body)
+	u.mu.Lock()
+	u.received = append(u.received, r)
+	u.bodies = append(u.bodies, string(b))
+	u.mu.Unlock()
+	w.Write([]byte(`{"id":"9ec79c33ec9942ab8353589fcb2e04dc"}`))
+}
+
+func newTestRelay(t *testing.T, upstream string) *relay {
+	dir := t.TempDir()
+	write := func(name, content string) string {
+		path := filepath.Join(dir, name)
+		if err := ioutil.WriteFile(path, []byte(content), 0600); err != nil {
+			t.Fatal(err)
+		}
+		return path
+	}
+	keys := write("keys.json", `{"keys": [{"public_key": "`+testKeyA+`", "project_id": "1"}, {"public_key": "`+testKeyB+`", "project_id": "2"}]}`)
+	tenants := write("tenants.json", `{"tenants": [{"public_key": "`+testKeyA+`", "rate_limit": 2}, {"public_key": "`+testKeyB+`", "allowed_endpoints": ["store"]}]}`)
+	config := write("relay.json", `{"keystore": "`+keys+`", "registry": "`+tenants+`",
+		"upstreams": {"default": "`+strings.Replace(upstream, "://", "://"+testKeyB+"@", 1)+`/42"},
+		"forwarder": {"workers": 1, "normalize_envelopes": true}}`)
+	cfg, err := dsn.LoadConfig(config)
+	if err != nil {
+		t.Fatal(err)
+	}
+	rl, err := newRelay(cfg, log.New(ioutil.Discard, "", 0))
+	if err != nil {
+		t.Fatal(err)
+	}
+	return rl
+}
+
+type testRelayRequest struct {
+	path        string
+	key         string
+	description string
+	status      int
+	limits      string
+}
+
+var testTableRelay = []testRelayRequest{
+	{"/api/1/envelope/", testKeyA, "Testing accepted envelope", http.StatusOK, ""},
+	{"/api/1/envelope/", testKeyA, "Testing second envelope within the limit", http.StatusOK, ""},
+	{"/api/1/envelope/", testKeyA, "Testing tenant over its rate limit", http.StatusTooManyRequests, "60::key:rate_limited"},
+	{"/api/2/envelope/", testKeyB, "Testing endpoint the tenant does not allow", http.StatusForbidden, ""},
+	{"/api/1/envelope/", "bbbbbbbbbbbbbbbbbbbbbbbbbbbbbbbb", "Testing unknown key", http.StatusUnauthorized, ""},
+}
+
+func TestRelay(t *testing.T) {
+	up := &testUpstream{}
+	upstream := httptest.NewServer(up)
+	defer upstream.Close()
+	rl := newTestRelay(t, upstream.URL)
+	now := time.Unix(1600000020, 0)
+	rl.limiter.clock = dsn.ClockFunc(func() time.Time { return now })
+
+	for _, test := range testTableRelay {
+		body := `{"event_id":"9ec79c33ec9942ab8353589fcb2e04dc","dsn":"http://` + test.key + `@relay.local/1"}` + "\n" + `{"type":"event"}` + "\n{}\n"
+		r := httptest.NewRequest("POST", "http://relay.local"+test.path, bytes.NewBufferString(body))
+		r.Header.Set("X-Sentry-Auth", "Sentry sentry_version=7, sentry_key="+test.key)
+		w := httptest.NewRecorder()
+		rl.handler.ServeHTTP(w, r)
+		if w.Code != test.status {
+			t.Errorf("%s: Expected -- %d -- Got %d %s", test.description, test.status, w.Code, w.Body)
+		}
+		if got := w.Header().Get(dsn.X_SENTRY_RATE_LIMITS); got != test.limits {
+			t.Errorf("%s: Expected -- rate limits %q -- Got %q", test.description, test.limits, got)
+		}
+	}
+	if err := rl.shutdown(context.Background()); err != nil {
+		t.Fatalf("Expected -- queue drained -- Got %v", err)
+	}
+
+	up.mu.Lock()
+	defer up.mu.Unlock()
+	if len(up.received) != 2 {
+		t.Fatalf("Expected -- 2 forwarded envelopes -- Got %d", len(up.received))
+	}
+	r, body := up.received[0], up.bodies[0]
+	if r.URL.Path != "/api/42/envelope/" || !strings.Contains(r.Header.Get("X-Sentry-Auth"), "sentry_key="+testKeyB) {
+		t.Errorf("Expected -- forwarded to the upstream DSN -- Got %s %s", r.URL.Path, r.Header.Get("X-Sentry-Auth"))
+	}
+	var header map[string]string
+	json.Unmarshal([]byte(body[:strings.IndexByte(body, '\n')]), &header)
+	if !strings.HasSuffix(header["dsn"], "/42") || len(header["sent_at"]) == 0 {
+		t.Errorf("Expected -- envelope header normalized -- Got %v", header)
+	}
+
+	w := httptest.NewRecorder()
+	rl.handler.ServeHTTP(w, httptest.NewRequest("GET", dsn.DEBUG_STATS_PATH, nil))
+	if !strings.Contains(w.Body.String(), `"rate_limiter"`) || !strings.Contains(w.Body.String(), `"denied": 1`) {
+		t.Errorf("Expected -- rate limiter in the stats -- Got %s", w.Body)
+	}
+}
+
+func TestRunUsage(t *testing.T) {
+	var stderr bytes.Buffer
+	if code := run([]string{"-config", filepath.Join(os.TempDir(), "does-not-exist.json")}, &stderr); code != 1 {
+		t.Errorf("Expected -- exit code 1 -- Got %d %s", code, stderr.String())
+	}
+	if code := run([]string{"-nope"}, &stderr); code != 2 {
+		t.Errorf("Expected -- exit code 2 -- Got %d", code)
+	}
+}
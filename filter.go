@@ -0,0 +1,254 @@
+package dsn
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"strings"
+	"sync"
+)
+
+// DefaultFilterBodyLimit is how much of a body WithFilters reads to find the release.
+var DefaultFilterBodyLimit int64 = 64 << 10
+
+// Reasons reported with OutcomeFiltered by the built in filters, named like Sentry's inbound filters.
+const (
+	FilterReasonRelease    = "release-version"
+	FilterReasonUserAgent  = "user-agent"
+	FilterReasonWebCrawler = "web-crawlers"
+	FilterReasonIP         = "ip-address"
+)
+
+// FilterInput is what filters decide on: the parse result plus metadata that is cheap to get without
+// decoding the whole event.
+type FilterInput struct {
+	Result    *ParseResult
+	Meta      *EventMeta //nil when the body was not inspected
+	UserAgent string
+	ClientIP  net.IP //ParseResult.ClientIP, or the peer address without WithTrustedProxies
+}
+
+func NewFilterInput(r *http.Request, res *ParseResult) *FilterInput {
+	/*
+		Collects the filter input of a parsed request. The release is read from the first DefaultFilterBodyLimit
+		bytes of store and envelope bodies; r.Body is restored for downstream handlers.
+	*/
+	in := &FilterInput{Result: res, UserAgent: r.UserAgent(), ClientIP: res.ClientIP}
+	if in.ClientIP == nil {
+		in.ClientIP = parseHop(r.RemoteAddr)
+	}
+	switch {
+	case res.payload != nil:
+		in.Meta, _ = ExtractEventMeta(bytes.NewReader(res.payload), int64(len(res.payload)))
+	case res.Endpoint == EndpointStore, res.Endpoint == EndpointEnvelope:
+		NewLimitedPeeker(DefaultFilterBodyLimit).PeekFunc(r, func(raw io.Reader) error {
+			body, err := decodeBody(r.Header.Get("Content-Encoding"), raw)
+			if err != nil {
+				return err
+			}
+			// a truncated body still yields the fields found before the cut
+			in.Meta, _ = ExtractEventMeta(body, maxDecodedRatio*DefaultFilterBodyLimit)
+			return nil
+		})
+	}
+	return in
+}
+
+// Filter drops submissions. A dropping filter returns the reason reported with the filtered outcome.
+type Filter interface {
+	Filter(in *FilterInput) (reason string, drop bool)
+}
+
+// FilterFunc adapts a function to Filter.
+type FilterFunc func(in *FilterInput) (string, bool)
+
+func (f FilterFunc) Filter(in *FilterInput) (string, bool) {
+	return f(in)
+}
+
+// FilterRules configures the built in filters. Patterns use * for any run of characters.
+type FilterRules struct {
+	Releases    []string `json:"releases,omitempty"`
+	UserAgents  []string `json:"user_agents,omitempty"` //case insensitive
+	WebCrawlers bool     `json:"web_crawlers,omitempty"`
+	ClientIPs   []string `json:"client_ips,omitempty"` //addresses or CIDR ranges
+}
+
+// crawlerMarkers are lower case user agent fragments of well known crawlers and uptime checkers.
+var crawlerMarkers = []string{
+	"bot/", "bot;", "bot)", "spider", "crawler", "slurp", "mediapartners-google", "feedfetcher-google",
+	"bingpreview", "facebookexternalhit", "ia_archiver", "pingdom", "lyticsbot", "aws security scanner",
+}
+
+// compiledRules are FilterRules ready to match.
+type compiledRules struct {
+	releases   []glob
+	userAgents []glob
+	crawlers   bool
+	nets       []*net.IPNet
+}
+
+func (fr FilterRules) compile() (*compiledRules, error) {
+	cr := &compiledRules{crawlers: fr.WebCrawlers}
+	for _, p := range fr.Releases {
+		cr.releases = append(cr.releases, compileGlob(p))
+	}
+	for _, p := range fr.UserAgents {
+		cr.userAgents = append(cr.userAgents, compileGlob(strings.ToLower(p)))
+	}
+	nets, err := parseNets(fr.ClientIPs)
+	if err != nil {
+		return nil, fmt.Errorf("dsn: filter client IP %w", err)
+	}
+	cr.nets = nets
+	return cr, nil
+}
+
+func (cr *compiledRules) check(in *FilterInput) (string, bool) {
+	if in.Meta != nil && len(in.Meta.Release) > 0 {
+		for _, g := range cr.releases {
+			if g.match(in.Meta.Release) {
+				return FilterReasonRelease, true
+			}
+		}
+	}
+	ua := strings.ToLower(in.UserAgent)
+	if len(ua) > 0 {
+		for _, g := range cr.userAgents {
+			if g.match(ua) {
+				return FilterReasonUserAgent, true
+			}
+		}
+		if cr.crawlers {
+			for _, m := range crawlerMarkers {
+				if strings.Contains(ua, m) {
+					return FilterReasonWebCrawler, true
+				}
+			}
+		}
+	}
+	if in.ClientIP != nil {
+		for _, n := range cr.nets {
+			if n.Contains(in.ClientIP) {
+				return FilterReasonIP, true
+			}
+		}
+	}
+	return "", false
+}
+
+// FilterChain drops submissions the way Sentry's inbound filters do, before they are forwarded.
+// Projects get their own FilterRules, which replace the defaults; custom Filters run after the rules.
+// Every drop is recorded as OutcomeFiltered with the filter's reason. A FilterChain is safe for concurrent use.
+type FilterChain struct {
+	Recorder *OutcomeRecorder //optional
+
+	filters  []Filter
+	mu       sync.RWMutex
+	defaults *compiledRules
+	projects map[string]*compiledRules
+}
+
+func NewFilterChain(defaults FilterRules, filters ...Filter) (*FilterChain, error) {
+	cr, err := defaults.compile()
+	if err != nil {
+		return nil, err
+	}
+	return &FilterChain{filters: filters, defaults: cr, projects: map[string]*compiledRules{}}, nil
+}
+
+func (fc *FilterChain) SetProject(projectID string, rules FilterRules) error {
+	/*
+		Rules for one project, replacing the defaults for it. Typically fed from ProjectConfig.FilterRules.
+	*/
+	cr, err := rules.compile()
+	if err != nil {
+		return err
+	}
+	fc.mu.Lock()
+	fc.projects[projectID] = cr
+	fc.mu.Unlock()
+	return nil
+}
+
+func (fc *FilterChain) RemoveProject(projectID string) {
+	fc.mu.Lock()
+	delete(fc.projects, projectID)
+	fc.mu.Unlock()
+}
+
+func (fc *FilterChain) Check(in *FilterInput) (string, bool) {
+	/*
+		Runs the project's rules and the custom filters. The first drop is recorded and its reason returned.
+	*/
+	var projectID string
+	if in.Result != nil && in.Result.DSN != nil {
+		projectID = in.Result.DSN.ProjectID
+	}
+	fc.mu.RLock()
+	cr, ok := fc.projects[projectID]
+	if !ok {
+		cr = fc.defaults
+	}
+	fc.mu.RUnlock()
+	reason, drop := cr.check(in)
+	for i := 0; !drop && i < len(fc.filters); i++ {
+		reason, drop = fc.filters[i].Filter(in)
+	}
+	if drop && fc.Recorder != nil && in.Result != nil && in.Result.DSN != nil {
+		category := in.Result.Category
+		if len(category) == 0 {
+			category = CategoryError
+		}
+		fc.Recorder.Record(in.Result.DSN, category, OutcomeFiltered, reason, 1)
+	}
+	return reason, drop
+}
+
+// filterSettings is the part of Relay's filterSettings that maps onto FilterRules.
+type filterSettings struct {
+	Releases *struct {
+		Releases []string `json:"releases"`
+	} `json:"releases"`
+	ClientIPs *struct {
+		BlacklistedIPs []string `json:"blacklistedIps"`
+	} `json:"clientIps"`
+	WebCrawlers *struct {
+		IsEnabled bool `json:"isEnabled"`
+	} `json:"webCrawlers"`
+}
+
+func (pc *ProjectConfig) FilterRules() (FilterRules, error) {
+	/*
+		The release, client IP and web crawler filters of the upstream's filterSettings. Other filters are ignored.
+	*/
+	var rules FilterRules
+	if len(pc.Filters) == 0 {
+		return rules, nil
+	}
+	var fs filterSettings
+	if err := json.Unmarshal(pc.Filters, &fs); err != nil {
+		return rules, err
+	}
+	if fs.Releases != nil {
+		rules.Releases = fs.Releases.Releases
+	}
+	if fs.ClientIPs != nil {
+		rules.ClientIPs = fs.ClientIPs.BlacklistedIPs
+	}
+	rules.WebCrawlers = fs.WebCrawlers != nil && fs.WebCrawlers.IsEnabled
+	return rules, nil
+}
+
+func WithFilters(fc *FilterChain) Option {
+	/*
+		The middleware answers filtered submissions like accepted ones, 200 with the event ID, so SDKs do not
+		retry them, and never calls the next handler.
+	*/
+	return func(c *config) {
+		c.filters = fc
+	}
+}
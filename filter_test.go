@@ -0,0 +1,121 @@
+package dsn
+
+import (
+	"encoding/json"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+var testFilterRules = FilterRules{
+	Releases:    []string{"app@0.*"},
+	UserAgents:  []string{"*curl/*"},
+	WebCrawlers: true,
+	ClientIPs:   []string{"10.0.0.0/8", "192.0.2.1"},
+}
+
+type testFilter struct {
+	release     string
+	userAgent   string
+	clientIP    string
+	description string
+	reason      string
+}
+
+var testTableFilter = []testFilter{
+	{"app@1.0.0", "Mozilla/5.0", "203.0.113.9", "Testing nothing filtered", ""},
+	{"app@0.9.1", "Mozilla/5.0", "203.0.113.9", "Testing release glob", FilterReasonRelease},
+	{"app@1.0.0", "CURL/8.1", "203.0.113.9", "Testing user agent case insensitive", FilterReasonUserAgent},
+	{"app@1.0.0", "Mozilla/5.0 (compatible; Googlebot/2.1)", "203.0.113.9", "Testing web crawler", FilterReasonWebCrawler},
+	{"app@1.0.0", "Mozilla/5.0", "10.1.2.3", "Testing CIDR", FilterReasonIP},
+	{"app@1.0.0", "Mozilla/5.0", "192.0.2.1", "Testing single address", FilterReasonIP},
+	{"", "", "", "Testing empty input", ""},
+}
+
+func TestFilterChain(t *testing.T) {
+	fc, err := NewFilterChain(testFilterRules)
+	if err != nil {
+		t.Fatal(err)
+	}
+	fc.Recorder = NewOutcomeRecorder()
+	d, _ := Parse("https://" + testKeyA + "@sentry.io/1")
+	filtered := 0
+	for _, test := range testTableFilter {
+		in := &FilterInput{Result: &ParseResult{DSN: d}, Meta: &EventMeta{Release: test.release}, UserAgent: test.userAgent, ClientIP: net.ParseIP(test.clientIP)}
+		reason, drop := fc.Check(in)
+		if reason != test.reason || drop != (len(test.reason) > 0) {
+			t.Errorf("%s: Expected -- %q -- Got %q %v", test.description, test.reason, reason, drop)
+		}
+		if drop {
+			filtered++
+		}
+	}
+	total := int64(0)
+	for _, c := range fc.Recorder.Snapshot() {
+		if c.Outcome != OutcomeFiltered || c.Category != CategoryError {
+			t.Errorf("Expected -- filtered error outcomes -- Got %+v", c)
+		}
+		total += c.Quantity
+	}
+	if total != int64(filtered) {
+		t.Errorf("Expected -- %d filtered outcomes -- Got %d", filtered, total)
+	}
+}
+
+func TestFilterChainProjects(t *testing.T) {
+	custom := FilterFunc(func(in *FilterInput) (string, bool) {
+		return "custom", in.Result.Endpoint == EndpointSecurity
+	})
+	fc, _ := NewFilterChain(testFilterRules, custom)
+	if err := fc.SetProject("2", FilterRules{Releases: []string{"beta"}}); err != nil {
+		t.Fatal(err)
+	}
+	d1, _ := Parse("https://" + testKeyA + "@sentry.io/1")
+	d2, _ := Parse("https://" + testKeyA + "@sentry.io/2")
+	if _, drop := fc.Check(&FilterInput{Result: &ParseResult{DSN: d2}, Meta: &EventMeta{Release: "app@0.1"}}); drop {
+		t.Errorf("Testing project rules replace defaults: Expected -- kept -- Got filtered")
+	}
+	if _, drop := fc.Check(&FilterInput{Result: &ParseResult{DSN: d2}, Meta: &EventMeta{Release: "beta"}}); !drop {
+		t.Errorf("Testing project rules: Expected -- filtered -- Got kept")
+	}
+	fc.RemoveProject("2")
+	if _, drop := fc.Check(&FilterInput{Result: &ParseResult{DSN: d2}, Meta: &EventMeta{Release: "app@0.1"}}); !drop {
+		t.Errorf("Testing removed project: Expected -- defaults apply -- Got kept")
+	}
+	if reason, _ := fc.Check(&FilterInput{Result: &ParseResult{DSN: d1, Endpoint: EndpointSecurity}}); reason != "custom" {
+		t.Errorf("Testing custom filter: Expected -- custom -- Got %q", reason)
+	}
+	if _, err := NewFilterChain(FilterRules{ClientIPs: []string{"10.0.0.0/99"}}); err == nil {
+		t.Errorf("Testing invalid CIDR: Expected -- error -- Got nil")
+	}
+}
+
+func TestProjectConfigFilterRules(t *testing.T) {
+	pc := &ProjectConfig{Filters: json.RawMessage(`{"releases": {"releases": ["1.*"]}, "clientIps": {"blacklistedIps": ["127.0.0.1"]},
+		"webCrawlers": {"isEnabled": true}, "legacyBrowsers": {"isEnabled": true}}`)}
+	rules, err := pc.FilterRules()
+	if err != nil || len(rules.Releases) != 1 || rules.Releases[0] != "1.*" || len(rules.ClientIPs) != 1 || !rules.WebCrawlers {
+		t.Errorf("Expected -- releases, IPs and crawlers -- Got %+v %v", rules, err)
+	}
+}
+
+func TestFilterMiddleware(t *testing.T) {
+	fc, _ := NewFilterChain(FilterRules{Releases: []string{"app@1.*"}})
+	called := false
+	handler := NewMiddleware(WithFilters(fc))(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		called = true
+	}))
+	r := httptest.NewRequest("POST", "https://relay.example.com/api/1/store/?sentry_key="+testKeyA, strings.NewReader(testMetaEvent))
+	w := httptest.NewRecorder()
+	handler.ServeHTTP(w, r)
+	if called || w.Code != http.StatusOK || !strings.Contains(w.Body.String(), "9ec79c33ec9942ab8353589fcb2e04dc") {
+		t.Errorf("Testing filtered release: Expected -- 200 with event id, next skipped -- Got %d %s %v", w.Code, w.Body, called)
+	}
+	r = httptest.NewRequest("POST", "https://relay.example.com/api/1/store/?sentry_key="+testKeyA, strings.NewReader(testEvent))
+	handler.ServeHTTP(httptest.NewRecorder(), r)
+	if !called {
+		t.Errorf("Testing kept event: Expected -- next called -- Got skipped")
+	}
+}
@@ -0,0 +1,148 @@
+package dsn
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/binary"
+	"encoding/hex"
+	"sort"
+	"sync"
+)
+
+func (d *DSN) Equal(other *DSN, ignoreSecret bool) bool {
+	/*
+		Compares the components that identify a DSN: host, project and keys.
+		URL is derived from those so it is not compared. With ignoreSecret the secret key may differ,
+		which is what you want when comparing a v7 (public key only) client against an older one.
+	*/
+	if d == nil || other == nil {
+		return d == other
+	}
+	if d.Host != other.Host || d.ProjectID != other.ProjectID || d.PublicKey != other.PublicKey {
+		return false
+	}
+	return ignoreSecret || d.SecretKey == other.SecretKey
+}
+
+func (d *DSN) Fingerprint() string {
+	/*
+		Stable hex hash of public key, host and project. Never includes the secret key
+		so it is safe to log and to use as a map or metrics key.
+	*/
+	h := sha256.New()
+	h.Write([]byte(d.PublicKey))
+	h.Write([]byte{0})
+	h.Write([]byte(d.Host))
+	h.Write([]byte{0})
+	h.Write([]byte(d.ProjectID))
+	return hex.EncodeToString(h.Sum(nil)[:16])
+}
+
+func (d *DSN) Shard(n int) int {
+	/*
+		Maps d onto one of n shards (0 to n-1) from its Fingerprint, so every process routes a project's
+		traffic to the same worker or partition. Uses jump consistent hashing: growing n moves only about
+		1/n of the DSNs. n below 1 counts as 1.
+	*/
+	if n <= 1 {
+		return 0
+	}
+	sum := sha256.Sum256([]byte(d.Fingerprint()))
+	key := binary.BigEndian.Uint64(sum[:8])
+	b, j := int64(-1), int64(0)
+	for j < int64(n) {
+		b = j
+		key = key*2862933555777941757 + 1
+		j = int64(float64(b+1) * (float64(int64(1)<<31) / float64((key>>33)+1)))
+	}
+	return int(b)
+}
+
+func (d *DSN) Anonymized(salt []byte) *DSN {
+	/*
+		Returns a DSN-shaped identifier whose keys are replaced by HMAC-SHA256(salt, key),
+		truncated to the usual 32 hex characters. Host and project are kept so dashboards can still group by them.
+		The same salt always yields the same keys, so rotate it to unlink old telemetry.
+	*/
+	user := &User{PublicKey: anonymizeKey(salt, d.PublicKey), SecretKey: anonymizeKey(salt, d.SecretKey)}
+	return CreateDSN(user, d.Host, d.ProjectID)
+}
+
+func anonymizeKey(salt []byte, key string) string {
+	if len(key) == 0 {
+		return ""
+	}
+	mac := hmac.New(sha256.New, salt)
+	mac.Write([]byte(key))
+	return hex.EncodeToString(mac.Sum(nil)[:16])
+}
+
+// DedupEntry is one distinct DSN seen by a Deduper.
+type DedupEntry struct {
+	DSN   *DSN
+	Count int
+}
+
+// Deduper collapses a stream of parsed DSNs into distinct fingerprints with counts.
+// It is safe for concurrent use.
+type Deduper struct {
+	mu      sync.Mutex
+	max     int
+	entries map[string]*DedupEntry
+}
+
+func NewDeduper(max int) *Deduper {
+	/*
+		max bounds the number of distinct DSNs remembered, 0 means unbounded.
+		Once full, unseen DSNs are reported as new but not remembered.
+	*/
+	return &Deduper{max: max, entries: map[string]*DedupEntry{}}
+}
+
+func (dd *Deduper) Add(d *DSN) bool {
+	/*
+		Records d and reports whether it is the first time its fingerprint was seen.
+	*/
+	fp := d.Fingerprint()
+	dd.mu.Lock()
+	defer dd.mu.Unlock()
+	if e, ok := dd.entries[fp]; ok {
+		e.Count++
+		return false
+	}
+	if dd.max == 0 || len(dd.entries) < dd.max {
+		dd.entries[fp] = &DedupEntry{DSN: d, Count: 1}
+	}
+	return true
+}
+
+func (dd *Deduper) Len() int {
+	dd.mu.Lock()
+	defer dd.mu.Unlock()
+	return len(dd.entries)
+}
+
+func (dd *Deduper) Entries() []DedupEntry {
+	/*
+		Snapshot of distinct DSNs, most frequent first.
+	*/
+	dd.mu.Lock()
+	out := make([]DedupEntry, 0, len(dd.entries))
+	for _, e := range dd.entries {
+		out = append(out, *e)
+	}
+	dd.mu.Unlock()
+	sort.Slice(out, func(i, j int) bool {
+		if out[i].Count == out[j].Count {
+			return out[i].DSN.Fingerprint() < out[j].DSN.Fingerprint()
+		}
+		return out[i].Count > out[j].Count
+	})
+	return out
+}
+
+func (dd *Deduper) Reset() {
+	dd.mu.Lock()
+	defer dd.mu.Unlock()
+	dd.entries = map[string]*DedupEntry{}
+}
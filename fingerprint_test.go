@@ -0,0 +1,116 @@
+package dsn
+
+import (
+	"fmt"
+	"testing"
+)
+
+type testEqual struct {
+	a, b         *DSN
+	ignoreSecret bool
+	description  string
+	expected     bool
+}
+
+var (
+	testBoth   = CreateDSN(&User{PublicKey: "4784fbc50de2473f9977cfce8a9adce5", SecretKey: "4784fbc50de2473f9977cfce8a9adce5"}, "sentry.io", "1234")
+	testPublic = CreateDSN(&User{PublicKey: "4784fbc50de2473f9977cfce8a9adce5"}, "sentry.io", "1234")
+	testOther  = CreateDSN(&User{PublicKey: "4784fbc50de2473f9977cfce8a9adce5"}, "sentry.io", "99")
+)
+
+var testTableEqual = []testEqual{
+	{testBoth, testBoth, false, "Testing identical", true},
+	{testBoth, testPublic, false, "Testing differing secret", false},
+	{testBoth, testPublic, true, "Testing differing secret ignored", true},
+	{testPublic, testOther, true, "Testing differing project", false},
+	{testPublic, nil, true, "Testing nil", false},
+}
+
+func TestEqual(t *testing.T) {
+	for _, test := range testTableEqual {
+		if got := test.a.Equal(test.b, test.ignoreSecret); got != test.expected {
+			t.Errorf("%s: Expected -- %t -- Got %t", test.description, test.expected, got)
+		}
+	}
+}
+
+func TestFingerprint(t *testing.T) {
+	if testBoth.Fingerprint() != testPublic.Fingerprint() {
+		t.Errorf("Expected -- fingerprint to ignore secret -- Got %s %s", testBoth.Fingerprint(), testPublic.Fingerprint())
+	}
+	if testPublic.Fingerprint() == testOther.Fingerprint() {
+		t.Errorf("Expected -- distinct fingerprints -- Got %s", testOther.Fingerprint())
+	}
+	if len(testPublic.Fingerprint()) != 32 {
+		t.Errorf("Expected -- 32 hex chars -- Got %s", testPublic.Fingerprint())
+	}
+}
+
+func TestShard(t *testing.T) {
+	if testBoth.Shard(16) != testPublic.Shard(16) {
+		t.Errorf("Expected -- shard to ignore secret -- Got %d %d", testBoth.Shard(16), testPublic.Shard(16))
+	}
+	for _, n := range []int{-1, 0, 1} {
+		if s := testPublic.Shard(n); s != 0 {
+			t.Errorf("Testing %d shards: Expected -- 0 -- Got %d", n, s)
+		}
+	}
+	moved, counts := 0, make([]int, 10)
+	for i := 0; i < 1000; i++ {
+		d := CreateDSN(&User{PublicKey: fmt.Sprintf("%032x", i)}, "sentry.io", "1")
+		s := d.Shard(10)
+		if s < 0 || s >= 10 || s != d.Shard(10) {
+			t.Fatalf("Expected -- stable shard in [0,10) -- Got %d", s)
+		}
+		counts[s]++
+		if d.Shard(11) != s {
+			moved++
+		}
+	}
+	for s, c := range counts {
+		if c < 50 || c > 150 {
+			t.Errorf("Expected -- about 100 DSNs on shard %d -- Got %d", s, c)
+		}
+	}
+	if moved > 150 {
+		t.Errorf("Expected -- about 1/11 of DSNs to move -- Got %d of 1000", moved)
+	}
+}
+
+func TestDeduper(t *testing.T) {
+	dd := NewDeduper(0)
+	for i, d := range []*DSN{testBoth, testPublic, testOther, testPublic} {
+		if got := dd.Add(d); got != (i == 0 || i == 2) {
+			t.Errorf("Add #%d: Expected -- %t -- Got %t", i, !got, got)
+		}
+	}
+	entries := dd.Entries()
+	if dd.Len() != 2 || entries[0].Count != 3 || entries[1].DSN != testOther {
+		t.Errorf("Expected -- 2 entries -- Got %v", entries)
+	}
+
+	bounded := NewDeduper(1)
+	bounded.Add(testPublic)
+	if !bounded.Add(testOther) || bounded.Len() != 1 {
+		t.Errorf("Expected -- bounded deduper to stay at 1 -- Got %d", bounded.Len())
+	}
+}
+
+func TestAnonymized(t *testing.T) {
+	a := testBoth.Anonymized([]byte("salt"))
+	if a.PublicKey == testBoth.PublicKey || a.SecretKey == testBoth.SecretKey || len(a.PublicKey) != 32 {
+		t.Errorf("Expected -- hashed keys -- Got %s", a.URL)
+	}
+	if a.Host != testBoth.Host || a.ProjectID != testBoth.ProjectID {
+		t.Errorf("Expected -- host and project kept -- Got %s", a.URL)
+	}
+	if !a.Equal(testBoth.Anonymized([]byte("salt")), false) {
+		t.Errorf("Expected -- stable output for the same salt -- Got %s", a.URL)
+	}
+	if a.Equal(testBoth.Anonymized([]byte("pepper")), false) {
+		t.Errorf("Expected -- different output for another salt -- Got %s", a.URL)
+	}
+	if p := testPublic.Anonymized([]byte("salt")); len(p.SecretKey) != 0 {
+		t.Errorf("Expected -- empty secret to stay empty -- Got %s", p.SecretKey)
+	}
+}
@@ -0,0 +1,70 @@
+package dsn
+
+import (
+	"bytes"
+	"compress/zlib"
+	"encoding/base64"
+	"errors"
+	"io/ioutil"
+	"net/http"
+	"strings"
+)
+
+// ErrInvalidPayload Thrown when the sentry_data of a GET submission can not be decoded
+var ErrInvalidPayload = errors.New("sentry:  invalid sentry_data payload")
+
+func WithGetSubmissions() Option {
+	/*
+		Accepts the GET store submissions ancient raven-js fell back to when CORS POSTs were impossible:
+		/api/<project_id>/store/?sentry_key=...&sentry_data=<event>
+		The event (plain JSON, or base64 of JSON optionally zlib compressed) is decoded into ParseResult.Payload.
+		Implies GET is allowed by WithMethodCheck.
+	*/
+	return func(c *config) {
+		c.getSubmissions = true
+	}
+}
+
+func (c *config) getPayload(r *http.Request, endpoint Endpoint) ([]byte, error) {
+	/*
+		nil, nil unless r is a GET store submission.
+	*/
+	if !c.getSubmissions || r.Method != http.MethodGet || endpoint != EndpointStore {
+		return nil, nil
+	}
+	data := r.URL.Query().Get("sentry_data")
+	if len(data) == 0 {
+		return nil, nil
+	}
+	return DecodeSentryData(data)
+}
+
+func DecodeSentryData(data string) ([]byte, error) {
+	/*
+		Decodes a sentry_data value the way Sentry did: JSON as is, otherwise base64 (standard or URL alphabet,
+		padding optional) of JSON or of zlib compressed JSON.
+	*/
+	if strings.HasPrefix(strings.TrimSpace(data), "{") {
+		return []byte(data), nil
+	}
+	data = strings.TrimRight(data, "=")
+	b, err := base64.RawStdEncoding.DecodeString(data)
+	if err != nil {
+		if b, err = base64.RawURLEncoding.DecodeString(data); err != nil {
+			return nil, ErrInvalidPayload
+		}
+	}
+	if len(b) > 0 && b[0] == 0x78 {
+		zr, err := zlib.NewReader(bytes.NewReader(b))
+		if err != nil {
+			return nil, ErrInvalidPayload
+		}
+		if b, err = ioutil.ReadAll(zr); err != nil {
+			return nil, ErrInvalidPayload
+		}
+	}
+	if !bytes.HasPrefix(bytes.TrimSpace(b), []byte("{")) {
+		return nil, ErrInvalidPayload
+	}
+	return b, nil
+}
@@ -0,0 +1,67 @@
+package dsn
+
+import (
+	"bytes"
+	"compress/zlib"
+	"encoding/base64"
+	"io/ioutil"
+	"net/http/httptest"
+	"net/url"
+	"testing"
+)
+
+const testEvent = `{"message":"beacon"}`
+
+func zlibBase64(s string) string {
+	var buf bytes.Buffer
+	zw := zlib.NewWriter(&buf)
+	zw.Write([]byte(s))
+	zw.Close()
+	return base64.StdEncoding.EncodeToString(buf.Bytes())
+}
+
+type testGetSubmission struct {
+	method      string
+	data        string
+	description string
+	expected    string
+	err         error
+}
+
+var testTableGetSubmissions = []testGetSubmission{
+	{"GET", testEvent, "Testing plain JSON", testEvent, nil},
+	{"GET", base64.StdEncoding.EncodeToString([]byte(testEvent)), "Testing base64", testEvent, nil},
+	{"GET", base64.RawURLEncoding.EncodeToString([]byte(testEvent)), "Testing unpadded URL base64", testEvent, nil},
+	{"GET", zlibBase64(testEvent), "Testing zlib base64", testEvent, nil},
+	{"GET", "!!not base64!!", "Testing garbage", "", ErrInvalidPayload},
+	{"GET", base64.StdEncoding.EncodeToString([]byte("plain text")), "Testing base64 of non-JSON", "", ErrInvalidPayload},
+	{"POST", testEvent, "Testing POST ignored", "", nil},
+	{"GET", "", "Testing no sentry_data", "", nil},
+}
+
+func TestGetSubmissions(t *testing.T) {
+	for _, test := range testTableGetSubmissions {
+		u := "https://sentry.io/api/1/store/?sentry_version=4&sentry_key=" + testKeyA
+		if len(test.data) > 0 {
+			u += "&sentry_data=" + url.QueryEscape(test.data)
+		}
+		r := httptest.NewRequest(test.method, u, nil)
+		res, err := ParseRequest(r.Context(), r, WithGetSubmissions(), WithMethodCheck(false))
+		if err != test.err {
+			t.Errorf("%s: Expected -- %v -- Got %v", test.description, test.err, err)
+			continue
+		}
+		if err != nil {
+			continue
+		}
+		if len(test.expected) == 0 {
+			if res.Payload != nil {
+				t.Errorf("%s: Expected -- no payload -- Got one", test.description)
+			}
+			continue
+		}
+		if got, _ := ioutil.ReadAll(res.Payload); string(got) != test.expected {
+			t.Errorf("%s: Expected -- %s -- Got %s", test.description, test.expected, got)
+		}
+	}
+}
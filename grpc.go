@@ -0,0 +1,60 @@
+package dsn
+
+import (
+	"net"
+	"net/url"
+	"strings"
+)
+
+// gRPC metadata keys are always lower case, so these differ from HTTP_X_SENTRY_AUTH.
+var GRPC_SENTRY_AUTH = "x-sentry-auth"
+var GRPC_SENTRY_PROJECT = "x-sentry-project"
+var GRPC_AUTHORITY = ":authority"
+
+// GRPCPath maps gRPC metadata and the full method name onto an ingest path understood by CheckPath.
+// Override it if your service uses a different naming convention.
+var GRPCPath = func(md map[string][]string, fullMethod string) string {
+	/*
+		Default convention: the project ID travels in the x-sentry-project metadata key and the
+		method name selects the endpoint, so "/sentry.Ingest/Store" for project 1234 becomes /api/1234/store/.
+	*/
+	project := firstValue(md, GRPC_SENTRY_PROJECT)
+	method := fullMethod[strings.LastIndex(fullMethod, "/")+1:]
+	if len(project) == 0 {
+		return "/api/" + strings.ToLower(method) + "/"
+	}
+	return "/api/" + project + "/" + strings.ToLower(method) + "/"
+}
+
+func FromGRPCMetadata(md map[string][]string, fullMethod string) (*DSN, error) {
+	/*
+		For services that tunnel Sentry payloads over gRPC instead of plain HTTP.
+		md is typically a google.golang.org/grpc/metadata.MD which shares the map[string][]string representation,
+		so it can be passed directly without this package depending on grpc.
+
+		The auth value is read from GRPC_SENTRY_AUTH and parsed exactly like an X-SENTRY-AUTH header.
+		The host is taken from the :authority pseudo header when present.
+	*/
+	user, err := ParseHeaders(md[GRPC_SENTRY_AUTH])
+	if err != nil {
+		return nil, err
+	}
+	// like url.URL.Hostname: without port and IPv6 brackets; bare IPv6 authorities have no port to strip
+	host := firstValue(md, GRPC_AUTHORITY)
+	if h, _, err := net.SplitHostPort(host); err == nil {
+		host = h
+	}
+	host = strings.TrimSuffix(strings.TrimPrefix(host, "["), "]")
+	p, err := CheckPath(&url.URL{Path: GRPCPath(md, fullMethod)})
+	if err != nil {
+		return nil, err
+	}
+	return CreateDSN(user, host, p), nil
+}
+
+func firstValue(md map[string][]string, key string) string {
+	if v := md[key]; len(v) > 0 {
+		return v[0]
+	}
+	return ""
+}
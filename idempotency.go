@@ -0,0 +1,141 @@
+package dsn
+
+import (
+	"bytes"
+	"compress/gzip"
+	"compress/zlib"
+	"crypto/sha256"
+	"encoding/hex"
+	"io"
+	"net/http"
+	"strings"
+)
+
+// DefaultIdempotencyBodyLimit bounds how much (encoded) body is read to compute an idempotency key.
+// Larger bodies get no key rather than one that only covers part of them.
+var DefaultIdempotencyBodyLimit int64 = 1 << 20
+
+// maxDecodedRatio bounds how much larger than its encoded form a body may get when decoded.
+const maxDecodedRatio = 10
+
+func WithIdempotencyKey() Option {
+	/*
+		Fills ParseResult.IdempotencyKey so relays can drop retried submissions before forwarding them.
+		The body is read up to DefaultIdempotencyBodyLimit and restored for downstream handlers.
+	*/
+	return func(c *config) {
+		c.idempotency = NewLimitedPeeker(DefaultIdempotencyBodyLimit)
+	}
+}
+
+func IdempotencyKey(d *DSN, body []byte) string {
+	/*
+		Stable hex hash of the DSN fingerprint and the decoded body. An SDK retrying the same event
+		to the same DSN gets the same key, whatever compression it used the second time.
+	*/
+	h := sha256.New()
+	h.Write([]byte(d.Fingerprint()))
+	h.Write([]byte{0})
+	h.Write(body)
+	return hex.EncodeToString(h.Sum(nil)[:16])
+}
+
+func (c *config) idempotencyKey(r *http.Request, res *ParseResult) string {
+	if res.payload != nil {
+		// GET submission, the payload was decoded from the query already
+		return IdempotencyKey(res.DSN, res.payload)
+	}
+	decoded, err := peekDecoded(r, c.idempotency)
+	if err != nil {
+		return ""
+	}
+	return IdempotencyKey(res.DSN, decoded)
+}
+
+func peekDecoded(r *http.Request, p *LimitedPeeker) ([]byte, error) {
+	/*
+		Peeks r.Body through p and undoes its Content-Encoding. r.Body is restored as sent.
+	*/
+	raw, err := p.PeekRequest(r)
+	if err != nil {
+		return nil, err
+	}
+	body, err := decodeBody(r.Header.Get("Content-Encoding"), bytes.NewReader(raw))
+	if err != nil {
+		return nil, err
+	}
+	// bound the decoded size too so a compression bomb can not blow up memory
+	limit := maxDecodedRatio * p.Limit
+	decoded, err := io.ReadAll(io.LimitReader(body, limit+1))
+	if err != nil {
+		return nil, err
+	}
+	if int64(len(decoded)) > limit {
+		return nil, &TruncatedError{Limit: limit}
+	}
+	return decoded, nil
+}
+
+func readBounded(r *http.Request, endpoint Endpoint) ([]byte, error) {
+	/*
+		Reads r.Body for rewriting. Bodies LimitRequestBody already caps are read as they are, others are capped at
+		the DefaultSizeLimits of endpoint (maxHandlerBody when it has none) and fail with a *SizeError past that.
+	*/
+	if _, ok := r.Body.(*limitedBody); ok {
+		return io.ReadAll(r.Body)
+	}
+	limit, ok := DefaultSizeLimits[endpoint]
+	if !ok {
+		limit = maxHandlerBody
+	}
+	raw, err := io.ReadAll(io.LimitReader(r.Body, limit+1))
+	if err != nil {
+		return nil, err
+	}
+	if int64(len(raw)) > limit {
+		return nil, &SizeError{Endpoint: endpoint, Limit: limit}
+	}
+	return raw, nil
+}
+
+func decodeBounded(encoding string, raw []byte, endpoint Endpoint) ([]byte, error) {
+	/*
+		Undoes the Content-Encoding of a buffered body. The decoded size is bounded so a compression bomb can not
+		blow up memory, but never below what Sentry accepts for endpoint. Past that it fails with a *TruncatedError.
+	*/
+	if len(encoding) == 0 {
+		return raw, nil
+	}
+	decoded, err := decodeBody(encoding, bytes.NewReader(raw))
+	if err != nil {
+		return nil, err
+	}
+	limit := maxDecodedRatio * int64(len(raw))
+	if sentry := DefaultSizeLimits[endpoint]; sentry > limit {
+		limit = sentry
+	}
+	body, err := io.ReadAll(io.LimitReader(decoded, limit+1))
+	if err != nil {
+		return nil, err
+	}
+	if int64(len(body)) > limit {
+		return nil, &TruncatedError{Limit: limit}
+	}
+	return body, nil
+}
+
+func decodeBody(encoding string, body io.Reader) (io.Reader, error) {
+	/*
+		Undoes a Content-Encoding. gzip and deflate are supported, anything else is ErrUnsupportedEncoding.
+	*/
+	switch strings.ToLower(strings.TrimSpace(encoding)) {
+	case "", "identity":
+		return body, nil
+	case "gzip", "x-gzip":
+		return gzip.NewReader(body)
+	case "deflate":
+		return zlib.NewReader(body)
+	default:
+		return nil, ErrUnsupportedEncoding
+	}
+}
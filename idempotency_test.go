@@ -0,0 +1,65 @@
+package dsn
+
+import (
+	"bytes"
+	"compress/gzip"
+	"io/ioutil"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"testing"
+)
+
+func idempotencyKeyOf(t *testing.T, u string, body []byte, encoding string) string {
+	r := httptest.NewRequest("POST", u, bytes.NewReader(body))
+	if len(encoding) > 0 {
+		r.Header.Set("Content-Encoding", encoding)
+	}
+	res, err := ParseRequest(r.Context(), r, WithIdempotencyKey())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if rest, _ := ioutil.ReadAll(r.Body); !bytes.Equal(rest, body) {
+		t.Errorf("Expected -- body restored -- Got %q", rest)
+	}
+	return res.IdempotencyKey
+}
+
+func TestIdempotencyKey(t *testing.T) {
+	u := "https://sentry.io/api/1/store/?sentry_key=" + testKeyA
+	plain := idempotencyKeyOf(t, u, []byte(testEvent), "")
+	if len(plain) != 32 {
+		t.Fatalf("Expected -- 32 hex characters -- Got %q", plain)
+	}
+	var buf bytes.Buffer
+	zw := gzip.NewWriter(&buf)
+	zw.Write([]byte(testEvent))
+	zw.Close()
+	if got := idempotencyKeyOf(t, u, buf.Bytes(), "gzip"); got != plain {
+		t.Errorf("Expected -- same key for gzip retry -- Got %s and %s", plain, got)
+	}
+	if got := idempotencyKeyOf(t, u, []byte(`{"message":"other"}`), ""); got == plain {
+		t.Errorf("Expected -- different key for a different body -- Got %s", got)
+	}
+	if got := idempotencyKeyOf(t, "https://sentry.io/api/2/store/?sentry_key="+testKeyA, []byte(testEvent), ""); got == plain {
+		t.Errorf("Expected -- different key for another project -- Got %s", got)
+	}
+	if got := idempotencyKeyOf(t, u, []byte(testEvent), "br"); got != "" {
+		t.Errorf("Expected -- no key for unknown encodings -- Got %s", got)
+	}
+
+	r := httptest.NewRequest("GET", u+"&sentry_data="+url.QueryEscape(testEvent), nil)
+	res, err := ParseRequest(r.Context(), r, WithIdempotencyKey(), WithGetSubmissions())
+	if err != nil || res.IdempotencyKey != plain {
+		t.Errorf("Expected -- GET submission keyed like the POST -- Got %s %v", res.IdempotencyKey, err)
+	}
+}
+
+func TestIdempotencyKeyLimit(t *testing.T) {
+	defer func(limit int64) { DefaultIdempotencyBodyLimit = limit }(DefaultIdempotencyBodyLimit)
+	DefaultIdempotencyBodyLimit = 8
+	got := idempotencyKeyOf(t, "https://sentry.io/api/1/store/?sentry_key="+testKeyA, []byte(strings.Repeat("x", 9)), "")
+	if got != "" {
+		t.Errorf("Expected -- no key past the limit -- Got %s", got)
+	}
+}
@@ -0,0 +1,142 @@
+// Package authheader reads Sentry credentials from X-Sentry-Auth values and sentry_* query strings.
+// It works on substrings of its input so the common case does not allocate.
+package authheader
+
+import (
+	"net/url"
+	"strings"
+
+	"github.com/dgbailey/dsn/internal/keys"
+)
+
+// Fields are the sentry_* values found, verbatim apart from unquoting and unescaping of the keys.
+type Fields struct {
+	PublicKey string //sentry_key, only when it is a hex key
+	SecretKey string //sentry_secret, only when it is a hex key
+	Version   string
+	Client    string
+	Timestamp string
+	Signature string
+	Extra     map[string]string //other sentry_* fields by full name, nil when there are none
+}
+
+func Parse(v string, normalize bool) (Fields, bool) {
+	/*
+		Anticipates header: Sentry <start-header-values,...> with or without spaces after the commas.
+		Keys must be 32 lower case hex characters, anything else is ignored unless normalize allows
+		keys.Canonical to repair it. Values may be quoted and keys URL encoded, as some SDKs and proxies send them.
+		Unknown sentry_* fields are kept in Extra so newer protocol fields survive forwarding.
+	*/
+	var f Fields
+	if len(v) > 7 && strings.EqualFold(v[:7], "sentry ") {
+		v = v[7:]
+	}
+	for len(v) > 0 {
+		var pair string
+		if i := strings.IndexByte(v, ','); i >= 0 {
+			pair, v = v[:i], v[i+1:]
+		} else {
+			pair, v = v, ""
+		}
+		pair = strings.TrimSpace(pair)
+		i := strings.IndexByte(pair, '=')
+		if i < 0 {
+			continue
+		}
+		switch key, value := pair[:i], keys.Unquote(pair[i+1:]); key {
+		case "sentry_key":
+			if value = keys.Unescape(value); normalize {
+				value = keys.Canonical(value)
+			}
+			if keys.IsHex(value) {
+				f.PublicKey = value
+			}
+		case "sentry_secret":
+			if value = keys.Unescape(value); normalize {
+				value = keys.Canonical(value)
+			}
+			if keys.IsHex(value) {
+				f.SecretKey = value
+			}
+		case "sentry_version":
+			f.Version = value
+		case "sentry_client":
+			f.Client = value
+		case "sentry_timestamp":
+			f.Timestamp = value
+		case "sentry_signature":
+			f.Signature = value
+		default:
+			if strings.HasPrefix(key, "sentry_") {
+				if f.Extra == nil {
+					f.Extra = map[string]string{}
+				}
+				f.Extra[key] = pair[i+1:]
+			}
+		}
+	}
+	return f, len(f.PublicKey) > 0
+}
+
+func ParseQuery(q string) (Fields, bool) {
+	/*
+		Same result as url.Values.Get on url.ParseQuery(q) for the sentry_* keys, without building the whole map:
+		pairs are split on & only and dropped when they contain a semicolon or do not unescape, and the first
+		value of a key wins even when it is empty. Quotes around sentry_key and sentry_secret are dropped on top.
+		A single pass over q, only unescaping (and allocating) when needed.
+	*/
+	var f Fields
+	var seen [4]bool
+	for len(q) > 0 {
+		var pair string
+		if i := strings.IndexByte(q, '&'); i >= 0 {
+			pair, q = q[:i], q[i+1:]
+		} else {
+			pair, q = q, ""
+		}
+		if len(pair) == 0 || strings.IndexByte(pair, ';') >= 0 {
+			continue
+		}
+		name, value := pair, ""
+		if i := strings.IndexByte(pair, '='); i >= 0 {
+			name, value = pair[:i], pair[i+1:]
+		}
+		if strings.ContainsAny(name, "%+") {
+			unescaped, err := url.QueryUnescape(name)
+			if err != nil {
+				continue
+			}
+			name = unescaped
+		}
+		var field *string
+		var n int
+		switch name {
+		case "sentry_key":
+			field, n = &f.PublicKey, 0
+		case "sentry_secret":
+			field, n = &f.SecretKey, 1
+		case "sentry_version":
+			field, n = &f.Version, 2
+		case "sentry_client":
+			field, n = &f.Client, 3
+		default:
+			continue
+		}
+		if strings.ContainsAny(value, "%+") {
+			unescaped, err := url.QueryUnescape(value)
+			if err != nil {
+				continue
+			}
+			value = unescaped
+		}
+		if seen[n] {
+			continue
+		}
+		seen[n] = true
+		if n < 2 {
+			value = keys.Unquote(value)
+		}
+		*field = value
+	}
+	return f, len(f.PublicKey) > 0
+}
@@ -0,0 +1,85 @@
+package authheader
+
+import (
+	"net/url"
+	"testing"
+)
+
+const testKey = "4784fbc50de2473f9977cfce8a9adce5"
+
+type testParse struct {
+	header      string
+	normalize   bool
+	description string
+	expected    Fields
+	ok          bool
+}
+
+var testTableParse = []testParse{
+	{"Sentry sentry_key=" + testKey + ", sentry_version=7", false, "Testing header", Fields{PublicKey: testKey, Version: "7"}, true},
+	{"sentry_key=" + testKey + ",sentry_secret=" + testKey, false, "Testing without prefix or spaces", Fields{PublicKey: testKey, SecretKey: testKey}, true},
+	{`Sentry sentry_key="` + testKey + `"`, false, "Testing quoted key", Fields{PublicKey: testKey}, true},
+	{"Sentry sentry_key=%22" + testKey + "%22", false, "Testing encoded quotes", Fields{PublicKey: testKey}, true},
+	{"Sentry sentry_key=4784FBC50DE2473F9977CFCE8A9ADCE5", false, "Testing upper case key", Fields{}, false},
+	{"Sentry sentry_key=4784FBC50DE2473F9977CFCE8A9ADCE5", true, "Testing upper case key normalized", Fields{PublicKey: testKey}, true},
+	{"Sentry sentry_key=" + testKey + ", sentry_new=1", false, "Testing extra field", Fields{PublicKey: testKey, Extra: map[string]string{"sentry_new": "1"}}, true},
+	{"Sentry sentry_version=7", false, "Testing missing key", Fields{Version: "7"}, false},
+}
+
+func TestParse(t *testing.T) {
+	for _, test := range testTableParse {
+		got, ok := Parse(test.header, test.normalize)
+		if ok != test.ok || !equal(got, test.expected) {
+			t.Errorf("%s: Expected -- %+v %v -- Got %+v %v", test.description, test.expected, test.ok, got, ok)
+		}
+	}
+}
+
+type testParseQuery struct {
+	query       string
+	description string
+	expected    Fields
+	ok          bool
+}
+
+var testTableParseQuery = []testParseQuery{
+	{"sentry_key=" + testKey + "&sentry_version=7", "Testing query", Fields{PublicKey: testKey, Version: "7"}, true},
+	{"sentry_key=" + testKey + "&sentry_key=other", "Testing first value wins", Fields{PublicKey: testKey}, true},
+	{"sentry%5Fkey=%22" + testKey + "%22&sentry_client=a+b", "Testing encoded name and quotes", Fields{PublicKey: testKey, Client: "a b"}, true},
+	{"sentry_version=7;sentry_key=" + testKey + "&sentry_client=a", "Testing pair with a semicolon dropped", Fields{Client: "a"}, false},
+	{"sentry_key=" + testKey + "&sentry_version=&sentry_version=7", "Testing empty first value wins", Fields{PublicKey: testKey}, true},
+	{"sentry_version=%zz&sentry_version=7&sentry_key=" + testKey, "Testing pair that does not unescape dropped", Fields{PublicKey: testKey, Version: "7"}, true},
+	{"sentry_version=7", "Testing missing key", Fields{Version: "7"}, false},
+}
+
+func TestParseQuery(t *testing.T) {
+	for _, test := range testTableParseQuery {
+		got, ok := ParseQuery(test.query)
+		if ok != test.ok || !equal(got, test.expected) {
+			t.Errorf("%s: Expected -- %+v %v -- Got %+v %v", test.description, test.expected, test.ok, got, ok)
+		}
+	}
+}
+
+func TestParseQueryMatchesURL(t *testing.T) {
+	for _, test := range testTableParseQuery {
+		values, _ := url.ParseQuery(test.query)
+		got, _ := ParseQuery(test.query)
+		if got.Version != values.Get("sentry_version") || got.Client != values.Get("sentry_client") {
+			t.Errorf("%s: Expected -- %q %q -- Got %q %q", test.description, values.Get("sentry_version"), values.Get("sentry_client"), got.Version, got.Client)
+		}
+	}
+}
+
+func equal(a, b Fields) bool {
+	if len(a.Extra) != len(b.Extra) {
+		return false
+	}
+	for k, v := range a.Extra {
+		if b.Extra[k] != v {
+			return false
+		}
+	}
+	return a.PublicKey == b.PublicKey && a.SecretKey == b.SecretKey && a.Version == b.Version &&
+		a.Client == b.Client && a.Timestamp == b.Timestamp && a.Signature == b.Signature
+}
@@ -0,0 +1,107 @@
+// Package ingestpath splits Sentry ingest paths (/api/<project_id>/<endpoint>/...) into their parts.
+// It works on substrings of the path so parsing does not allocate.
+package ingestpath
+
+import (
+	"errors"
+	"strings"
+
+	"github.com/dgbailey/dsn/internal/keys"
+)
+
+var (
+	// ErrNotIngest Thrown for Sentry Web API paths (/api/0/...) which never carry events
+	ErrNotIngest = errors.New("ingestpath: not an ingest path")
+	// ErrMalformed Thrown for any other path that is not a known ingest endpoint
+	ErrMalformed = errors.New("ingestpath: malformed ingest path")
+)
+
+// Info is everything an ingest path says about a request, see dsn.PathInfo.
+type Info struct {
+	Prefix      string
+	ProjectID   string //empty for the legacy /api/store/
+	Endpoint    string //endpoint name as in dsn.Endpoint, csp-report is reported as security
+	EventID     string
+	MonitorSlug string
+	PublicKey   string //key carried in the path (unreal and cron), when valid
+}
+
+func Parse(path string, info *Info) error {
+	/*
+		Fills info from path. Returns ErrNotIngest for the Web API and ErrMalformed for anything else unknown,
+		in which case info may be partially filled.
+	*/
+	i := strings.Index(path, "/api/")
+	if i < 0 {
+		return ErrMalformed
+	}
+	info.Prefix = path[:i]
+	rest := path[i+len("/api/"):]
+	if strings.HasPrefix(rest, "0/") {
+		return ErrNotIngest
+	}
+	if strings.HasPrefix(rest, "store/") {
+		info.Endpoint = "store"
+		return nil
+	}
+	n := 0
+	for n < len(rest) && rest[n] >= '0' && rest[n] <= '9' {
+		n++
+	}
+	if n == 0 || n == len(rest) || rest[n] != '/' {
+		return ErrMalformed
+	}
+	rest = rest[n+1:]
+	end := strings.IndexByte(rest, '/')
+	if end < 0 {
+		return ErrMalformed //the endpoint name is always followed by a slash
+	}
+	segment := rest[:end]
+	rest = rest[end+1:]
+	switch segment {
+	case "store", "envelope", "minidump", "security", "unreal":
+		info.Endpoint = segment
+		if segment == "unreal" {
+			key, _ := nextSegment(rest)
+			info.setKey(key)
+		}
+	case "csp-report":
+		info.Endpoint = "security"
+	case "events":
+		var next string
+		info.EventID, rest = nextSegment(rest)
+		if next, _ = nextSegment(rest); len(info.EventID) == 0 || next != "attachments" {
+			return ErrMalformed
+		}
+		info.Endpoint = "attachments"
+	case "cron":
+		var key string
+		info.MonitorSlug, rest = nextSegment(rest)
+		if len(info.MonitorSlug) == 0 {
+			return ErrMalformed
+		}
+		key, _ = nextSegment(rest)
+		info.setKey(key)
+		info.Endpoint = "cron"
+	default:
+		return ErrMalformed
+	}
+	info.ProjectID = path[i+len("/api/") : i+len("/api/")+n]
+	return nil
+}
+
+func (info *Info) setKey(key string) {
+	if keys.IsHex(key) {
+		info.PublicKey = key
+	}
+}
+
+func nextSegment(s string) (segment string, rest string) {
+	/*
+		Splits "a/b/c" into "a" and "b/c". A segment without a trailing slash is returned with an empty rest.
+	*/
+	if i := strings.IndexByte(s, '/'); i >= 0 {
+		return s[:i], s[i+1:]
+	}
+	return s, ""
+}
@@ -0,0 +1,40 @@
+package ingestpath
+
+import (
+	"testing"
+)
+
+const testKey = "4784fbc50de2473f9977cfce8a9adce5"
+
+type testParse struct {
+	path        string
+	description string
+	expected    Info
+	err         error
+}
+
+var testTableParse = []testParse{
+	{"/api/1/store/", "Testing store", Info{ProjectID: "1", Endpoint: "store"}, nil},
+	{"/api/store/", "Testing legacy store", Info{Endpoint: "store"}, nil},
+	{"/sentry/api/12/envelope/", "Testing prefix", Info{Prefix: "/sentry", ProjectID: "12", Endpoint: "envelope"}, nil},
+	{"/api/1/csp-report/", "Testing csp-report", Info{ProjectID: "1", Endpoint: "security"}, nil},
+	{"/api/1/unreal/" + testKey + "/", "Testing unreal key", Info{ProjectID: "1", Endpoint: "unreal", PublicKey: testKey}, nil},
+	{"/api/1/unreal/nothex/", "Testing unreal invalid key", Info{ProjectID: "1", Endpoint: "unreal"}, nil},
+	{"/api/1/events/abc/attachments/", "Testing attachments", Info{ProjectID: "1", Endpoint: "attachments", EventID: "abc"}, nil},
+	{"/api/1/cron/nightly/" + testKey + "/", "Testing cron", Info{ProjectID: "1", Endpoint: "cron", MonitorSlug: "nightly", PublicKey: testKey}, nil},
+	{"/api/0/projects/", "Testing Web API", Info{}, ErrNotIngest},
+	{"/api/1/store", "Testing missing slash", Info{}, ErrMalformed},
+	{"/api/x/store/", "Testing non numeric project", Info{}, ErrMalformed},
+	{"/api/1/events//attachments/", "Testing missing event ID", Info{}, ErrMalformed},
+	{"/store/", "Testing no api", Info{}, ErrMalformed},
+}
+
+func TestParse(t *testing.T) {
+	for _, test := range testTableParse {
+		var got Info
+		err := Parse(test.path, &got)
+		if err != test.err || (err == nil && got != test.expected) {
+			t.Errorf("%s: Expected -- %+v %v -- Got %+v %v", test.description, test.expected, test.err, got, err)
+		}
+	}
+}
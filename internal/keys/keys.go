@@ -0,0 +1,68 @@
+// Package keys recognizes and repairs the spellings of Sentry public and secret keys.
+package keys
+
+import (
+	"net/url"
+	"strings"
+)
+
+func IsHex(s string) bool {
+	/*
+		Whether s is a key as Sentry issues them: 32 lower case hex characters.
+	*/
+	if len(s) != 32 {
+		return false
+	}
+	for i := 0; i < len(s); i++ {
+		if c := s[i]; (c < '0' || c > '9') && (c < 'a' || c > 'f') {
+			return false
+		}
+	}
+	return true
+}
+
+func Lower(key string) string {
+	/*
+		key lower cased when that makes it a hex key, key itself otherwise.
+	*/
+	if lower := strings.ToLower(key); IsHex(lower) {
+		return lower
+	}
+	return key
+}
+
+func Canonical(key string) string {
+	/*
+		key as 32 lower case hex characters when it is one in another spelling, key itself otherwise.
+	*/
+	if IsHex(key) || (len(key) != 32 && len(key) != 36) {
+		return key
+	}
+	if len(key) == 36 {
+		if key[8] != '-' || key[13] != '-' || key[18] != '-' || key[23] != '-' {
+			return key
+		}
+		key = key[:8] + key[9:13] + key[14:18] + key[19:23] + key[24:]
+	}
+	return Lower(key)
+}
+
+func Unquote(v string) string {
+	if len(v) >= 2 && (v[0] == '"' || v[0] == '\'') && v[len(v)-1] == v[0] {
+		return v[1 : len(v)-1]
+	}
+	return v
+}
+
+func Unescape(v string) string {
+	/*
+		Undoes URL encoding of a key value, quotes included (%22abc...%22). Allocates only when v is encoded.
+	*/
+	if strings.IndexByte(v, '%') < 0 {
+		return v
+	}
+	if unescaped, err := url.PathUnescape(v); err == nil {
+		return Unquote(unescaped)
+	}
+	return v
+}
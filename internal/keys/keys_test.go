@@ -0,0 +1,64 @@
+package keys
+
+import (
+	"testing"
+)
+
+const testKey = "4784fbc50de2473f9977cfce8a9adce5"
+
+type testCanonical struct {
+	key         string
+	description string
+	expected    string
+}
+
+var testTableCanonical = []testCanonical{
+	{testKey, "Testing canonical key", testKey},
+	{"4784FBC50DE2473F9977CFCE8A9ADCE5", "Testing upper case", testKey},
+	{"4784fbc5-0de2-473f-9977-cfce8a9adce5", "Testing dashed UUID", testKey},
+	{"4784FBC5-0DE2-473F-9977-CFCE8A9ADCE5", "Testing upper case dashed UUID", testKey},
+	{"4784fbc50-de2-473f-9977-cfce8a9adce5", "Testing misplaced dashes", "4784fbc50-de2-473f-9977-cfce8a9adce5"},
+	{"4784FBC50DE2473F9977CFCE8A9ADCEZ", "Testing non hex", "4784FBC50DE2473F9977CFCE8A9ADCEZ"},
+	{"", "Testing empty", ""},
+}
+
+func TestCanonical(t *testing.T) {
+	for _, test := range testTableCanonical {
+		if got := Canonical(test.key); got != test.expected {
+			t.Errorf("%s: Expected -- %s -- Got %s", test.description, test.expected, got)
+		}
+	}
+}
+
+func TestUnescape(t *testing.T) {
+	for in, expected := range map[string]string{
+		testKey:                 testKey,
+		"%22" + testKey + "%22": testKey,
+		"%zz":                   "%zz",
+		"'" + testKey + "'":     "'" + testKey + "'",
+	} {
+		if got := Unescape(in); got != expected {
+			t.Errorf("Testing %s: Expected -- %s -- Got %s", in, expected, got)
+		}
+	}
+}
+
+type testLower struct {
+	key         string
+	description string
+	expected    string
+}
+
+var testTableLower = []testLower{
+	{"4784FBC50DE2473F9977CFCE8A9ADCE5", "Testing upper case", testKey},
+	{"4784fbc5-0de2-473f-9977-cfce8a9adce5", "Testing dashed UUID left alone", "4784fbc5-0de2-473f-9977-cfce8a9adce5"},
+	{"4784FBC50DE2473F9977CFCE8A9ADCEZ", "Testing non hex", "4784FBC50DE2473F9977CFCE8A9ADCEZ"},
+}
+
+func TestLower(t *testing.T) {
+	for _, test := range testTableLower {
+		if got := Lower(test.key); got != test.expected {
+			t.Errorf("%s: Expected -- %s -- Got %s", test.description, test.expected, got)
+		}
+	}
+}
@@ -0,0 +1,80 @@
+package dsn
+
+import (
+	"encoding/json"
+	"net/http"
+)
+
+// DefaultItemCountBodyLimit bounds how much (encoded) envelope is read to count its items.
+// Larger envelopes get no ItemCounts.
+var DefaultItemCountBodyLimit int64 = 1 << 20
+
+func itemType(item envelopeItem) string {
+	var h struct {
+		Type string `json:"type"`
+	}
+	json.Unmarshal(item.header, &h)
+	return h.Type
+}
+
+func ItemCategory(itemType string) string {
+	/*
+		Data category of an envelope item type, as used for rate limits and outcomes.
+		Both single "session" updates and "sessions" aggregates count as CategorySession so
+		release health traffic is accounted apart from errors.
+	*/
+	switch itemType {
+	case "event":
+		return CategoryError
+	case "transaction":
+		return CategoryTransaction
+	case "session", "sessions":
+		return CategorySession
+	case "attachment":
+		return CategoryAttachment
+	case "check_in":
+		return CategoryMonitor
+	default:
+		return CategoryDefault
+	}
+}
+
+func EnvelopeItemCounts(body []byte) (map[string]int, error) {
+	/*
+		Counts the items of an envelope per item type.
+	*/
+	_, items, err := splitEnvelope(body)
+	if err != nil {
+		return nil, err
+	}
+	counts := map[string]int{}
+	for _, item := range items {
+		counts[itemType(item)]++
+	}
+	return counts, nil
+}
+
+func WithItemCounts() Option {
+	/*
+		Fills ParseResult.ItemCounts for envelope requests. The body is read up to DefaultItemCountBodyLimit
+		and restored for downstream handlers.
+	*/
+	return func(c *config) {
+		c.itemCounts = NewLimitedPeeker(DefaultItemCountBodyLimit)
+	}
+}
+
+func (c *config) countItems(r *http.Request, endpoint Endpoint) map[string]int {
+	if endpoint != EndpointEnvelope {
+		return nil
+	}
+	body, err := peekDecoded(r, c.itemCounts)
+	if err != nil {
+		return nil
+	}
+	counts, err := EnvelopeItemCounts(body)
+	if err != nil {
+		return nil
+	}
+	return counts
+}
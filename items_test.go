@@ -0,0 +1,70 @@
+package dsn
+
+import (
+	"bytes"
+	"compress/gzip"
+	"io/ioutil"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+const testSessionEnvelope = `{"sent_at":"2020-01-01T00:00:00Z"}
+{"type":"session"}
+{"sid":"7c7b6585f901430b94c7f3ce3e1a7f1b","status":"exited"}
+{"type":"sessions"}
+{"aggregates":[{"started":"2020-01-01T00:00:00Z","exited":3}]}
+{"type":"sessions"}
+{"aggregates":[{"started":"2020-01-01T00:01:00Z","errored":1}]}
+{"type":"event"}
+{"message":"hello"}
+`
+
+type testItemCategory struct {
+	itemType    string
+	description string
+	expected    string
+}
+
+var testTableItemCategory = []testItemCategory{
+	{"event", "Testing event", CategoryError},
+	{"transaction", "Testing transaction", CategoryTransaction},
+	{"session", "Testing session update", CategorySession},
+	{"sessions", "Testing session aggregates", CategorySession},
+	{"attachment", "Testing attachment", CategoryAttachment},
+	{"client_report", "Testing anything else", CategoryDefault},
+}
+
+func TestItemCategory(t *testing.T) {
+	for _, test := range testTableItemCategory {
+		if got := ItemCategory(test.itemType); got != test.expected {
+			t.Errorf("%s: Expected -- %s -- Got %s", test.description, test.expected, got)
+		}
+	}
+}
+
+func TestParseRequestItemCounts(t *testing.T) {
+	var gz bytes.Buffer
+	zw := gzip.NewWriter(&gz)
+	zw.Write([]byte(testSessionEnvelope))
+	zw.Close()
+	for _, encoding := range []string{"", "gzip"} {
+		body := []byte(testSessionEnvelope)
+		if encoding == "gzip" {
+			body = gz.Bytes()
+		}
+		r := httptest.NewRequest("POST", "https://sentry.io/api/1/envelope/?sentry_key="+testKeyA, bytes.NewReader(body))
+		r.Header.Set("Content-Encoding", encoding)
+		res, err := ParseRequest(r.Context(), r, WithItemCounts())
+		if err != nil || res.ItemCounts["session"] != 1 || res.ItemCounts["sessions"] != 2 || res.ItemCounts["event"] != 1 {
+			t.Errorf("Testing %q encoding: Expected -- 1 session, 2 sessions, 1 event -- Got %v %v", encoding, res.ItemCounts, err)
+		}
+		if rest, _ := ioutil.ReadAll(r.Body); !bytes.Equal(rest, body) {
+			t.Errorf("Testing %q encoding: Expected -- body restored -- Got %d bytes", encoding, len(rest))
+		}
+	}
+	r := httptest.NewRequest("POST", "https://sentry.io/api/1/store/?sentry_key="+testKeyA, strings.NewReader(testEvent))
+	if res, err := ParseRequest(r.Context(), r, WithItemCounts()); err != nil || res.ItemCounts != nil {
+		t.Errorf("Testing store: Expected -- no item counts -- Got %v %v", res.ItemCounts, err)
+	}
+}
@@ -0,0 +1,107 @@
+package dsn
+
+import (
+	"bytes"
+	"io/ioutil"
+	"net/http"
+	"strconv"
+)
+
+// ItemSizeLimits maps envelope item types ("event", "attachment", ...) onto their maximum payload size in bytes.
+// Item types without an entry are unlimited.
+type ItemSizeLimits map[string]int64
+
+// DefaultItemSizeLimits mirror the per item limits Sentry applies.
+var DefaultItemSizeLimits = ItemSizeLimits{
+	"event":       1 << 20,
+	"transaction": 1 << 20,
+	"session":     1 << 20,
+	"sessions":    1 << 20,
+	"attachment":  100 << 20,
+}
+
+// ReasonTooLarge is the outcome reason recorded for dropped oversized items.
+const ReasonTooLarge = "too_large"
+
+func EnvelopeItemSizes(body []byte) (map[string]int64, error) {
+	/*
+		Sums the payload sizes of an envelope per item type.
+	*/
+	_, items, err := splitEnvelope(body)
+	if err != nil {
+		return nil, err
+	}
+	sizes := map[string]int64{}
+	for _, item := range items {
+		sizes[itemType(item)] += int64(len(item.payload))
+	}
+	return sizes, nil
+}
+
+func DropOversizedItems(d *DSN, body []byte, limits ItemSizeLimits, rec *OutcomeRecorder) (out []byte, dropped int, err error) {
+	/*
+		Removes the items of an envelope whose payload exceeds the limit of their type and returns the rest.
+		Every drop is recorded on rec (when non nil) as OutcomeInvalid with ReasonTooLarge. body is returned
+		unchanged when nothing is dropped.
+	*/
+	header, items, err := splitEnvelope(body)
+	if err != nil {
+		return nil, 0, err
+	}
+	kept := items[:0]
+	for _, item := range items {
+		typ := itemType(item)
+		if limit, ok := limits[typ]; ok && int64(len(item.payload)) > limit {
+			dropped++
+			if rec != nil {
+				rec.Record(d, ItemCategory(typ), OutcomeInvalid, ReasonTooLarge, 1)
+			}
+			continue
+		}
+		kept = append(kept, item)
+	}
+	if dropped == 0 {
+		return body, 0, nil
+	}
+	return joinEnvelope(header, kept), dropped, nil
+}
+
+func LimitEnvelopeItems(r *http.Request, res *ParseResult, limits ItemSizeLimits, rec *OutcomeRecorder) error {
+	/*
+		Runs DropOversizedItems over the body of an envelope request and replaces it with the result.
+		Other endpoints are left alone. Like ScrubRequest, bodies are capped before they are read, and gzip and
+		deflate bodies are forwarded uncompressed and fail with a *TruncatedError when they decode to more than Sentry would accept.
+	*/
+	if res.Endpoint != EndpointEnvelope || r.Body == nil || r.Body == http.NoBody {
+		return nil
+	}
+	raw, err := readBounded(r, res.Endpoint)
+	if err != nil {
+		return err
+	}
+	body, err := decodeBounded(r.Header.Get("Content-Encoding"), raw, res.Endpoint)
+	if err != nil {
+		return err
+	}
+	body, _, err = DropOversizedItems(res.DSN, body, limits, rec)
+	if err != nil {
+		return err
+	}
+	r.Body.Close()
+	r.Header.Del("Content-Encoding")
+	r.Body = ioutil.NopCloser(bytes.NewReader(body))
+	r.ContentLength = int64(len(body))
+	r.Header.Set("Content-Length", strconv.Itoa(len(body)))
+	return nil
+}
+
+func WithItemSizeLimits(limits ItemSizeLimits, rec *OutcomeRecorder) Option {
+	/*
+		Has NewMiddleware drop oversized envelope items (see LimitEnvelopeItems) before calling the next handler,
+		recording each drop on rec. rec may be nil.
+	*/
+	return func(c *config) {
+		c.itemSizeLimits = limits
+		c.itemOutcomes = rec
+	}
+}
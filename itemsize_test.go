@@ -0,0 +1,125 @@
+package dsn
+
+import (
+	"bytes"
+	"compress/gzip"
+	"errors"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+const testSizedEnvelope = `{"event_id":"9ec79c33ec9942ab8353589fcb2e04dc"}
+{"type":"event"}
+{"message":"hello"}
+{"type":"attachment","length":10,"filename":"a.txt"}
+0123456789
+{"type":"session"}
+{"status":"ok"}
+`
+
+var testItemSizeLimits = ItemSizeLimits{"event": 64, "attachment": 4}
+
+type testItemSize struct {
+	limits      ItemSizeLimits
+	description string
+	dropped     int
+	kept        []string
+	outcomes    map[string]int64
+}
+
+var testTableItemSize = []testItemSize{
+	{testItemSizeLimits, "Testing oversized attachment dropped", 1, []string{`{"message":"hello"}`, `{"status":"ok"}`}, map[string]int64{CategoryAttachment: 1}},
+	{ItemSizeLimits{"event": 4, "session": 4}, "Testing event and session dropped", 2, []string{"0123456789"}, map[string]int64{CategoryError: 1, CategorySession: 1}},
+	{ItemSizeLimits{}, "Testing no limits", 0, []string{`{"message":"hello"}`, "0123456789", `{"status":"ok"}`}, map[string]int64{}},
+}
+
+func TestDropOversizedItems(t *testing.T) {
+	d, _ := Parse("https://" + testKeyA + "@sentry.io/1")
+	for _, test := range testTableItemSize {
+		rec := NewOutcomeRecorder()
+		out, dropped, err := DropOversizedItems(d, []byte(testSizedEnvelope), test.limits, rec)
+		if err != nil || dropped != test.dropped {
+			t.Errorf("%s: Expected -- %d dropped -- Got %d %v", test.description, test.dropped, dropped, err)
+			continue
+		}
+		_, items, err := splitEnvelope(out)
+		if err != nil || len(items) != len(test.kept) {
+			t.Errorf("%s: Expected -- %d items -- Got %d %v", test.description, len(test.kept), len(items), err)
+			continue
+		}
+		for i, item := range items {
+			if string(item.payload) != test.kept[i] {
+				t.Errorf("%s: Expected -- %s -- Got %s", test.description, test.kept[i], item.payload)
+			}
+		}
+		got := map[string]int64{}
+		for _, o := range rec.Snapshot() {
+			if o.Outcome != OutcomeInvalid || o.Reason != ReasonTooLarge {
+				t.Errorf("%s: Expected -- invalid/too_large -- Got %s/%s", test.description, o.Outcome, o.Reason)
+			}
+			got[o.Category] += o.Quantity
+		}
+		if len(got) != len(test.outcomes) {
+			t.Errorf("%s: Expected -- %v -- Got %v", test.description, test.outcomes, got)
+		}
+		for k, v := range test.outcomes {
+			if got[k] != v {
+				t.Errorf("%s: Expected -- %v -- Got %v", test.description, test.outcomes, got)
+			}
+		}
+	}
+}
+
+func TestEnvelopeItemSizes(t *testing.T) {
+	sizes, err := EnvelopeItemSizes([]byte(testSizedEnvelope))
+	if err != nil || sizes["event"] != 19 || sizes["attachment"] != 10 || sizes["session"] != 15 {
+		t.Errorf("Expected -- event 19, attachment 10, session 15 -- Got %v %v", sizes, err)
+	}
+}
+
+func TestMiddlewareItemSizeLimits(t *testing.T) {
+	rec := NewOutcomeRecorder()
+	var body string
+	h := NewMiddleware(WithItemSizeLimits(testItemSizeLimits, rec))(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		b, _ := ioutil.ReadAll(r.Body)
+		body = string(b)
+	}))
+	r := httptest.NewRequest("POST", "https://sentry.io/api/1/envelope/?sentry_key="+testKeyA, strings.NewReader(testSizedEnvelope))
+	w := httptest.NewRecorder()
+	h.ServeHTTP(w, r)
+	if w.Code != http.StatusOK || strings.Contains(body, "0123456789") || !strings.Contains(body, `{"status":"ok"}`) {
+		t.Errorf("Expected -- attachment dropped -- Got %d %q", w.Code, body)
+	}
+	if len(rec.Snapshot()) != 1 {
+		t.Errorf("Expected -- 1 outcome -- Got %v", rec.Snapshot())
+	}
+}
+
+func testBomb(size int) []byte {
+	var buf bytes.Buffer
+	zw := gzip.NewWriter(&buf)
+	zw.Write(make([]byte, size))
+	zw.Close()
+	return buf.Bytes()
+}
+
+func TestLimitEnvelopeItemsBomb(t *testing.T) {
+	r := httptest.NewRequest("POST", "https://sentry.io/api/1/envelope/", bytes.NewReader(testBomb(30<<20)))
+	r.Header.Set("Content-Encoding", "gzip")
+	res := &ParseResult{DSN: &DSN{PublicKey: testKeyA, ProjectID: "1"}, Endpoint: EndpointEnvelope}
+	if err := LimitEnvelopeItems(r, res, testItemSizeLimits, nil); !errors.Is(err, ErrBodyTruncated) {
+		t.Errorf("Expected -- %v -- Got %v", ErrBodyTruncated, err)
+	}
+	r = httptest.NewRequest("POST", "https://sentry.io/api/1/envelope/", bytes.NewReader(make([]byte, 20<<20+1)))
+	if err := LimitEnvelopeItems(r, res, testItemSizeLimits, nil); !errors.Is(err, ErrPayloadTooLarge) {
+		t.Errorf("Testing raw body: Expected -- %v -- Got %v", ErrPayloadTooLarge, err)
+	}
+	r = httptest.NewRequest("POST", "https://sentry.io/api/1/envelope/", bytes.NewReader(make([]byte, 20<<20+1)))
+	LimitRequestBody(r, EndpointEnvelope, SizeLimits{EndpointEnvelope: 30 << 20})
+	if err := LimitEnvelopeItems(r, res, testItemSizeLimits, nil); errors.Is(err, ErrPayloadTooLarge) {
+		t.Errorf("Testing configured limit: Expected -- the larger limit to apply -- Got %v", err)
+	}
+}
@@ -0,0 +1,272 @@
+package dsn
+
+import (
+	"context"
+	"encoding/json"
+	"io/ioutil"
+	"os"
+	"sort"
+	"sync"
+	"time"
+)
+
+// KeyEntry is one project key as stored in a FileKeystore.
+type KeyEntry struct {
+	PublicKey string `json:"public_key"`
+	SecretKey string `json:"secret_key,omitempty"`
+	ProjectID string `json:"project_id"`
+	KeyID     string `json:"key_id,omitempty"` //numeric, see KeyInfo
+	Disabled  bool   `json:"disabled,omitempty"`
+	Validity
+}
+
+func (e KeyEntry) equal(other KeyEntry) bool {
+	return e.PublicKey == other.PublicKey && e.SecretKey == other.SecretKey && e.ProjectID == other.ProjectID &&
+		e.KeyID == other.KeyID && e.Disabled == other.Disabled && e.Validity.equal(other.Validity)
+}
+
+// KeyEvent is the kind of change a FileKeystore reports to subscribers.
+type KeyEvent int
+
+const (
+	KeyAdded KeyEvent = iota
+	KeyRemoved
+	KeyUpdated  //secret, project, key ID or validity changed
+	KeyDisabled //Disabled went from false to true
+	KeyEnabled  //Disabled went from true to false
+)
+
+func (e KeyEvent) String() string {
+	switch e {
+	case KeyAdded:
+		return "added"
+	case KeyRemoved:
+		return "removed"
+	case KeyUpdated:
+		return "updated"
+	case KeyDisabled:
+		return "disabled"
+	case KeyEnabled:
+		return "enabled"
+	default:
+		return "unknown"
+	}
+}
+
+// KeyChange is sent to FileKeystore subscribers. Key holds the new entry, or the old one for KeyRemoved.
+type KeyChange struct {
+	Event KeyEvent
+	Key   KeyEntry
+}
+
+// keystoreFile is the JSON layout read by OpenFileKeystore.
+type keystoreFile struct {
+	Keys []KeyEntry `json:"keys"`
+}
+
+// FileKeystore is a KeyResolver backed by a JSON file of project keys.
+// Reload (or Watch) picks up edits without a restart and tells subscribers what changed.
+// A project may have several keys; each is only accepted within its Validity.
+// Disabled keys are rejected with ErrKeyDisabled. A FileKeystore is safe for concurrent use.
+type FileKeystore struct {
+	Logger Logger //receives Watch reload failures, nil to drop them
+
+	mu      sync.RWMutex
+	path    string
+	cipher  SecretCipher
+	keys    map[string]KeyEntry
+	ids     map[string]string //key ID to public key
+	modTime time.Time
+	subs    []func(KeyChange)
+}
+
+func OpenFileKeystore(path string, opts ...Option) (*FileKeystore, error) {
+	/*
+		Reads keys from a JSON file of the form {"keys": [{"public_key": "...", "project_id": "1", "key_id": "7"}]},
+		key_id being optional.
+		With WithSecretCipher secret keys may be stored sealed; they are decrypted on every Reload.
+	*/
+	ks := &FileKeystore{path: path, keys: map[string]KeyEntry{}, cipher: newConfig(opts).cipher}
+	if err := ks.Reload(); err != nil {
+		return nil, err
+	}
+	return ks, nil
+}
+
+func (ks *FileKeystore) ResolveKey(ctx context.Context, publicKey string) (*KeyInfo, error) {
+	ks.mu.RLock()
+	e, ok := ks.keys[publicKey]
+	ks.mu.RUnlock()
+	if !ok {
+		return nil, ErrUnknownKey
+	}
+	return e.info(), nil
+}
+
+func (ks *FileKeystore) ResolveKeyID(ctx context.Context, keyID string) (*KeyInfo, error) {
+	/*
+		Like ResolveKey, by key ID.
+	*/
+	ks.mu.RLock()
+	e, ok := ks.keys[ks.ids[keyID]]
+	ks.mu.RUnlock()
+	if !ok || len(keyID) == 0 {
+		return nil, ErrUnknownKey
+	}
+	return e.info(), nil
+}
+
+func (ks *FileKeystore) Key(key string) (KeyEntry, bool) {
+	/*
+		The entry with key as public key, or else as key ID.
+	*/
+	ks.mu.RLock()
+	defer ks.mu.RUnlock()
+	if e, ok := ks.keys[key]; ok {
+		return e, true
+	}
+	e, ok := ks.keys[ks.ids[key]]
+	return e, ok && len(key) > 0
+}
+
+func (e KeyEntry) info() *KeyInfo {
+	return &KeyInfo{PublicKey: e.PublicKey, SecretKey: e.SecretKey, ProjectID: e.ProjectID, KeyID: e.KeyID, Disabled: e.Disabled, Validity: e.Validity}
+}
+
+func (ks *FileKeystore) ProjectKeys(projectID string) []KeyEntry {
+	/*
+		All keys of projectID sorted by public key, e.g. to see which ones are still valid mid-rotation.
+	*/
+	var keys []KeyEntry
+	for _, e := range ks.Keys() {
+		if e.ProjectID == projectID {
+			keys = append(keys, e)
+		}
+	}
+	return keys
+}
+
+func (ks *FileKeystore) Keys() []KeyEntry {
+	/*
+		Snapshot of all keys, disabled ones included, sorted by public key.
+	*/
+	ks.mu.RLock()
+	defer ks.mu.RUnlock()
+	keys := make([]KeyEntry, 0, len(ks.keys))
+	for _, e := range ks.keys {
+		keys = append(keys, e)
+	}
+	sort.Slice(keys, func(i, j int) bool { return keys[i].PublicKey < keys[j].PublicKey })
+	return keys
+}
+
+func (ks *FileKeystore) Subscribe(fn func(KeyChange)) {
+	/*
+		fn is called after every Reload for each key that changed, sorted by public key.
+		Calls happen on the reloading goroutine and must not call back into Reload.
+	*/
+	ks.mu.Lock()
+	defer ks.mu.Unlock()
+	ks.subs = append(ks.subs, fn)
+}
+
+func (ks *FileKeystore) Reload() error {
+	/*
+		Re-reads the file. On error the current keys stay in place and nobody is notified.
+	*/
+	info, err := os.Stat(ks.path)
+	if err != nil {
+		return err
+	}
+	b, err := ioutil.ReadFile(ks.path)
+	if err != nil {
+		return err
+	}
+	var f keystoreFile
+	if err := json.Unmarshal(b, &f); err != nil {
+		return err
+	}
+	keys := make(map[string]KeyEntry, len(f.Keys))
+	ids := map[string]string{}
+	for _, e := range f.Keys {
+		if len(e.PublicKey) == 0 {
+			return ErrMissingUser
+		}
+		if !projectIDPattern.MatchString(e.ProjectID) {
+			return ErrMissingProjectID
+		}
+		if len(e.KeyID) > 0 {
+			if _, dup := ids[e.KeyID]; dup || !projectIDPattern.MatchString(e.KeyID) {
+				return ErrInvalidKeyID
+			}
+			ids[e.KeyID] = e.PublicKey
+		}
+		if e.SecretKey, err = openSecret(ks.cipher, e.SecretKey); err != nil {
+			return err
+		}
+		keys[e.PublicKey] = e
+	}
+
+	ks.mu.Lock()
+	changes := diffKeys(ks.keys, keys)
+	ks.keys, ks.ids, ks.modTime = keys, ids, info.ModTime()
+	subs := ks.subs
+	ks.mu.Unlock()
+
+	for _, c := range changes {
+		for _, fn := range subs {
+			fn(c)
+		}
+	}
+	return nil
+}
+
+func (ks *FileKeystore) Watch(ctx context.Context, interval time.Duration) error {
+	/*
+		Polls the file every interval and reloads it when its modification time changes, until ctx is done.
+		Failed reloads are logged and retried on the next tick. Returns ctx.Err().
+	*/
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-ticker.C:
+		}
+		info, err := os.Stat(ks.path)
+		ks.mu.RLock()
+		unchanged := err == nil && info.ModTime().Equal(ks.modTime)
+		ks.mu.RUnlock()
+		if unchanged {
+			continue
+		}
+		if err := ks.Reload(); err != nil && ks.Logger != nil {
+			ks.Logger.Printf("dsn: keystore %s not reloaded: %v", ks.path, err)
+		}
+	}
+}
+
+func diffKeys(old, cur map[string]KeyEntry) []KeyChange {
+	var changes []KeyChange
+	for k, n := range cur {
+		o, ok := old[k]
+		switch {
+		case !ok:
+			changes = append(changes, KeyChange{KeyAdded, n})
+		case !o.Disabled && n.Disabled:
+			changes = append(changes, KeyChange{KeyDisabled, n})
+		case o.Disabled && !n.Disabled:
+			changes = append(changes, KeyChange{KeyEnabled, n})
+		case !o.equal(n):
+			changes = append(changes, KeyChange{KeyUpdated, n})
+		}
+	}
+	for k, o := range old {
+		if _, ok := cur[k]; !ok {
+			changes = append(changes, KeyChange{KeyRemoved, o})
+		}
+	}
+	sort.Slice(changes, func(i, j int) bool { return changes[i].Key.PublicKey < changes[j].Key.PublicKey })
+	return changes
+}
@@ -0,0 +1,167 @@
+package dsn
+
+import (
+	"context"
+	"io/ioutil"
+	"net/http/httptest"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+type testKeystoreReload struct {
+	file        string
+	description string
+	expected    []KeyChange
+}
+
+var testTableKeystoreReload = []testKeystoreReload{
+	{`{"keys": [{"public_key": "` + testKeyA + `", "project_id": "1"}, {"public_key": "` + testKeyB + `", "project_id": "2"}]}`,
+		"Testing added key", []KeyChange{{KeyAdded, KeyEntry{PublicKey: testKeyB, ProjectID: "2"}}}},
+	{`{"keys": [{"public_key": "` + testKeyA + `", "project_id": "1"}, {"public_key": "` + testKeyB + `", "project_id": "2", "disabled": true}]}`,
+		"Testing disabled key", []KeyChange{{KeyDisabled, KeyEntry{PublicKey: testKeyB, ProjectID: "2", Disabled: true}}}},
+	{`{"keys": [{"public_key": "` + testKeyA + `", "project_id": "3"}]}`,
+		"Testing updated and removed keys", []KeyChange{
+			{KeyUpdated, KeyEntry{PublicKey: testKeyA, ProjectID: "3"}},
+			{KeyRemoved, KeyEntry{PublicKey: testKeyB, ProjectID: "2", Disabled: true}},
+		}},
+	{`{"keys": [{"public_key": "` + testKeyA + `", "project_id": "3"}]}`,
+		"Testing unchanged file", nil},
+	{`{"keys": [{"public_key": "` + testKeyA + `", "project_id": "3", "not_after": "2030-01-01T00:00:00+01:00"}]}`,
+		"Testing validity change", []KeyChange{{KeyUpdated, KeyEntry{PublicKey: testKeyA, ProjectID: "3",
+			Validity: Validity{NotAfter: time.Date(2029, 12, 31, 23, 0, 0, 0, time.UTC)}}}}},
+	{`{"keys": [{"public_key": "` + testKeyA + `", "project_id": "3", "not_after": "2030-01-01T00:00:00+01:00"}]}`,
+		"Testing unchanged validity", nil},
+}
+
+func TestFileKeystoreReload(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "keys.json")
+	write := func(s string) {
+		if err := ioutil.WriteFile(path, []byte(s), 0600); err != nil {
+			t.Fatal(err)
+		}
+	}
+	write(`{"keys": [{"public_key": "` + testKeyA + `", "project_id": "1"}]}`)
+	ks, err := OpenFileKeystore(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var got []KeyChange
+	ks.Subscribe(func(c KeyChange) { got = append(got, c) })
+	for _, test := range testTableKeystoreReload {
+		got = nil
+		write(test.file)
+		if err := ks.Reload(); err != nil {
+			t.Fatalf("%s: %v", test.description, err)
+		}
+		if len(got) != len(test.expected) {
+			t.Errorf("%s: Expected -- %v -- Got %v", test.description, test.expected, got)
+			continue
+		}
+		for i := range got {
+			if got[i].Event != test.expected[i].Event || !got[i].Key.equal(test.expected[i].Key) {
+				t.Errorf("%s: Expected -- %v -- Got %v", test.description, test.expected[i], got[i])
+			}
+		}
+	}
+
+	write(`{"keys": [{"public_key": "` + testKeyB + `", "project_id": "not a project"}]}`)
+	if err := ks.Reload(); err != ErrMissingProjectID {
+		t.Errorf("Expected -- %v -- Got %v", ErrMissingProjectID, err)
+	}
+	if info, err := ks.ResolveKey(context.Background(), testKeyA); err != nil || info.ProjectID != "3" {
+		t.Errorf("Expected -- old keys kept after failed reload -- Got %v %v", info, err)
+	}
+}
+
+func TestFileKeystoreResolve(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "keys.json")
+	ioutil.WriteFile(path, []byte(`{"keys": [{"public_key": "`+testKeyA+`", "project_id": "1"}, {"public_key": "`+testKeyB+`", "project_id": "1", "disabled": true}]}`), 0600)
+	ks, err := OpenFileKeystore(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	r := httptest.NewRequest("POST", "https://sentry.io/api/1/store/?sentry_key="+testKeyA, nil)
+	if _, err := FromRequest(r, WithKeyResolver(ks)); err != nil {
+		t.Errorf("Expected -- no error -- Got %v", err)
+	}
+	r = httptest.NewRequest("POST", "https://sentry.io/api/1/store/?sentry_key="+testKeyB, nil)
+	if _, err := FromRequest(r, WithKeyResolver(ks)); err != ErrKeyDisabled {
+		t.Errorf("Expected -- %v -- Got %v", ErrKeyDisabled, err)
+	}
+	if n := len(ks.Keys()); n != 2 {
+		t.Errorf("Expected -- 2 keys -- Got %d", n)
+	}
+	if n := len(ks.ProjectKeys("1")); n != 2 {
+		t.Errorf("Expected -- 2 keys for project 1 -- Got %d", n)
+	}
+}
+
+func TestFileKeystoreWatch(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "keys.json")
+	ioutil.WriteFile(path, []byte(`{"keys": []}`), 0600)
+	ks, err := OpenFileKeystore(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	added := make(chan KeyChange, 1)
+	ks.Subscribe(func(c KeyChange) { added <- c })
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	go ks.Watch(ctx, 5*time.Millisecond)
+
+	ioutil.WriteFile(path, []byte(`{"keys": [{"public_key": "`+testKeyA+`", "project_id": "1"}]}`), 0600)
+	ks.mu.Lock()
+	ks.modTime = time.Time{} //coarse file system timestamps may not move within the test
+	ks.mu.Unlock()
+	select {
+	case c := <-added:
+		if c.Event != KeyAdded || c.Key.PublicKey != testKeyA {
+			t.Errorf("Expected -- added %s -- Got %v", testKeyA, c)
+		}
+	case <-time.After(time.Second):
+		t.Errorf("Expected -- change notification -- Got none")
+	}
+}
+
+type testKeystoreLookup struct {
+	key         string
+	description string
+	expected    string
+	err         error
+}
+
+var testTableKeystoreLookup = []testKeystoreLookup{
+	{testKeyA, "Testing lookup by public key", testKeyA, nil},
+	{"7", "Testing lookup by key ID", testKeyA, nil},
+	{"8", "Testing unknown key ID", "", ErrUnknownKey},
+	{testKeyB, "Testing key without key ID", testKeyB, nil},
+}
+
+func TestFileKeystoreKeyID(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "keys.json")
+	ioutil.WriteFile(path, []byte(`{"keys": [{"public_key": "`+testKeyA+`", "project_id": "1", "key_id": "7"}, {"public_key": "`+testKeyB+`", "project_id": "1"}]}`), 0600)
+	ks, err := OpenFileKeystore(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, test := range testTableKeystoreLookup {
+		info, err := LookupKey(context.Background(), ks, test.key)
+		if err != test.err || (err == nil && info.PublicKey != test.expected) {
+			t.Errorf("%s: Expected -- %s %v -- Got %v %v", test.description, test.expected, test.err, info, err)
+		}
+		if e, ok := ks.Key(test.key); ok != (test.err == nil) || e.PublicKey != test.expected {
+			t.Errorf("%s: Expected -- entry %s -- Got %v %v", test.description, test.expected, e, ok)
+		}
+	}
+
+	for _, file := range []string{
+		`{"keys": [{"public_key": "` + testKeyA + `", "project_id": "1", "key_id": "x7"}]}`,
+		`{"keys": [{"public_key": "` + testKeyA + `", "project_id": "1", "key_id": "7"}, {"public_key": "` + testKeyB + `", "project_id": "1", "key_id": "7"}]}`,
+	} {
+		ioutil.WriteFile(path, []byte(file), 0600)
+		if err := ks.Reload(); err != ErrInvalidKeyID {
+			t.Errorf("Expected -- %v -- Got %v", ErrInvalidKeyID, err)
+		}
+	}
+}
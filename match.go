@@ -0,0 +1,153 @@
+package dsn
+
+import (
+	"errors"
+	"strings"
+)
+
+var (
+	// ErrInvalidPattern Thrown when a Matcher pattern is not shaped like a DSN
+	ErrInvalidPattern = errors.New("sentry:  invalid DSN pattern")
+	// ErrDSNNotAllowed Thrown when WithAllowedDSNs is set and no pattern matches the request's DSN
+	ErrDSNNotAllowed = errors.New("sentry:  DSN not allowed")
+)
+
+// Matcher matches DSNs against a pattern written like a DSN where * stands for any run of characters:
+//
+//	*@*.ingest.sentry.io/123        any key, any ingest host, project 123
+//	https://abc*@myhost/*           keys starting with abc on myhost, any project
+//
+// A pattern without scheme matches any scheme, one without port any port, and one without path any project.
+// The secret is only compared when the pattern has one. Matchers are immutable and safe for concurrent use.
+type Matcher struct {
+	pattern string
+	scheme  glob
+	key     glob
+	secret  glob
+	host    glob
+	port    glob
+	path    glob //matched against Path + "/" + ProjectID
+}
+
+func CompileMatcher(pattern string) (*Matcher, error) {
+	m := &Matcher{pattern: pattern}
+	rest := strings.TrimSpace(pattern)
+	scheme := "*"
+	if i := strings.Index(rest, "://"); i >= 0 {
+		scheme, rest = rest[:i], rest[i+3:]
+	}
+	at := strings.LastIndexByte(rest, '@')
+	if at < 0 {
+		return nil, ErrInvalidPattern
+	}
+	user, rest := rest[:at], rest[at+1:]
+	key, secret := user, "*"
+	if i := strings.IndexByte(user, ':'); i >= 0 {
+		key, secret = user[:i], user[i+1:]
+	}
+	hostport, path := rest, "/*"
+	if i := strings.IndexByte(rest, '/'); i >= 0 {
+		hostport, path = rest[:i], strings.TrimSuffix(rest[i:], "/")
+	}
+	host, port := hostport, "*"
+	if i := strings.LastIndexByte(hostport, ':'); i >= 0 && !strings.HasSuffix(hostport, "]") {
+		host, port = hostport[:i], hostport[i+1:]
+	}
+	if len(key) == 0 || len(host) == 0 || len(path) < 2 {
+		return nil, ErrInvalidPattern
+	}
+	m.scheme = compileGlob(strings.ToLower(scheme))
+	m.key = compileGlob(key)
+	m.secret = compileGlob(secret)
+	m.host = compileGlob(strings.ToLower(host))
+	m.port = compileGlob(port)
+	m.path = compileGlob(path)
+	return m, nil
+}
+
+func MustCompileMatcher(pattern string) *Matcher {
+	/*
+		Like CompileMatcher but panics on invalid patterns, for package level variables.
+	*/
+	m, err := CompileMatcher(pattern)
+	if err != nil {
+		panic(err.Error() + ": " + pattern)
+	}
+	return m
+}
+
+func (m *Matcher) String() string {
+	return m.pattern
+}
+
+func (m *Matcher) Match(d *DSN) bool {
+	if d == nil {
+		return false
+	}
+	scheme := d.Scheme
+	if len(scheme) == 0 {
+		scheme = "https"
+	}
+	project := d.ProjectID
+	if len(project) == 0 {
+		project = d.ProjectSlug
+	}
+	return m.key.match(d.PublicKey) &&
+		m.host.match(strings.ToLower(d.Host)) &&
+		m.path.match(d.Path+"/"+project) &&
+		m.scheme.match(strings.ToLower(scheme)) &&
+		m.port.match(d.Port) &&
+		m.secret.match(d.SecretKey)
+}
+
+// glob is a pattern where * matches any run of characters, split on * at compile time.
+// A pattern without * is a single literal part.
+type glob []string
+
+func compileGlob(pattern string) glob {
+	return strings.Split(pattern, "*")
+}
+
+func (g glob) match(s string) bool {
+	if len(g) == 1 {
+		return s == g[0]
+	}
+	if !strings.HasPrefix(s, g[0]) {
+		return false
+	}
+	s = s[len(g[0]):]
+	last := g[len(g)-1]
+	for _, part := range g[1 : len(g)-1] {
+		i := strings.Index(s, part)
+		if i < 0 {
+			return false
+		}
+		s = s[i+len(part):]
+	}
+	return len(s) >= len(last) && strings.HasSuffix(s, last)
+}
+
+func WithAllowedDSNs(matchers ...*Matcher) Option {
+	/*
+		Rejects requests whose DSN matches none of matchers with ErrDSNNotAllowed.
+		With no matchers at all every request is rejected, an empty allow list never means allow all.
+	*/
+	if matchers == nil {
+		matchers = []*Matcher{}
+	}
+	return func(c *config) {
+		c.allowedDSNs = matchers
+	}
+}
+
+func (c *config) checkAllowed(d *DSN) error {
+	if c.allowedDSNs == nil {
+		return nil
+	}
+	for _, m := range c.allowedDSNs {
+		if m.Match(d) {
+			return nil
+		}
+	}
+	return ErrDSNNotAllowed
+}
@@ -0,0 +1,78 @@
+package dsn
+
+import (
+	"net/http/httptest"
+	"testing"
+)
+
+type testMatch struct {
+	pattern     string
+	dsn         string
+	description string
+	expected    bool
+}
+
+var testTableMatch = []testMatch{
+	{"*@*.ingest.sentry.io/123", "https://" + testKeyA + "@o1.ingest.sentry.io/123", "Testing any key on ingest hosts", true},
+	{"*@*.ingest.sentry.io/123", "https://" + testKeyA + "@o1.ingest.sentry.io/124", "Testing other project", false},
+	{"*@*.ingest.sentry.io/123", "https://" + testKeyA + "@sentry.io/123", "Testing host without subdomain", false},
+	{"https://4784*@myhost/*", "https://" + testKeyA + "@myhost/9", "Testing key prefix", true},
+	{"https://4784*@myhost/*", "http://" + testKeyA + "@myhost/9", "Testing scheme mismatch", false},
+	{"https://4784*@myhost/*", "https://" + testKeyB + "@myhost/9", "Testing key prefix mismatch", false},
+	{"*@myhost", "https://" + testKeyA + "@myhost:9000/sentry/9", "Testing no path, port or scheme", true},
+	{"*@myhost:9000/sentry/*", "https://" + testKeyA + "@myhost/sentry/9", "Testing port mismatch", false},
+	{"*@MyHost/1", "https://" + testKeyA + "@myhost/1", "Testing host case", true},
+	{"*:*@myhost/1", "https://" + testKeyA + "@myhost/1", "Testing secret wildcard without secret", true},
+	{"*:" + testKeyB + "@myhost/1", "https://" + testKeyA + "@myhost/1", "Testing secret required", false},
+	{"*a*c*@h/1", "https://xaxbxcx@h/1", "Testing inner wildcards", true},
+	{"*ab*ba*@h/1", "https://aba@h/1", "Testing overlapping parts", false},
+}
+
+func TestMatcher(t *testing.T) {
+	for _, test := range testTableMatch {
+		m, err := CompileMatcher(test.pattern)
+		if err != nil {
+			t.Errorf("%s: Expected -- no error -- Got %v", test.description, err)
+			continue
+		}
+		d, err := Parse(test.dsn)
+		if err != nil {
+			t.Fatalf("%s: %v", test.description, err)
+		}
+		if got := m.Match(d); got != test.expected {
+			t.Errorf("%s: Expected -- %v -- Got %v", test.description, test.expected, got)
+		}
+	}
+}
+
+func TestMatcherInvalid(t *testing.T) {
+	for _, pattern := range []string{"sentry.io/1", "@host/1", "*@/1", "*@host/"} {
+		if _, err := CompileMatcher(pattern); err != ErrInvalidPattern {
+			t.Errorf("%s: Expected -- %v -- Got %v", pattern, ErrInvalidPattern, err)
+		}
+	}
+}
+
+func TestMatcherRouting(t *testing.T) {
+	up, _ := Parse("https://" + testKeyB + "@upstream.example.com/2")
+	rt := NewRouter()
+	rt.AddPattern(MustCompileMatcher("*@*.sentry.io/*"), up)
+	r := httptest.NewRequest("POST", "https://o1.sentry.io/api/1/store/?sentry_key="+testKeyA, nil)
+	if _, got, err := rt.RouteRequest(r.Context(), r); err != nil || got.URL != up.URL {
+		t.Errorf("Expected -- %s -- Got %v %v", up.URL, got, err)
+	}
+
+	allowed := WithAllowedDSNs(MustCompileMatcher("4784*@*/1"))
+	r = httptest.NewRequest("POST", "https://sentry.io/api/1/store/?sentry_key="+testKeyA, nil)
+	if _, err := FromRequest(r, allowed); err != nil {
+		t.Errorf("Expected -- allowed -- Got %v", err)
+	}
+	r = httptest.NewRequest("POST", "https://sentry.io/api/2/store/?sentry_key="+testKeyA, nil)
+	if _, err := FromRequest(r, allowed); err != ErrDSNNotAllowed {
+		t.Errorf("Expected -- %v -- Got %v", ErrDSNNotAllowed, err)
+	}
+	r = httptest.NewRequest("POST", "https://sentry.io/api/1/store/?sentry_key="+testKeyA, nil)
+	if _, err := FromRequest(r, WithAllowedDSNs()); err != ErrDSNNotAllowed {
+		t.Errorf("Testing empty allow list: Expected -- %v -- Got %v", ErrDSNNotAllowed, err)
+	}
+}
@@ -0,0 +1,127 @@
+package dsn
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/json"
+	"io"
+	"io/ioutil"
+)
+
+// EventMeta are the event fields relays commonly log and route on.
+type EventMeta struct {
+	EventID     string `json:"event_id,omitempty"`
+	Platform    string `json:"platform,omitempty"`
+	Release     string `json:"release,omitempty"`
+	Environment string `json:"environment,omitempty"`
+}
+
+func (m *EventMeta) complete() bool {
+	return m.EventID != "" && m.Platform != "" && m.Release != "" && m.Environment != ""
+}
+
+func ExtractEventMeta(r io.Reader, limit int64) (*EventMeta, error) {
+	/*
+		Reads event_id, platform, release and environment from a store payload or from the first event or
+		transaction item of an envelope. The body is streamed through a JSON decoder rather than buffered,
+		nested values are skipped and reading stops as soon as all four fields are known.
+		Reading more than limit bytes fails with a *TruncatedError, though fields found before that are still returned.
+	*/
+	lr := &io.LimitedReader{R: r, N: limit + 1}
+	meta := &EventMeta{}
+	fail := func(err error) (*EventMeta, error) {
+		if lr.N <= 0 {
+			return meta, &TruncatedError{Limit: limit}
+		}
+		return meta, err
+	}
+	// the first value is the event of a store payload or the header of an envelope
+	dec := json.NewDecoder(lr)
+	if err := scanMeta(dec, meta); err != nil {
+		return fail(ErrInvalidPayload)
+	}
+	if meta.complete() {
+		return meta, nil
+	}
+	rest := bufio.NewReader(io.MultiReader(dec.Buffered(), lr))
+	if _, err := rest.ReadBytes('\n'); err != nil {
+		// nothing after the first value, a store payload
+		return fail(nil)
+	}
+	for {
+		header, err := rest.ReadBytes('\n')
+		if len(bytes.TrimSpace(header)) == 0 && err != nil {
+			return fail(nil)
+		}
+		var h struct {
+			Type   string `json:"type"`
+			Length *int64 `json:"length"`
+		}
+		if json.Unmarshal(header, &h) != nil {
+			return fail(ErrInvalidEnvelope)
+		}
+		if h.Type == "event" || h.Type == "transaction" {
+			var payload io.Reader = rest
+			if h.Length != nil {
+				payload = io.LimitReader(rest, *h.Length)
+			}
+			if err := scanMeta(json.NewDecoder(payload), meta); err != nil {
+				return fail(ErrInvalidEnvelope)
+			}
+			return meta, nil
+		}
+		if h.Length != nil {
+			if _, err := io.CopyN(ioutil.Discard, rest, *h.Length); err != nil {
+				return fail(nil)
+			}
+		}
+		if _, err := rest.ReadBytes('\n'); err != nil {
+			return fail(nil)
+		}
+	}
+}
+
+func scanMeta(dec *json.Decoder, meta *EventMeta) error {
+	/*
+		Collects the EventMeta fields of the JSON object dec is positioned at, skipping everything else.
+	*/
+	if t, err := dec.Token(); err != nil {
+		return err
+	} else if t != json.Delim('{') {
+		return ErrInvalidPayload
+	}
+	for dec.More() {
+		t, err := dec.Token()
+		if err != nil {
+			return err
+		}
+		var field *string
+		switch t {
+		case "event_id":
+			field = &meta.EventID
+		case "platform":
+			field = &meta.Platform
+		case "release":
+			field = &meta.Release
+		case "environment":
+			field = &meta.Environment
+		default:
+			if err := skipValue(dec); err != nil {
+				return err
+			}
+			continue
+		}
+		var v interface{}
+		if err := dec.Decode(&v); err != nil {
+			return err
+		}
+		if s, ok := v.(string); ok && *field == "" {
+			*field = s
+		}
+		if meta.complete() {
+			return nil
+		}
+	}
+	_, err := dec.Token()
+	return err
+}
@@ -0,0 +1,44 @@
+package dsn
+
+import (
+	"errors"
+	"strings"
+	"testing"
+)
+
+const testMetaEvent = `{"event_id":"9ec79c33ec9942ab8353589fcb2e04dc","contexts":{"os":{"release":"nested"}},"platform":"go","release":"app@1.0.0","environment":"production","extra":{"big":"` + "xxxxxxxxxxxxxxxxxxxxxxxxxxxxxxxx" + `"}}`
+
+var testMetaFull = EventMeta{"9ec79c33ec9942ab8353589fcb2e04dc", "go", "app@1.0.0", "production"}
+
+type testMeta struct {
+	body        string
+	limit       int64
+	description string
+	expected    EventMeta
+	err         error
+}
+
+var testTableMeta = []testMeta{
+	{testMetaEvent, 1 << 10, "Testing store payload", testMetaFull, nil},
+	{"{\n  \"platform\": \"python\",\n  \"tags\": [[\"release\", \"no\"]]\n}\n", 1 << 10, "Testing pretty printed partial payload", EventMeta{Platform: "python"}, nil},
+	{testMetaEvent, 150, "Testing limit past the fields", testMetaFull, nil},
+	{testMetaEvent, 60, "Testing limit before the fields", EventMeta{EventID: "9ec79c33ec9942ab8353589fcb2e04dc"}, ErrBodyTruncated},
+	{"{\"event_id\":\"9ec79c33ec9942ab8353589fcb2e04dc\"}\n{\"type\":\"attachment\",\"length\":3}\n{\n}\n{\"type\":\"session\"}\n{\"release\":\"session@1\"}\n{\"type\":\"event\"}\n" + testMetaEvent + "\n", 1 << 10, "Testing envelope", testMetaFull, nil},
+	{"{}\n{\"type\":\"transaction\",\"length\":56}\n{\"platform\":\"javascript\",\"release\":\"web@2\",\"spans\":[]}\n", 1 << 10, "Testing envelope transaction with length", EventMeta{Platform: "javascript", Release: "web@2"}, nil},
+	{"{}\n{\"type\":\"session\"}\n{}\n", 1 << 10, "Testing envelope without event", EventMeta{}, nil},
+	{"{}\nnot json\n", 1 << 10, "Testing broken envelope", EventMeta{}, ErrInvalidEnvelope},
+	{"[]", 1 << 10, "Testing non object", EventMeta{}, ErrInvalidPayload},
+}
+
+func TestExtractEventMeta(t *testing.T) {
+	for _, test := range testTableMeta {
+		got, err := ExtractEventMeta(strings.NewReader(test.body), test.limit)
+		if !errors.Is(err, test.err) || (err == nil) != (test.err == nil) {
+			t.Errorf("%s: Expected -- %v -- Got %v", test.description, test.err, err)
+			continue
+		}
+		if *got != test.expected {
+			t.Errorf("%s: Expected -- %+v -- Got %+v", test.description, test.expected, *got)
+		}
+	}
+}
@@ -0,0 +1,103 @@
+package dsn
+
+import (
+	"errors"
+	"net/http"
+	"strings"
+)
+
+// ErrMethodNotAllowed Thrown when WithMethodCheck is set and a request uses a method ingest endpoints do not accept
+var ErrMethodNotAllowed = errors.New("sentry:  method not allowed")
+
+// MethodError carries the rejected method and the allowed ones, for the Allow response header.
+// errors.Is(err, ErrMethodNotAllowed) matches it.
+type MethodError struct {
+	Method  string
+	Allowed []string
+}
+
+func (e *MethodError) Error() string {
+	return ErrMethodNotAllowed.Error() + ": " + e.Method
+}
+
+func (e *MethodError) Is(target error) bool {
+	return target == ErrMethodNotAllowed
+}
+
+func WithMethodCheck(allowGET bool) Option {
+	/*
+		Only POST is accepted, plus GET with allowGET (or WithGetSubmissions) for old image beacon style store submissions
+		Everything else fails with a *MethodError (405 with an Allow header behind the middleware).
+		OPTIONS preflights never get this far behind NewMiddleware, which answers them with Preflight.
+	*/
+	return func(c *config) {
+		c.methods = []string{http.MethodPost, http.MethodOptions}
+		if allowGET {
+			c.methods = []string{http.MethodGet, http.MethodPost, http.MethodOptions}
+		}
+	}
+}
+
+func (c *config) checkMethod(r *http.Request) error {
+	if c.methods == nil {
+		return nil
+	}
+	method := r.Method
+	if len(method) == 0 {
+		method = http.MethodGet
+	}
+	if c.getSubmissions && method == http.MethodGet {
+		return nil
+	}
+	for _, m := range c.methods {
+		if m == method && m != http.MethodOptions {
+			return nil
+		}
+	}
+	return &MethodError{Method: method, Allowed: c.methods}
+}
+
+// CORS headers sent by SetCORSHeaders and Preflight. They match what Sentry itself answers browser SDKs with.
+var (
+	CORS_ALLOW_HEADERS  = "X-Sentry-Auth, X-Requested-With, Origin, Accept, Content-Type, Authentication, Authorization, Content-Encoding, sentry-trace, baggage"
+	CORS_ALLOW_METHODS  = "GET, POST, OPTIONS"
+	CORS_EXPOSE_HEADERS = "X-Sentry-Error, X-Sentry-Rate-Limits, Retry-After"
+	CORS_MAX_AGE        = "3600"
+)
+
+func SetCORSHeaders(w http.ResponseWriter, r *http.Request) {
+	/*
+		Lets browser SDKs read the response of a cross origin submission. No-op for requests without Origin.
+		Which origins may actually submit is decided per key, see Tenant.AllowedOrigins.
+	*/
+	origin := r.Header.Get("Origin")
+	if len(origin) == 0 {
+		return
+	}
+	h := w.Header()
+	h.Set("Access-Control-Allow-Origin", origin)
+	h.Add("Vary", "Origin")
+	h.Set("Access-Control-Expose-Headers", CORS_EXPOSE_HEADERS)
+}
+
+func Preflight(w http.ResponseWriter, r *http.Request) {
+	/*
+		Answers a CORS preflight (OPTIONS) for an ingest endpoint. Preflights carry no credentials,
+		so they are always allowed and the real request is checked when it arrives.
+	*/
+	SetCORSHeaders(w, r)
+	h := w.Header()
+	h.Set("Access-Control-Allow-Methods", CORS_ALLOW_METHODS)
+	h.Set("Access-Control-Allow-Headers", CORS_ALLOW_HEADERS)
+	h.Set("Access-Control-Max-Age", CORS_MAX_AGE)
+	h.Set("Allow", CORS_ALLOW_METHODS)
+	w.WriteHeader(http.StatusOK)
+}
+
+func allowHeader(err error) (string, bool) {
+	var merr *MethodError
+	if !errors.As(err, &merr) {
+		return "", false
+	}
+	return strings.Join(merr.Allowed, ", "), true
+}
@@ -0,0 +1,52 @@
+package dsn
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+type testMethod struct {
+	method      string
+	allowGET    bool
+	description string
+	status      int
+	allow       string
+}
+
+var testTableMethods = []testMethod{
+	{"POST", false, "Testing POST", http.StatusOK, ""},
+	{"GET", false, "Testing GET without option", http.StatusMethodNotAllowed, "POST, OPTIONS"},
+	{"GET", true, "Testing GET with option", http.StatusOK, ""},
+	{"HEAD", true, "Testing HEAD", http.StatusMethodNotAllowed, "GET, POST, OPTIONS"},
+	{"PUT", false, "Testing PUT", http.StatusMethodNotAllowed, "POST, OPTIONS"},
+	{"OPTIONS", false, "Testing preflight", http.StatusOK, CORS_ALLOW_METHODS},
+}
+
+func TestMethodCheck(t *testing.T) {
+	for _, test := range testTableMethods {
+		h := NewMiddleware(WithMethodCheck(test.allowGET))(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {}))
+		r := httptest.NewRequest(test.method, "https://sentry.io/api/1/store/?sentry_key="+testKeyA, nil)
+		r.Header.Set("Origin", "https://example.com")
+		w := httptest.NewRecorder()
+		h.ServeHTTP(w, r)
+		if w.Code != test.status || w.Header().Get("Allow") != test.allow {
+			t.Errorf("%s: Expected -- %d %q -- Got %d %q", test.description, test.status, test.allow, w.Code, w.Header().Get("Allow"))
+		}
+		if w.Header().Get("Access-Control-Allow-Origin") != "https://example.com" {
+			t.Errorf("%s: Expected -- CORS headers -- Got %v", test.description, w.Header())
+		}
+	}
+}
+
+func TestMethodError(t *testing.T) {
+	r := httptest.NewRequest("DELETE", "https://sentry.io/api/1/store/?sentry_key="+testKeyA, nil)
+	_, err := FromRequest(r, WithMethodCheck(false))
+	if merr, ok := err.(*MethodError); !ok || merr.Method != "DELETE" || ErrorStatus(err) != http.StatusMethodNotAllowed {
+		t.Errorf("Expected -- *MethodError for DELETE -- Got %v", err)
+	}
+	r = httptest.NewRequest("DELETE", "https://sentry.io/api/1/store/?sentry_key="+testKeyA, nil)
+	if _, err := FromRequest(r); err != nil {
+		t.Errorf("Expected -- methods unchecked by default -- Got %v", err)
+	}
+}
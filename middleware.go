@@ -0,0 +1,117 @@
+package dsn
+
+import (
+	"context"
+	"errors"
+	"net/http"
+)
+
+type contextKey struct{}
+
+type tenantContextKey struct{}
+
+type resultContextKey struct{}
+
+// X_SENTRY_ERROR is the response header SDKs read to explain why a submission was refused.
+var X_SENTRY_ERROR = "X-Sentry-Error"
+
+func NewContext(ctx context.Context, d *DSN) context.Context {
+	/*
+		Returns a copy of ctx carrying d. Used by Middleware and the router adapters.
+	*/
+	return context.WithValue(ctx, contextKey{}, d)
+}
+
+func FromContext(ctx context.Context) (*DSN, bool) {
+	/*
+		Returns the DSN stored by Middleware (or NewContext) if there is one.
+	*/
+	d, ok := ctx.Value(contextKey{}).(*DSN)
+	return d, ok && d != nil
+}
+
+func ResultFromContext(ctx context.Context) (*ParseResult, bool) {
+	/*
+		Returns the full ParseResult stored by NewMiddleware, e.g. for the endpoint of the request.
+	*/
+	res, ok := ctx.Value(resultContextKey{}).(*ParseResult)
+	return res, ok && res != nil
+}
+
+func TenantFromContext(ctx context.Context) (*Tenant, bool) {
+	/*
+		Returns the Tenant stored by a NewMiddleware configured WithRegistry.
+	*/
+	t, ok := ctx.Value(tenantContextKey{}).(*Tenant)
+	return t, ok && t != nil
+}
+
+func ErrorStatus(err error) int {
+	/*
+		Maps parse errors onto the status codes Sentry itself uses for the same failure so SDKs behave as usual.
+	*/
+	var verr *ValidationError
+	switch {
+	case err == nil:
+		return http.StatusOK
+	case errors.As(err, &verr) && len(verr.Errors) > 0:
+		return ErrorStatus(verr.Errors[0])
+	case errors.Is(err, ErrMissingUser), errors.Is(err, ErrUnknownKey), errors.Is(err, ErrSecretRequired),
+		errors.Is(err, ErrKeyExpired), errors.Is(err, ErrKeyNotYetValid):
+		return http.StatusUnauthorized
+	case errors.Is(err, ErrProjectMismatch), errors.Is(err, ErrEndpointNotAllowed), errors.Is(err, ErrOriginNotAllowed),
+		errors.Is(err, ErrKeyDisabled), errors.Is(err, ErrDSNNotAllowed):
+		return http.StatusForbidden
+	case errors.Is(err, ErrNotIngestEndpoint):
+		return http.StatusNotFound
+	case errors.Is(err, ErrPayloadTooLarge):
+		return http.StatusRequestEntityTooLarge
+	case errors.Is(err, ErrMethodNotAllowed):
+		return http.StatusMethodNotAllowed
+	case errors.Is(err, ErrUnsupportedEncoding):
+		return http.StatusUnsupportedMediaType
+	case errors.Is(err, ErrConcurrencyLimit), errors.Is(err, ErrSpikeLimited):
+		return http.StatusTooManyRequests
+	case errors.Is(err, ErrNotForwardable):
+		return http.StatusNotImplemented
+	case errors.Is(err, ErrUpstream):
+		return http.StatusBadGateway
+	case errors.Is(err, ErrQueueFull), errors.Is(err, ErrPipelineClosed), errors.Is(err, ErrCircuitOpen),
+		errors.Is(err, ErrProjectConfigPending):
+		return http.StatusServiceUnavailable
+	case errors.Is(err, context.DeadlineExceeded), errors.Is(err, context.Canceled):
+		return http.StatusServiceUnavailable
+	default:
+		return http.StatusBadRequest
+	}
+}
+
+func WriteError(w http.ResponseWriter, err error) {
+	/*
+		Writes the status from ErrorStatus along with the X-Sentry-Error header.
+	*/
+	w.Header().Set(X_SENTRY_ERROR, err.Error())
+	if allow, ok := allowHeader(err); ok {
+		w.Header().Set("Allow", allow)
+	}
+	WriteRateLimits(w, errorRateLimits(err))
+	http.Error(w, err.Error(), ErrorStatus(err))
+}
+
+func Middleware(next http.Handler) http.Handler {
+	/*
+		net/http middleware (usable as-is with chi, gorilla/mux and friends).
+		Requests that fail FromRequestContext are answered with WriteError and never reach next.
+		Otherwise the DSN is attached to the request context, retrieve it with FromContext(r.Context()).
+	*/
+	return defaultParser.Middleware(next)
+}
+
+func NewMiddleware(opts ...Option) func(http.Handler) http.Handler {
+	/*
+		Same as Middleware but parses with opts, e.g. NewMiddleware(WithKeyResolver(store)).
+		Lookups run under the request context so they stop when the client goes away.
+		OPTIONS requests are answered with Preflight, and responses to browser requests get SetCORSHeaders.
+	*/
+	return parserFor(opts).Middleware
+}
@@ -0,0 +1,66 @@
+package dsn
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func TestMiddleware(t *testing.T) {
+	var got *DSN
+	h := Middleware(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		got, _ = FromContext(r.Context())
+	}))
+	for _, test := range testTableLegacyUserInfo {
+		rb, _ := json.Marshal(test.body)
+		r := httptest.NewRequest("POST", test.url, bytes.NewBuffer(rb))
+		r.Header.Set("X-SENTRY-AUTH", strings.Join(test.header, ","))
+		w := httptest.NewRecorder()
+		got = nil
+		h.ServeHTTP(w, r)
+		if w.Code != http.StatusOK || got == nil || got.URL != test.expected {
+			t.Errorf("%s: Expected -- %s -- Got %d %v", test.description, test.expected, w.Code, got)
+		}
+	}
+}
+
+func TestMiddlewareRejects(t *testing.T) {
+	h := Middleware(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		t.Errorf("handler should not be called")
+	}))
+	for _, test := range testTableMissingUserInfo {
+		rb, _ := json.Marshal(test.body)
+		r := httptest.NewRequest("POST", test.url, bytes.NewBuffer(rb))
+		r.Header.Set("X-SENTRY-AUTH", strings.Join(test.header, ","))
+		w := httptest.NewRecorder()
+		h.ServeHTTP(w, r)
+		if w.Code != http.StatusUnauthorized || w.Header().Get("X-Sentry-Error") != ErrMissingUser.Error() {
+			t.Errorf("%s: Expected -- %d -- Got %d", test.description, http.StatusUnauthorized, w.Code)
+		}
+	}
+}
+
+func TestFromContextEmpty(t *testing.T) {
+	if d, ok := FromContext(httptest.NewRequest("GET", "/", nil).Context()); ok || d != nil {
+		t.Errorf("Expected -- no DSN -- Got %v", d)
+	}
+}
+
+func TestMiddlewareDisabledKey(t *testing.T) {
+	resolver := KeyResolverFunc(func(ctx context.Context, publicKey string) (*KeyInfo, error) {
+		return &KeyInfo{PublicKey: publicKey, ProjectID: "1", Disabled: true}, nil
+	})
+	h := NewMiddleware(WithKeyResolver(resolver))(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		t.Errorf("handler should not be called")
+	}))
+	r := httptest.NewRequest("POST", "https://sentry.io/api/1/store/?sentry_key="+testKeyA, nil)
+	w := httptest.NewRecorder()
+	h.ServeHTTP(w, r)
+	if w.Code != http.StatusForbidden || w.Header().Get("X-Sentry-Error") != ErrKeyDisabled.Error() {
+		t.Errorf("Expected -- %d %s -- Got %d %s", http.StatusForbidden, ErrKeyDisabled, w.Code, w.Header().Get("X-Sentry-Error"))
+	}
+}
@@ -0,0 +1,78 @@
+package dsn
+
+import (
+	"context"
+	"errors"
+	"io"
+	"io/ioutil"
+	"mime"
+	"mime/multipart"
+	"net/http"
+	"net/url"
+	"strings"
+)
+
+// ErrNotMultipart Thrown when form fields are requested from a request that is not multipart
+var ErrNotMultipart = errors.New("sentry:  not a multipart request")
+
+// maxFieldSize bounds a single form field value; attribution fields are a few dozen bytes.
+const maxFieldSize = 4 << 10
+
+// ctxReader fails reads once ctx is done, so a slow client can not hold a parse past its deadline.
+type ctxReader struct {
+	ctx context.Context
+	r   io.Reader
+}
+
+func (cr *ctxReader) Read(p []byte) (int, error) {
+	if err := cr.ctx.Err(); err != nil {
+		return 0, err
+	}
+	return cr.r.Read(p)
+}
+
+func MultipartFields(ctx context.Context, r *http.Request, limit int64) (url.Values, error) {
+	/*
+		Returns the plain form fields at the start of a multipart body (minidump and attachment uploads) without
+		touching its file parts: at most limit bytes are read as the body streams in, scanning stops at the first file part, and r.Body
+		is restored so the upload can be forwarded byte for byte. Crash reporters send their fields first; fields
+		after a file part or past limit are not seen. Reading stops with ctx.Err() once ctx is done.
+	*/
+	mediaType, params, err := mime.ParseMediaType(r.Header.Get("Content-Type"))
+	if err != nil || !strings.HasPrefix(mediaType, "multipart/") || len(params["boundary"]) == 0 {
+		return nil, ErrNotMultipart
+	}
+	fields := url.Values{}
+	err = NewLimitedPeeker(limit).PeekFunc(r, func(body io.Reader) error {
+		mr := multipart.NewReader(&ctxReader{ctx: ctx, r: body}, params["boundary"])
+		for {
+			part, err := mr.NextPart()
+			if err != nil || len(part.FileName()) > 0 {
+				// end of the form, the limit, or the first file
+				return ctx.Err()
+			}
+			value, err := ioutil.ReadAll(io.LimitReader(part, maxFieldSize+1))
+			if err != nil || len(value) > maxFieldSize {
+				return ctx.Err()
+			}
+			if name := part.FormName(); len(name) > 0 {
+				fields.Add(name, string(value))
+			}
+		}
+	})
+	if err != nil {
+		return nil, err
+	}
+	return fields, nil
+}
+
+func parseAuthMultipart(r *http.Request) (Auth, bool) {
+	/*
+		Breakpad and Crashpad style uploads may carry sentry_key (and friends) as form fields.
+	*/
+	fields, err := MultipartFields(r.Context(), r, DefaultBodyPeekLimit)
+	if err != nil || len(fields) == 0 {
+		return Auth{}, false
+	}
+	return parseAuthQuery(fields.Encode())
+}
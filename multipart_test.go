@@ -0,0 +1,89 @@
+package dsn
+
+import (
+	"bytes"
+	"context"
+	"io"
+	"io/ioutil"
+	"mime/multipart"
+	"net/http/httptest"
+	"testing"
+)
+
+// countingReader counts how much of the body was read.
+type countingReader struct {
+	r io.Reader
+	n int
+}
+
+func (c *countingReader) Read(p []byte) (int, error) {
+	n, err := c.r.Read(p)
+	c.n += n
+	return n, err
+}
+
+func newMinidumpUpload(fields map[string]string, dumpSize int) (*bytes.Buffer, string) {
+	var buf bytes.Buffer
+	mw := multipart.NewWriter(&buf)
+	for _, k := range []string{"sentry_key", "sentry_secret", "sentry[release]"} {
+		if v, ok := fields[k]; ok {
+			mw.WriteField(k, v)
+		}
+	}
+	fw, _ := mw.CreateFormFile("upload_file_minidump", "crash.dmp")
+	fw.Write(bytes.Repeat([]byte("M"), dumpSize))
+	mw.WriteField("after_file", "unseen")
+	mw.Close()
+	return &buf, mw.FormDataContentType()
+}
+
+func TestMultipartFields(t *testing.T) {
+	body, contentType := newMinidumpUpload(map[string]string{"sentry_key": testKeyA, "sentry[release]": "app@1"}, 1<<20)
+	sent := append([]byte(nil), body.Bytes()...)
+	counter := &countingReader{r: body}
+	r := httptest.NewRequest("POST", "https://sentry.io/api/1/minidump/", counter)
+	r.Header.Set("Content-Type", contentType)
+	fields, err := MultipartFields(context.Background(), r, 4<<10)
+	if err != nil || fields.Get("sentry_key") != testKeyA || fields.Get("sentry[release]") != "app@1" || fields.Get("after_file") != "" {
+		t.Errorf("Expected -- leading fields only -- Got %v %v", fields, err)
+	}
+	if counter.n > 4<<10 {
+		t.Errorf("Expected -- at most 4KB read -- Got %d", counter.n)
+	}
+	if rest, _ := ioutil.ReadAll(r.Body); !bytes.Equal(rest, sent) {
+		t.Errorf("Expected -- body restored -- Got %d of %d bytes", len(rest), len(sent))
+	}
+}
+
+func TestMultipartFieldsErrors(t *testing.T) {
+	r := httptest.NewRequest("POST", "https://sentry.io/api/1/minidump/", bytes.NewBufferString(testEvent))
+	r.Header.Set("Content-Type", "application/json")
+	if _, err := MultipartFields(context.Background(), r, 1<<10); err != ErrNotMultipart {
+		t.Errorf("Expected -- %v -- Got %v", ErrNotMultipart, err)
+	}
+	body, contentType := newMinidumpUpload(map[string]string{"sentry_key": testKeyA}, 1<<10)
+	sent := append([]byte(nil), body.Bytes()...)
+	r = httptest.NewRequest("POST", "https://sentry.io/api/1/minidump/", body)
+	r.Header.Set("Content-Type", contentType)
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	if _, err := MultipartFields(ctx, r, 1<<10); err != context.Canceled {
+		t.Errorf("Expected -- %v -- Got %v", context.Canceled, err)
+	}
+	if rest, _ := ioutil.ReadAll(r.Body); !bytes.Equal(rest, sent) {
+		t.Errorf("Expected -- body untouched after cancel -- Got %d of %d bytes", len(rest), len(sent))
+	}
+}
+
+func TestSourceMultipart(t *testing.T) {
+	body, contentType := newMinidumpUpload(map[string]string{"sentry_key": testKeyA, "sentry_secret": testKeyB}, 1<<10)
+	r := httptest.NewRequest("POST", "https://sentry.io/api/1/minidump/", body)
+	r.Header.Set("Content-Type", contentType)
+	res, err := ParseRequest(r.Context(), r, WithCredentialSources(SourceHeader, SourceQuery, SourceMultipart))
+	if err != nil || res.DSN.PublicKey != testKeyA || res.DSN.SecretKey != testKeyB || res.KeySource != SourceMultipart {
+		t.Errorf("Expected -- keys from form fields -- Got %v %v", res, err)
+	}
+	if res != nil && res.Endpoint != EndpointMinidump {
+		t.Errorf("Expected -- %s -- Got %s", EndpointMinidump, res.Endpoint)
+	}
+}
@@ -0,0 +1,56 @@
+package dsn
+
+import (
+	"strings"
+
+	"github.com/dgbailey/dsn/internal/keys"
+)
+
+var defaultPorts = map[string]string{"http": "80", "https": "443"}
+
+func (d *DSN) Normalize() *DSN {
+	/*
+		Returns a canonical copy so two DSNs for the same project compare equal and make stable cache keys:
+		scheme and host are lower cased, default ports dropped, repeated slashes in the path collapsed,
+		hex keys lower cased, and URL re-rendered. d itself is not modified.
+	*/
+	n := d.Clone()
+	n.Scheme = strings.ToLower(n.Scheme)
+	if len(n.Scheme) == 0 {
+		n.Scheme = "https"
+	}
+	n.Host = strings.ToLower(strings.TrimSuffix(n.Host, "."))
+	if n.Port == defaultPorts[n.Scheme] {
+		n.Port = ""
+	}
+	n.Path = collapseSlashes(n.Path)
+	n.PublicKey = keys.Lower(n.PublicKey)
+	n.SecretKey = keys.Lower(n.SecretKey)
+	n.URL = n.String()
+	return n
+}
+
+func collapseSlashes(path string) string {
+	if !strings.Contains(path, "//") && !strings.HasSuffix(path, "/") {
+		return path
+	}
+	var b strings.Builder
+	for i := 0; i < len(path); i++ {
+		if path[i] == '/' && (i+1 == len(path) || path[i+1] == '/') {
+			continue
+		}
+		b.WriteByte(path[i])
+	}
+	return b.String()
+}
+
+func WithKeyNormalization() Option {
+	/*
+		Accepts keys that intermediate tooling reformatted: upper case hex, and dashed UUIDs such as
+		4784FBC5-0DE2-473F-9977-CFCE8A9ADCE5. They are rewritten to 32 lower case hex characters before
+		anything else sees them. Without this option such keys are ignored.
+	*/
+	return func(c *config) {
+		c.normalizeKeys = true
+	}
+}
@@ -0,0 +1,38 @@
+package dsn
+
+import (
+	"testing"
+)
+
+type testNormalize struct {
+	dsn         *DSN
+	description string
+	expected    string
+}
+
+var testTableNormalize = []testNormalize{
+	{&DSN{Scheme: "HTTPS", Host: "O1.Ingest.Sentry.IO", Port: "443", ProjectID: "1", PublicKey: "4784FBC50DE2473F9977CFCE8A9ADCE5"},
+		"Testing case and default port", "https://4784fbc50de2473f9977cfce8a9adce5@o1.ingest.sentry.io/1"},
+	{&DSN{Scheme: "http", Host: "localhost", Port: "80", Path: "//sentry//relay/", ProjectID: "1", PublicKey: "4784fbc50de2473f9977cfce8a9adce5"},
+		"Testing path slashes", "http://4784fbc50de2473f9977cfce8a9adce5@localhost/sentry/relay/1"},
+	{&DSN{Scheme: "http", Host: "localhost", Port: "9000", ProjectID: "1", PublicKey: "not-a-hex-key"},
+		"Testing non default port and non hex key", "http://not-a-hex-key@localhost:9000/1"},
+	{CreateDSN(&User{PublicKey: "4784fbc50de2473f9977cfce8a9adce5"}, "sentry.io.", "1234"),
+		"Testing request derived DSN", "https://4784fbc50de2473f9977cfce8a9adce5@sentry.io/1234"},
+}
+
+func TestNormalize(t *testing.T) {
+	for _, test := range testTableNormalize {
+		before := test.dsn.String()
+		got := test.dsn.Normalize()
+		if got.URL != test.expected || got.String() != test.expected {
+			t.Errorf("%s: Expected -- %s -- Got %s", test.description, test.expected, got.URL)
+		}
+		if test.dsn.String() != before {
+			t.Errorf("%s: Expected -- original untouched -- Got %s", test.description, test.dsn.String())
+		}
+		if again := got.Normalize(); again.URL != got.URL {
+			t.Errorf("%s: Expected -- idempotent -- Got %s", test.description, again.URL)
+		}
+	}
+}
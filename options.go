@@ -0,0 +1,197 @@
+package dsn
+
+import (
+	"context"
+	"errors"
+	"time"
+)
+
+var (
+	// ErrUnknownKey Thrown when a configured KeyResolver does not know the public key
+	ErrUnknownKey = errors.New("sentry:  unknown public key")
+	// ErrKeyDisabled Thrown when a KeyResolver reports the public key as disabled
+	ErrKeyDisabled = errors.New("sentry:  API key is disabled")
+	// ErrProjectMismatch Thrown when the project in the path does not own the public key
+	ErrProjectMismatch = errors.New("sentry:  public key does not belong to project")
+	// ErrInvalidKeyID Thrown when a keystore holds a key ID that is not numeric or belongs to two keys
+	ErrInvalidKeyID = errors.New("sentry:  invalid key ID")
+)
+
+// DefaultResolverTimeout bounds a single KeyResolver lookup when no WithResolverTimeout option is given.
+var DefaultResolverTimeout = 2 * time.Second
+
+// KeyInfo is what a KeyResolver knows about a public key.
+type KeyInfo struct {
+	PublicKey string
+	SecretKey string //only needed to verify legacy signatures, see WithSignatureVerification
+	ProjectID string
+	KeyID     string //numeric ID of the key in Sentry's management API, empty when unknown
+	Disabled  bool   //deactivated keys are answered with ErrKeyDisabled
+	Validity         //checked on every request, see ErrKeyExpired
+}
+
+// KeyResolver looks up public keys, typically in a keystore or database.
+// Implementations must honour ctx cancellation.
+type KeyResolver interface {
+	ResolveKey(ctx context.Context, publicKey string) (*KeyInfo, error)
+}
+
+// KeyResolverFunc adapts a plain function to KeyResolver.
+type KeyResolverFunc func(ctx context.Context, publicKey string) (*KeyInfo, error)
+
+func (f KeyResolverFunc) ResolveKey(ctx context.Context, publicKey string) (*KeyInfo, error) {
+	return f(ctx, publicKey)
+}
+
+// KeyIDResolver is implemented by KeyResolvers that can also look keys up by their numeric key ID,
+// as used by the Sentry management API.
+type KeyIDResolver interface {
+	ResolveKeyID(ctx context.Context, keyID string) (*KeyInfo, error)
+}
+
+func LookupKey(ctx context.Context, r KeyResolver, key string) (*KeyInfo, error) {
+	/*
+		Resolves key as a public key, or as a key ID when it is numeric and not 32 characters long and r is a
+		KeyIDResolver. Meant for tooling cross-referencing management API output, the parser only uses public keys.
+	*/
+	if ids, ok := r.(KeyIDResolver); ok && len(key) != 32 && projectIDPattern.MatchString(key) {
+		return ids.ResolveKeyID(ctx, key)
+	}
+	return r.ResolveKey(ctx, key)
+}
+
+// Logger receives warnings about requests that were accepted anyway. *log.Logger satisfies it.
+type Logger interface {
+	Printf(format string, v ...interface{})
+}
+
+// Option configures FromRequestContext and friends.
+type Option func(*config)
+
+type config struct {
+	resolver        KeyResolver
+	resolverTimeout time.Duration
+	slugs           bool
+	slugResolver    SlugResolver
+	collectAll      bool
+	signatures      *signatureConfig
+	protocol        *ProtocolPolicy
+	sources         []Source
+	mergeSources    bool
+	querySecret     QuerySecretPolicy
+	logger          Logger
+	registry        *Registry
+	scrubber        Scrubber
+	methods         []string
+	getSubmissions  bool
+	allowedDSNs     []*Matcher
+	stats           *Stats
+	clock           Clock
+	ids             IDGenerator
+	idempotency     *LimitedPeeker
+	sizeLimits      SizeLimits
+	itemSizeLimits  ItemSizeLimits
+	itemOutcomes    *OutcomeRecorder
+	itemCounts      *LimitedPeeker
+	classify        *LimitedPeeker
+	authHeader      string //canonical, empty to read HTTP_X_SENTRY_AUTH per request
+	maxKeyAge       time.Duration
+	filters         *FilterChain
+	trustedProxies  TrustedProxies //nil leaves ParseResult.ClientIP unset
+	replay          *ReplayGuard
+	normalizeKeys   bool
+	conflicts       ConflictPolicy
+	tlsPolicy       TLSPolicy
+	cipher          SecretCipher
+	trace           bool
+	projectStats    *ProjectStats
+	spikes          *SpikeProtection
+	unixSocketDirs  []string //see WithUnixSockets
+}
+
+// shared by option-less calls so the common path does not allocate; without options there is nothing to time out
+var defaultConfig = &config{}
+
+func newConfig(opts []Option) *config {
+	if len(opts) == 0 {
+		return defaultConfig
+	}
+	c := &config{resolverTimeout: DefaultResolverTimeout}
+	for _, opt := range opts {
+		opt(c)
+	}
+	return c
+}
+
+func WithLogger(l Logger) Option {
+	/*
+		Sends warnings (see WithQuerySecretPolicy) to l. Nothing is logged by default.
+	*/
+	return func(c *config) {
+		c.logger = l
+	}
+}
+
+func (c *config) logf(format string, v ...interface{}) {
+	if c.logger != nil {
+		c.logger.Printf(format, v...)
+	}
+}
+
+func WithKeyResolver(kr KeyResolver) Option {
+	/*
+		Every parsed public key is looked up with kr. Unknown keys are rejected and the legacy
+		/api/store/ endpoint gets its project ID from the resolver instead of returning an empty DSN.URL.
+	*/
+	return func(c *config) {
+		c.resolver = kr
+	}
+}
+
+func WithResolverTimeout(d time.Duration) Option {
+	/*
+		Upper bound for a single resolver lookup. The caller's context deadline still applies if it is sooner.
+		Zero disables the extra bound.
+	*/
+	return func(c *config) {
+		c.resolverTimeout = d
+	}
+}
+
+func (c *config) resolve(ctx context.Context, user *User, projectID string) (*KeyInfo, string, error) {
+	/*
+		Returns the resolver's KeyInfo (nil without a resolver) and the project ID to use for the DSN.
+	*/
+	if c.resolver == nil {
+		return nil, projectID, nil
+	}
+	if c.resolverTimeout > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, c.resolverTimeout)
+		defer cancel()
+	}
+	info, err := c.resolver.ResolveKey(ctx, user.PublicKey)
+	if err != nil {
+		return nil, "", err
+	}
+	if info == nil {
+		return nil, "", ErrUnknownKey
+	}
+	if info.Disabled {
+		return nil, "", ErrKeyDisabled
+	}
+	now := c.now()
+	if err := info.Check(now); err != nil {
+		return nil, "", err
+	}
+	if expires := info.Expires(c.maxKeyAge); !expires.IsZero() && !now.Before(expires) {
+		return nil, "", ErrKeyExpired
+	}
+	if len(projectID) == 0 {
+		return info, info.ProjectID, nil
+	}
+	if len(info.ProjectID) > 0 && info.ProjectID != projectID {
+		return nil, "", ErrProjectMismatch
+	}
+	return info, projectID, nil
+}
@@ -0,0 +1,64 @@
+package dsn
+
+import (
+	"context"
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+var testKeys = KeyResolverFunc(func(ctx context.Context, publicKey string) (*KeyInfo, error) {
+	if publicKey == "4784fbc50de2473f9977cfce8a9adce5" {
+		return &KeyInfo{PublicKey: publicKey, ProjectID: "1234"}, nil
+	}
+	return nil, ErrUnknownKey
+})
+
+type testResolve struct {
+	url         string
+	description string
+	expected    string
+	err         error
+}
+
+var testTableResolver = []testResolve{
+	{"https://sentry.io/api/store/?sentry_key=4784fbc50de2473f9977cfce8a9adce5", "Testing legacy path resolved by key",
+		"https://4784fbc50de2473f9977cfce8a9adce5@sentry.io/1234", nil},
+	{"https://sentry.io/api/1234/store/?sentry_key=4784fbc50de2473f9977cfce8a9adce5", "Testing matching project",
+		"https://4784fbc50de2473f9977cfce8a9adce5@sentry.io/1234", nil},
+	{"https://sentry.io/api/99/store/?sentry_key=4784fbc50de2473f9977cfce8a9adce5", "Testing mismatched project",
+		"", ErrProjectMismatch},
+	{"https://sentry.io/api/1234/store/?sentry_key=00000000000000000000000000000000", "Testing unknown key",
+		"", ErrUnknownKey},
+}
+
+func TestFromRequestContextResolver(t *testing.T) {
+	for _, test := range testTableResolver {
+		r := httptest.NewRequest("POST", test.url, nil)
+		got, err := FromRequestContext(context.Background(), r, WithKeyResolver(testKeys))
+		if err != test.err {
+			t.Errorf("%s: Expected -- %v -- Got %v", test.description, test.err, err)
+		} else if got != nil && got.URL != test.expected {
+			t.Errorf("%s: Expected -- %s -- Got %s", test.description, test.expected, got.URL)
+		}
+	}
+}
+
+func TestResolverTimeout(t *testing.T) {
+	slow := KeyResolverFunc(func(ctx context.Context, publicKey string) (*KeyInfo, error) {
+		<-ctx.Done()
+		return nil, ctx.Err()
+	})
+	r := httptest.NewRequest("POST", testTableResolver[0].url, nil)
+	_, err := FromRequest(r, WithKeyResolver(slow), WithResolverTimeout(time.Millisecond))
+	if err != context.DeadlineExceeded {
+		t.Errorf("Expected -- %s -- Got %v", context.DeadlineExceeded, err)
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	_, err = FromRequestContext(ctx, r, WithKeyResolver(slow))
+	if err != context.Canceled {
+		t.Errorf("Expected -- %s -- Got %v", context.Canceled, err)
+	}
+}
@@ -0,0 +1,181 @@
+package dsn
+
+import (
+	"encoding/json"
+	"sort"
+	"sync"
+)
+
+// Outcome is what happened to a submission, following the outcomes Sentry's Relay reports.
+type Outcome int
+
+const (
+	OutcomeAccepted Outcome = iota
+	OutcomeFiltered
+	OutcomeRateLimited
+	OutcomeInvalid
+)
+
+func (o Outcome) String() string {
+	switch o {
+	case OutcomeAccepted:
+		return "accepted"
+	case OutcomeFiltered:
+		return "filtered"
+	case OutcomeRateLimited:
+		return "rate_limited"
+	case OutcomeInvalid:
+		return "invalid"
+	default:
+		return "unknown"
+	}
+}
+
+// Data categories used in client reports. Any other Sentry category string works as well.
+const (
+	CategoryError       = "error"
+	CategoryTransaction = "transaction"
+	CategorySession     = "session"
+	CategoryAttachment  = "attachment"
+	CategoryDefault     = "default"
+)
+
+// OutcomeCount is one aggregated line of an OutcomeRecorder.
+type OutcomeCount struct {
+	DSN      *DSN
+	Category string
+	Outcome  Outcome
+	Reason   string //free form, e.g. "browser-extensions"; defaults to the outcome name in client reports
+	Quantity int64
+}
+
+// clientReport is the payload of a client_report envelope item.
+type clientReport struct {
+	Timestamp float64          `json:"timestamp"`
+	Discarded []discardedEvent `json:"discarded_events"`
+}
+
+type discardedEvent struct {
+	Reason   string `json:"reason"`
+	Category string `json:"category"`
+	Quantity int64  `json:"quantity"`
+}
+
+const clientReportHeader = `{"type":"client_report"}`
+
+type outcomeKey struct {
+	fingerprint string
+	category    string
+	outcome     Outcome
+	reason      string
+}
+
+// OutcomeRecorder aggregates outcomes per DSN, category and reason until they are drained,
+// typically into client_report items sent upstream. It is safe for concurrent use.
+type OutcomeRecorder struct {
+	mu     sync.Mutex
+	config *config
+	counts map[outcomeKey]*OutcomeCount
+}
+
+func NewOutcomeRecorder(opts ...Option) *OutcomeRecorder {
+	/*
+		Only WithClock applies, client report timestamps come from it.
+	*/
+	return &OutcomeRecorder{config: newConfig(opts), counts: map[outcomeKey]*OutcomeCount{}}
+}
+
+func (rec *OutcomeRecorder) Record(d *DSN, category string, outcome Outcome, reason string, quantity int64) {
+	/*
+		Adds quantity items of category with the given outcome for d.
+	*/
+	k := outcomeKey{d.Fingerprint(), category, outcome, reason}
+	rec.mu.Lock()
+	defer rec.mu.Unlock()
+	if c, ok := rec.counts[k]; ok {
+		c.Quantity += quantity
+		return
+	}
+	rec.counts[k] = &OutcomeCount{DSN: d, Category: category, Outcome: outcome, Reason: reason, Quantity: quantity}
+}
+
+func (rec *OutcomeRecorder) Snapshot() []OutcomeCount {
+	/*
+		Current counts sorted by DSN fingerprint, category, outcome and reason.
+	*/
+	rec.mu.Lock()
+	out := make([]OutcomeCount, 0, len(rec.counts))
+	for _, c := range rec.counts {
+		out = append(out, *c)
+	}
+	rec.mu.Unlock()
+	sortOutcomes(out)
+	return out
+}
+
+func (rec *OutcomeRecorder) Drain(d *DSN) []OutcomeCount {
+	/*
+		Removes and returns the counts of d, sorted like Snapshot.
+	*/
+	fp := d.Fingerprint()
+	rec.mu.Lock()
+	var out []OutcomeCount
+	for k, c := range rec.counts {
+		if k.fingerprint == fp {
+			out = append(out, *c)
+			delete(rec.counts, k)
+		}
+	}
+	rec.mu.Unlock()
+	sortOutcomes(out)
+	return out
+}
+
+func (rec *OutcomeRecorder) ClientReport(d *DSN) ([]byte, bool, error) {
+	/*
+		Drains d and renders its discarded items as a client_report envelope item (item header and payload
+		lines, ready to append to an envelope for d). Accepted outcomes are dropped since client reports only
+		carry discards. ok is false when there is nothing to report.
+	*/
+	var report clientReport
+	report.Timestamp = float64(rec.config.now().UnixNano()) / 1e9
+	for _, c := range rec.Drain(d) {
+		if c.Outcome == OutcomeAccepted {
+			continue
+		}
+		reason := c.Reason
+		if len(reason) == 0 {
+			reason = c.Outcome.String()
+		}
+		report.Discarded = append(report.Discarded, discardedEvent{reason, c.Category, c.Quantity})
+	}
+	if len(report.Discarded) == 0 {
+		return nil, false, nil
+	}
+	payload, err := json.Marshal(report)
+	if err != nil {
+		return nil, false, err
+	}
+	item := make([]byte, 0, len(clientReportHeader)+len(payload)+2)
+	item = append(item, clientReportHeader...)
+	item = append(item, '\n')
+	item = append(item, payload...)
+	item = append(item, '\n')
+	return item, true, nil
+}
+
+func sortOutcomes(out []OutcomeCount) {
+	sort.Slice(out, func(i, j int) bool {
+		a, b := out[i], out[j]
+		if fa, fb := a.DSN.Fingerprint(), b.DSN.Fingerprint(); fa != fb {
+			return fa < fb
+		}
+		if a.Category != b.Category {
+			return a.Category < b.Category
+		}
+		if a.Outcome != b.Outcome {
+			return a.Outcome < b.Outcome
+		}
+		return a.Reason < b.Reason
+	})
+}
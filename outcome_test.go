@@ -0,0 +1,37 @@
+package dsn
+
+import (
+	"testing"
+	"time"
+)
+
+func TestOutcomeRecorder(t *testing.T) {
+	a, _ := Parse("https://" + testKeyA + "@sentry.io/1")
+	b, _ := Parse("https://" + testKeyB + "@sentry.io/2")
+	rec := NewOutcomeRecorder(WithClock(ClockFunc(func() time.Time { return time.Unix(1600000000, 500000000) })))
+	rec.Record(a, CategoryError, OutcomeAccepted, "", 5)
+	rec.Record(a, CategoryError, OutcomeRateLimited, "", 2)
+	rec.Record(a, CategoryError, OutcomeRateLimited, "", 1)
+	rec.Record(a, CategoryTransaction, OutcomeFiltered, "browser-extensions", 4)
+	rec.Record(b, CategoryError, OutcomeInvalid, "", 1)
+	if n := len(rec.Snapshot()); n != 4 {
+		t.Errorf("Expected -- 4 counts -- Got %d", n)
+	}
+
+	item, ok, err := rec.ClientReport(a)
+	expected := `{"type":"client_report"}` + "\n" +
+		`{"timestamp":1600000000.5,"discarded_events":[{"reason":"rate_limited","category":"error","quantity":3},` +
+		`{"reason":"browser-extensions","category":"transaction","quantity":4}]}` + "\n"
+	if err != nil || !ok || string(item) != expected {
+		t.Errorf("Expected -- %s -- Got %s %v", expected, item, err)
+	}
+	if _, items, err := splitEnvelope(append([]byte("{}\n"), item...)); err != nil || len(items) != 1 {
+		t.Errorf("Expected -- a valid envelope item -- Got %v", err)
+	}
+	if _, ok, _ := rec.ClientReport(a); ok {
+		t.Errorf("Expected -- drained -- Got another report")
+	}
+	if got := rec.Snapshot(); len(got) != 1 || got[0].DSN != b || got[0].Outcome != OutcomeInvalid {
+		t.Errorf("Expected -- only the invalid count of b left -- Got %+v", got)
+	}
+}
@@ -0,0 +1,161 @@
+package dsn
+
+import (
+	"errors"
+	"net/url"
+	"regexp"
+	"strings"
+)
+
+var (
+	// ErrInvalidDSN Thrown when a DSN string is not a URL at all
+	ErrInvalidDSN = errors.New("sentry:  invalid DSN")
+	// ErrInvalidScheme Thrown when a DSN string uses something other than http, https or http+unix
+	ErrInvalidScheme = errors.New("sentry:  invalid DSN scheme")
+	// ErrMissingHost Thrown when a DSN string has no host
+	ErrMissingHost = errors.New("sentry:  missing DSN host")
+)
+
+var projectIDPattern = regexp.MustCompile(`^\d+$`)
+
+func Parse(s string) (*DSN, error) {
+	/*
+		Parses a full DSN string {PROTOCOL}://{PUBLIC_KEY}:{SECRET_KEY}@{HOST}{PATH}/{PROJECT_ID}[?{OPTIONS}].
+		Anything in the query string is kept in DSN.Options and rendered again by String so round trips are lossless.
+		http+unix DSNs fail with ErrSocketNotAllowed, see ParseUpstream.
+	*/
+	return parseDSN(s, nil)
+}
+
+func parseDSN(s string, socketDirs []string) (*DSN, error) {
+	u, err := url.Parse(strings.TrimSpace(s))
+	if err != nil {
+		return nil, ErrInvalidDSN
+	}
+	if u.Scheme != "http" && u.Scheme != "https" && u.Scheme != SchemeHTTPUnix {
+		return nil, ErrInvalidScheme
+	}
+	if len(u.Hostname()) == 0 {
+		return nil, ErrMissingHost
+	}
+	if u.User == nil || len(u.User.Username()) == 0 {
+		return nil, ErrMissingUser
+	}
+	secret, _ := u.User.Password()
+
+	path := strings.TrimSuffix(u.Path, "/")
+	i := strings.LastIndex(path, "/")
+	if i < 0 || !projectIDPattern.MatchString(path[i+1:]) {
+		return nil, ErrMissingProjectID
+	}
+
+	d := &DSN{
+		Scheme:    u.Scheme,
+		Host:      u.Hostname(),
+		Port:      u.Port(),
+		Path:      path[:i],
+		ProjectID: path[i+1:],
+		PublicKey: u.User.Username(),
+		SecretKey: secret,
+	}
+	if len(u.RawQuery) > 0 {
+		d.Options = u.Query()
+	}
+	if d.Scheme == SchemeHTTPUnix {
+		d.Socket = d.Options.Get(socketOption)
+		if delete(d.Options, socketOption); len(d.Options) == 0 {
+			d.Options = nil
+		}
+		if len(d.Socket) == 0 {
+			return nil, ErrMissingSocket
+		}
+		if err := allowSocket(d.Socket, socketDirs); err != nil {
+			return nil, err
+		}
+	}
+	d.URL = d.String()
+	return d, nil
+}
+
+func (d *DSN) String() string {
+	/*
+		Renders the DSN from its components. Like CreateDSN this is empty when the
+		public key or project is unknown (legacy /api/store/ requests).
+		Unresolved slugs are rendered in place of the project ID.
+	*/
+	project := d.ProjectID
+	if len(project) == 0 {
+		project = d.ProjectSlug
+	}
+	if len(d.PublicKey) == 0 || len(project) == 0 {
+		return ""
+	}
+	var b strings.Builder
+	scheme := d.Scheme
+	if len(scheme) == 0 {
+		scheme = "https"
+	}
+	b.Grow(len(scheme) + len(d.PublicKey) + len(d.SecretKey) + len(d.Host) + len(d.Port) + len(d.Path) + len(project) + 8)
+	b.WriteString(scheme)
+	b.WriteString("://")
+	b.WriteString(d.PublicKey)
+	if len(d.SecretKey) > 0 {
+		b.WriteString(":")
+		b.WriteString(d.SecretKey)
+	}
+	b.WriteString("@")
+	b.WriteString(d.Host)
+	if len(d.Port) > 0 {
+		b.WriteString(":")
+		b.WriteString(d.Port)
+	}
+	b.WriteString(d.Path)
+	b.WriteString("/")
+	b.WriteString(project)
+	if len(d.Options) > 0 || len(d.Socket) > 0 {
+		b.WriteString("?")
+		b.WriteString(d.query())
+	}
+	return b.String()
+}
+
+func (d *DSN) Redacted() string {
+	/*
+		String with the secret key masked, for logs and CLI output.
+	*/
+	if len(d.SecretKey) == 0 {
+		return d.String()
+	}
+	c := d.Clone()
+	c.SecretKey = "***"
+	return c.String()
+}
+
+func (d *DSN) EndpointBase() string {
+	/*
+		Origin of the Sentry server, scheme://host[:port], without credentials or path.
+	*/
+	scheme := d.Scheme
+	if len(scheme) == 0 {
+		scheme = "https"
+	}
+	if len(d.Port) > 0 {
+		return scheme + "://" + d.Host + ":" + d.Port
+	}
+	return scheme + "://" + d.Host
+}
+
+func (d *DSN) PublicURL() string {
+	/*
+		The DSN without userinfo and options, scheme://host[:port]/path/project, safe to embed in CSP
+		report-uri values and documentation. Empty when the project is unknown, like String.
+	*/
+	project := d.ProjectID
+	if len(project) == 0 {
+		project = d.ProjectSlug
+	}
+	if len(project) == 0 {
+		return ""
+	}
+	return d.EndpointBase() + d.Path + "/" + project
+}
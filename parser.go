@@ -0,0 +1,137 @@
+package dsn
+
+import (
+	"context"
+	"net/http"
+)
+
+// Parser is a parsing configuration: header name, credential sources and modes, resolvers, hooks.
+// Options are applied once by NewParser and never changed afterwards, so a Parser is safe for concurrent use
+// and unaffected by later changes to package globals such as HTTP_X_SENTRY_AUTH.
+// The package level FromRequest, ParseRequest and Middleware delegate to a default Parser.
+type Parser struct {
+	config *config
+}
+
+// defaultParser backs the option-less package level functions. It reads HTTP_X_SENTRY_AUTH on every
+// request, as those functions always have.
+var defaultParser = &Parser{config: defaultConfig}
+
+func NewParser(opts ...Option) *Parser {
+	/*
+		Parser configured with opts. Unless WithAuthHeader is among them the current HTTP_X_SENTRY_AUTH is used.
+	*/
+	c := &config{resolverTimeout: DefaultResolverTimeout}
+	for _, opt := range opts {
+		opt(c)
+	}
+	if len(c.authHeader) == 0 {
+		c.authHeader = http.CanonicalHeaderKey(HTTP_X_SENTRY_AUTH)
+	}
+	return &Parser{config: c}
+}
+
+func parserFor(opts []Option) *Parser {
+	if len(opts) == 0 {
+		return defaultParser
+	}
+	return NewParser(opts...)
+}
+
+func WithAuthHeader(name string) Option {
+	/*
+		Reads credentials from the header name instead of HTTP_X_SENTRY_AUTH.
+	*/
+	return func(c *config) {
+		c.authHeader = http.CanonicalHeaderKey(name)
+	}
+}
+
+func (c *config) authHeaderValues(h http.Header) []string {
+	if len(c.authHeader) == 0 {
+		return authHeaderValues(h)
+	}
+	return h[c.authHeader]
+}
+
+func (p *Parser) FromRequest(r *http.Request) (*DSN, error) {
+	/*
+		See the package level FromRequest.
+	*/
+	return p.FromRequestContext(context.Background(), r)
+}
+
+func (p *Parser) FromRequestContext(ctx context.Context, r *http.Request) (*DSN, error) {
+	/*
+		See the package level FromRequestContext.
+	*/
+	var res ParseResult
+	if err := parseRequest(ctx, r, p.config, &res); err != nil {
+		return nil, err
+	}
+	return res.DSN, nil
+}
+
+func (p *Parser) ParseRequest(ctx context.Context, r *http.Request) (*ParseResult, error) {
+	/*
+		See the package level ParseRequest.
+	*/
+	res := &ParseResult{}
+	if err := parseRequest(ctx, r, p.config, res); err != nil {
+		return nil, err
+	}
+	return res, nil
+}
+
+func (p *Parser) Middleware(next http.Handler) http.Handler {
+	/*
+		See NewMiddleware.
+	*/
+	c := p.config
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Method == http.MethodOptions {
+			Preflight(w, r)
+			return
+		}
+		SetCORSHeaders(w, r)
+		res := &ParseResult{}
+		err := parseRequest(r.Context(), r, c, res)
+		if err == nil && c.sizeLimits != nil {
+			LimitRequestBody(r, res.Endpoint, c.sizeLimits)
+		}
+		if err == nil && c.itemSizeLimits != nil {
+			if err = LimitEnvelopeItems(r, res, c.itemSizeLimits, c.itemOutcomes); err != nil {
+				err = res.reject(err)
+			}
+		}
+		if err == nil && c.scrubber != nil {
+			if err = ScrubRequest(r, res, c.scrubber); err != nil {
+				err = res.reject(err)
+			}
+		}
+		if err != nil {
+			if terr, ok := err.(*TraceError); ok {
+				c.logf("dsn: rejected %s %s from %s:\n%s", r.Method, r.URL.Path, r.RemoteAddr, terr.Trace)
+			}
+			WriteError(w, err)
+			return
+		}
+		if c.filters != nil {
+			in := NewFilterInput(r, res)
+			if _, drop := c.filters.Check(in); drop {
+				eventID := c.newID()
+				if in.Meta != nil && len(in.Meta.EventID) > 0 {
+					eventID = in.Meta.EventID
+				}
+				WriteAccepted(w, eventID)
+				return
+			}
+		}
+		ctx := NewContext(r.Context(), res.DSN)
+		ctx = context.WithValue(ctx, resultContextKey{}, res)
+		if res.Tenant != nil {
+			ctx = context.WithValue(ctx, tenantContextKey{}, res.Tenant)
+		}
+		next.ServeHTTP(w, r.WithContext(ctx))
+	})
+}
@@ -0,0 +1,69 @@
+package dsn
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"testing"
+)
+
+func newAuthRequest(header string) *http.Request {
+	r := httptest.NewRequest("POST", "https://sentry.io/api/1/store/", nil)
+	r.Header.Set(header, "Sentry sentry_version=7, sentry_key="+testKeyA)
+	return r
+}
+
+type testParser struct {
+	opts        []Option
+	header      string
+	description string
+	ok          bool
+}
+
+var testTableParser = []testParser{
+	{nil, "X-Sentry-Auth", "Testing default header", true},
+	{[]Option{WithAuthHeader("x-relay-auth")}, "X-Relay-Auth", "Testing custom header", true},
+	{[]Option{WithAuthHeader("X-Relay-Auth")}, "X-Sentry-Auth", "Testing custom header ignores the default", false},
+}
+
+func TestParser(t *testing.T) {
+	for _, test := range testTableParser {
+		d, err := NewParser(test.opts...).FromRequest(newAuthRequest(test.header))
+		if (err == nil) != test.ok || (test.ok && d.PublicKey != testKeyA) {
+			t.Errorf("%s: Expected -- ok %v -- Got %v %v", test.description, test.ok, d, err)
+		}
+	}
+}
+
+func TestParserIgnoresGlobalChanges(t *testing.T) {
+	p := NewParser()
+	old := HTTP_X_SENTRY_AUTH
+	HTTP_X_SENTRY_AUTH = "X-Other-Auth"
+	defer func() { HTTP_X_SENTRY_AUTH = old }()
+	if _, err := p.FromRequest(newAuthRequest("X-Sentry-Auth")); err != nil {
+		t.Errorf("Expected -- header fixed at NewParser -- Got %v", err)
+	}
+	// the package level functions keep following the global
+	if _, err := FromRequest(newAuthRequest("X-Other-Auth")); err != nil {
+		t.Errorf("Expected -- FromRequest to follow HTTP_X_SENTRY_AUTH -- Got %v", err)
+	}
+}
+
+func TestParserConcurrent(t *testing.T) {
+	p := NewParser(CollectAllErrors(), WithStats(NewStats()))
+	var wg sync.WaitGroup
+	for i := 0; i < 8; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for j := 0; j < 100; j++ {
+				if res, err := p.ParseRequest(context.Background(), newAuthRequest("X-Sentry-Auth")); err != nil || res.DSN.PublicKey != testKeyA {
+					t.Errorf("Expected -- %s -- Got %v %v", testKeyA, res, err)
+					return
+				}
+			}
+		}()
+	}
+	wg.Wait()
+}
@@ -0,0 +1,52 @@
+package dsn
+
+import (
+	"github.com/dgbailey/dsn/internal/ingestpath"
+)
+
+// More endpoints recognized by ParsePath and request parsing, next to those in result.go.
+const (
+	EndpointMinidump    Endpoint = "minidump"    // /api/<project_id>/minidump/
+	EndpointSecurity    Endpoint = "security"    // /api/<project_id>/security/ and the older /api/<project_id>/csp-report/
+	EndpointAttachments Endpoint = "attachments" // /api/<project_id>/events/<event_id>/attachments/
+	EndpointCron        Endpoint = "cron"        // /api/<project_id>/cron/<monitor_slug>/<sentry_key>/
+)
+
+// PathInfo is everything an ingest path says about a request.
+type PathInfo struct {
+	Prefix      string   `json:"prefix,omitempty"`     //anything before /api/, e.g. /sentry for a relay mounted below /sentry
+	ProjectID   string   `json:"project_id,omitempty"` //empty for the legacy /api/store/
+	Endpoint    Endpoint `json:"endpoint"`
+	EventID     string   `json:"event_id,omitempty"`     //attachments endpoint only
+	MonitorSlug string   `json:"monitor_slug,omitempty"` //cron endpoint only
+	PublicKey   string   `json:"public_key,omitempty"`   //key carried in the path (unreal and cron), when valid
+}
+
+func ParsePath(path string) (*PathInfo, error) {
+	/*
+		Parses an ingest path without a url.URL, e.g. for routing layers working on raw request lines.
+		Same rules and errors as CheckPath: ErrNotIngestEndpoint for the Web API (/api/0/...), ErrMissingProjectID otherwise.
+	*/
+	info := &PathInfo{}
+	if err := parsePathInfo(path, info); err != nil {
+		return nil, err
+	}
+	return info, nil
+}
+
+func parsePathInfo(path string, info *PathInfo) error {
+	/*
+		ingestpath.Parse with its errors mapped onto ErrNotIngestEndpoint and ErrMissingProjectID.
+	*/
+	var p ingestpath.Info
+	err := ingestpath.Parse(path, &p)
+	*info = PathInfo{p.Prefix, p.ProjectID, Endpoint(p.Endpoint), p.EventID, p.MonitorSlug, p.PublicKey}
+	switch err {
+	case nil:
+		return nil
+	case ingestpath.ErrNotIngest:
+		return ErrNotIngestEndpoint
+	default:
+		return ErrMissingProjectID
+	}
+}
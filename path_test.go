@@ -0,0 +1,27 @@
+package dsn
+
+import (
+	"net/http/httptest"
+	"testing"
+)
+
+func TestParsePath(t *testing.T) {
+	for _, test := range TestVectors().Paths {
+		got, err := ParsePath(test.Input)
+		if ErrorCode(err) != test.Error {
+			t.Errorf("Testing %s: Expected -- %s -- Got %v", test.Description, test.Error, err)
+			continue
+		}
+		if err == nil && *got != test.Expected {
+			t.Errorf("Testing %s: Expected -- %+v -- Got %+v", test.Description, test.Expected, *got)
+		}
+	}
+}
+
+func TestCronPathKey(t *testing.T) {
+	r := httptest.NewRequest("POST", "https://sentry.io/api/1/cron/nightly-job/"+testKeyA+"/", nil)
+	res, err := ParseRequest(r.Context(), r, WithCredentialSources(SourceHeader, SourcePath))
+	if err != nil || res.KeySource != SourcePath || res.Endpoint != EndpointCron {
+		t.Errorf("Expected -- cron key from path -- Got %+v %v", res, err)
+	}
+}
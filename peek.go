@@ -0,0 +1,117 @@
+package dsn
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+)
+
+// ErrBodyTruncated Thrown when a body is longer than the peek limit. The peeked prefix is still returned.
+var ErrBodyTruncated = errors.New("sentry:  request body exceeds peek limit")
+
+// TruncatedError carries the limit that was hit. errors.Is(err, ErrBodyTruncated) matches it.
+type TruncatedError struct {
+	Limit int64
+}
+
+func (e *TruncatedError) Error() string {
+	return fmt.Sprintf("%s of %d bytes", ErrBodyTruncated, e.Limit)
+}
+
+func (e *TruncatedError) Is(target error) bool {
+	return target == ErrBodyTruncated
+}
+
+// LimitedPeeker reads the start of a body for body-derived parsing (tunnels, envelopes, minidumps)
+// without ever buffering more than Limit bytes, and hands the full body back for downstream handlers.
+type LimitedPeeker struct {
+	Limit int64
+}
+
+func NewLimitedPeeker(limit int64) *LimitedPeeker {
+	return &LimitedPeeker{Limit: limit}
+}
+
+func (p *LimitedPeeker) Peek(body io.Reader) ([]byte, io.Reader, error) {
+	/*
+		Reads at most Limit bytes of body. The returned reader yields the complete original stream,
+		peeked bytes included. If body is longer than Limit a *TruncatedError is returned along with the first Limit bytes.
+	*/
+	buf, err := io.ReadAll(io.LimitReader(body, p.Limit+1))
+	restored := io.MultiReader(bytes.NewReader(buf), body)
+	if err != nil {
+		return nil, restored, err
+	}
+	if int64(len(buf)) > p.Limit {
+		return buf[:p.Limit], restored, &TruncatedError{Limit: p.Limit}
+	}
+	return buf, restored, nil
+}
+
+func (p *LimitedPeeker) PeekRequest(r *http.Request) ([]byte, error) {
+	/*
+		Peek on r.Body. r.Body is replaced so the next reader still sees the whole body, and closing it closes the original.
+	*/
+	if r.Body == nil || r.Body == http.NoBody {
+		return nil, nil
+	}
+	buf, restored, err := p.Peek(r.Body)
+	r.Body = &peekedBody{Reader: restored, Closer: r.Body}
+	return buf, err
+}
+
+func (p *LimitedPeeker) PeekFunc(r *http.Request, fn func(body io.Reader) error) error {
+	/*
+		Runs fn on r.Body, which fn may read as far as it needs up to Limit bytes; reads past that fail with a
+		*TruncatedError. Unlike PeekRequest nothing fn does not ask for is read, so chunked bodies and bodies
+		without Content-Length are parsed as they stream in: the first line of an envelope is available before
+		the SDK has sent the rest. r.Body is restored as sent afterwards.
+	*/
+	if r.Body == nil || r.Body == http.NoBody {
+		return fn(http.NoBody)
+	}
+	rec := &recordingReader{r: r.Body, limit: p.Limit}
+	err := fn(rec)
+	r.Body = &peekedBody{Reader: io.MultiReader(bytes.NewReader(rec.buf), r.Body), Closer: r.Body}
+	return err
+}
+
+// recordingReader keeps what was read from r so it can be handed back, and stops at limit.
+type recordingReader struct {
+	r     io.Reader
+	buf   []byte
+	limit int64
+	err   error //sticky once the limit was hit
+}
+
+func (rr *recordingReader) Read(b []byte) (int, error) {
+	if rr.err != nil {
+		return 0, rr.err
+	}
+	room := rr.limit - int64(len(rr.buf))
+	if room <= 0 {
+		// a body of exactly limit bytes is not truncated, look for one more
+		var probe [1]byte
+		n, err := io.ReadFull(rr.r, probe[:])
+		rr.buf = append(rr.buf, probe[:n]...)
+		if n == 0 {
+			rr.err = err
+		} else {
+			rr.err = &TruncatedError{Limit: rr.limit}
+		}
+		return 0, rr.err
+	}
+	if int64(len(b)) > room {
+		b = b[:room]
+	}
+	n, err := rr.r.Read(b)
+	rr.buf = append(rr.buf, b[:n]...)
+	return n, err
+}
+
+type peekedBody struct {
+	io.Reader
+	io.Closer
+}
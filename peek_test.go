@@ -0,0 +1,90 @@
+package dsn
+
+import (
+	"errors"
+	"io"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+type testPeek struct {
+	body        string
+	limit       int64
+	description string
+	expected    string
+	err         error
+}
+
+var testTablePeek = []testPeek{
+	{`{"event_id":"fc6d8c0c43fc4630ad850ee518f1b9d0"}`, 1024, "Testing body under limit", `{"event_id":"fc6d8c0c43fc4630ad850ee518f1b9d0"}`, nil},
+	{`{"event_id":"fc6d8c0c43fc4630ad850ee518f1b9d0"}`, 12, "Testing body over limit", `{"event_id":`, ErrBodyTruncated},
+	{`0123456789`, 10, "Testing body exactly at limit", `0123456789`, nil},
+	{``, 10, "Testing empty body", ``, nil},
+}
+
+func TestLimitedPeeker(t *testing.T) {
+	for _, test := range testTablePeek {
+		r := httptest.NewRequest("POST", "https://sentry.io/api/1234/envelope/", strings.NewReader(test.body))
+		got, err := NewLimitedPeeker(test.limit).PeekRequest(r)
+		if !errors.Is(err, test.err) || (err == nil) != (test.err == nil) {
+			t.Errorf("%s: Expected -- %v -- Got %v", test.description, test.err, err)
+		}
+		if string(got) != test.expected {
+			t.Errorf("%s: Expected -- %s -- Got %s", test.description, test.expected, got)
+		}
+		rest, _ := io.ReadAll(r.Body)
+		if string(rest) != test.body {
+			t.Errorf("%s: Expected -- body restored -- Got %s", test.description, rest)
+		}
+	}
+}
+
+func TestLimitedPeekerTruncatedError(t *testing.T) {
+	_, _, err := NewLimitedPeeker(2).Peek(strings.NewReader("abc"))
+	var terr *TruncatedError
+	if !errors.As(err, &terr) || terr.Limit != 2 {
+		t.Errorf("Expected -- *TruncatedError with limit 2 -- Got %v", err)
+	}
+}
+
+func TestLimitedPeekerPeekFunc(t *testing.T) {
+	for _, test := range testTablePeek {
+		r := httptest.NewRequest("POST", "https://sentry.io/api/1234/envelope/", strings.NewReader(test.body))
+		var got []byte
+		err := NewLimitedPeeker(test.limit).PeekFunc(r, func(body io.Reader) (err error) {
+			got, err = io.ReadAll(body)
+			return err
+		})
+		if !errors.Is(err, test.err) || (err == nil) != (test.err == nil) || string(got) != test.expected {
+			t.Errorf("%s: Expected -- %s %v -- Got %s %v", test.description, test.expected, test.err, got, err)
+		}
+		if rest, _ := io.ReadAll(r.Body); string(rest) != test.body {
+			t.Errorf("%s: Expected -- body restored -- Got %s", test.description, rest)
+		}
+	}
+}
+
+func TestLimitedPeekerPeekFuncStreaming(t *testing.T) {
+	// the SDK has sent the first line and is still streaming, nothing past it may be waited for
+	pr, pw := io.Pipe()
+	go pw.Write([]byte("first\n"))
+	r := httptest.NewRequest("POST", "https://sentry.io/api/1234/envelope/", pr)
+	r.ContentLength, r.TransferEncoding = -1, []string{"chunked"}
+	var line []byte
+	err := NewLimitedPeeker(1024).PeekFunc(r, func(body io.Reader) (err error) {
+		line = make([]byte, 6)
+		_, err = io.ReadFull(body, line)
+		return err
+	})
+	if err != nil || string(line) != "first\n" {
+		t.Fatalf("Expected -- first line -- Got %q %v", line, err)
+	}
+	go func() {
+		pw.Write([]byte("rest"))
+		pw.Close()
+	}()
+	if rest, _ := io.ReadAll(r.Body); string(rest) != "first\nrest" {
+		t.Errorf("Expected -- body restored -- Got %q", rest)
+	}
+}
@@ -0,0 +1,413 @@
+package dsn
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"sort"
+	"sync"
+	"time"
+)
+
+var (
+	// ErrQueueFull Thrown when a Pipeline has no room for another submission
+	ErrQueueFull = errors.New("sentry:  submission queue full")
+	// ErrPipelineClosed Thrown when a submission is handed to a closed Pipeline
+	ErrPipelineClosed = errors.New("sentry:  pipeline closed")
+	// ErrNotForwardable Thrown when HTTPSink has no upstream URL for a submission's endpoint (attachments, cron, tunnel)
+	ErrNotForwardable = errors.New("sentry:  submission can not be forwarded")
+)
+
+// Submission is an accepted request on its way upstream.
+type Submission struct {
+	DSN      *DSN
+	Endpoint Endpoint
+	Headers  http.Header
+	Body     []byte    //as received, Content-Encoding in Headers still applies
+	Received time.Time //when the relay accepted it, zero if unknown
+}
+
+// Sink is where a Pipeline delivers submissions: an HTTP forwarder, a message queue, a disk spool.
+// Send is called from several workers at once.
+type Sink interface {
+	Send(ctx context.Context, s *Submission) error
+}
+
+// EndpointSink is implemented by sinks that can deliver only some endpoints, like HTTPSink. Pipeline.Handler
+// answers requests for the others with ErrNotForwardable instead of acknowledging data that would be lost.
+type EndpointSink interface {
+	Sink
+	Accepts(endpoint Endpoint) bool
+}
+
+func sinkAccepts(sink Sink, endpoint Endpoint) bool {
+	if es, ok := sink.(EndpointSink); ok {
+		return es.Accepts(endpoint)
+	}
+	return true
+}
+
+// SinkFunc adapts a function to Sink.
+type SinkFunc func(ctx context.Context, s *Submission) error
+
+func (f SinkFunc) Send(ctx context.Context, s *Submission) error {
+	return f(ctx, s)
+}
+
+// Shutdowner is implemented by sinks holding state that has to be flushed on shutdown, like Spool.
+// Pipeline.Shutdown calls it once the queue is drained.
+type Shutdowner interface {
+	Shutdown(ctx context.Context) error
+}
+
+// Pipeline queues submissions in memory and hands them to a Sink from a fixed number of workers.
+// The queue is bounded: when it is full Enqueue fails with ErrQueueFull instead of blocking,
+// which the Handler answers with a 503 so SDKs back off.
+type Pipeline struct {
+	sink   Sink
+	config *config
+	queue  chan *Submission
+	wg     sync.WaitGroup
+	ctx    context.Context //canceled when Shutdown runs out of time
+	cancel context.CancelFunc
+
+	mu      sync.RWMutex
+	closed  bool
+	dropped []*Submission
+}
+
+func NewPipeline(sink Sink, size int, workers int, opts ...Option) *Pipeline {
+	/*
+		Starts workers goroutines sending to sink, with room for size queued submissions.
+		WithLogger reports failed sends, WithIDGenerator the event IDs the Handler makes up.
+	*/
+	if workers < 1 {
+		workers = 1
+	}
+	p := &Pipeline{sink: sink, config: newConfig(opts), queue: make(chan *Submission, size)}
+	p.ctx, p.cancel = context.WithCancel(context.Background())
+	p.wg.Add(workers)
+	for i := 0; i < workers; i++ {
+		go p.work()
+	}
+	return p
+}
+
+func (p *Pipeline) work() {
+	defer p.wg.Done()
+	for s := range p.queue {
+		if p.ctx.Err() != nil {
+			p.drop(s)
+			continue
+		}
+		if err := p.sink.Send(p.ctx, s); err != nil {
+			p.config.logf("dsn: sending %s submission for %s: %v", s.Endpoint, s.DSN.Redacted(), err)
+			if p.ctx.Err() != nil {
+				p.drop(s)
+			}
+		}
+	}
+}
+
+func (p *Pipeline) drop(s *Submission) {
+	p.mu.Lock()
+	p.dropped = append(p.dropped, s)
+	p.mu.Unlock()
+}
+
+func (p *Pipeline) Enqueue(s *Submission) error {
+	/*
+		Queues s without blocking. Fails with ErrQueueFull or ErrPipelineClosed.
+	*/
+	p.mu.RLock()
+	defer p.mu.RUnlock()
+	if p.closed {
+		return ErrPipelineClosed
+	}
+	select {
+	case p.queue <- s:
+		return nil
+	default:
+		return ErrQueueFull
+	}
+}
+
+func (p *Pipeline) Len() int {
+	/*
+		Submissions waiting for a worker.
+	*/
+	return len(p.queue)
+}
+
+func (p *Pipeline) Close() {
+	/*
+		Shutdown without a deadline: returns once every queued submission has been sent.
+	*/
+	p.Shutdown(context.Background())
+}
+
+func (p *Pipeline) Shutdown(ctx context.Context) (dropped []*Submission, err error) {
+	/*
+		Stops accepting submissions (Enqueue fails with ErrPipelineClosed, the Handler answers 503), waits for the
+		queue to drain and then shuts the sink down if it is a Shutdowner. When ctx is done first, sends in flight
+		are canceled and everything not delivered is returned as dropped along with ctx.Err(), so the caller can log
+		or persist it. Call it on SIGTERM after the HTTP server stopped accepting requests.
+	*/
+	p.mu.Lock()
+	if !p.closed {
+		p.closed = true
+		close(p.queue)
+	}
+	p.mu.Unlock()
+	drained := make(chan struct{})
+	go func() {
+		p.wg.Wait()
+		close(drained)
+	}()
+	select {
+	case <-drained:
+	case <-ctx.Done():
+		err = ctx.Err()
+		p.cancel()
+		<-drained
+	}
+	p.cancel()
+	if sd, ok := p.sink.(Shutdowner); ok {
+		if serr := sd.Shutdown(ctx); err == nil {
+			err = serr
+		}
+	}
+	p.mu.Lock()
+	dropped, p.dropped = p.dropped, nil
+	p.mu.Unlock()
+	return dropped, err
+}
+
+// maxHandlerBody caps bodies of endpoints the size limits of Pipeline.Handler do not list, e.g. tunnel
+const maxHandlerBody = 100 << 20
+
+func (p *Pipeline) Handler() http.Handler {
+	/*
+		Terminal handler to mount behind NewMiddleware: wraps the request into a Submission, queues it
+		and answers like Sentry with the event ID. Requests that did not pass the middleware are rejected,
+		and so are endpoints the sink can not deliver (see EndpointSink) before anything is acknowledged.
+		Bodies are capped at the WithSizeLimits of the pipeline, DefaultSizeLimits when there are none,
+		and endpoints without a limit at maxHandlerBody.
+	*/
+	limits := p.config.sizeLimits
+	if limits == nil {
+		limits = DefaultSizeLimits
+	}
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		res, ok := ResultFromContext(r.Context())
+		if !ok {
+			WriteError(w, ErrMissingUser)
+			return
+		}
+		if !sinkAccepts(p.sink, res.Endpoint) {
+			WriteError(w, res.reject(fmt.Errorf("%w: %s", ErrNotForwardable, res.Endpoint)))
+			return
+		}
+		if _, ok := limits[res.Endpoint]; ok {
+			LimitRequestBody(r, res.Endpoint, limits)
+		} else {
+			LimitRequestBody(r, res.Endpoint, SizeLimits{res.Endpoint: maxHandlerBody})
+		}
+		body, err := ioutil.ReadAll(r.Body)
+		if err != nil {
+			WriteError(w, res.reject(err))
+			return
+		}
+		s := &Submission{DSN: res.DSN, Endpoint: res.Endpoint, Headers: r.Header.Clone(), Body: body, Received: p.config.now()}
+		if err := p.Enqueue(s); err != nil {
+			WriteError(w, res.reject(err))
+			return
+		}
+		id := submissionEventID(s)
+		if len(id) == 0 {
+			id = p.config.newID()
+		}
+		WriteAccepted(w, id)
+	})
+}
+
+func submissionEventID(s *Submission) string {
+	decoded, err := decodeBody(s.Headers.Get("Content-Encoding"), bytes.NewReader(s.Body))
+	if err != nil {
+		return ""
+	}
+	meta, _ := ExtractEventMeta(decoded, maxDecodedRatio*int64(len(s.Body)))
+	return meta.EventID
+}
+
+// UpstreamError carries the status of a rejected forward. errors.Is(err, ErrUpstream) matches it.
+type UpstreamError struct {
+	StatusCode int
+	Reason     string //X-Sentry-Error of the response
+}
+
+// ErrUpstream Thrown when the upstream answers a forwarded submission with an error status
+var ErrUpstream = errors.New("sentry:  upstream rejected submission")
+
+func (e *UpstreamError) Error() string {
+	if len(e.Reason) > 0 {
+		return fmt.Sprintf("%s: %d %s", ErrUpstream, e.StatusCode, e.Reason)
+	}
+	return fmt.Sprintf("%s: %d", ErrUpstream, e.StatusCode)
+}
+
+func (e *UpstreamError) Is(target error) bool {
+	return target == ErrUpstream
+}
+
+// PermanentError wraps a send that fails the same way however often it is retried: no route, a transformer
+// refusing the submission, an endpoint that can not be forwarded. Spool drops these instead of spooling them.
+type PermanentError struct {
+	Err error
+}
+
+func (e *PermanentError) Error() string {
+	return e.Err.Error()
+}
+
+func (e *PermanentError) Unwrap() error {
+	return e.Err
+}
+
+// HTTPSink forwards submissions to Sentry (or another relay) over HTTP.
+// Without a Router submissions go to the DSN they were sent with. Attachment and cron submissions are not supported.
+// http+unix upstreams are dialed over their socket with a copy of Client, see SchemeHTTPUnix.
+type HTTPSink struct {
+	Client  *http.Client //a client with a 30 second timeout when nil
+	Router  *Router
+	Breaker *CircuitBreaker //optional, refuses sends to failing hosts with ErrCircuitOpen
+	// Transformers rewrite each submission for its upstream, in order, e.g. DSNRewriteTransformer when
+	// tunneled envelopes go to a different DSN than they name
+	Transformers []Transformer
+}
+
+// defaultSinkClient is http.DefaultClient with a timeout, so a stalled upstream can not hold a worker forever.
+var defaultSinkClient = &http.Client{Timeout: 30 * time.Second}
+
+// forwardedHeaders are copied from the inbound request, auth is set for the upstream DSN.
+var forwardedHeaders = []string{"Content-Type", "Content-Encoding", "User-Agent"}
+
+func (h *HTTPSink) Send(ctx context.Context, s *Submission) error {
+	up := s.DSN
+	if h.Router != nil {
+		var err error
+		if up, err = h.Router.Route(s.DSN); err != nil {
+			return &PermanentError{err}
+		}
+	}
+	if len(h.Transformers) > 0 {
+		var err error
+		if s, err = transform(s, up, h.Transformers); err != nil {
+			return &PermanentError{err}
+		}
+	}
+	endpoint := s.Endpoint
+	if len(endpoint) == 0 {
+		endpoint = EndpointStore
+	}
+	u, err := ingestURL(up, endpoint)
+	if err != nil {
+		return err
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, u, bytes.NewReader(s.Body))
+	if err != nil {
+		return err
+	}
+	for _, k := range forwardedHeaders {
+		if v := s.Headers.Get(k); len(v) > 0 {
+			req.Header.Set(k, v)
+		}
+	}
+	received, _ := parseAuthHeader(s.Headers.Get(HTTP_X_SENTRY_AUTH), false)
+	req.Header.Set(HTTP_X_SENTRY_AUTH, authHeader(up, received.extraFields()))
+	if h.Breaker == nil {
+		return h.do(req, up.Socket)
+	}
+	host := req.URL.Host
+	if len(up.Socket) > 0 {
+		host = "unix:" + up.Socket
+	}
+	if err := h.Breaker.Allow(host); err != nil {
+		return err
+	}
+	err = h.do(req, up.Socket)
+	h.Breaker.Report(host, err)
+	return err
+}
+
+func (h *HTTPSink) do(req *http.Request, socket string) error {
+	client := h.Client
+	if client == nil {
+		client = defaultSinkClient
+	}
+	if len(socket) > 0 {
+		client = unixClient(client, socket)
+	}
+	resp, err := client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	ioutil.ReadAll(resp.Body)
+	if resp.StatusCode >= 300 {
+		return &UpstreamError{StatusCode: resp.StatusCode, Reason: resp.Header.Get(X_SENTRY_ERROR)}
+	}
+	return nil
+}
+
+func (h *HTTPSink) Accepts(endpoint Endpoint) bool {
+	/*
+		Attachment and cron paths carry IDs a Submission does not keep, and tunnels are not HTTP posts, so only
+		store, envelope, minidump, security and unreal submissions are forwarded.
+	*/
+	return forwardable(endpoint)
+}
+
+func forwardable(endpoint Endpoint) bool {
+	switch endpoint {
+	case "", EndpointStore, EndpointEnvelope, EndpointMinidump, EndpointSecurity, EndpointUnreal:
+		return true
+	}
+	return false
+}
+
+func ingestURL(d *DSN, endpoint Endpoint) (string, error) {
+	/*
+		Upstream URL for endpoint, see HTTPSink.Accepts for the endpoints that have one.
+	*/
+	if !forwardable(endpoint) {
+		return "", &PermanentError{fmt.Errorf("%w: %s", ErrNotForwardable, endpoint)}
+	}
+	suffix := string(endpoint) + "/"
+	if endpoint == EndpointUnreal {
+		suffix += d.PublicKey + "/"
+	}
+	return d.transportBase() + d.Path + "/api/" + d.ProjectID + "/" + suffix, nil
+}
+
+func authHeader(d *DSN, extra map[string]string) string {
+	/*
+		Credentials of d, followed by the unknown sentry_* fields of the received header, sorted.
+	*/
+	v := "Sentry sentry_version=7, sentry_key=" + d.PublicKey
+	if len(d.SecretKey) > 0 {
+		v += ", sentry_secret=" + d.SecretKey
+	}
+	names := make([]string, 0, len(extra))
+	for k := range extra {
+		names = append(names, k)
+	}
+	sort.Strings(names)
+	for _, k := range names {
+		v += ", " + k + "=" + extra[k]
+	}
+	return v
+}
@@ -0,0 +1,216 @@
+package dsn
+
+import (
+	"context"
+	"errors"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+func TestPipelineBackpressure(t *testing.T) {
+	release := make(chan struct{})
+	var mu sync.Mutex
+	var sent []*Submission
+	p := NewPipeline(SinkFunc(func(ctx context.Context, s *Submission) error {
+		<-release
+		mu.Lock()
+		sent = append(sent, s)
+		mu.Unlock()
+		return nil
+	}), 2, 1)
+	d, _ := Parse("https://" + testKeyA + "@sentry.io/1")
+	var full int
+	for i := 0; i < 5; i++ {
+		if err := p.Enqueue(&Submission{DSN: d, Endpoint: EndpointStore}); errors.Is(err, ErrQueueFull) {
+			full++
+		}
+	}
+	// one submission is held by the worker, two wait in the queue
+	if full < 2 || full > 3 {
+		t.Errorf("Expected -- 2 or 3 rejected submissions -- Got %d", full)
+	}
+	close(release)
+	p.Close()
+	if len(sent) != 5-full {
+		t.Errorf("Expected -- %d sent -- Got %d", 5-full, len(sent))
+	}
+	if err := p.Enqueue(&Submission{DSN: d}); err != ErrPipelineClosed {
+		t.Errorf("Expected -- %v -- Got %v", ErrPipelineClosed, err)
+	}
+}
+
+func TestPipelineHandler(t *testing.T) {
+	got := make(chan *Submission, 1)
+	p := NewPipeline(SinkFunc(func(ctx context.Context, s *Submission) error {
+		got <- s
+		return nil
+	}), 1, 1, WithIDGenerator(IDGeneratorFunc(func() string { return "generated" })))
+	defer p.Close()
+	h := Middleware(p.Handler())
+	tests := []struct {
+		body        string
+		description string
+		id          string
+	}{
+		{testMetaEvent, "Testing event ID from payload", "9ec79c33ec9942ab8353589fcb2e04dc"},
+		{"not json", "Testing generated event ID", "generated"},
+	}
+	for _, test := range tests {
+		r := httptest.NewRequest("POST", "https://sentry.io/api/1/store/?sentry_key="+testKeyA, strings.NewReader(test.body))
+		w := httptest.NewRecorder()
+		h.ServeHTTP(w, r)
+		if w.Code != http.StatusOK || !strings.Contains(w.Body.String(), test.id) {
+			t.Errorf("%s: Expected -- 200 %s -- Got %d %s", test.description, test.id, w.Code, w.Body)
+		}
+		s := <-got
+		if s.Endpoint != EndpointStore || string(s.Body) != test.body || s.DSN.PublicKey != testKeyA {
+			t.Errorf("%s: Expected -- store submission -- Got %+v", test.description, s)
+		}
+	}
+}
+
+func TestPipelineHandlerNotForwardable(t *testing.T) {
+	cl := NewConcurrencyLimiter(1, 0)
+	p := NewPipeline(cl.Sink(&HTTPSink{}), 1, 1)
+	defer p.Close()
+	h := Middleware(p.Handler())
+	r := httptest.NewRequest("POST", "https://sentry.io/api/1/events/9ec79c33ec9942ab8353589fcb2e04dc/attachments/?sentry_key="+testKeyA, strings.NewReader("data"))
+	w := httptest.NewRecorder()
+	h.ServeHTTP(w, r)
+	if w.Code != http.StatusNotImplemented || p.Len() != 0 {
+		t.Errorf("Expected -- 501 and nothing queued -- Got %d %d", w.Code, p.Len())
+	}
+	if code := ErrorCode(ErrNotForwardable); code != "not_forwardable" {
+		t.Errorf("Expected -- not_forwardable -- Got %s", code)
+	}
+}
+
+func TestPipelineHandlerEndpoint(t *testing.T) {
+	got := make(chan *Submission, 1)
+	p := NewPipeline(SinkFunc(func(ctx context.Context, s *Submission) error {
+		got <- s
+		return nil
+	}), 1, 1)
+	defer p.Close()
+	h := NewMiddleware(WithProjectSlugs())(p.Handler())
+	r := httptest.NewRequest("POST", "https://sentry.io/api/my-project/envelope/?sentry_key="+testKeyA, strings.NewReader("{}"))
+	w := httptest.NewRecorder()
+	h.ServeHTTP(w, r)
+	if w.Code != http.StatusOK {
+		t.Fatalf("Expected -- 200 -- Got %d %s", w.Code, w.Body)
+	}
+	if s := <-got; s.Endpoint != EndpointEnvelope {
+		t.Errorf("Expected -- %s -- Got %s", EndpointEnvelope, s.Endpoint)
+	}
+	// chunked, so only the cap on the body catches it
+	r = httptest.NewRequest("POST", "https://sentry.io/api/1/store/?sentry_key="+testKeyA,
+		io.MultiReader(strings.NewReader(strings.Repeat("a", 1<<20)), strings.NewReader("a")))
+	w = httptest.NewRecorder()
+	h.ServeHTTP(w, r)
+	if w.Code != http.StatusRequestEntityTooLarge {
+		t.Errorf("Expected -- 413 -- Got %d", w.Code)
+	}
+	w = httptest.NewRecorder()
+	p.Handler().ServeHTTP(w, httptest.NewRequest("POST", "https://sentry.io/api/1/store/", strings.NewReader("{}")))
+	if w.Code != http.StatusUnauthorized {
+		t.Errorf("Expected -- 401 -- Got %d", w.Code)
+	}
+}
+
+func TestHTTPSink(t *testing.T) {
+	var path, auth, body string
+	status := http.StatusOK
+	upstream := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		path, auth = r.URL.Path, r.Header.Get("X-Sentry-Auth")
+		b, _ := ioutil.ReadAll(r.Body)
+		body = string(b)
+		if status != http.StatusOK {
+			w.Header().Set("X-Sentry-Error", "nope")
+		}
+		w.WriteHeader(status)
+	}))
+	defer upstream.Close()
+	up, _ := Parse(strings.Replace(upstream.URL, "://", "://"+testKeyB+"@", 1) + "/42")
+	in, _ := Parse("https://" + testKeyA + "@sentry.io/1")
+	rt := NewRouter()
+	rt.SetDefault(up)
+	sink := &HTTPSink{Client: upstream.Client(), Router: rt}
+	s := &Submission{DSN: in, Endpoint: EndpointEnvelope, Headers: http.Header{}, Body: []byte("{}\n")}
+	s.Headers.Set("X-Sentry-Auth", "Sentry sentry_key="+testKeyA+", sentry_flavor=mint")
+	if err := sink.Send(context.Background(), s); err != nil {
+		t.Fatalf("Expected -- no error -- Got %v", err)
+	}
+	if path != "/api/42/envelope/" || !strings.Contains(auth, "sentry_key="+testKeyB) || body != "{}\n" {
+		t.Errorf("Expected -- envelope forwarded as upstream -- Got %s %s %q", path, auth, body)
+	}
+	if !strings.HasSuffix(auth, ", sentry_flavor=mint") || strings.Contains(auth, testKeyA) {
+		t.Errorf("Expected -- unknown fields passed through -- Got %s", auth)
+	}
+	status = http.StatusTooManyRequests
+	var uerr *UpstreamError
+	if err := sink.Send(context.Background(), s); !errors.As(err, &uerr) || uerr.StatusCode != status || uerr.Reason != "nope" {
+		t.Errorf("Expected -- *UpstreamError 429 -- Got %v", err)
+	}
+	s.Endpoint = EndpointCron
+	if err := sink.Send(context.Background(), s); err == nil {
+		t.Errorf("Expected -- cron submissions to fail -- Got nil")
+	}
+}
+
+func TestPipelineShutdown(t *testing.T) {
+	d, _ := Parse("https://" + testKeyA + "@sentry.io/1")
+	// the sink hangs until its context is canceled
+	started := make(chan struct{}, 1)
+	p := NewPipeline(SinkFunc(func(ctx context.Context, s *Submission) error {
+		started <- struct{}{}
+		<-ctx.Done()
+		return ctx.Err()
+	}), 4, 1)
+	for i := 0; i < 3; i++ {
+		p.Enqueue(&Submission{DSN: d, Endpoint: EndpointStore})
+	}
+	<-started
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Millisecond)
+	defer cancel()
+	dropped, err := p.Shutdown(ctx)
+	if err != context.DeadlineExceeded || len(dropped) != 3 {
+		t.Errorf("Expected -- 3 dropped with %v -- Got %d %v", context.DeadlineExceeded, len(dropped), err)
+	}
+	h := Middleware(p.Handler())
+	w := httptest.NewRecorder()
+	h.ServeHTTP(w, httptest.NewRequest("POST", "https://sentry.io/api/1/store/?sentry_key="+testKeyA, strings.NewReader(testEvent)))
+	if w.Code != http.StatusServiceUnavailable {
+		t.Errorf("Expected -- %d after shutdown -- Got %d", http.StatusServiceUnavailable, w.Code)
+	}
+}
+
+func TestPipelineShutdownSpool(t *testing.T) {
+	d, _ := Parse("https://" + testKeyA + "@sentry.io/1")
+	up := &testUpstream{}
+	// upstream calls hang until canceled, then the spool keeps the submission
+	sp, _ := NewSpool(t.TempDir(), SinkFunc(func(ctx context.Context, s *Submission) error {
+		<-ctx.Done()
+		return ctx.Err()
+	}))
+	p := NewPipeline(sp, 4, 2)
+	p.Enqueue(&Submission{DSN: d, Endpoint: EndpointStore, Body: []byte("a")})
+	p.Enqueue(&Submission{DSN: d, Endpoint: EndpointStore, Body: []byte("b")})
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Millisecond)
+	defer cancel()
+	if dropped, _ := p.Shutdown(ctx); len(dropped) != 0 {
+		t.Errorf("Expected -- nothing dropped -- Got %d", len(dropped))
+	}
+	if n, _ := sp.Pending(); n != 2 {
+		t.Errorf("Expected -- 2 spooled -- Got %d", n)
+	}
+	sp.sink = up
+	if err := sp.Shutdown(context.Background()); err != nil || len(up.sent) != 2 {
+		t.Errorf("Expected -- spool flushed on shutdown -- Got %d %v", len(up.sent), err)
+	}
+}
@@ -0,0 +1,409 @@
+package dsn
+
+import (
+	"bytes"
+	"context"
+	"crypto/ed25519"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"io/ioutil"
+	"net/http"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+)
+
+// ErrProjectConfigPending Thrown when the upstream is still computing a project config; retry shortly
+var ErrProjectConfigPending = errors.New("sentry:  project config pending")
+
+// Headers authenticating a relay against its upstream.
+var HTTP_X_SENTRY_RELAY_ID = "X-Sentry-Relay-Id"
+var HTTP_X_SENTRY_RELAY_SIGNATURE = "X-Sentry-Relay-Signature"
+
+// Defaults for ProjectConfigClient, the same intervals Relay uses.
+var (
+	DefaultProjectConfigTTL   = 5 * time.Minute
+	DefaultProjectConfigGrace = time.Minute
+)
+
+// projectConfigPath is Relay's upstream project config endpoint.
+const projectConfigPath = "/api/0/relays/projectconfigs/?version=3"
+
+// maxProjectConfigBatch is how many keys go into a single upstream request.
+const maxProjectConfigBatch = 100
+
+// Quota is one rate limit of a project config. A nil Limit is unlimited, zero rejects everything.
+type Quota struct {
+	ID         string   `json:"id,omitempty"`
+	Categories []string `json:"categories,omitempty"` //data categories such as "error" or "transaction", empty for all
+	Scope      string   `json:"scope,omitempty"`      //"organization", "project" or "key"
+	Limit      *int64   `json:"limit,omitempty"`
+	Window     int64    `json:"window,omitempty"` //seconds
+	ReasonCode string   `json:"reasonCode,omitempty"`
+}
+
+// ProjectConfig is the part of an upstream project config this package acts on.
+type ProjectConfig struct {
+	PublicKey      string
+	ProjectID      string
+	KeyID          string //numericId of the key, empty when the upstream did not send it
+	Disabled       bool   //project disabled or key deactivated
+	AllowedDomains []string
+	Quotas         []Quota
+	Filters        json.RawMessage //filterSettings as sent by the upstream
+}
+
+func (pc *ProjectConfig) Tenant() Tenant {
+	/*
+		Registry settings for the key. RateLimit is the tightest error quota scaled to a minute; quotas
+		rejecting everything (limit 0) do not fit there, check Quotas for those.
+	*/
+	t := Tenant{PublicKey: pc.PublicKey}
+	for _, d := range pc.AllowedDomains {
+		if d == "*" {
+			t.AllowedOrigins = nil
+			break
+		}
+		t.AllowedOrigins = append(t.AllowedOrigins, d)
+	}
+	for _, q := range pc.Quotas {
+		if q.Limit == nil || *q.Limit <= 0 || q.Window <= 0 || !q.covers(CategoryError) {
+			continue
+		}
+		perMinute := int((*q.Limit*60 + q.Window - 1) / q.Window)
+		if t.RateLimit == 0 || perMinute < t.RateLimit {
+			t.RateLimit = perMinute
+		}
+	}
+	return t
+}
+
+func (q Quota) covers(category string) bool {
+	if len(q.Categories) == 0 {
+		return true
+	}
+	for _, c := range q.Categories {
+		if c == category || c == CategoryDefault {
+			return true
+		}
+	}
+	return false
+}
+
+// projectConfigEntry is a cached lookup; config is nil for keys the upstream does not know.
+type projectConfigEntry struct {
+	config  *ProjectConfig
+	fetched time.Time
+}
+
+// projectConfigCall is an upstream lookup in progress. Lookups of the same key wait for it instead of asking again.
+type projectConfigCall struct {
+	done    chan struct{}
+	configs map[string]*ProjectConfig
+	err     error
+}
+
+// ProjectConfigClient fetches project configs from a Sentry (or Relay) upstream the way Relay does,
+// signing requests with the relay's credentials. Configs are cached for TTL; when a refresh fails the
+// stale config keeps being served for Grace longer. Concurrent lookups of a key that is not cached share one request.
+// It is a KeyResolver, and keeps Registry (when set) in step with what it fetched. Safe for concurrent use.
+type ProjectConfigClient struct {
+	Client   *http.Client //http.DefaultClient when nil
+	TTL      time.Duration
+	Grace    time.Duration
+	Registry *Registry //optional, receives a Tenant per enabled key and loses disabled or unknown ones
+
+	upstream string
+	relayID  string
+	key      ed25519.PrivateKey
+	config   *config
+	mu       sync.Mutex
+	cache    map[string]projectConfigEntry
+	calls    map[string]*projectConfigCall //lookups in flight, by public key
+}
+
+func NewProjectConfigClient(upstream string, relayID string, key ed25519.PrivateKey, opts ...Option) *ProjectConfigClient {
+	/*
+		upstream is the base URL, such as https://sentry.io. relayID and key are the relay's registered credentials;
+		a nil key sends unsigned requests, for upstreams that trust the network instead.
+		WithClock ages the cache, WithLogger reports failed revalidations.
+	*/
+	return &ProjectConfigClient{
+		TTL:      DefaultProjectConfigTTL,
+		Grace:    DefaultProjectConfigGrace,
+		upstream: strings.TrimSuffix(upstream, "/"),
+		relayID:  relayID,
+		key:      key,
+		config:   newConfig(opts),
+		cache:    map[string]projectConfigEntry{},
+		calls:    map[string]*projectConfigCall{},
+	}
+}
+
+// projectConfigResponse is the upstream's answer, keyed by public key.
+type projectConfigResponse struct {
+	Configs map[string]*struct {
+		Disabled   bool        `json:"disabled"`
+		ProjectID  json.Number `json:"projectId"`
+		PublicKeys []struct {
+			PublicKey string      `json:"publicKey"`
+			NumericID json.Number `json:"numericId"`
+			IsEnabled bool        `json:"isEnabled"`
+		} `json:"publicKeys"`
+		Config struct {
+			AllowedDomains []string        `json:"allowedDomains"`
+			FilterSettings json.RawMessage `json:"filterSettings"`
+			Quotas         []Quota         `json:"quotas"`
+		} `json:"config"`
+	} `json:"configs"`
+	Pending []string `json:"pending"`
+}
+
+func (pc *ProjectConfigClient) Fetch(ctx context.Context, publicKeys ...string) (map[string]*ProjectConfig, []string, error) {
+	/*
+		Asks the upstream for publicKeys, bypassing and then refreshing the cache. Returns the configs, nil
+		for unknown keys, and the keys the upstream is still working on. Pending keys are not cached.
+	*/
+	configs := make(map[string]*ProjectConfig, len(publicKeys))
+	var pending []string
+	for len(publicKeys) > 0 {
+		n := len(publicKeys)
+		if n > maxProjectConfigBatch {
+			n = maxProjectConfigBatch
+		}
+		p, err := pc.fetch(ctx, publicKeys[:n], configs)
+		if err != nil {
+			return nil, nil, err
+		}
+		pending = append(pending, p...)
+		publicKeys = publicKeys[n:]
+	}
+	pc.store(configs)
+	return configs, pending, nil
+}
+
+func (pc *ProjectConfigClient) fetch(ctx context.Context, publicKeys []string, configs map[string]*ProjectConfig) ([]string, error) {
+	body, err := json.Marshal(struct {
+		PublicKeys []string `json:"publicKeys"`
+		FullConfig bool     `json:"fullConfig"`
+	}{publicKeys, false})
+	if err != nil {
+		return nil, err
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, pc.upstream+projectConfigPath, bytes.NewReader(body))
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	if len(pc.relayID) > 0 {
+		req.Header.Set(HTTP_X_SENTRY_RELAY_ID, pc.relayID)
+	}
+	if pc.key != nil {
+		req.Header.Set(HTTP_X_SENTRY_RELAY_SIGNATURE, signRelayRequest(pc.key, body, pc.config.now()))
+	}
+	client := pc.Client
+	if client == nil {
+		client = http.DefaultClient
+	}
+	resp, err := client.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode >= 300 {
+		ioutil.ReadAll(resp.Body)
+		return nil, &UpstreamError{StatusCode: resp.StatusCode, Reason: resp.Header.Get(X_SENTRY_ERROR)}
+	}
+	var out projectConfigResponse
+	if err := json.NewDecoder(resp.Body).Decode(&out); err != nil {
+		return nil, err
+	}
+	pending := map[string]bool{}
+	for _, k := range out.Pending {
+		pending[k] = true
+	}
+	for _, k := range publicKeys {
+		if pending[k] {
+			continue
+		}
+		state := out.Configs[k]
+		if state == nil {
+			configs[k] = nil
+			continue
+		}
+		c := &ProjectConfig{
+			PublicKey:      k,
+			ProjectID:      state.ProjectID.String(),
+			Disabled:       state.Disabled,
+			AllowedDomains: state.Config.AllowedDomains,
+			Quotas:         state.Config.Quotas,
+			Filters:        state.Config.FilterSettings,
+		}
+		for _, pk := range state.PublicKeys {
+			if pk.PublicKey != k {
+				continue
+			}
+			c.KeyID = pk.NumericID.String()
+			if !pk.IsEnabled {
+				c.Disabled = true
+			}
+		}
+		configs[k] = c
+	}
+	return out.Pending, nil
+}
+
+func signRelayRequest(key ed25519.PrivateKey, body []byte, now time.Time) string {
+	/*
+		Relay's signature format: base64url(signature) "." base64url(header), where the signature covers
+		the encoded header, a NUL byte and the body. The header's timestamp lets the upstream refuse replays.
+	*/
+	header, _ := json.Marshal(struct {
+		T string `json:"t"`
+	}{now.UTC().Format(time.RFC3339Nano)})
+	encodedHeader := base64.RawURLEncoding.EncodeToString(header)
+	msg := make([]byte, 0, len(encodedHeader)+1+len(body))
+	msg = append(append(append(msg, encodedHeader...), 0), body...)
+	return base64.RawURLEncoding.EncodeToString(ed25519.Sign(key, msg)) + "." + encodedHeader
+}
+
+func (pc *ProjectConfigClient) store(configs map[string]*ProjectConfig) {
+	now := pc.config.now()
+	pc.mu.Lock()
+	for k, c := range configs {
+		pc.cache[k] = projectConfigEntry{config: c, fetched: now}
+	}
+	pc.mu.Unlock()
+	if pc.Registry == nil {
+		return
+	}
+	for k, c := range configs {
+		if c == nil || c.Disabled {
+			pc.Registry.Remove(k)
+			continue
+		}
+		if err := pc.Registry.Set(c.Tenant()); err != nil {
+			pc.config.logf("dsn: registering project config of %s: %v", k, err)
+		}
+	}
+}
+
+func (pc *ProjectConfigClient) ProjectConfig(ctx context.Context, publicKey string) (*ProjectConfig, error) {
+	/*
+		The config of publicKey, from the cache while it is younger than TTL. ErrUnknownKey when the upstream
+		does not know the key, ErrProjectConfigPending while it is still being computed. When the upstream
+		fails, a cached config is served up to Grace past its TTL.
+	*/
+	pc.mu.Lock()
+	e, ok := pc.cache[publicKey]
+	pc.mu.Unlock()
+	age := pc.config.now().Sub(e.fetched)
+	if ok && age < pc.TTL {
+		return e.result()
+	}
+	configs, err := pc.fetchShared(ctx, publicKey)
+	if err != nil {
+		if ok && age < pc.TTL+pc.Grace {
+			pc.config.logf("dsn: revalidating project config of %s: %v", publicKey, err)
+			return e.result()
+		}
+		return nil, err
+	}
+	c, known := configs[publicKey]
+	if !known {
+		return nil, ErrProjectConfigPending
+	}
+	return projectConfigEntry{config: c}.result()
+}
+
+func (pc *ProjectConfigClient) fetchShared(ctx context.Context, publicKey string) (map[string]*ProjectConfig, error) {
+	/*
+		Fetch of a single key, joined by every lookup of publicKey that comes in while it runs so a burst of
+		requests for an expired or new key sends one upstream request. A waiter whose own ctx is still alive
+		retries when the fetch it waited for was cancelled by its caller.
+	*/
+	for {
+		pc.mu.Lock()
+		call, ok := pc.calls[publicKey]
+		if !ok {
+			call = &projectConfigCall{done: make(chan struct{})}
+			pc.calls[publicKey] = call
+			pc.mu.Unlock()
+			call.configs, _, call.err = pc.Fetch(ctx, publicKey)
+			pc.mu.Lock()
+			delete(pc.calls, publicKey)
+			pc.mu.Unlock()
+			close(call.done)
+			return call.configs, call.err
+		}
+		pc.mu.Unlock()
+		select {
+		case <-call.done:
+		case <-ctx.Done():
+			return nil, ctx.Err()
+		}
+		if call.err != nil && (errors.Is(call.err, context.Canceled) || errors.Is(call.err, context.DeadlineExceeded)) {
+			continue
+		}
+		return call.configs, call.err
+	}
+}
+
+func (e projectConfigEntry) result() (*ProjectConfig, error) {
+	if e.config == nil {
+		return nil, ErrUnknownKey
+	}
+	return e.config, nil
+}
+
+func (pc *ProjectConfigClient) ResolveKey(ctx context.Context, publicKey string) (*KeyInfo, error) {
+	c, err := pc.ProjectConfig(ctx, publicKey)
+	if err != nil {
+		return nil, err
+	}
+	return &KeyInfo{PublicKey: c.PublicKey, ProjectID: c.ProjectID, KeyID: c.KeyID, Disabled: c.Disabled}, nil
+}
+
+func (pc *ProjectConfigClient) Revalidate(ctx context.Context) error {
+	/*
+		Refetches every cached key so lookups keep hitting the cache. Keys that could not be refreshed for
+		longer than TTL plus Grace are forgotten and fetched again on their next lookup.
+	*/
+	now := pc.config.now()
+	var keys []string
+	pc.mu.Lock()
+	for k, e := range pc.cache {
+		if now.Sub(e.fetched) >= pc.TTL+pc.Grace {
+			delete(pc.cache, k)
+			continue
+		}
+		keys = append(keys, k)
+	}
+	pc.mu.Unlock()
+	if len(keys) == 0 {
+		return nil
+	}
+	sort.Strings(keys)
+	_, _, err := pc.Fetch(ctx, keys...)
+	return err
+}
+
+func (pc *ProjectConfigClient) Run(ctx context.Context, interval time.Duration) {
+	/*
+		Calls Revalidate every interval until ctx is done. An interval below TTL refreshes configs before they expire.
+	*/
+	t := time.NewTicker(interval)
+	defer t.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-t.C:
+			if err := pc.Revalidate(ctx); err != nil && ctx.Err() == nil {
+				pc.config.logf("dsn: revalidating project configs: %v", err)
+			}
+		}
+	}
+}
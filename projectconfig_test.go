@@ -0,0 +1,247 @@
+package dsn
+
+import (
+	"context"
+	"crypto/ed25519"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+const (
+	testDisabledKey = "dddddddddddddddddddddddddddddddd"
+	testPendingKey  = "pppppppppppppppppppppppppppppppp"
+)
+
+// testProjectConfigs is the upstream's answer for every request; keys it does not mention are unknown.
+var testProjectConfigs = `{"configs": {
+	"` + testKeyA + `": {"disabled": false, "projectId": 42, "publicKeys": [{"publicKey": "` + testKeyA + `", "numericId": 7, "isEnabled": true}],
+		"config": {"allowedDomains": ["example.com"], "filterSettings": {"releases": {"releases": ["1.0"]}},
+			"quotas": [{"id": "q", "categories": ["error"], "limit": 30, "window": 10}, {"categories": ["transaction"], "limit": 1, "window": 60}]}},
+	"` + testDisabledKey + `": {"disabled": false, "projectId": 43, "publicKeys": [{"publicKey": "` + testDisabledKey + `", "isEnabled": false}]}
+}, "pending": ["` + testPendingKey + `"]}`
+
+// testConfigUpstream serves testProjectConfigs and checks the relay signature. It fails with 503 while down is set.
+func testConfigUpstream(t *testing.T, pub ed25519.PublicKey, requests *int32, down *int32) *httptest.Server {
+	return httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		atomic.AddInt32(requests, 1)
+		if atomic.LoadInt32(down) != 0 {
+			w.WriteHeader(http.StatusServiceUnavailable)
+			return
+		}
+		body, _ := ioutil.ReadAll(r.Body)
+		if r.URL.Path != "/api/0/relays/projectconfigs/" || r.URL.Query().Get("version") != "3" {
+			t.Errorf("Expected -- project config endpoint -- Got %s", r.URL)
+		}
+		if r.Header.Get("X-Sentry-Relay-Id") != "relay-1" {
+			t.Errorf("Expected -- relay id -- Got %q", r.Header.Get("X-Sentry-Relay-Id"))
+		}
+		parts := strings.SplitN(r.Header.Get("X-Sentry-Relay-Signature"), ".", 2)
+		sig, _ := base64.RawURLEncoding.DecodeString(parts[0])
+		if len(parts) != 2 || !ed25519.Verify(pub, append(append([]byte(parts[1]), 0), body...), sig) {
+			t.Errorf("Expected -- valid signature -- Got %q", r.Header.Get("X-Sentry-Relay-Signature"))
+		}
+		var req struct {
+			PublicKeys []string `json:"publicKeys"`
+		}
+		if err := json.Unmarshal(body, &req); err != nil || len(req.PublicKeys) == 0 {
+			t.Errorf("Expected -- public keys -- Got %s", body)
+		}
+		w.Write([]byte(testProjectConfigs))
+	}))
+}
+
+func newTestConfigClient(t *testing.T, now *time.Time) (*ProjectConfigClient, *int32, *int32) {
+	pub, priv, _ := ed25519.GenerateKey(nil)
+	var requests, down int32
+	srv := testConfigUpstream(t, pub, &requests, &down)
+	t.Cleanup(srv.Close)
+	pc := NewProjectConfigClient(srv.URL+"/", "relay-1", priv, WithClock(ClockFunc(func() time.Time { return *now })))
+	return pc, &requests, &down
+}
+
+type testProjectConfigResolve struct {
+	publicKey   string
+	description string
+	projectID   string
+	err         error
+}
+
+var testTableProjectConfigResolve = []testProjectConfigResolve{
+	{testKeyA, "Testing enabled key", "42", nil},
+	{testDisabledKey, "Testing deactivated key", "", ErrKeyDisabled},
+	{testKeyB, "Testing unknown key", "", ErrUnknownKey},
+	{testPendingKey, "Testing pending config", "", ErrProjectConfigPending},
+}
+
+func TestProjectConfigResolve(t *testing.T) {
+	now := testNow
+	pc, _, _ := newTestConfigClient(t, &now)
+	for _, test := range testTableProjectConfigResolve {
+		r := httptest.NewRequest("POST", "https://relay.example.com/api/42/store/?sentry_key="+test.publicKey, nil)
+		res, err := ParseRequest(context.Background(), r, WithKeyResolver(pc))
+		if err != test.err {
+			t.Errorf("%s: Expected -- %v -- Got %v", test.description, test.err, err)
+			continue
+		}
+		if err == nil && res.DSN.ProjectID != test.projectID {
+			t.Errorf("%s: Expected -- project %s -- Got %s", test.description, test.projectID, res.DSN.ProjectID)
+		}
+	}
+	if info, err := pc.ResolveKey(context.Background(), testKeyA); err != nil || info.KeyID != "7" {
+		t.Errorf("Expected -- key ID 7 from numericId -- Got %v %v", info, err)
+	}
+}
+
+func TestProjectConfigCache(t *testing.T) {
+	now := testNow
+	pc, requests, down := newTestConfigClient(t, &now)
+	ctx := context.Background()
+	steps := []struct {
+		description string
+		advance     time.Duration
+		down        int32
+		requests    int32
+		err         error
+	}{
+		{"Testing first lookup", 0, 0, 1, nil},
+		{"Testing cached lookup", time.Minute, 0, 1, nil},
+		{"Testing revalidation after TTL", DefaultProjectConfigTTL, 0, 2, nil},
+		{"Testing stale config while upstream is down", DefaultProjectConfigTTL, 1, 3, nil},
+		{"Testing upstream down past grace", DefaultProjectConfigGrace, 1, 4, ErrUpstream},
+	}
+	for _, step := range steps {
+		now = now.Add(step.advance)
+		atomic.StoreInt32(down, step.down)
+		c, err := pc.ProjectConfig(ctx, testKeyA)
+		if (err == nil) != (step.err == nil) || (step.err != nil && !strings.Contains(err.Error(), step.err.Error())) {
+			t.Errorf("%s: Expected -- %v -- Got %v", step.description, step.err, err)
+		}
+		if err == nil && c.ProjectID != "42" {
+			t.Errorf("%s: Expected -- project 42 -- Got %s", step.description, c.ProjectID)
+		}
+		if n := atomic.LoadInt32(requests); n != step.requests {
+			t.Errorf("%s: Expected -- %d upstream requests -- Got %d", step.description, step.requests, n)
+		}
+	}
+}
+
+// testSlowConfigUpstream answers like testConfigUpstream, unsigned, once release is closed.
+func testSlowConfigUpstream(t *testing.T, requests *int32, release chan struct{}) *ProjectConfigClient {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		atomic.AddInt32(requests, 1)
+		<-release
+		w.Write([]byte(testProjectConfigs))
+	}))
+	t.Cleanup(srv.Close)
+	return NewProjectConfigClient(srv.URL, "relay-1", nil)
+}
+
+func testWaitRequests(requests *int32, n int32) {
+	for atomic.LoadInt32(requests) < n {
+		time.Sleep(time.Millisecond)
+	}
+	time.Sleep(20 * time.Millisecond) //lets the other lookups reach the call in flight
+}
+
+func TestProjectConfigSingleFlight(t *testing.T) {
+	var requests int32
+	release := make(chan struct{})
+	pc := testSlowConfigUpstream(t, &requests, release)
+	var wg sync.WaitGroup
+	errs := make(chan error, 20)
+	for i := 0; i < cap(errs); i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			c, err := pc.ProjectConfig(context.Background(), testKeyA)
+			if err == nil && c.ProjectID != "42" {
+				err = errors.New("project " + c.ProjectID)
+			}
+			errs <- err
+		}()
+	}
+	testWaitRequests(&requests, 1)
+	close(release)
+	wg.Wait()
+	close(errs)
+	for err := range errs {
+		if err != nil {
+			t.Errorf("Expected -- project 42 -- Got %v", err)
+		}
+	}
+	if n := atomic.LoadInt32(&requests); n != 1 {
+		t.Errorf("Expected -- 1 upstream request -- Got %d", n)
+	}
+}
+
+func TestProjectConfigSingleFlightCancel(t *testing.T) {
+	var requests int32
+	release := make(chan struct{})
+	pc := testSlowConfigUpstream(t, &requests, release)
+	ctx, cancel := context.WithCancel(context.Background())
+	first := make(chan error, 1)
+	go func() {
+		_, err := pc.ProjectConfig(ctx, testKeyA)
+		first <- err
+	}()
+	testWaitRequests(&requests, 1)
+	second := make(chan error, 1)
+	go func() {
+		_, err := pc.ProjectConfig(context.Background(), testKeyA)
+		second <- err
+	}()
+	time.Sleep(20 * time.Millisecond)
+	cancel()
+	if err := <-first; !errors.Is(err, context.Canceled) {
+		t.Errorf("Testing cancelled lookup: Expected -- %v -- Got %v", context.Canceled, err)
+	}
+	close(release)
+	if err := <-second; err != nil {
+		t.Errorf("Testing waiting lookup: Expected -- retried -- Got %v", err)
+	}
+}
+
+func TestProjectConfigRegistry(t *testing.T) {
+	now := testNow
+	pc, requests, _ := newTestConfigClient(t, &now)
+	reg, _ := NewRegistry(Tenant{PublicKey: testDisabledKey}, Tenant{PublicKey: testKeyB})
+	pc.Registry = reg
+	if _, _, err := pc.Fetch(context.Background(), testKeyA, testDisabledKey, testKeyB); err != nil {
+		t.Fatal(err)
+	}
+	tenant, ok := reg.Lookup(testKeyA)
+	if !ok || tenant.RateLimit != 180 || len(tenant.AllowedOrigins) != 1 || tenant.AllowedOrigins[0] != "example.com" {
+		t.Errorf("Expected -- tenant limited to 180/min from example.com -- Got %+v", tenant)
+	}
+	if _, ok := reg.Lookup(testDisabledKey); ok {
+		t.Errorf("Testing deactivated key: Expected -- removed from registry -- Got registered")
+	}
+	if _, ok := reg.Lookup(testKeyB); ok {
+		t.Errorf("Testing unknown key: Expected -- removed from registry -- Got registered")
+	}
+	now = now.Add(DefaultProjectConfigTTL)
+	if err := pc.Revalidate(context.Background()); err != nil || atomic.LoadInt32(requests) != 2 {
+		t.Errorf("Testing revalidation: Expected -- 2 upstream requests -- Got %d, %v", atomic.LoadInt32(requests), err)
+	}
+}
+
+func TestProjectConfigFilters(t *testing.T) {
+	now := testNow
+	pc, _, _ := newTestConfigClient(t, &now)
+	c, err := pc.ProjectConfig(context.Background(), testKeyA)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !strings.Contains(string(c.Filters), `"1.0"`) || len(c.Quotas) != 2 || c.Quotas[0].ID != "q" {
+		t.Errorf("Expected -- filters and quotas kept -- Got %s %+v", c.Filters, c.Quotas)
+	}
+}
@@ -0,0 +1,187 @@
+package dsn
+
+import (
+	"net/http"
+	"sort"
+	"sync"
+	"time"
+)
+
+// ProjectStats keeps rolling per-minute counts of accepted and rejected submissions per project ID, for
+// admission decisions such as spike protection and for the debug endpoint. Pass it to WithProjectStats or call
+// Record. Projects without submissions in the window are forgotten. A ProjectStats is safe for concurrent use.
+type ProjectStats struct {
+	mu       sync.Mutex
+	clock    Clock
+	minutes  int64
+	projects map[string][]minuteBucket //ring of minutes entries, indexed by minute % minutes
+	pruned   int64                     //minute of the last prune
+}
+
+// minuteBucket counts one minute of one project.
+type minuteBucket struct {
+	minute   int64 //Unix minute, identifies stale entries of the ring
+	accepted int64
+	rejected int64
+}
+
+// MinuteCount is one minute of ProjectCounts.
+type MinuteCount struct {
+	Start    time.Time `json:"start"`
+	Accepted int64     `json:"accepted"`
+	Rejected int64     `json:"rejected"`
+}
+
+// ProjectCounts is the window of one project, oldest minute first. The current minute is still filling up.
+type ProjectCounts struct {
+	ProjectID string        `json:"project_id"`
+	Accepted  int64         `json:"accepted"` //sum over the window
+	Rejected  int64         `json:"rejected"`
+	Minutes   []MinuteCount `json:"minutes"`
+}
+
+func NewProjectStats(window time.Duration, opts ...Option) *ProjectStats {
+	/*
+		Keeps window, rounded up to whole minutes and at least one. Only WithClock applies.
+	*/
+	clock := newConfig(opts).clock
+	if clock == nil {
+		clock = SystemClock
+	}
+	minutes := int64((window + time.Minute - 1) / time.Minute)
+	if minutes < 1 {
+		minutes = 1
+	}
+	return &ProjectStats{clock: clock, minutes: minutes, projects: map[string][]minuteBucket{}}
+}
+
+func WithProjectStats(ps *ProjectStats) Option {
+	/*
+		Records every parse in ps under the project ID of the request path. Requests without one
+		(legacy /api/store/ rejected before resolution, unknown paths) are not counted.
+	*/
+	return func(c *config) {
+		c.projectStats = ps
+	}
+}
+
+func (ps *ProjectStats) record(r *http.Request, res *ParseResult, err error) {
+	projectID := ""
+	if err == nil && res.DSN != nil {
+		projectID = res.DSN.ProjectID
+	} else {
+		projectID, _, _ = parseIngestPath(r.URL.Path)
+	}
+	if len(projectID) > 0 {
+		ps.Record(projectID, err == nil)
+	}
+}
+
+func (ps *ProjectStats) Record(projectID string, accepted bool) {
+	/*
+		Counts one submission for projectID in the current minute.
+	*/
+	minute := ps.clock.Now().Unix() / 60
+	ps.mu.Lock()
+	defer ps.mu.Unlock()
+	if minute != ps.pruned {
+		ps.prune(minute)
+	}
+	ring, ok := ps.projects[projectID]
+	if !ok {
+		ring = make([]minuteBucket, ps.minutes)
+		ps.projects[projectID] = ring
+	}
+	b := &ring[minute%ps.minutes]
+	if b.minute != minute {
+		*b = minuteBucket{minute: minute}
+	}
+	if accepted {
+		b.accepted++
+	} else {
+		b.rejected++
+	}
+}
+
+func (ps *ProjectStats) prune(minute int64) {
+	/*
+		Drops projects whose newest minute left the window. Called once per minute, with ps.mu held.
+	*/
+	ps.pruned = minute
+	for id, ring := range ps.projects {
+		active := false
+		for _, b := range ring {
+			active = active || minute-b.minute < ps.minutes
+		}
+		if !active {
+			delete(ps.projects, id)
+		}
+	}
+}
+
+func (ps *ProjectStats) Project(projectID string) ProjectCounts {
+	/*
+		The window of projectID, all zero for projects without submissions in it.
+	*/
+	minute := ps.clock.Now().Unix() / 60
+	ps.mu.Lock()
+	defer ps.mu.Unlock()
+	return ps.counts(projectID, ps.projects[projectID], minute)
+}
+
+func (ps *ProjectStats) Rate(projectID string, minutes int) (accepted int64, rejected int64) {
+	/*
+		Submissions of projectID over the last minutes minutes, the current one included. Cheaper than Project
+		for admission decisions made per request.
+	*/
+	minute := ps.clock.Now().Unix() / 60
+	ps.mu.Lock()
+	defer ps.mu.Unlock()
+	for _, b := range ps.projects[projectID] {
+		if age := minute - b.minute; age >= 0 && age < int64(minutes) && age < ps.minutes {
+			accepted += b.accepted
+			rejected += b.rejected
+		}
+	}
+	return accepted, rejected
+}
+
+func (ps *ProjectStats) Snapshot() []ProjectCounts {
+	/*
+		Every project with submissions in the window, sorted by project ID.
+	*/
+	minute := ps.clock.Now().Unix() / 60
+	ps.mu.Lock()
+	defer ps.mu.Unlock()
+	ps.prune(minute)
+	snap := make([]ProjectCounts, 0, len(ps.projects))
+	for id, ring := range ps.projects {
+		snap = append(snap, ps.counts(id, ring, minute))
+	}
+	sort.Slice(snap, func(i, j int) bool { return snap[i].ProjectID < snap[j].ProjectID })
+	return snap
+}
+
+func (ps *ProjectStats) counts(projectID string, ring []minuteBucket, minute int64) ProjectCounts {
+	pc := ProjectCounts{ProjectID: projectID, Minutes: make([]MinuteCount, ps.minutes)}
+	for i := range pc.Minutes {
+		m := minute - ps.minutes + 1 + int64(i)
+		pc.Minutes[i].Start = time.Unix(m*60, 0).UTC()
+		if ring == nil {
+			continue
+		}
+		if b := ring[m%ps.minutes]; b.minute == m {
+			pc.Minutes[i].Accepted, pc.Minutes[i].Rejected = b.accepted, b.rejected
+			pc.Accepted += b.accepted
+			pc.Rejected += b.rejected
+		}
+	}
+	return pc
+}
+
+func (ps *ProjectStats) Register(s *Stats) {
+	/*
+		Exposes Snapshot as "projects" in s.
+	*/
+	s.Register("projects", func() interface{} { return ps.Snapshot() })
+}
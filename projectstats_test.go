@@ -0,0 +1,67 @@
+package dsn
+
+import (
+	"encoding/json"
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+func TestProjectStats(t *testing.T) {
+	now := time.Unix(1600000000, 0)
+	ps := NewProjectStats(3*time.Minute, WithClock(ClockFunc(func() time.Time { return now })))
+	requests := []string{
+		"https://sentry.io/api/1/store/?sentry_key=" + testKeyA,
+		"https://sentry.io/api/1/envelope/?sentry_key=" + testKeyA,
+		"https://sentry.io/api/1/envelope/",
+		"https://sentry.io/api/2/envelope/?sentry_key=" + testKeyA,
+		"https://sentry.io/api/store/",
+	}
+	for _, u := range requests {
+		FromRequest(httptest.NewRequest("POST", u, nil), WithProjectStats(ps))
+	}
+	now = now.Add(time.Minute)
+	ps.Record("1", true)
+
+	p := ps.Project("1")
+	if p.Accepted != 3 || p.Rejected != 1 || len(p.Minutes) != 3 {
+		t.Errorf("Expected -- 3 accepted, 1 rejected over 3 minutes -- Got %+v", p)
+	}
+	if m := p.Minutes[2]; m.Accepted != 1 || !m.Start.Equal(time.Unix(1600000000/60*60+60, 0)) {
+		t.Errorf("Expected -- current minute last -- Got %+v", m)
+	}
+	if a, r := ps.Rate("1", 1); a != 1 || r != 0 {
+		t.Errorf("Expected -- 1 accepted in the current minute -- Got %d %d", a, r)
+	}
+	if a, r := ps.Rate("1", 60); a != 3 || r != 1 {
+		t.Errorf("Expected -- rate bounded by the window -- Got %d %d", a, r)
+	}
+	if snap := ps.Snapshot(); len(snap) != 2 || snap[0].ProjectID != "1" || snap[1].Accepted != 1 {
+		t.Errorf("Expected -- projects 1 and 2 -- Got %+v", snap)
+	}
+
+	// project 2 leaves the window, project 1 still has the newer minute
+	now = now.Add(2 * time.Minute)
+	if snap := ps.Snapshot(); len(snap) != 1 || snap[0].ProjectID != "1" || snap[0].Accepted != 1 {
+		t.Errorf("Expected -- only project 1 left -- Got %+v", snap)
+	}
+	now = now.Add(time.Minute)
+	if p := ps.Project("1"); p.Accepted != 0 || len(p.Minutes) != 3 {
+		t.Errorf("Expected -- empty window -- Got %+v", p)
+	}
+}
+
+func TestProjectStatsRegister(t *testing.T) {
+	stats, ps := NewStats(), NewProjectStats(time.Minute)
+	ps.Register(stats)
+	ps.Record("42", false)
+	b, _ := json.Marshal(stats.Snapshot())
+	var got struct {
+		Sources struct {
+			Projects []ProjectCounts `json:"projects"`
+		} `json:"sources"`
+	}
+	if err := json.Unmarshal(b, &got); err != nil || len(got.Sources.Projects) != 1 || got.Sources.Projects[0].Rejected != 1 {
+		t.Errorf("Expected -- project 42 in the debug snapshot -- Got %s %v", b, err)
+	}
+}
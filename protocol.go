@@ -0,0 +1,68 @@
+package dsn
+
+import (
+	"errors"
+	"strconv"
+	"strings"
+)
+
+var (
+	// ErrMissingVersion Thrown by a ProtocolPolicy when the request has no sentry_version
+	ErrMissingVersion = errors.New("sentry:  missing sentry_version")
+	// ErrUnsupportedVersion Thrown by a ProtocolPolicy for unparsable or too old protocol versions
+	ErrUnsupportedVersion = errors.New("sentry:  unsupported sentry_version")
+	// ErrSecretRequired Thrown by a ProtocolPolicy when an old protocol version omits sentry_secret
+	ErrSecretRequired = errors.New("sentry:  sentry_secret required for this protocol version")
+	// ErrSecretNotAllowed Thrown by a ProtocolPolicy when a protocol version that deprecated the secret still sends it
+	ErrSecretNotAllowed = errors.New("sentry:  sentry_secret not allowed for this protocol version")
+)
+
+// ProtocolPolicy decides which key combinations are valid for each sentry_version.
+// Zero fields disable the corresponding check.
+type ProtocolPolicy struct {
+	MinVersion         int `json:"min_version,omitempty"`          //older versions are rejected with ErrUnsupportedVersion
+	RequireSecretBelow int `json:"require_secret_below,omitempty"` //versions below this must send sentry_secret
+	RejectSecretFrom   int `json:"reject_secret_from,omitempty"`   //versions at or above this must not send sentry_secret
+}
+
+// DefaultProtocolPolicy follows the protocol history: v7 deprecated the secret key, older versions required it.
+var DefaultProtocolPolicy = ProtocolPolicy{RequireSecretBelow: 7, RejectSecretFrom: 7}
+
+func WithProtocolPolicy(p ProtocolPolicy) Option {
+	/*
+		Enforces p on every request. Requests without sentry_version are rejected with ErrMissingVersion
+		since the policy cannot be applied to them.
+	*/
+	return func(c *config) {
+		c.protocol = &p
+	}
+}
+
+func ProtocolVersion(v string) (int, bool) {
+	/*
+		Major protocol version from a sentry_version value such as "7" or "2.0".
+	*/
+	if i := strings.IndexByte(v, '.'); i >= 0 {
+		v = v[:i]
+	}
+	n, err := strconv.Atoi(v)
+	return n, err == nil && n > 0
+}
+
+func (p *ProtocolPolicy) check(auth *Auth) error {
+	if len(auth.Version) == 0 {
+		return ErrMissingVersion
+	}
+	v, ok := ProtocolVersion(auth.Version)
+	if !ok || v < p.MinVersion {
+		return ErrUnsupportedVersion
+	}
+	hasSecret := len(auth.SecretKey) > 0
+	if p.RequireSecretBelow > 0 && v < p.RequireSecretBelow && !hasSecret {
+		return ErrSecretRequired
+	}
+	if p.RejectSecretFrom > 0 && v >= p.RejectSecretFrom && hasSecret {
+		return ErrSecretNotAllowed
+	}
+	return nil
+}
@@ -0,0 +1,47 @@
+package dsn
+
+import (
+	"net/http/httptest"
+	"testing"
+)
+
+type testProtocol struct {
+	header      string
+	policy      ProtocolPolicy
+	description string
+	err         error
+}
+
+var testTableProtocol = []testProtocol{
+	{"Sentry sentry_version=7, sentry_key=4784fbc50de2473f9977cfce8a9adce5", DefaultProtocolPolicy,
+		"Testing v7 public key only", nil},
+	{"Sentry sentry_version=7, sentry_key=4784fbc50de2473f9977cfce8a9adce5, sentry_secret=4784fbc50de2473f9977cfce8a9adce5", DefaultProtocolPolicy,
+		"Testing v7 with secret", ErrSecretNotAllowed},
+	{"Sentry sentry_version=6, sentry_key=4784fbc50de2473f9977cfce8a9adce5, sentry_secret=4784fbc50de2473f9977cfce8a9adce5", DefaultProtocolPolicy,
+		"Testing v6 with secret", nil},
+	{"Sentry sentry_version=2.0, sentry_key=4784fbc50de2473f9977cfce8a9adce5", DefaultProtocolPolicy,
+		"Testing v2 without secret", ErrSecretRequired},
+	{"Sentry sentry_key=4784fbc50de2473f9977cfce8a9adce5", DefaultProtocolPolicy,
+		"Testing missing version", ErrMissingVersion},
+	{"Sentry sentry_version=abc, sentry_key=4784fbc50de2473f9977cfce8a9adce5", DefaultProtocolPolicy,
+		"Testing garbage version", ErrUnsupportedVersion},
+	{"Sentry sentry_version=5, sentry_key=4784fbc50de2473f9977cfce8a9adce5, sentry_secret=4784fbc50de2473f9977cfce8a9adce5", ProtocolPolicy{MinVersion: 6},
+		"Testing minimum version", ErrUnsupportedVersion},
+}
+
+func TestProtocolPolicy(t *testing.T) {
+	for _, test := range testTableProtocol {
+		r := httptest.NewRequest("POST", "https://sentry.io/api/1234/store/", nil)
+		r.Header.Set("X-SENTRY-AUTH", test.header)
+		if _, err := FromRequest(r, WithProtocolPolicy(test.policy)); err != test.err {
+			t.Errorf("%s: Expected -- %v -- Got %v", test.description, test.err, err)
+		}
+	}
+}
+
+func TestProtocolPolicyQuery(t *testing.T) {
+	r := httptest.NewRequest("GET", "https://sentry.io/api/1234/store/?sentry_version=7&sentry_key=4784fbc50de2473f9977cfce8a9adce5", nil)
+	if _, err := FromRequest(r, WithProtocolPolicy(DefaultProtocolPolicy)); err != nil {
+		t.Errorf("Expected -- nil -- Got %v", err)
+	}
+}
@@ -0,0 +1,43 @@
+package dsn
+
+import (
+	"errors"
+	"net/http"
+)
+
+// ErrSecretInQuery Thrown by QuerySecretReject when sentry_secret is sent in the query string
+var ErrSecretInQuery = errors.New("sentry:  sentry_secret must not be sent in the query string")
+
+// QuerySecretPolicy decides what happens to requests carrying sentry_secret in the query string,
+// where it ends up in access logs of every proxy along the way.
+type QuerySecretPolicy int
+
+const (
+	QuerySecretAllow  QuerySecretPolicy = iota //default, the secret is accepted silently
+	QuerySecretWarn                            //accepted, with a warning through the Logger
+	QuerySecretReject                          //rejected with ErrSecretInQuery
+)
+
+func WithQuerySecretPolicy(p QuerySecretPolicy) Option {
+	/*
+		Applies p to requests sending sentry_secret in the query string, whether or not the secret was
+		taken from there. QuerySecretWarn needs WithLogger to have any effect.
+	*/
+	return func(c *config) {
+		c.querySecret = p
+	}
+}
+
+func (c *config) checkQuerySecret(r *http.Request) error {
+	if c.querySecret == QuerySecretAllow || len(r.URL.RawQuery) == 0 {
+		return nil
+	}
+	if q, _ := parseAuthQuery(r.URL.RawQuery); len(q.SecretKey) == 0 {
+		return nil
+	}
+	if c.querySecret == QuerySecretReject {
+		return ErrSecretInQuery
+	}
+	c.logf("dsn: deprecated: sentry_secret sent in the query string by %s (client %q)", r.RemoteAddr, r.UserAgent())
+	return nil
+}
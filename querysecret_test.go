@@ -0,0 +1,50 @@
+package dsn
+
+import (
+	"bytes"
+	"log"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+type testQuerySecret struct {
+	url         string
+	header      string
+	policy      QuerySecretPolicy
+	description string
+	err         error
+	warned      bool
+}
+
+var testTableQuerySecret = []testQuerySecret{
+	{"https://sentry.io/api/1/store/?sentry_key=" + testKeyA + "&sentry_secret=" + testKeyB, "", QuerySecretAllow,
+		"Testing allowed by default", nil, false},
+	{"https://sentry.io/api/1/store/?sentry_key=" + testKeyA + "&sentry_secret=" + testKeyB, "", QuerySecretWarn,
+		"Testing warning", nil, true},
+	{"https://sentry.io/api/1/store/?sentry_key=" + testKeyA + "&sentry_secret=" + testKeyB, "", QuerySecretReject,
+		"Testing rejected", ErrSecretInQuery, false},
+	{"https://sentry.io/api/1/store/?sentry_secret=" + testKeyB, "Sentry sentry_key=" + testKeyA, QuerySecretReject,
+		"Testing rejected when key comes from header", ErrSecretInQuery, false},
+	{"https://sentry.io/api/1/store/?sentry_key=" + testKeyA, "", QuerySecretReject,
+		"Testing query without secret", nil, false},
+	{"https://sentry.io/api/1/store/", "Sentry sentry_key=" + testKeyA + ", sentry_secret=" + testKeyB, QuerySecretReject,
+		"Testing header secret", nil, false},
+}
+
+func TestQuerySecretPolicy(t *testing.T) {
+	for _, test := range testTableQuerySecret {
+		r := httptest.NewRequest("POST", test.url, nil)
+		if len(test.header) > 0 {
+			r.Header.Set("X-SENTRY-AUTH", test.header)
+		}
+		var buf bytes.Buffer
+		_, err := FromRequest(r, WithQuerySecretPolicy(test.policy), WithLogger(log.New(&buf, "", 0)))
+		if err != test.err {
+			t.Errorf("%s: Expected -- %v -- Got %v", test.description, test.err, err)
+		}
+		if warned := strings.Contains(buf.String(), "sentry_secret"); warned != test.warned {
+			t.Errorf("%s: Expected -- warning %v -- Got %q", test.description, test.warned, buf.String())
+		}
+	}
+}
@@ -0,0 +1,112 @@
+package dsn
+
+import (
+	"math"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// X_SENTRY_RATE_LIMITS is the header Sentry and Relay list active rate limits in.
+var X_SENTRY_RATE_LIMITS = "X-Sentry-Rate-Limits"
+
+// RateLimit is one entry of X-Sentry-Rate-Limits: retry_after:categories:scope:reason_code:namespaces.
+type RateLimit struct {
+	RetryAfter time.Duration
+	Categories []string //data categories such as "error" or "transaction", empty for all
+	Scope      string   //"organization", "project" or "key", optional
+	ReasonCode string   //optional, e.g. "spike_limited"
+	Namespaces []string //metric namespaces, only meaningful for the metric_bucket category
+}
+
+// RateLimits is the value of an X-Sentry-Rate-Limits header.
+type RateLimits []RateLimit
+
+func ParseRateLimits(header string) RateLimits {
+	/*
+		Reads X-Sentry-Rate-Limits the way SDKs do: a retry_after that is not a number counts as 60 seconds,
+		and empty entries are skipped.
+	*/
+	var limits RateLimits
+	for _, entry := range strings.Split(header, ",") {
+		entry = strings.TrimSpace(entry)
+		if len(entry) == 0 {
+			continue
+		}
+		fields := strings.SplitN(entry, ":", 5)
+		for len(fields) < 5 {
+			fields = append(fields, "")
+		}
+		l := RateLimit{RetryAfter: 60 * time.Second, Categories: splitList(fields[1]), Scope: fields[2], ReasonCode: fields[3],
+			Namespaces: splitList(fields[4])}
+		if seconds, err := strconv.ParseFloat(strings.TrimSpace(fields[0]), 64); err == nil && seconds >= 0 {
+			l.RetryAfter = time.Duration(seconds * float64(time.Second))
+		}
+		limits = append(limits, l)
+	}
+	return limits
+}
+
+func splitList(s string) []string {
+	var items []string
+	for _, item := range strings.Split(s, ";") {
+		if item = strings.TrimSpace(item); len(item) > 0 {
+			items = append(items, item)
+		}
+	}
+	return items
+}
+
+func (limits RateLimits) String() string {
+	/*
+		The header value. Retry times are rounded up to whole seconds so clients never come back early,
+		and trailing empty fields are left out like Relay does: "60:transaction;error:key".
+	*/
+	entries := make([]string, 0, len(limits))
+	for _, l := range limits {
+		fields := []string{seconds(l.RetryAfter), strings.Join(l.Categories, ";"), l.Scope, l.ReasonCode, strings.Join(l.Namespaces, ";")}
+		n := len(fields)
+		for n > 2 && len(fields[n-1]) == 0 {
+			n--
+		}
+		entries = append(entries, strings.Join(fields[:n], ":"))
+	}
+	return strings.Join(entries, ", ")
+}
+
+func (limits RateLimits) RetryAfter() time.Duration {
+	/*
+		The longest retry time, what SDKs without category support wait for.
+	*/
+	var longest time.Duration
+	for _, l := range limits {
+		if l.RetryAfter > longest {
+			longest = l.RetryAfter
+		}
+	}
+	return longest
+}
+
+func WriteRateLimits(w http.ResponseWriter, limits RateLimits) {
+	/*
+		Sets X-Sentry-Rate-Limits and Retry-After for limits, e.g. ones read from an upstream response with
+		ParseRateLimits or a relay's own. Limits that already ran out are left out; without any nothing is set.
+		Call it before writing the status.
+	*/
+	var active RateLimits
+	for _, l := range limits {
+		if l.RetryAfter > 0 {
+			active = append(active, l)
+		}
+	}
+	if len(active) == 0 {
+		return
+	}
+	w.Header().Set(X_SENTRY_RATE_LIMITS, active.String())
+	w.Header().Set("Retry-After", seconds(active.RetryAfter()))
+}
+
+func seconds(d time.Duration) string {
+	return strconv.FormatInt(int64(math.Ceil(d.Seconds())), 10)
+}
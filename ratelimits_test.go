@@ -0,0 +1,67 @@
+package dsn
+
+import (
+	"errors"
+	"net/http/httptest"
+	"reflect"
+	"testing"
+	"time"
+)
+
+type testRateLimits struct {
+	header      string
+	description string
+	expected    RateLimits
+	written     string
+}
+
+var testTableRateLimits = []testRateLimits{
+	{"60:transaction;error:key", "Testing categories and scope",
+		RateLimits{{RetryAfter: time.Minute, Categories: []string{"transaction", "error"}, Scope: "key"}}, "60:transaction;error:key"},
+	{"2700::organization:usage_exceeded, 10:metric_bucket:project::custom;spans", "Testing all categories, reason and namespaces",
+		RateLimits{
+			{RetryAfter: 45 * time.Minute, Scope: "organization", ReasonCode: "usage_exceeded"},
+			{RetryAfter: 10 * time.Second, Categories: []string{"metric_bucket"}, Scope: "project", Namespaces: []string{"custom", "spans"}},
+		}, "2700::organization:usage_exceeded, 10:metric_bucket:project::custom;spans"},
+	{"1.5:error, soon:, ,", "Testing fractional and invalid retry_after",
+		RateLimits{{RetryAfter: 1500 * time.Millisecond, Categories: []string{"error"}}, {RetryAfter: time.Minute}}, "2:error, 60:"},
+	{"", "Testing empty header", nil, ""},
+}
+
+func TestRateLimits(t *testing.T) {
+	for _, test := range testTableRateLimits {
+		got := ParseRateLimits(test.header)
+		if !reflect.DeepEqual(got, test.expected) {
+			t.Errorf("%s: Expected -- %+v -- Got %+v", test.description, test.expected, got)
+		}
+		if s := got.String(); s != test.written {
+			t.Errorf("%s: Expected -- %q -- Got %q", test.description, test.written, s)
+		}
+	}
+}
+
+func TestWriteRateLimits(t *testing.T) {
+	w := httptest.NewRecorder()
+	WriteRateLimits(w, RateLimits{{RetryAfter: 0, Categories: []string{"error"}}, {RetryAfter: 30 * time.Second}, {RetryAfter: 90 * time.Second, Categories: []string{"transaction"}}})
+	if got := w.Header().Get(X_SENTRY_RATE_LIMITS); got != "30:, 90:transaction" {
+		t.Errorf("Expected -- expired limit left out -- Got %q", got)
+	}
+	if got := w.Header().Get("Retry-After"); got != "90" {
+		t.Errorf("Expected -- longest retry -- Got %q", got)
+	}
+	w = httptest.NewRecorder()
+	WriteRateLimits(w, nil)
+	if len(w.Header()) != 0 {
+		t.Errorf("Expected -- no headers -- Got %v", w.Header())
+	}
+	w = httptest.NewRecorder()
+	WriteError(w, &SpikeLimitError{ProjectID: "1", retry: 20 * time.Second})
+	if got := w.Header().Get(X_SENTRY_RATE_LIMITS); got != "20::project:spike_limited" {
+		t.Errorf("Expected -- spike limit announced -- Got %q", got)
+	}
+	w = httptest.NewRecorder()
+	WriteError(w, errors.New("nope"))
+	if got := w.Header().Get(X_SENTRY_RATE_LIMITS); got != "" {
+		t.Errorf("Expected -- no rate limits -- Got %q", got)
+	}
+}
@@ -0,0 +1,222 @@
+package dsn
+
+import (
+	"encoding/json"
+	"errors"
+	"io/ioutil"
+	"net/http"
+	"net/url"
+	"strings"
+	"sync"
+)
+
+var (
+	// ErrEndpointNotAllowed Thrown when a tenant sends to an endpoint missing from its AllowedEndpoints
+	ErrEndpointNotAllowed = errors.New("sentry:  endpoint not allowed for this key")
+	// ErrOriginNotAllowed Thrown when a browser request comes from an origin missing from the tenant's AllowedOrigins
+	ErrOriginNotAllowed = errors.New("sentry:  origin not allowed for this key")
+)
+
+// Tenant holds the per public key settings of a Registry.
+// Empty lists allow everything. Tenants returned by a Registry are shared and must not be modified.
+type Tenant struct {
+	PublicKey        string     `json:"public_key"`
+	AllowedEndpoints []Endpoint `json:"allowed_endpoints,omitempty"`
+	AllowedOrigins   []string   `json:"allowed_origins,omitempty"` //"*", "example.com", "*.example.com" or "https://example.com"
+	RateLimit        int        `json:"rate_limit,omitempty"`      //events per minute, 0 for unlimited. Enforced by the caller
+	Upstream         string     `json:"upstream,omitempty"`        //DSN to forward to, see UpstreamDSN
+	Validity
+
+	upstream *DSN
+}
+
+func (t *Tenant) UpstreamDSN() *DSN {
+	/*
+		Parsed Upstream, nil when there is none. Shared, use Clone to modify.
+	*/
+	return t.upstream
+}
+
+func (t *Tenant) allows(endpoint Endpoint, origin string) error {
+	/*
+		Checks the request's endpoint and Origin header. Requests without Origin (server side SDKs) pass the origin check.
+	*/
+	if len(t.AllowedEndpoints) > 0 && len(endpoint) > 0 {
+		allowed := false
+		for _, e := range t.AllowedEndpoints {
+			allowed = allowed || e == endpoint
+		}
+		if !allowed {
+			return ErrEndpointNotAllowed
+		}
+	}
+	if len(t.AllowedOrigins) == 0 || len(origin) == 0 {
+		return nil
+	}
+	host := origin
+	if u, err := url.Parse(origin); err == nil && len(u.Host) > 0 {
+		host = u.Hostname()
+	}
+	for _, pattern := range t.AllowedOrigins {
+		switch {
+		case pattern == "*", pattern == origin, strings.EqualFold(pattern, host):
+			return nil
+		case strings.HasPrefix(pattern, "*.") && strings.HasSuffix(strings.ToLower(host), strings.ToLower(pattern[1:])):
+			return nil
+		}
+	}
+	return ErrOriginNotAllowed
+}
+
+// registryFile is the JSON layout read by LoadRegistry.
+type registryFile struct {
+	Tenants []Tenant `json:"tenants"`
+}
+
+// Registry maps public keys onto Tenant settings. Pass it to WithRegistry and the parse rejects unknown keys
+// and requests the tenant does not allow.
+// A Registry is safe for concurrent use; Reload swaps all tenants at once.
+type Registry struct {
+	mu      sync.RWMutex
+	path    string
+	cipher  SecretCipher
+	sockets []string //WithUnixSockets directories for http+unix upstreams
+	tenants map[string]*Tenant
+}
+
+func NewRegistry(tenants ...Tenant) (*Registry, error) {
+	/*
+		In-memory registry. Fails if an Upstream is not a valid DSN.
+	*/
+	reg := &Registry{tenants: map[string]*Tenant{}}
+	for _, t := range tenants {
+		if err := reg.Set(t); err != nil {
+			return nil, err
+		}
+	}
+	return reg, nil
+}
+
+func LoadRegistry(path string, opts ...Option) (*Registry, error) {
+	/*
+		Reads tenants from a JSON file of the form {"tenants": [{"public_key": "...", ...}]}.
+		Call Reload (e.g. on SIGHUP) to pick up changes. With WithSecretCipher the secret keys of upstream DSNs
+		may be stored sealed; UpstreamDSN returns them decrypted while Upstream keeps the file's text.
+		http+unix upstreams need WithUnixSockets.
+	*/
+	c := newConfig(opts)
+	reg := &Registry{path: path, cipher: c.cipher, sockets: c.unixSocketDirs}
+	if err := reg.Reload(); err != nil {
+		return nil, err
+	}
+	return reg, nil
+}
+
+func (reg *Registry) Reload() error {
+	/*
+		Re-reads the file given to LoadRegistry. On error the current tenants stay in place.
+		No-op for registries built with NewRegistry.
+	*/
+	if len(reg.path) == 0 {
+		return nil
+	}
+	b, err := ioutil.ReadFile(reg.path)
+	if err != nil {
+		return err
+	}
+	var f registryFile
+	if err := json.Unmarshal(b, &f); err != nil {
+		return err
+	}
+	tenants := make(map[string]*Tenant, len(f.Tenants))
+	for i := range f.Tenants {
+		t := f.Tenants[i]
+		if err := t.prepare(reg.sockets); err != nil {
+			return err
+		}
+		if up := t.upstream; up != nil && IsSealed(up.SecretKey) {
+			if up.SecretKey, err = openSecret(reg.cipher, up.SecretKey); err != nil {
+				return err
+			}
+			up.URL = up.String()
+		}
+		tenants[t.PublicKey] = &t
+	}
+	reg.mu.Lock()
+	defer reg.mu.Unlock()
+	reg.tenants = tenants
+	return nil
+}
+
+func (t *Tenant) prepare(socketDirs []string) error {
+	if len(t.PublicKey) == 0 {
+		return ErrMissingUser
+	}
+	t.upstream = nil
+	if len(t.Upstream) > 0 {
+		up, err := parseDSN(t.Upstream, socketDirs)
+		if err != nil {
+			return err
+		}
+		t.upstream = up
+	}
+	return nil
+}
+
+func (reg *Registry) Set(t Tenant) error {
+	/*
+		Adds or replaces the tenant for t.PublicKey. Lost on the next Reload of a file backed registry.
+	*/
+	if err := t.prepare(reg.sockets); err != nil {
+		return err
+	}
+	reg.mu.Lock()
+	defer reg.mu.Unlock()
+	if reg.tenants == nil {
+		reg.tenants = map[string]*Tenant{}
+	}
+	reg.tenants[t.PublicKey] = &t
+	return nil
+}
+
+func (reg *Registry) Remove(publicKey string) {
+	reg.mu.Lock()
+	defer reg.mu.Unlock()
+	delete(reg.tenants, publicKey)
+}
+
+func (reg *Registry) Lookup(publicKey string) (*Tenant, bool) {
+	reg.mu.RLock()
+	defer reg.mu.RUnlock()
+	t, ok := reg.tenants[publicKey]
+	return t, ok
+}
+
+func (reg *Registry) Len() int {
+	reg.mu.RLock()
+	defer reg.mu.RUnlock()
+	return len(reg.tenants)
+}
+
+func WithRegistry(reg *Registry) Option {
+	/*
+		Consults reg after parsing: unknown public keys fail with ErrUnknownKey, keys outside their Validity
+		with ErrKeyExpired or ErrKeyNotYetValid, disallowed endpoints with
+		ErrEndpointNotAllowed and disallowed browser origins with ErrOriginNotAllowed.
+		The tenant is available as ParseResult.Tenant and, behind NewMiddleware, through TenantFromContext.
+	*/
+	return func(c *config) {
+		c.registry = reg
+	}
+}
+
+func (c *config) tenant(r *http.Request, publicKey string, endpoint Endpoint) (*Tenant, error) {
+	t, ok := c.registry.Lookup(publicKey)
+	if !ok {
+		return nil, ErrUnknownKey
+	}
+	if err := t.Check(c.now()); err != nil {
+		return nil, err
+	}
+	return t, t.allows(endpoint, r.Header.Get("Origin"))
+}
@@ -0,0 +1,104 @@
+package dsn
+
+import (
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+type testRegistry struct {
+	url         string
+	origin      string
+	description string
+	err         error
+}
+
+var testTableRegistry = []testRegistry{
+	{"https://sentry.io/api/1/store/?sentry_key=" + testKeyA, "",
+		"Testing known key", nil},
+	{"https://sentry.io/api/1/store/?sentry_key=" + testKeyB, "",
+		"Testing unknown key", ErrUnknownKey},
+	{"https://sentry.io/api/1/envelope/?sentry_key=" + testKeyA, "",
+		"Testing endpoint not allowed", ErrEndpointNotAllowed},
+	{"https://sentry.io/api/1/store/?sentry_key=" + testKeyA, "https://app.example.com",
+		"Testing wildcard origin", nil},
+	{"https://sentry.io/api/1/store/?sentry_key=" + testKeyA, "https://example.org",
+		"Testing origin not allowed", ErrOriginNotAllowed},
+}
+
+func TestRegistry(t *testing.T) {
+	reg, err := NewRegistry(Tenant{
+		PublicKey:        testKeyA,
+		AllowedEndpoints: []Endpoint{EndpointStore},
+		AllowedOrigins:   []string{"*.example.com"},
+		Upstream:         "https://" + testKeyB + "@o1.ingest.sentry.io/2",
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, test := range testTableRegistry {
+		r := httptest.NewRequest("POST", test.url, nil)
+		if len(test.origin) > 0 {
+			r.Header.Set("Origin", test.origin)
+		}
+		res, err := ParseRequest(r.Context(), r, WithRegistry(reg))
+		if err != test.err {
+			t.Errorf("%s: Expected -- %v -- Got %v", test.description, test.err, err)
+			continue
+		}
+		if err == nil && res.Tenant.UpstreamDSN().ProjectID != "2" {
+			t.Errorf("%s: Expected -- upstream project 2 -- Got %+v", test.description, res.Tenant.UpstreamDSN())
+		}
+	}
+}
+
+func TestRegistryReload(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "tenants.json")
+	write := func(s string) {
+		if err := ioutil.WriteFile(path, []byte(s), 0600); err != nil {
+			t.Fatal(err)
+		}
+	}
+	write(`{"tenants": [{"public_key": "` + testKeyA + `"}]}`)
+	reg, err := LoadRegistry(path)
+	if err != nil || reg.Len() != 1 {
+		t.Fatalf("Expected -- 1 tenant -- Got %v", err)
+	}
+	write(`{"tenants": [{"public_key": "` + testKeyB + `", "upstream": "not a dsn"}]}`)
+	if err := reg.Reload(); err == nil {
+		t.Errorf("Expected -- invalid upstream error -- Got nil")
+	}
+	if _, ok := reg.Lookup(testKeyA); !ok {
+		t.Errorf("Expected -- old tenants kept after failed reload -- Got none")
+	}
+	write(`{"tenants": [{"public_key": "` + testKeyB + `", "rate_limit": 60}]}`)
+	if err := reg.Reload(); err != nil {
+		t.Fatal(err)
+	}
+	if tenant, ok := reg.Lookup(testKeyB); !ok || tenant.RateLimit != 60 {
+		t.Errorf("Expected -- reloaded tenant -- Got %+v", tenant)
+	}
+	if _, ok := reg.Lookup(testKeyA); ok {
+		t.Errorf("Expected -- removed tenant gone -- Got it")
+	}
+	os.Remove(path)
+	if err := reg.Reload(); err == nil {
+		t.Errorf("Expected -- missing file error -- Got nil")
+	}
+}
+
+func TestRegistryMiddleware(t *testing.T) {
+	reg, _ := NewRegistry(Tenant{PublicKey: testKeyA, RateLimit: 10})
+	var got *Tenant
+	h := NewMiddleware(WithRegistry(reg))(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		got, _ = TenantFromContext(r.Context())
+	}))
+	r := httptest.NewRequest("POST", "https://sentry.io/api/1/store/?sentry_key="+testKeyA, nil)
+	h.ServeHTTP(httptest.NewRecorder(), r)
+	if got == nil || got.RateLimit != 10 {
+		t.Errorf("Expected -- tenant in context -- Got %+v", got)
+	}
+}
@@ -0,0 +1,168 @@
+package dsn
+
+import (
+	"crypto/sha256"
+	"errors"
+	"math"
+	"net/http"
+	"strconv"
+	"sync"
+	"time"
+)
+
+var (
+	// ErrReplayed Thrown when the same key, timestamp and body were already seen within the replay window
+	ErrReplayed = errors.New("sentry:  duplicate submission")
+	// ErrStaleTimestamp Thrown when sentry_timestamp lies outside the replay window
+	ErrStaleTimestamp = errors.New("sentry:  sentry_timestamp outside replay window")
+)
+
+// DefaultReplayBodyLimit bounds how much body is hashed for replay protection. Longer bodies are identified
+// by this prefix plus their Content-Length.
+var DefaultReplayBodyLimit int64 = 1 << 20
+
+type replayEntry struct {
+	key     [sha256.Size]byte
+	expires time.Time
+}
+
+// ReplayGuard rejects exact repeats of a submission, the same public key, sentry_timestamp and body, within
+// Window. Requests whose sentry_timestamp is further than Window from now are rejected as well, since the
+// guard could no longer recognize them. It remembers at most MaxEntries submissions, forgetting the oldest
+// first when full. Meant for relays exposed to untrusted networks; SDK retries of a submission that did arrive
+// are rejected too, which is harmless. A ReplayGuard is safe for concurrent use.
+type ReplayGuard struct {
+	Window     time.Duration
+	MaxEntries int
+
+	config  *config
+	peeker  *LimitedPeeker
+	mu      sync.Mutex
+	seen    map[[sha256.Size]byte]time.Time
+	queue   []replayEntry //oldest first
+	dropped int           //entries at the front of queue already pruned
+}
+
+func NewReplayGuard(window time.Duration, maxEntries int, opts ...Option) *ReplayGuard {
+	/*
+		WithClock is the only option that applies.
+	*/
+	return &ReplayGuard{
+		Window:     window,
+		MaxEntries: maxEntries,
+		config:     newConfig(opts),
+		peeker:     NewLimitedPeeker(DefaultReplayBodyLimit),
+		seen:       map[[sha256.Size]byte]time.Time{},
+	}
+}
+
+func WithReplayProtection(g *ReplayGuard) Option {
+	/*
+		Checks every request that otherwise parsed with g. The body is read up to DefaultReplayBodyLimit
+		and restored for downstream handlers. Requests turned down after the check, by spike protection,
+		NewMiddleware or Pipeline.Handler, are forgotten again so the SDK's retry is accepted.
+	*/
+	return func(c *config) {
+		c.replay = g
+	}
+}
+
+func (g *ReplayGuard) Check(publicKey string, timestamp string, body []byte) error {
+	/*
+		Nil the first time a submission is seen, ErrReplayed for repeats within Window.
+		timestamp is sentry_timestamp (Unix seconds, possibly fractional) and may be empty.
+	*/
+	_, err := g.check(publicKey, timestamp, body)
+	return err
+}
+
+func (g *ReplayGuard) check(publicKey string, timestamp string, body []byte) (release func(), err error) {
+	/*
+		Check, returning a func that forgets the submission again for when a later step rejects it,
+		so the SDK's retry is not refused as a replay.
+	*/
+	now := g.config.now()
+	if len(timestamp) > 0 {
+		ts, err := strconv.ParseFloat(timestamp, 64)
+		if err != nil || math.Abs(now.Sub(time.Unix(0, int64(ts*1e9))).Seconds()) > g.Window.Seconds() {
+			return nil, ErrStaleTimestamp
+		}
+	}
+	h := sha256.New()
+	h.Write([]byte(publicKey))
+	h.Write([]byte{0})
+	h.Write([]byte(timestamp))
+	h.Write([]byte{0})
+	h.Write(body)
+	var key [sha256.Size]byte
+	copy(key[:], h.Sum(nil))
+
+	g.mu.Lock()
+	defer g.mu.Unlock()
+	g.prune(now)
+	if expires, ok := g.seen[key]; ok && now.Before(expires) {
+		return nil, ErrReplayed
+	}
+	expires := now.Add(g.Window)
+	g.seen[key] = expires
+	g.queue = append(g.queue, replayEntry{key, expires})
+	return func() {
+		g.mu.Lock()
+		defer g.mu.Unlock()
+		// its queue entry stays until pruned, prune only deletes keys that still carry its expiry
+		if g.seen[key].Equal(expires) {
+			delete(g.seen, key)
+		}
+	}, nil
+}
+
+func (g *ReplayGuard) prune(now time.Time) {
+	/*
+		Drops expired entries and, over MaxEntries, the oldest ones. Called with mu held.
+	*/
+	for g.dropped < len(g.queue) {
+		e := g.queue[g.dropped]
+		if now.Before(e.expires) && (g.MaxEntries <= 0 || len(g.queue)-g.dropped < g.MaxEntries) {
+			break
+		}
+		if g.seen[e.key].Equal(e.expires) {
+			delete(g.seen, e.key)
+		}
+		g.queue[g.dropped] = replayEntry{}
+		g.dropped++
+	}
+	// compact once the pruned front outweighs the rest
+	if g.dropped > len(g.queue)/2 {
+		g.queue = append(g.queue[:0], g.queue[g.dropped:]...)
+		g.dropped = 0
+	}
+}
+
+func (g *ReplayGuard) Len() int {
+	/*
+		Submissions currently remembered.
+	*/
+	g.mu.Lock()
+	defer g.mu.Unlock()
+	return len(g.seen)
+}
+
+func (g *ReplayGuard) checkRequest(r *http.Request, res *ParseResult) error {
+	/*
+		Checks the request and has res.reject release it if anything after this turns it down.
+	*/
+	body := res.payload
+	if body == nil {
+		var err error
+		body, err = g.peeker.PeekRequest(r)
+		if err != nil && !errors.Is(err, ErrBodyTruncated) {
+			return err
+		}
+		if err != nil {
+			body = append(body, strconv.FormatInt(r.ContentLength, 10)...)
+		}
+	}
+	release, err := g.check(res.Auth.PublicKey, res.Auth.Timestamp, body)
+	res.release = release
+	return err
+}
@@ -0,0 +1,97 @@
+package dsn
+
+import (
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+)
+
+type testReplay struct {
+	key         string
+	timestamp   string
+	body        string
+	advance     time.Duration
+	description string
+	err         error
+}
+
+var testTableReplay = []testReplay{
+	{testKeyA, "1600000000", testEvent, 0, "Testing first submission", nil},
+	{testKeyA, "1600000000", testEvent, time.Second, "Testing exact repeat", ErrReplayed},
+	{testKeyA, "1600000001.5", testEvent, 0, "Testing new timestamp", nil},
+	{testKeyB, "1600000000", testEvent, 0, "Testing other key", nil},
+	{testKeyA, "1600000000", testMetaEvent, 0, "Testing other body", nil},
+	{testKeyA, "", testEvent, 0, "Testing without timestamp", nil},
+	{testKeyA, "", testEvent, 0, "Testing repeat without timestamp", ErrReplayed},
+	{testKeyA, "1599990000", testEvent, 0, "Testing stale timestamp", ErrStaleTimestamp},
+	{testKeyA, "1600009000", testEvent, 0, "Testing future timestamp", ErrStaleTimestamp},
+	{testKeyA, "soon", testEvent, 0, "Testing bad timestamp", ErrStaleTimestamp},
+	{testKeyA, "", testEvent, time.Minute, "Testing repeat after window", nil},
+}
+
+func TestReplayGuard(t *testing.T) {
+	now := time.Unix(1600000000, 0)
+	g := NewReplayGuard(time.Minute, 100, WithClock(ClockFunc(func() time.Time { return now })))
+	for _, test := range testTableReplay {
+		now = now.Add(test.advance)
+		if err := g.Check(test.key, test.timestamp, []byte(test.body)); err != test.err {
+			t.Errorf("%s: Expected -- %v -- Got %v", test.description, test.err, err)
+		}
+	}
+	if g.Len() != 1 {
+		t.Errorf("Expected -- 1 remembered after window -- Got %d", g.Len())
+	}
+}
+
+func TestReplayGuardBounded(t *testing.T) {
+	g := NewReplayGuard(time.Hour, 3)
+	for _, body := range []string{"a", "b", "c", "d"} {
+		g.Check(testKeyA, "", []byte(body))
+	}
+	if g.Len() != 3 {
+		t.Errorf("Expected -- 3 remembered -- Got %d", g.Len())
+	}
+	if err := g.Check(testKeyA, "", []byte("a")); err != nil {
+		t.Errorf("Testing evicted: Expected -- nil -- Got %v", err)
+	}
+	if err := g.Check(testKeyA, "", []byte("d")); err != ErrReplayed {
+		t.Errorf("Testing kept: Expected -- %v -- Got %v", ErrReplayed, err)
+	}
+}
+
+func TestWithReplayProtection(t *testing.T) {
+	g := NewReplayGuard(time.Minute, 100)
+	for i, status := range []int{http.StatusOK, http.StatusBadRequest} {
+		r := httptest.NewRequest("POST", "https://relay.example.com/api/1/store/", strings.NewReader(testEvent))
+		r.Header.Set("X-Sentry-Auth", "Sentry sentry_version=7, sentry_key="+testKeyA)
+		_, err := ParseRequest(r.Context(), r, WithReplayProtection(g))
+		if ErrorStatus(err) != status {
+			t.Errorf("Testing request %d: Expected -- %d -- Got %v", i, status, err)
+		}
+		if body, _ := ioutil.ReadAll(r.Body); string(body) != testEvent {
+			t.Errorf("Testing request %d: Expected -- body restored -- Got %s", i, body)
+		}
+	}
+}
+
+func TestReplayReleasedOnRejection(t *testing.T) {
+	g := NewReplayGuard(time.Minute, 100)
+	h := NewMiddleware(WithReplayProtection(g), WithScrubber(testScrubber))(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {}))
+	// the scrubber turns down the first attempt, the retry must not count as a replay
+	for i, test := range []struct {
+		encoding string
+		status   int
+	}{{"br", http.StatusUnsupportedMediaType}, {"", http.StatusOK}, {"", http.StatusBadRequest}} {
+		r := httptest.NewRequest("POST", "https://relay.example.com/api/1/store/", strings.NewReader(testEvent))
+		r.Header.Set("X-Sentry-Auth", "Sentry sentry_version=7, sentry_key="+testKeyA)
+		r.Header.Set("Content-Encoding", test.encoding)
+		w := httptest.NewRecorder()
+		h.ServeHTTP(w, r)
+		if w.Code != test.status {
+			t.Errorf("Testing request %d: Expected -- %d -- Got %d %s", i, test.status, w.Code, w.Body)
+		}
+	}
+}
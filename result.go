@@ -0,0 +1,51 @@
+package dsn
+
+import (
+	"io"
+	"net"
+	"time"
+)
+
+// Endpoint is the kind of ingest endpoint a request was sent to.
+type Endpoint string
+
+const (
+	EndpointStore    Endpoint = "store"    // /api/<project_id>/store/ and the legacy /api/store/
+	EndpointEnvelope Endpoint = "envelope" // /api/<project_id>/envelope/
+	EndpointUnreal   Endpoint = "unreal"   // /api/<project_id>/unreal/<sentry_key>/
+)
+
+// ParseResult is everything ParseRequest learned about a request.
+// Like DSN it is never modified after being returned. MarshalBinary and MarshalJSON serialize it
+// so workers behind a queue do not have to parse the request again.
+type ParseResult struct {
+	DSN            *DSN
+	Auth           Auth           //every auth field found, from all sources
+	Endpoint       Endpoint       //empty when the path was not inspected
+	KeySource      Source         //where the public key came from
+	SecretSource   Source         //where the secret key came from, SourceNone without a secret
+	Tenant         *Tenant        //set when parsed WithRegistry
+	Payload        io.Reader      //decoded event of a GET submission, see WithGetSubmissions
+	IdempotencyKey string         //same for retries of the same submission, see WithIdempotencyKey
+	Category       string         //CategoryError, CategoryTransaction, ... of the submission, see WithClassification
+	KeyExpires     time.Time      //when the resolved key stops being accepted, zero if it does not expire
+	ItemCounts     map[string]int //envelope items per type ("event", "session", "sessions", ...), see WithItemCounts
+	ClientIP       net.IP         //the client behind trusted proxies, see WithTrustedProxies
+	BodySize       int64          //Content-Length of the request, -1 when unknown
+	Warnings       []Warning      //non-fatal issues, e.g. a legacy endpoint or a stale sentry_timestamp
+	Trace          Trace          //decisions of the parse, see WithTrace. Not serialized
+
+	payload []byte //backs Payload
+	release func() //undoes the ReplayGuard record when the request is rejected after all, see reject
+}
+
+func (res *ParseResult) reject(err error) error {
+	/*
+		For rejections after parseRequest recorded the submission as seen: forgets it again so a retry passes.
+	*/
+	if res.release != nil {
+		res.release()
+		res.release = nil
+	}
+	return err
+}
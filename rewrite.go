@@ -0,0 +1,118 @@
+package dsn
+
+import (
+	"errors"
+	"regexp"
+)
+
+// ErrNoRewrite Thrown when no Rewriter rule matches the inbound DSN
+var ErrNoRewrite = errors.New("sentry:  no rewrite rule matches DSN")
+
+// RewriteRule turns an inbound DSN into an outbound one. ok reports whether the rule applied.
+type RewriteRule interface {
+	Rewrite(in *DSN) (out *DSN, ok bool)
+}
+
+// RewriteFunc adapts a plain function to RewriteRule.
+type RewriteFunc func(in *DSN) (*DSN, bool)
+
+func (f RewriteFunc) Rewrite(in *DSN) (*DSN, bool) {
+	return f(in)
+}
+
+// RewriteTarget describes the outbound side of a rule. Empty fields keep the inbound value.
+type RewriteTarget struct {
+	Host      string
+	PublicKey string
+	SecretKey string
+	Socket    string //forward over this unix socket instead, as http+unix; Host is then only the Host header
+}
+
+func (t RewriteTarget) apply(in *DSN) *DSN {
+	user := &User{PublicKey: in.PublicKey, SecretKey: in.SecretKey}
+	host := in.Host
+	if len(t.Host) > 0 {
+		host = t.Host
+	}
+	if len(t.PublicKey) > 0 {
+		// a new key pair never inherits the inbound secret
+		user = &User{PublicKey: t.PublicKey, SecretKey: t.SecretKey}
+	}
+	out := CreateDSN(user, host, in.ProjectID)
+	if len(t.Socket) > 0 {
+		out.Scheme, out.Socket = SchemeHTTPUnix, t.Socket
+		out.URL = out.String()
+	}
+	return out
+}
+
+type hostMapRule map[string]RewriteTarget
+
+func (m hostMapRule) Rewrite(in *DSN) (*DSN, bool) {
+	t, ok := m[in.Host]
+	if !ok {
+		return nil, false
+	}
+	return t.apply(in), true
+}
+
+func HostMapRule(m map[string]RewriteTarget) RewriteRule {
+	/*
+		Exact host match, e.g. {"sentry.mycorp.com": {Host: "o1.ingest.sentry.io"}}.
+		The map is copied so later changes by the caller have no effect.
+	*/
+	rule := hostMapRule{}
+	for k, v := range m {
+		rule[k] = v
+	}
+	return rule
+}
+
+type regexRule struct {
+	re     *regexp.Regexp
+	target RewriteTarget
+}
+
+func (r regexRule) Rewrite(in *DSN) (*DSN, bool) {
+	if !r.re.MatchString(in.Host) {
+		return nil, false
+	}
+	t := r.target
+	t.Host = r.re.ReplaceAllString(in.Host, r.target.Host)
+	return t.apply(in), true
+}
+
+func RegexRule(pattern string, target RewriteTarget) (RewriteRule, error) {
+	/*
+		Matches the inbound host against pattern. target.Host is a replacement template
+		so capture groups can be reused, e.g. `^(\w+)\.sentry\.mycorp\.com$` -> "$1.ingest.sentry.io".
+	*/
+	re, err := regexp.Compile(pattern)
+	if err != nil {
+		return nil, err
+	}
+	return regexRule{re: re, target: target}, nil
+}
+
+// Rewriter applies the first matching rule to inbound DSNs.
+// It is safe for concurrent use as long as its rules are.
+type Rewriter struct {
+	rules []RewriteRule
+}
+
+func NewRewriter(rules ...RewriteRule) *Rewriter {
+	return &Rewriter{rules: rules}
+}
+
+func (rw *Rewriter) Rewrite(in *DSN) (*DSN, error) {
+	/*
+		Rules are tried in the order given to NewRewriter. Returns ErrNoRewrite if none applies.
+		The inbound DSN is never modified.
+	*/
+	for _, rule := range rw.rules {
+		if out, ok := rule.Rewrite(in); ok {
+			return out, nil
+		}
+	}
+	return nil, ErrNoRewrite
+}
@@ -0,0 +1,71 @@
+package dsn
+
+import (
+	"errors"
+	"time"
+)
+
+var (
+	// ErrKeyExpired Thrown when a public key is used after its NotAfter
+	ErrKeyExpired = errors.New("sentry:  public key expired")
+	// ErrKeyNotYetValid Thrown when a public key is used before its NotBefore
+	ErrKeyNotYetValid = errors.New("sentry:  public key not yet valid")
+)
+
+// Validity is the window in which a key is accepted. Zero times leave that side open.
+// During a rotation the old and new keys of a project overlap: give the new key a NotBefore
+// and the old one a NotAfter a little later, then roll SDKs over in between.
+// Short-lived keys for untrusted devices get a NotAfter, or an IssuedAt combined with WithMaxKeyAge.
+type Validity struct {
+	NotBefore time.Time `json:"not_before,omitempty"`
+	NotAfter  time.Time `json:"not_after,omitempty"` //not valid at or after this instant
+	IssuedAt  time.Time `json:"issued_at,omitempty"`
+}
+
+func (v Validity) Check(now time.Time) error {
+	/*
+		Nil if now lies inside the window, ErrKeyNotYetValid or ErrKeyExpired otherwise.
+	*/
+	switch {
+	case !v.NotBefore.IsZero() && now.Before(v.NotBefore):
+		return ErrKeyNotYetValid
+	case !v.NotAfter.IsZero() && !now.Before(v.NotAfter):
+		return ErrKeyExpired
+	default:
+		return nil
+	}
+}
+
+func (v Validity) Expired(now time.Time) bool {
+	/*
+		Whether now is at or past NotAfter. Keys without a NotAfter never expire.
+	*/
+	return !v.NotAfter.IsZero() && !now.Before(v.NotAfter)
+}
+
+func (v Validity) Expires(maxAge time.Duration) time.Time {
+	/*
+		When the key stops being accepted: the earlier of NotAfter and IssuedAt+maxAge. Zero if neither applies.
+	*/
+	expires := v.NotAfter
+	if maxAge > 0 && !v.IssuedAt.IsZero() {
+		if aged := v.IssuedAt.Add(maxAge); expires.IsZero() || aged.Before(expires) {
+			expires = aged
+		}
+	}
+	return expires
+}
+
+func (v Validity) equal(other Validity) bool {
+	return v.NotBefore.Equal(other.NotBefore) && v.NotAfter.Equal(other.NotAfter) && v.IssuedAt.Equal(other.IssuedAt)
+}
+
+func WithMaxKeyAge(maxAge time.Duration) Option {
+	/*
+		Rejects resolved keys issued more than maxAge ago with ErrKeyExpired, on top of their NotAfter.
+		Keys without an IssuedAt are not affected.
+	*/
+	return func(c *config) {
+		c.maxKeyAge = maxAge
+	}
+}
@@ -0,0 +1,100 @@
+package dsn
+
+import (
+	"context"
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+type testValidity struct {
+	validity    Validity
+	description string
+	err         error
+}
+
+var testNow = time.Date(2020, 6, 1, 12, 0, 0, 0, time.UTC)
+
+var testTableValidity = []testValidity{
+	{Validity{}, "Testing open window", nil},
+	{Validity{NotBefore: testNow.Add(-time.Hour), NotAfter: testNow.Add(time.Hour)}, "Testing inside window", nil},
+	{Validity{NotBefore: testNow.Add(time.Hour)}, "Testing not yet valid", ErrKeyNotYetValid},
+	{Validity{NotAfter: testNow}, "Testing expired at NotAfter", ErrKeyExpired},
+}
+
+func TestValidityCheck(t *testing.T) {
+	for _, test := range testTableValidity {
+		if err := test.validity.Check(testNow); err != test.err {
+			t.Errorf("%s: Expected -- %v -- Got %v", test.description, test.err, err)
+		}
+	}
+}
+
+func TestKeyRotation(t *testing.T) {
+	now := time.Now()
+	keys := map[string]*KeyInfo{
+		testKeyA: {PublicKey: testKeyA, ProjectID: "1", Validity: Validity{NotAfter: now.Add(-time.Minute)}},
+		testKeyB: {PublicKey: testKeyB, ProjectID: "1", Validity: Validity{NotBefore: now.Add(-time.Hour)}},
+	}
+	resolver := KeyResolverFunc(func(ctx context.Context, publicKey string) (*KeyInfo, error) {
+		return keys[publicKey], nil
+	})
+	r := httptest.NewRequest("POST", "https://sentry.io/api/1/store/?sentry_key="+testKeyA, nil)
+	if _, err := FromRequest(r, WithKeyResolver(resolver)); err != ErrKeyExpired {
+		t.Errorf("Expected -- %v -- Got %v", ErrKeyExpired, err)
+	}
+	if status := ErrorStatus(ErrKeyExpired); status != 401 {
+		t.Errorf("Expected -- 401 -- Got %d", status)
+	}
+	r = httptest.NewRequest("POST", "https://sentry.io/api/1/store/?sentry_key="+testKeyB, nil)
+	if _, err := FromRequest(r, WithKeyResolver(resolver)); err != nil {
+		t.Errorf("Expected -- no error -- Got %v", err)
+	}
+
+	reg, _ := NewRegistry(Tenant{PublicKey: testKeyA, Validity: keys[testKeyA].Validity})
+	r = httptest.NewRequest("POST", "https://sentry.io/api/1/store/?sentry_key="+testKeyA, nil)
+	if _, err := FromRequest(r, WithRegistry(reg)); err != ErrKeyExpired {
+		t.Errorf("Expected -- %v -- Got %v", ErrKeyExpired, err)
+	}
+}
+
+type testExpiry struct {
+	validity    Validity
+	maxAge      time.Duration
+	description string
+	expires     time.Time
+	err         error
+}
+
+var testTableExpiry = []testExpiry{
+	{Validity{}, time.Hour, "Testing no expiry", time.Time{}, nil},
+	{Validity{IssuedAt: testNow.Add(-30 * time.Minute)}, 0, "Testing IssuedAt without max age", time.Time{}, nil},
+	{Validity{IssuedAt: testNow.Add(-30 * time.Minute)}, time.Hour, "Testing young key", testNow.Add(30 * time.Minute), nil},
+	{Validity{IssuedAt: testNow.Add(-2 * time.Hour)}, time.Hour, "Testing aged key", testNow.Add(-time.Hour), ErrKeyExpired},
+	{Validity{IssuedAt: testNow.Add(-30 * time.Minute), NotAfter: testNow.Add(time.Minute)}, time.Hour, "Testing NotAfter before max age", testNow.Add(time.Minute), nil},
+	{Validity{NotAfter: testNow.Add(-time.Second)}, time.Hour, "Testing expired NotAfter", testNow.Add(-time.Second), ErrKeyExpired},
+}
+
+func TestKeyExpiry(t *testing.T) {
+	for _, test := range testTableExpiry {
+		if got := test.validity.Expires(test.maxAge); !got.Equal(test.expires) {
+			t.Errorf("%s: Expected -- %v -- Got %v", test.description, test.expires, got)
+		}
+		resolver := KeyResolverFunc(func(ctx context.Context, publicKey string) (*KeyInfo, error) {
+			return &KeyInfo{PublicKey: publicKey, ProjectID: "1", Validity: test.validity}, nil
+		})
+		r := httptest.NewRequest("POST", "https://sentry.io/api/1/store/?sentry_key="+testKeyA, nil)
+		res, err := ParseRequest(r.Context(), r, WithKeyResolver(resolver), WithMaxKeyAge(test.maxAge),
+			WithClock(ClockFunc(func() time.Time { return testNow })))
+		if err != test.err {
+			t.Errorf("%s: Expected -- %v -- Got %v", test.description, test.err, err)
+			continue
+		}
+		if err == nil && !res.KeyExpires.Equal(test.expires) {
+			t.Errorf("%s: Expected -- KeyExpires %v -- Got %v", test.description, test.expires, res.KeyExpires)
+		}
+	}
+	if !(Validity{NotAfter: testNow}).Expired(testNow) || (Validity{}).Expired(testNow) {
+		t.Errorf("Expected -- Expired at NotAfter only -- Got the opposite")
+	}
+}
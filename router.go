@@ -0,0 +1,119 @@
+package dsn
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"sync"
+)
+
+// ErrNoRoute Thrown when a Router has no upstream for a DSN and no default is set
+var ErrNoRoute = errors.New("sentry:  no upstream DSN for request")
+
+// Router maps inbound DSNs onto upstream DSNs for multi-tenant relays.
+// Lookups go by public key, then by project ID, then by pattern in the order added, then fall back to the default DSN.
+// A Router is safe for concurrent use. Upstreams are cloned when added, so the caller may reuse its values,
+// and the DSNs returned by Route are shared and must not be modified.
+type Router struct {
+	mu        sync.RWMutex
+	byKey     map[string]*DSN
+	byProject map[string]*DSN
+	patterns  []patternRoute
+	fallback  *DSN
+}
+
+type patternRoute struct {
+	matcher  *Matcher
+	upstream *DSN
+}
+
+func NewRouter() *Router {
+	return &Router{byKey: map[string]*DSN{}, byProject: map[string]*DSN{}}
+}
+
+func (rt *Router) AddKey(publicKey string, upstream *DSN) {
+	/*
+		Requests authenticated with publicKey are forwarded as upstream. Takes precedence over AddProject.
+	*/
+	rt.mu.Lock()
+	defer rt.mu.Unlock()
+	rt.byKey[publicKey] = upstream.Clone()
+}
+
+func (rt *Router) AddProject(projectID string, upstream *DSN) {
+	/*
+		Requests for projectID are forwarded as upstream unless their public key has its own route.
+	*/
+	rt.mu.Lock()
+	defer rt.mu.Unlock()
+	rt.byProject[projectID] = upstream.Clone()
+}
+
+func (rt *Router) AddPattern(m *Matcher, upstream *DSN) {
+	/*
+		Requests whose DSN matches m are forwarded as upstream unless their key or project has its own route.
+		Patterns are tried in the order they were added.
+	*/
+	rt.mu.Lock()
+	defer rt.mu.Unlock()
+	rt.patterns = append(rt.patterns, patternRoute{m, upstream.Clone()})
+}
+
+func (rt *Router) SetDefault(upstream *DSN) {
+	/*
+		Used when neither key nor project match. nil removes the fallback.
+	*/
+	rt.mu.Lock()
+	defer rt.mu.Unlock()
+	rt.fallback = upstream.Clone()
+}
+
+func (rt *Router) Remove(publicKey string, projectID string) {
+	/*
+		Drops the routes for publicKey and projectID. Empty strings are ignored.
+	*/
+	rt.mu.Lock()
+	defer rt.mu.Unlock()
+	delete(rt.byKey, publicKey)
+	delete(rt.byProject, projectID)
+}
+
+func (rt *Router) Route(in *DSN) (*DSN, error) {
+	/*
+		Answers "which DSN should this request be forwarded as?".
+		Returns ErrNoRoute when nothing matches and there is no default.
+	*/
+	rt.mu.RLock()
+	defer rt.mu.RUnlock()
+	if up, ok := rt.byKey[in.PublicKey]; ok {
+		return up, nil
+	}
+	if up, ok := rt.byProject[in.ProjectID]; ok && len(in.ProjectID) > 0 {
+		return up, nil
+	}
+	for _, p := range rt.patterns {
+		if p.matcher.Match(in) {
+			return p.upstream, nil
+		}
+	}
+	if rt.fallback != nil {
+		return rt.fallback, nil
+	}
+	return nil, ErrNoRoute
+}
+
+func (rt *Router) RouteRequest(ctx context.Context, r *http.Request, opts ...Option) (*DSN, *DSN, error) {
+	/*
+		Parses r with FromRequestContext and routes the result.
+		Returns the inbound and upstream DSNs.
+	*/
+	in, err := FromRequestContext(ctx, r, opts...)
+	if err != nil {
+		return nil, nil, err
+	}
+	up, err := rt.Route(in)
+	if err != nil {
+		return in, nil, err
+	}
+	return in, up, nil
+}
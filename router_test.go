@@ -0,0 +1,71 @@
+package dsn
+
+import (
+	"context"
+	"net/http/httptest"
+	"testing"
+)
+
+type testRoute struct {
+	in          *DSN
+	description string
+	expected    *DSN
+}
+
+var (
+	upstreamA = CreateDSN(&User{PublicKey: "aaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaa"}, "o1.ingest.sentry.io", "1")
+	upstreamB = CreateDSN(&User{PublicKey: "bbbbbbbbbbbbbbbbbbbbbbbbbbbbbbbb"}, "o2.ingest.sentry.io", "2")
+	upstreamC = CreateDSN(&User{PublicKey: "cccccccccccccccccccccccccccccccc"}, "o3.ingest.sentry.io", "3")
+)
+
+var testTableRouter = []testRoute{
+	{CreateDSN(&User{PublicKey: "4784fbc50de2473f9977cfce8a9adce5"}, "relay", "1234"), "Testing key route", upstreamA},
+	{CreateDSN(&User{PublicKey: "4784fbc50de2473f9977cfce8a9adce5"}, "relay", "99"), "Testing key route wins over project", upstreamA},
+	{CreateDSN(&User{PublicKey: "00000000000000000000000000000000"}, "relay", "99"), "Testing project route", upstreamB},
+	{CreateDSN(&User{PublicKey: "00000000000000000000000000000000"}, "relay", "5"), "Testing default route", upstreamC},
+}
+
+func newTestRouter() *Router {
+	rt := NewRouter()
+	rt.AddKey("4784fbc50de2473f9977cfce8a9adce5", upstreamA)
+	rt.AddProject("99", upstreamB)
+	rt.SetDefault(upstreamC)
+	return rt
+}
+
+func TestRouterRoute(t *testing.T) {
+	rt := newTestRouter()
+	for _, test := range testTableRouter {
+		got, err := rt.Route(test.in)
+		if err != nil || !got.Equal(test.expected, false) {
+			t.Errorf("%s: Expected -- %s -- Got %v %v", test.description, test.expected.URL, got, err)
+		}
+	}
+}
+
+func TestRouterNoRoute(t *testing.T) {
+	rt := newTestRouter()
+	rt.SetDefault(nil)
+	if got, err := rt.Route(testTableRouter[3].in); err != ErrNoRoute {
+		t.Errorf("Expected -- %s -- Got %v %v", ErrNoRoute, got, err)
+	}
+}
+
+func TestRouterRouteRequest(t *testing.T) {
+	rt := newTestRouter()
+	r := httptest.NewRequest("POST", "https://relay/api/99/store/?sentry_key=4784fbc50de2473f9977cfce8a9adce5", nil)
+	in, up, err := rt.RouteRequest(context.Background(), r)
+	if err != nil || in.ProjectID != "99" || !up.Equal(upstreamA, false) {
+		t.Errorf("Expected -- %s -- Got %v %v", upstreamA.URL, up, err)
+	}
+}
+
+func TestRouterClonesUpstreams(t *testing.T) {
+	up := upstreamA.Clone()
+	rt := NewRouter()
+	rt.SetDefault(up)
+	up.Host = "changed.example.com"
+	if got, _ := rt.Route(testTableRouter[0].in); got.Host != upstreamA.Host {
+		t.Errorf("Expected -- %s -- Got %s", upstreamA.Host, got.Host)
+	}
+}
@@ -0,0 +1,99 @@
+package dsn
+
+import (
+	"bufio"
+	"bytes"
+	"regexp"
+	"strings"
+)
+
+// Finding is a DSN-shaped value found by ScanConfig. Value is verbatim, secret key included, so treat
+// findings like the file they came from.
+type Finding struct {
+	Line    int    `json:"line"`          //1-based
+	Column  int    `json:"column"`        //1-based byte offset of Value in the line
+	Key     string `json:"key,omitempty"` //variable or YAML key the value is assigned to, e.g. SENTRY_DSN
+	Value   string `json:"value"`         //quotes removed
+	Valid   bool   `json:"valid"`
+	DSN     *DSN   `json:"dsn,omitempty"`
+	Err     error  `json:"-"`
+	Code    string `json:"code,omitempty"`  //ErrorCode of Err
+	Message string `json:"error,omitempty"` //Err as text
+}
+
+var (
+	// dsnShape finds DSNs in free text: an http(s) URL with userinfo
+	dsnShape = regexp.MustCompile(`(?i)\bhttps?(?:\+unix)?://[^\s"'@/]+@[^\s"'#,;]+`)
+	// assignment matches KEY=VALUE (.env, compose environment lists) and key: value (YAML) lines
+	assignment = regexp.MustCompile(`^(?:-\s+)?(?:export\s+)?([A-Za-z_][A-Za-z0-9_.\-]*)\s*(?:=|:(?:\s|$))\s*`)
+	// interpolation matches values taken from elsewhere, ${SENTRY_DSN} or $SENTRY_DSN
+	interpolation = regexp.MustCompile(`^\$(?:\{[^}]*\}|[A-Za-z_][A-Za-z0-9_]*)$`)
+)
+
+func ScanConfig(content []byte) []Finding {
+	/*
+		Finds DSNs in .env files, docker-compose and other YAML files: the values of keys naming a DSN
+		(SENTRY_DSN=..., dsn: ..., - "SENTRY_DSN=...") whatever they hold, and http(s) URLs with a public key
+		anywhere else. Every finding is run through Parse. Comments, empty values and interpolations like
+		${SENTRY_DSN} are skipped, and so are YAML block scalars.
+	*/
+	var findings []Finding
+	s := bufio.NewScanner(bytes.NewReader(content))
+	s.Buffer(nil, 1<<20)
+	for n := 1; s.Scan(); n++ {
+		line := s.Text()
+		start := len(line) - len(strings.TrimLeft(line, " \t"))
+		rest := line[start:]
+		if len(rest) == 0 || rest[0] == '#' {
+			continue
+		}
+		// compose list items may quote the whole assignment: - "SENTRY_DSN=https://..."
+		if strings.HasPrefix(rest, "- ") {
+			item := strings.TrimLeft(rest[2:], " ")
+			if len(item) > 1 && (item[0] == '"' || item[0] == '\'') && item[len(item)-1] == item[0] {
+				start += len(rest) - len(item) + 1
+				rest = item[1 : len(item)-1]
+			}
+		}
+		key := ""
+		if m := assignment.FindStringSubmatch(rest); m != nil {
+			key = m[1]
+			if strings.Contains(strings.ToLower(key), "dsn") {
+				value, offset := configValue(rest[len(m[0]):])
+				if len(value) > 0 && !interpolation.MatchString(value) {
+					findings = append(findings, newFinding(n, start+len(m[0])+offset, key, value))
+				}
+				continue
+			}
+		}
+		for _, loc := range dsnShape.FindAllStringIndex(rest, -1) {
+			findings = append(findings, newFinding(n, start+loc[0], key, rest[loc[0]:loc[1]]))
+		}
+	}
+	return findings
+}
+
+func configValue(v string) (string, int) {
+	/*
+		The value of an assignment with quotes or a trailing comment removed, and its offset in v.
+	*/
+	if len(v) > 1 && (v[0] == '"' || v[0] == '\'') {
+		if end := strings.IndexByte(v[1:], v[0]); end >= 0 {
+			return v[1 : end+1], 1
+		}
+	}
+	if i := strings.Index(v, " #"); i >= 0 {
+		v = v[:i]
+	}
+	return strings.TrimRight(v, " \t"), 0
+}
+
+func newFinding(line int, offset int, key string, value string) Finding {
+	f := Finding{Line: line, Column: offset + 1, Key: key, Value: value}
+	f.DSN, f.Err = Parse(value)
+	f.Valid = f.Err == nil
+	if f.Err != nil {
+		f.Code, f.Message = ErrorCode(f.Err), f.Err.Error()
+	}
+	return f
+}
@@ -0,0 +1,48 @@
+package dsn
+
+import (
+	"testing"
+)
+
+type testScanConfig struct {
+	content     string
+	description string
+	expected    []Finding //Line, Column, Key, Value and Code are compared
+}
+
+var testTableScanConfig = []testScanConfig{
+	{"SENTRY_DSN=https://" + testKeyA + "@sentry.io/1\n", "Testing .env assignment",
+		[]Finding{{Line: 1, Column: 12, Key: "SENTRY_DSN", Value: "https://" + testKeyA + "@sentry.io/1"}}},
+	{"# SENTRY_DSN=https://" + testKeyA + "@sentry.io/1\nexport SENTRY_DSN=\"https://" + testKeyA + "@sentry.io/2\" # prod\n", "Testing comment, export and quotes",
+		[]Finding{{Line: 2, Column: 20, Key: "SENTRY_DSN", Value: "https://" + testKeyA + "@sentry.io/2"}}},
+	{"NEXT_PUBLIC_SENTRY_DSN=https://sentry.io/1 # no key\nSENTRY_DSN=${SENTRY_DSN}\nSENTRY_DSN=\n", "Testing invalid DSN, interpolation and empty value",
+		[]Finding{{Line: 1, Column: 24, Key: "NEXT_PUBLIC_SENTRY_DSN", Value: "https://sentry.io/1", Code: "missing_public_key"}}},
+	{"services:\n  web:\n    environment:\n      - SENTRY_DSN=https://" + testKeyA + "@sentry.io/1\n      - \"OTHER_DSN=https://" + testKeyB + "@sentry.io/2\"\n",
+		"Testing compose environment list", []Finding{
+			{Line: 4, Column: 20, Key: "SENTRY_DSN", Value: "https://" + testKeyA + "@sentry.io/1"},
+			{Line: 5, Column: 20, Key: "OTHER_DSN", Value: "https://" + testKeyB + "@sentry.io/2"},
+		}},
+	{"sentry:\n  dsn: 'https://" + testKeyA + "@sentry.io/1'\n", "Testing YAML value",
+		[]Finding{{Line: 2, Column: 9, Key: "dsn", Value: "https://" + testKeyA + "@sentry.io/1"}}},
+	{"DATABASE_URL=postgres://user:pass@db/1\nERROR_REPORTING=https://" + testKeyA + "@o1.ingest.sentry.io/1\n", "Testing DSN under another key",
+		[]Finding{{Line: 2, Column: 17, Key: "ERROR_REPORTING", Value: "https://" + testKeyA + "@o1.ingest.sentry.io/1"}}},
+	{"command: relay --upstream https://" + testKeyA + "@sentry.io/1 --port 3000\n", "Testing DSN in free text",
+		[]Finding{{Line: 1, Column: 27, Key: "command", Value: "https://" + testKeyA + "@sentry.io/1"}}},
+	{"- \"\n-\n", "Testing degenerate list items", nil},
+}
+
+func TestScanConfig(t *testing.T) {
+	for _, test := range testTableScanConfig {
+		got := ScanConfig([]byte(test.content))
+		if len(got) != len(test.expected) {
+			t.Errorf("%s: Expected -- %+v -- Got %+v", test.description, test.expected, got)
+			continue
+		}
+		for i, f := range got {
+			e := test.expected[i]
+			if f.Line != e.Line || f.Column != e.Column || f.Key != e.Key || f.Value != e.Value || f.Code != e.Code || f.Valid != (e.Code == "") {
+				t.Errorf("%s: Expected -- %+v -- Got %+v", test.description, e, f)
+			}
+		}
+	}
+}
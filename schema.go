@@ -0,0 +1,96 @@
+package dsn
+
+// SchemaID identifies the JSON Schema returned by Schema. It changes with WireVersion.
+const SchemaID = "https://github.com/dgbailey/dsn/schema/parse-result-v3.json"
+
+// schema describes the JSON form of ParseResult (MarshalJSON) and of DSN.
+const schema = `{
+  "$schema": "https://json-schema.org/draft/2020-12/schema",
+  "$id": "` + SchemaID + `",
+  "title": "ParseResult",
+  "description": "A parsed Sentry ingest request as written by ParseResult.MarshalJSON. Secret keys are included.",
+  "type": "object",
+  "required": ["v", "auth", "key_source", "secret_source", "body_size"],
+  "properties": {
+    "v": {"description": "Wire format version.", "const": 3},
+    "dsn": {"$ref": "#/$defs/dsn"},
+    "auth": {"$ref": "#/$defs/auth"},
+    "endpoint": {"$ref": "#/$defs/endpoint"},
+    "key_source": {"$ref": "#/$defs/source"},
+    "secret_source": {"$ref": "#/$defs/source"},
+    "tenant": {"$ref": "#/$defs/tenant"},
+    "idempotency_key": {"type": "string"},
+    "category": {"description": "Sentry data category, e.g. error or transaction.", "type": "string"},
+    "key_expires": {"type": "string", "format": "date-time"},
+    "item_counts": {"description": "Envelope items per item type.", "type": "object", "additionalProperties": {"type": "integer"}},
+    "client_ip": {"type": "string", "anyOf": [{"format": "ipv4"}, {"format": "ipv6"}]},
+    "body_size": {"description": "Bytes of the request body, -1 when unknown.", "type": "integer"},
+    "warnings": {"type": "array", "items": {"$ref": "#/$defs/warning"}},
+    "payload": {"description": "Decoded event of a GET submission, base64.", "type": "string", "contentEncoding": "base64"}
+  },
+  "$defs": {
+    "dsn": {
+      "type": "object",
+      "required": ["url", "host", "project_id", "public_key"],
+      "properties": {
+        "url": {"description": "The DSN string, empty when the project or key is unknown.", "type": "string"},
+        "scheme": {"description": "Empty means https.", "enum": ["http", "https", "http+unix"]},
+        "host": {"type": "string"},
+        "port": {"type": "string", "pattern": "^[0-9]+$"},
+        "path": {"description": "Prefix before the project ID.", "type": "string"},
+        "socket": {"description": "Unix socket of http+unix DSNs.", "type": "string"},
+        "project_id": {"type": "string", "pattern": "^([0-9]+)?$"},
+        "project_slug": {"type": "string"},
+        "public_key": {"type": "string"},
+        "secret_key": {"type": "string"},
+        "options": {"type": "object", "additionalProperties": {"type": "array", "items": {"type": "string"}}}
+      }
+    },
+    "auth": {
+      "type": "object",
+      "properties": {
+        "public_key": {"type": "string"},
+        "secret_key": {"type": "string"},
+        "version": {"type": "string"},
+        "client": {"type": "string"},
+        "timestamp": {"type": "string"},
+        "signature": {"type": "string"},
+        "extra": {"description": "Unknown sentry_* fields by their full name, e.g. sentry_new, values verbatim.", "type": "object", "propertyNames": {"pattern": "^sentry_"}, "additionalProperties": {"type": "string"}}
+      }
+    },
+    "endpoint": {"enum": ["", "store", "envelope", "unreal", "minidump", "security", "attachments", "cron", "tunnel"]},
+    "source": {"enum": ["none", "header", "query", "path", "body", "basic_auth", "multipart"]},
+    "tenant": {
+      "type": "object",
+      "required": ["public_key"],
+      "properties": {
+        "public_key": {"type": "string"},
+        "allowed_endpoints": {"type": "array", "items": {"$ref": "#/$defs/endpoint"}},
+        "allowed_origins": {"type": "array", "items": {"type": "string"}},
+        "rate_limit": {"description": "Events per minute, 0 for unlimited.", "type": "integer"},
+        "upstream": {"description": "DSN to forward to.", "type": "string"},
+        "not_before": {"type": "string", "format": "date-time"},
+        "not_after": {"type": "string", "format": "date-time"},
+        "issued_at": {"type": "string", "format": "date-time"}
+      }
+    },
+    "warning": {
+      "type": "object",
+      "required": ["code", "message"],
+      "properties": {
+        "code": {"type": "string"},
+        "message": {"type": "string"}
+      }
+    }
+  }
+}
+`
+
+func Schema() []byte {
+	/*
+		JSON Schema (draft 2020-12) of the JSON wire format of ParseResult, with DSN as its "dsn" definition,
+		for consumers in other languages to generate readers and validate queued payloads. Additional
+		properties are allowed so readers keep working when later versions add fields.
+	*/
+	return []byte(schema)
+}
@@ -0,0 +1,206 @@
+package dsn
+
+import (
+	"encoding/json"
+	"fmt"
+	"math"
+	"net"
+	"net/http/httptest"
+	"reflect"
+	"regexp"
+	"strings"
+	"testing"
+	"time"
+)
+
+type testSchemaFields struct {
+	value       interface{}
+	definition  string
+	description string
+}
+
+var testTableSchemaFields = []testSchemaFields{
+	{wireResult{}, "", "Testing parse result fields"},
+	{DSN{}, "dsn", "Testing DSN fields"},
+	{wireAuth{}, "auth", "Testing auth fields"},
+	{Tenant{}, "tenant", "Testing tenant fields"},
+	{Warning{}, "warning", "Testing warning fields"},
+}
+
+// testSchemaDoc is the part of the schema the tests look at.
+type testSchemaDoc struct {
+	ID         string                     `json:"$id"`
+	Properties map[string]json.RawMessage `json:"properties"`
+	Defs       map[string]struct {
+		Properties map[string]json.RawMessage `json:"properties"`
+		Enum       []string                   `json:"enum"`
+	} `json:"$defs"`
+}
+
+func jsonFields(t reflect.Type) []string {
+	var names []string
+	for i := 0; i < t.NumField(); i++ {
+		f := t.Field(i)
+		if f.Anonymous {
+			names = append(names, jsonFields(f.Type)...)
+			continue
+		}
+		name := strings.Split(f.Tag.Get("json"), ",")[0]
+		if len(f.PkgPath) == 0 && name != "-" && len(name) > 0 {
+			names = append(names, name)
+		}
+	}
+	return names
+}
+
+func TestSchemaFields(t *testing.T) {
+	var doc testSchemaDoc
+	if err := json.Unmarshal(Schema(), &doc); err != nil || doc.ID != SchemaID {
+		t.Fatalf("Expected -- a JSON document -- Got %v", err)
+	}
+	for _, test := range testTableSchemaFields {
+		props := doc.Properties
+		if len(test.definition) > 0 {
+			props = doc.Defs[test.definition].Properties
+		}
+		for _, name := range jsonFields(reflect.TypeOf(test.value)) {
+			if _, ok := props[name]; !ok {
+				t.Errorf("%s: Expected -- %s in the schema -- Got %v", test.description, name, props)
+			}
+		}
+	}
+	if got := doc.Defs["source"].Enum; !reflect.DeepEqual(got, sourceNames[:]) {
+		t.Errorf("Expected -- %v -- Got %v", sourceNames, got)
+	}
+}
+
+func TestSchemaCoversMarshalJSON(t *testing.T) {
+	var doc testSchemaDoc
+	json.Unmarshal(Schema(), &doc)
+	d, _ := Parse("https://" + testKeyA + ":" + testKeyB + "@sentry.io/sentry/1?region=eu")
+	tenant := &Tenant{PublicKey: testKeyA, AllowedEndpoints: []Endpoint{EndpointStore}, RateLimit: 10}
+	res := &ParseResult{DSN: d, Endpoint: EndpointEnvelope, KeySource: SourceHeader, Tenant: tenant, ItemCounts: map[string]int{"event": 1},
+		ClientIP: net.ParseIP("10.0.0.1"), KeyExpires: time.Unix(1600000000, 0), Warnings: []Warning{{"x", "y"}}, payload: []byte("{}")}
+	b, _ := json.Marshal(res)
+	var got map[string]json.RawMessage
+	json.Unmarshal(b, &got)
+	for k := range got {
+		if _, ok := doc.Properties[k]; !ok {
+			t.Errorf("Expected -- %s in the schema -- Got %s", k, Schema())
+		}
+	}
+	var v struct {
+		Endpoint string `json:"endpoint"`
+	}
+	json.Unmarshal(b, &v)
+	found := false
+	for _, e := range doc.Defs["endpoint"].Enum {
+		found = found || e == v.Endpoint
+	}
+	if !found {
+		t.Errorf("Expected -- endpoint %s in the enum -- Got %v", v.Endpoint, doc.Defs["endpoint"].Enum)
+	}
+}
+
+// testValidate checks v against the keywords Schema uses, formats aside, and returns what does not conform.
+func testValidate(root, s map[string]interface{}, v interface{}, path string) []string {
+	if ref, ok := s["$ref"].(string); ok {
+		def := strings.TrimPrefix(ref, "#/$defs/")
+		return testValidate(root, root["$defs"].(map[string]interface{})[def].(map[string]interface{}), v, path)
+	}
+	var errs []string
+	fail := func(format string, args ...interface{}) {
+		errs = append(errs, path+": "+fmt.Sprintf(format, args...))
+	}
+	if c, ok := s["const"]; ok && !reflect.DeepEqual(c, v) {
+		fail("expected %v, got %v", c, v)
+	}
+	if enum, ok := s["enum"].([]interface{}); ok {
+		found := false
+		for _, e := range enum {
+			found = found || reflect.DeepEqual(e, v)
+		}
+		if !found {
+			fail("%v not in %v", v, enum)
+		}
+	}
+	if anyOf, ok := s["anyOf"].([]interface{}); ok {
+		matched := false
+		for _, sub := range anyOf {
+			matched = matched || len(testValidate(root, sub.(map[string]interface{}), v, path)) == 0
+		}
+		if !matched {
+			fail("%v matches none of anyOf", v)
+		}
+	}
+	if p, ok := s["pattern"].(string); ok {
+		if str, ok := v.(string); ok && !regexp.MustCompile(p).MatchString(str) {
+			fail("%q does not match %s", str, p)
+		}
+	}
+	switch typ, _ := s["type"].(string); typ {
+	case "string":
+		if _, ok := v.(string); !ok {
+			fail("expected a string, got %T", v)
+		}
+	case "integer":
+		if n, ok := v.(float64); !ok || n != math.Trunc(n) {
+			fail("expected an integer, got %v", v)
+		}
+	case "array":
+		items, ok := v.([]interface{})
+		if !ok {
+			fail("expected an array, got %T", v)
+		}
+		if sub, ok := s["items"].(map[string]interface{}); ok {
+			for i, item := range items {
+				errs = append(errs, testValidate(root, sub, item, fmt.Sprintf("%s[%d]", path, i))...)
+			}
+		}
+	case "object":
+		obj, ok := v.(map[string]interface{})
+		if !ok {
+			fail("expected an object, got %T", v)
+		}
+		required, _ := s["required"].([]interface{})
+		for _, name := range required {
+			if _, ok := obj[name.(string)]; !ok {
+				fail("missing %s", name)
+			}
+		}
+		props, _ := s["properties"].(map[string]interface{})
+		for name, value := range obj {
+			if names, ok := s["propertyNames"].(map[string]interface{}); ok {
+				errs = append(errs, testValidate(root, names, name, path+"."+name+" (name)")...)
+			}
+			if sub, ok := props[name].(map[string]interface{}); ok {
+				errs = append(errs, testValidate(root, sub, value, path+"."+name)...)
+			} else if sub, ok := s["additionalProperties"].(map[string]interface{}); ok {
+				errs = append(errs, testValidate(root, sub, value, path+"."+name)...)
+			}
+		}
+	}
+	return errs
+}
+
+func TestSchemaValidatesParseResult(t *testing.T) {
+	var root map[string]interface{}
+	if err := json.Unmarshal(Schema(), &root); err != nil {
+		t.Fatal(err)
+	}
+	r := httptest.NewRequest("POST", "https://sentry.io/api/1/envelope/", strings.NewReader(testSessionEnvelope))
+	r.Header.Set("X-Sentry-Auth", "Sentry sentry_key="+testKeyA+", sentry_secret="+testKeyB+", sentry_version=7, sentry_client=sentry.go/0.1, sentry_new=\"1\"")
+	res, err := ParseRequest(r.Context(), r, WithItemCounts(), WithClassification())
+	if err != nil {
+		t.Fatal(err)
+	}
+	b, _ := json.Marshal(res)
+	var v interface{}
+	json.Unmarshal(b, &v)
+	for _, e := range testValidate(root, root, v, "$") {
+		t.Errorf("Expected -- %s to conform to the schema -- Got %s", b, e)
+	}
+	if extra := res.Auth.Extra(); extra["sentry_new"] != `"1"` {
+		t.Errorf("Expected -- sentry_new kept by its full name -- Got %v", extra)
+	}
+}
@@ -0,0 +1,226 @@
+package dsn
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"io"
+	"io/ioutil"
+	"net"
+	"net/http"
+	"regexp"
+	"strconv"
+	"strings"
+)
+
+// ErrUnsupportedEncoding Thrown by ScrubRequest for Content-Encodings it cannot decode
+var ErrUnsupportedEncoding = errors.New("sentry:  unsupported content encoding")
+
+// Scrubber sanitizes a payload before it is forwarded. It receives the parsed DSN so implementations can
+// apply per tenant rules, and the endpoint to know what kind of body it is looking at.
+type Scrubber interface {
+	Scrub(d *DSN, endpoint Endpoint, body io.Reader) (io.Reader, error)
+}
+
+// ScrubberFunc adapts a plain function to Scrubber.
+type ScrubberFunc func(d *DSN, endpoint Endpoint, body io.Reader) (io.Reader, error)
+
+func (f ScrubberFunc) Scrub(d *DSN, endpoint Endpoint, body io.Reader) (io.Reader, error) {
+	return f(d, endpoint, body)
+}
+
+// ipCandidate finds things that may be IPv4 or IPv6 addresses, ipBoundary and net.ParseIP decide.
+var ipCandidate = regexp.MustCompile(`(?:\d{1,3}\.){3}\d{1,3}|[0-9a-fA-F]{0,4}(?::[0-9a-fA-F]{0,4}){2,7}(?:(?:\d{1,3}\.){3}\d{1,3})?`)
+
+// IPMask replaces IP addresses masked by BasicScrubber, the same placeholder Sentry's @ip rule uses.
+var IPMask = "[ip]"
+
+// BasicScrubber removes request headers (e.g. Cookie, Authorization) from events and masks IP addresses
+// in every string of the payload. It understands store (JSON) and envelope bodies; anything else, such as
+// unreal crash reports and non-JSON attachments, passes through untouched.
+type BasicScrubber struct {
+	Headers []string //matched case-insensitively against event request headers
+	MaskIPs bool
+}
+
+func (s *BasicScrubber) Scrub(d *DSN, endpoint Endpoint, body io.Reader) (io.Reader, error) {
+	b, err := ioutil.ReadAll(body)
+	if err != nil {
+		return nil, err
+	}
+	switch endpoint {
+	case EndpointStore:
+		b, err = s.scrubJSON(b)
+	case EndpointEnvelope:
+		b, err = s.scrubEnvelope(b)
+	}
+	if err != nil {
+		return nil, err
+	}
+	return bytes.NewReader(b), nil
+}
+
+func (s *BasicScrubber) scrubEnvelope(b []byte) ([]byte, error) {
+	header, items, err := splitEnvelope(b)
+	if err != nil {
+		return nil, err
+	}
+	for i := range items {
+		payload, err := s.scrubJSON(items[i].payload)
+		if err != nil {
+			return nil, err
+		}
+		if err := items[i].setPayload(payload); err != nil {
+			return nil, err
+		}
+	}
+	return joinEnvelope(header, items), nil
+}
+
+func (s *BasicScrubber) scrubJSON(b []byte) ([]byte, error) {
+	/*
+		Returns non-JSON input unchanged.
+	*/
+	if !json.Valid(b) {
+		return b, nil
+	}
+	dec := json.NewDecoder(bytes.NewReader(b))
+	dec.UseNumber()
+	var v interface{}
+	if err := dec.Decode(&v); err != nil {
+		return nil, err
+	}
+	var out bytes.Buffer
+	enc := json.NewEncoder(&out)
+	enc.SetEscapeHTML(false) //keep <, > and & of e.g. C++ frames as sent
+	if err := enc.Encode(s.walk("", v)); err != nil {
+		return nil, err
+	}
+	return bytes.TrimSuffix(out.Bytes(), []byte("\n")), nil
+}
+
+func (s *BasicScrubber) walk(key string, v interface{}) interface{} {
+	switch v := v.(type) {
+	case map[string]interface{}:
+		for k, child := range v {
+			if key == "headers" && s.dropHeader(k) {
+				delete(v, k)
+				continue
+			}
+			v[k] = s.walk(k, child)
+		}
+		return v
+	case []interface{}:
+		kept := v[:0]
+		for _, child := range v {
+			// headers may also be sent as a list of [name, value] pairs
+			if pair, ok := child.([]interface{}); key == "headers" && ok && len(pair) == 2 {
+				if name, _ := pair[0].(string); s.dropHeader(name) {
+					continue
+				}
+			}
+			kept = append(kept, s.walk(key, child))
+		}
+		return kept
+	case string:
+		if s.MaskIPs {
+			return maskIPs(v)
+		}
+		return v
+	default:
+		return v
+	}
+}
+
+func (s *BasicScrubber) dropHeader(name string) bool {
+	for _, h := range s.Headers {
+		if strings.EqualFold(h, name) {
+			return true
+		}
+	}
+	return false
+}
+
+func maskIPs(s string) string {
+	matches := ipCandidate.FindAllStringIndex(s, -1)
+	if matches == nil {
+		return s
+	}
+	var b strings.Builder
+	last := 0
+	for _, m := range matches {
+		if !ipBoundary(s, m[0], m[1]) || net.ParseIP(s[m[0]:m[1]]) == nil {
+			continue
+		}
+		b.WriteString(s[last:m[0]])
+		b.WriteString(IPMask)
+		last = m[1]
+	}
+	b.WriteString(s[last:])
+	return b.String()
+}
+
+func ipBoundary(s string, start, end int) bool {
+	/*
+		Whether s[start:end] stands on its own rather than being part of an identifier such as std::vector
+		or a longer dotted version. Colons only bind IPv6 candidates, so 10.0.0.1:8080 is still an address.
+	*/
+	colon := strings.IndexByte(s[start:end], ':') >= 0
+	joins := func(c byte) bool {
+		return c == '_' || (c >= '0' && c <= '9') || (c >= 'a' && c <= 'z') || (c >= 'A' && c <= 'Z') || (colon && c == ':')
+	}
+	digit := func(i int) bool {
+		return i >= 0 && i < len(s) && s[i] >= '0' && s[i] <= '9'
+	}
+	if start > 0 && (joins(s[start-1]) || (s[start-1] == '.' && digit(start-2))) {
+		return false
+	}
+	if end < len(s) && (joins(s[end]) || (s[end] == '.' && digit(end+1))) {
+		return false
+	}
+	return true
+}
+
+func ScrubRequest(r *http.Request, res *ParseResult, s Scrubber) error {
+	/*
+		Runs s over r.Body and replaces it with the result. gzip and deflate bodies are decoded first and
+		forwarded uncompressed, other encodings fail with ErrUnsupportedEncoding rather than slip through unscrubbed.
+		Bodies larger than Sentry would accept fail with a *SizeError, unless LimitRequestBody already set the cap,
+		and bodies decoding to more than that with a *TruncatedError.
+	*/
+	if r.Body == nil || r.Body == http.NoBody {
+		return nil
+	}
+	raw, err := readBounded(r, res.Endpoint)
+	if err != nil {
+		return err
+	}
+	body, err := decodeBounded(r.Header.Get("Content-Encoding"), raw, res.Endpoint)
+	if err != nil {
+		return err
+	}
+	scrubbed, err := s.Scrub(res.DSN, res.Endpoint, bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	b, err := ioutil.ReadAll(scrubbed)
+	if err != nil {
+		return err
+	}
+	r.Body.Close()
+	r.Header.Del("Content-Encoding")
+	r.Body = ioutil.NopCloser(bytes.NewReader(b))
+	r.ContentLength = int64(len(b))
+	r.Header.Set("Content-Length", strconv.Itoa(len(b)))
+	return nil
+}
+
+func WithScrubber(s Scrubber) Option {
+	/*
+		Has NewMiddleware run s over every accepted request body (see ScrubRequest) before calling the next handler.
+		Parsing itself is unaffected.
+	*/
+	return func(c *config) {
+		c.scrubber = s
+	}
+}
@@ -0,0 +1,89 @@
+package dsn
+
+import (
+	"bytes"
+	"compress/gzip"
+	"errors"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+type testScrub struct {
+	body        string
+	endpoint    Endpoint
+	description string
+	expected    string
+}
+
+var testScrubber = &BasicScrubber{Headers: []string{"cookie", "Authorization"}, MaskIPs: true}
+
+var testTableScrub = []testScrub{
+	{`{"request":{"headers":{"Cookie":"a=b","Accept":"*/*"}},"user":{"ip_address":"10.0.0.1"}}`, EndpointStore,
+		"Testing header map and IPv4", `{"request":{"headers":{"Accept":"*/*"}},"user":{"ip_address":"[ip]"}}`},
+	{`{"request":{"headers":[["authorization","Bearer x"],["Host","a"]]},"message":"from ::1 at 12:30:45"}`, EndpointStore,
+		"Testing header pairs, IPv6 and a clock time", `{"message":"from [ip] at 12:30:45","request":{"headers":[["Host","a"]]}}`},
+	{"{\"event_id\":\"1\"}\n{\"type\":\"event\",\"length\":24}\n{\"msg\":\"from 192.0.2.1\"}\n", EndpointEnvelope,
+		"Testing envelope length update", "{\"event_id\":\"1\"}\n{\"length\":19,\"type\":\"event\"}\n{\"msg\":\"from [ip]\"}\n"},
+	{"{}\n{\"type\":\"attachment\"}\nraw 10.0.0.1 bytes\n", EndpointEnvelope,
+		"Testing non-JSON attachment untouched", "{}\n{\"type\":\"attachment\"}\nraw 10.0.0.1 bytes\n"},
+	{`{"frames":[{"function":"std::vector<int>::at"},{"function":"core::ptr::drop_in_place"}],"message":"v1.2.3.4.5 via [::1]:80 and 10.0.0.1:8080"}`, EndpointStore,
+		"Testing identifiers, versions and ports", `{"frames":[{"function":"std::vector<int>::at"},{"function":"core::ptr::drop_in_place"}],"message":"v1.2.3.4.5 via [[ip]]:80 and [ip]:8080"}`},
+	{"MDMP 10.0.0.1", EndpointUnreal,
+		"Testing unreal passthrough", "MDMP 10.0.0.1"},
+}
+
+func TestBasicScrubber(t *testing.T) {
+	for _, test := range testTableScrub {
+		out, err := testScrubber.Scrub(nil, test.endpoint, strings.NewReader(test.body))
+		if err != nil {
+			t.Errorf("%s: Expected -- no error -- Got %v", test.description, err)
+			continue
+		}
+		if got, _ := ioutil.ReadAll(out); string(got) != test.expected {
+			t.Errorf("%s: Expected -- %s -- Got %s", test.description, test.expected, got)
+		}
+	}
+}
+
+func TestScrubMiddleware(t *testing.T) {
+	var got []byte
+	h := NewMiddleware(WithScrubber(testScrubber))(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		got, _ = ioutil.ReadAll(r.Body)
+	}))
+	var buf bytes.Buffer
+	zw := gzip.NewWriter(&buf)
+	zw.Write([]byte(`{"user":{"ip_address":"10.0.0.1"}}`))
+	zw.Close()
+	r := httptest.NewRequest("POST", "https://sentry.io/api/1/store/?sentry_key="+testKeyA, &buf)
+	r.Header.Set("Content-Encoding", "gzip")
+	w := httptest.NewRecorder()
+	h.ServeHTTP(w, r)
+	if expected := `{"user":{"ip_address":"[ip]"}}`; w.Code != http.StatusOK || string(got) != expected {
+		t.Errorf("Expected -- %s -- Got %d %s", expected, w.Code, got)
+	}
+
+	r = httptest.NewRequest("POST", "https://sentry.io/api/1/store/?sentry_key="+testKeyA, strings.NewReader("x"))
+	r.Header.Set("Content-Encoding", "br")
+	w = httptest.NewRecorder()
+	h.ServeHTTP(w, r)
+	if w.Code != http.StatusUnsupportedMediaType {
+		t.Errorf("Expected -- %d -- Got %d", http.StatusUnsupportedMediaType, w.Code)
+	}
+}
+
+func TestScrubRequestBomb(t *testing.T) {
+	r := httptest.NewRequest("POST", "https://sentry.io/api/1/store/", bytes.NewReader(testBomb(2<<20)))
+	r.Header.Set("Content-Encoding", "gzip")
+	res := &ParseResult{DSN: &DSN{PublicKey: testKeyA, ProjectID: "1"}, Endpoint: EndpointStore}
+	if err := ScrubRequest(r, res, testScrubber); !errors.Is(err, ErrBodyTruncated) {
+		t.Errorf("Expected -- %v -- Got %v", ErrBodyTruncated, err)
+	}
+	// no WithSizeLimits, the raw body is still capped before it is read
+	r = httptest.NewRequest("POST", "https://sentry.io/api/1/store/", strings.NewReader(strings.Repeat("a", 1<<20+1)))
+	if err := ScrubRequest(r, res, testScrubber); !errors.Is(err, ErrPayloadTooLarge) {
+		t.Errorf("Testing raw body: Expected -- %v -- Got %v", ErrPayloadTooLarge, err)
+	}
+}
@@ -0,0 +1,15 @@
+module github.com/dgbailey/dsn/sentrygo
+
+go 1.25.0
+
+replace github.com/dgbailey/dsn => ../
+
+require (
+	github.com/dgbailey/dsn v0.0.0
+	github.com/getsentry/sentry-go v0.49.0
+)
+
+require (
+	golang.org/x/sys v0.46.0 // indirect
+	golang.org/x/text v0.39.0 // indirect
+)
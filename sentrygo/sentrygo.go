@@ -0,0 +1,47 @@
+// Package sentrygo turns DSNs reconstructed by the dsn package into sentry-go client options,
+// so a service can re-emit the events it receives with the official SDK.
+package sentrygo
+
+import (
+	"errors"
+
+	"github.com/dgbailey/dsn"
+	"github.com/getsentry/sentry-go"
+)
+
+// ErrIncompleteDSN Thrown for DSNs without a project, e.g. from the legacy /api/store/ endpoint
+var ErrIncompleteDSN = errors.New("sentry:  DSN has no project ID and cannot initialize an SDK client")
+
+func Validate(d *dsn.DSN) error {
+	/*
+		Checks d against sentry-go's own DSN parser so problems surface before sentry.Init.
+	*/
+	s := d.String()
+	if len(s) == 0 || len(d.ProjectID) == 0 {
+		return ErrIncompleteDSN
+	}
+	_, err := sentry.NewDsn(s)
+	return err
+}
+
+func ClientOptions(d *dsn.DSN) (sentry.ClientOptions, error) {
+	/*
+		Returns sentry.ClientOptions with Dsn filled in, ready for sentry.Init or sentry.NewClient.
+		Any other field can be set on the result before use.
+	*/
+	if err := Validate(d); err != nil {
+		return sentry.ClientOptions{}, err
+	}
+	return sentry.ClientOptions{Dsn: d.String()}, nil
+}
+
+func NewClient(d *dsn.DSN) (*sentry.Client, error) {
+	/*
+		Shortcut for sentry.NewClient with ClientOptions(d).
+	*/
+	opts, err := ClientOptions(d)
+	if err != nil {
+		return nil, err
+	}
+	return sentry.NewClient(opts)
+}
@@ -0,0 +1,39 @@
+package sentrygo
+
+import (
+	"net/http/httptest"
+	"testing"
+
+	"github.com/dgbailey/dsn"
+)
+
+func TestClientOptions(t *testing.T) {
+	r := httptest.NewRequest("POST", "https://o87286.ingest.sentry.io/api/1234/store/?sentry_key=4784fbc50de2473f9977cfce8a9adce5", nil)
+	d, err := dsn.FromRequest(r)
+	if err != nil {
+		t.Fatal(err)
+	}
+	opts, err := ClientOptions(d)
+	if err != nil || opts.Dsn != d.URL {
+		t.Errorf("Expected -- %s -- Got %s %v", d.URL, opts.Dsn, err)
+	}
+	client, err := NewClient(d)
+	if err != nil || client.Options().Dsn != d.URL {
+		t.Errorf("Expected -- client for %s -- Got %v", d.URL, err)
+	}
+}
+
+func TestValidateLegacy(t *testing.T) {
+	r := httptest.NewRequest("POST", "https://sentry.io/api/store/?sentry_key=4784fbc50de2473f9977cfce8a9adce5", nil)
+	d, _ := dsn.FromRequest(r)
+	if err := Validate(d); err != ErrIncompleteDSN {
+		t.Errorf("Expected -- %s -- Got %v", ErrIncompleteDSN, err)
+	}
+}
+
+func TestValidateScheme(t *testing.T) {
+	d := &dsn.DSN{Scheme: "ftp", Host: "sentry.io", ProjectID: "1", PublicKey: "4784fbc50de2473f9977cfce8a9adce5"}
+	if err := Validate(d); err == nil {
+		t.Errorf("Expected -- sentry-go to reject %s -- Got nil", d)
+	}
+}
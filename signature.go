@@ -0,0 +1,82 @@
+package dsn
+
+import (
+	"crypto/hmac"
+	"crypto/sha1"
+	"encoding/hex"
+	"errors"
+	"net/http"
+)
+
+var (
+	// ErrMissingSignature Thrown when signatures are required but the request has no sentry_signature
+	ErrMissingSignature = errors.New("sentry:  missing sentry_signature")
+	// ErrInvalidSignature Thrown when sentry_signature does not match the body
+	ErrInvalidSignature = errors.New("sentry:  invalid sentry_signature")
+)
+
+// DefaultSignatureBodyLimit bounds how much body is read to verify a signature.
+var DefaultSignatureBodyLimit int64 = 1 << 20
+
+type signatureConfig struct {
+	required bool
+	peeker   *LimitedPeeker
+}
+
+func WithSignatureVerification(required bool) Option {
+	/*
+		Very old SDKs (protocol <= 4) sign each request with HMAC-SHA1(secret, "<sentry_timestamp> <body>")
+		and send it as sentry_signature in X-SENTRY-AUTH. With this option such signatures are verified against the
+		KeyInfo.SecretKey stored for the public key, so it needs a KeyResolver. The sentry_secret of the request is
+		never used, a client could sign with any secret it likes. Signed requests for keys without a stored secret
+		fail with ErrInvalidSignature. With required set, requests without a signature are rejected with
+		ErrMissingSignature.
+
+		The body is read up to DefaultSignatureBodyLimit (see LimitedPeeker) and restored for downstream handlers.
+	*/
+	return func(c *config) {
+		c.signatures = &signatureConfig{required: required, peeker: NewLimitedPeeker(DefaultSignatureBodyLimit)}
+	}
+}
+
+func Signature(secret string, timestamp string, body []byte) string {
+	/*
+		Hex HMAC-SHA1 the way raven clients computed sentry_signature.
+	*/
+	mac := hmac.New(sha1.New, []byte(secret))
+	mac.Write([]byte(timestamp))
+	mac.Write([]byte(" "))
+	mac.Write(body)
+	return hex.EncodeToString(mac.Sum(nil))
+}
+
+func VerifySignature(secret string, auth *Auth, body []byte) error {
+	/*
+		Checks auth.Signature against body in constant time.
+	*/
+	if len(auth.Signature) == 0 {
+		return ErrMissingSignature
+	}
+	expected := Signature(secret, auth.Timestamp, body)
+	if len(secret) == 0 || !hmac.Equal([]byte(expected), []byte(auth.Signature)) {
+		return ErrInvalidSignature
+	}
+	return nil
+}
+
+func (sc *signatureConfig) verify(r *http.Request, auth *Auth, info *KeyInfo) error {
+	if len(auth.Signature) == 0 {
+		if sc.required {
+			return ErrMissingSignature
+		}
+		return nil
+	}
+	if info == nil || len(info.SecretKey) == 0 {
+		return ErrInvalidSignature
+	}
+	body, err := sc.peeker.PeekRequest(r)
+	if err != nil {
+		return err
+	}
+	return VerifySignature(info.SecretKey, auth, body)
+}
@@ -0,0 +1,83 @@
+package dsn
+
+import (
+	"context"
+	"errors"
+	"io"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+const testSecret = "4784fbc50de2473f9977cfce8a9adce5"
+
+type testSignature struct {
+	header      string
+	body        string
+	required    bool
+	description string
+	err         error
+}
+
+var testTableSignature = []testSignature{
+	{"Sentry sentry_version=4, sentry_timestamp=1614144877, sentry_key=4784fbc50de2473f9977cfce8a9adce5, sentry_secret=" + testSecret +
+		", sentry_signature=" + Signature(testSecret, "1614144877", []byte("eJzLSM3JyQcABiwCFQ==")), "eJzLSM3JyQcABiwCFQ==", false,
+		"Testing valid signature", nil},
+	{"Sentry sentry_version=4, sentry_timestamp=1614144877, sentry_key=4784fbc50de2473f9977cfce8a9adce5, sentry_secret=" + testSecret +
+		", sentry_signature=" + Signature(testSecret, "1614144877", []byte("eJzLSM3JyQcABiwCFQ==")), "tampered", false,
+		"Testing tampered body", ErrInvalidSignature},
+	{"Sentry sentry_version=4, sentry_timestamp=1614144877, sentry_key=4784fbc50de2473f9977cfce8a9adce5, sentry_signature=" +
+		Signature(testSecret, "1614144877", []byte("body")), "body", false,
+		"Testing signature with secret from resolver", nil},
+	{"Sentry sentry_version=4, sentry_timestamp=1614144877, sentry_key=4784fbc50de2473f9977cfce8a9adce5, sentry_secret=forged" +
+		", sentry_signature=" + Signature("forged", "1614144877", []byte("body")), "body", false,
+		"Testing signature with a secret picked by the client", ErrInvalidSignature},
+	{"Sentry sentry_version=4, sentry_timestamp=1614144877, sentry_key=aaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaa, sentry_secret=" + testSecret +
+		", sentry_signature=" + Signature(testSecret, "1614144877", []byte("body")), "body", false,
+		"Testing key without stored secret", ErrInvalidSignature},
+	{"Sentry sentry_version=7, sentry_key=4784fbc50de2473f9977cfce8a9adce5", "body", false,
+		"Testing unsigned request allowed", nil},
+	{"Sentry sentry_version=7, sentry_key=4784fbc50de2473f9977cfce8a9adce5", "body", true,
+		"Testing unsigned request required", ErrMissingSignature},
+}
+
+func TestSignatureVerification(t *testing.T) {
+	keys := KeyResolverFunc(func(ctx context.Context, publicKey string) (*KeyInfo, error) {
+		if publicKey == testKeyB {
+			return &KeyInfo{PublicKey: publicKey, ProjectID: "1234"}, nil
+		}
+		return &KeyInfo{PublicKey: publicKey, SecretKey: testSecret, ProjectID: "1234"}, nil
+	})
+	for _, test := range testTableSignature {
+		r := httptest.NewRequest("POST", "https://sentry.io/api/1234/store/", strings.NewReader(test.body))
+		r.Header.Set("X-SENTRY-AUTH", test.header)
+		_, err := FromRequest(r, WithKeyResolver(keys), WithSignatureVerification(test.required))
+		if !errors.Is(err, test.err) || (err == nil) != (test.err == nil) {
+			t.Errorf("%s: Expected -- %v -- Got %v", test.description, test.err, err)
+		}
+	}
+}
+
+func TestSignatureWithoutResolver(t *testing.T) {
+	test := testTableSignature[0]
+	r := httptest.NewRequest("POST", "https://sentry.io/api/1234/store/", strings.NewReader(test.body))
+	r.Header.Set("X-SENTRY-AUTH", test.header)
+	if _, err := FromRequest(r, WithSignatureVerification(true)); err != ErrInvalidSignature {
+		t.Errorf("Expected -- %v -- Got %v", ErrInvalidSignature, err)
+	}
+}
+
+func TestSignatureBodyRestored(t *testing.T) {
+	test := testTableSignature[0]
+	keys := KeyResolverFunc(func(ctx context.Context, publicKey string) (*KeyInfo, error) {
+		return &KeyInfo{PublicKey: publicKey, SecretKey: testSecret, ProjectID: "1234"}, nil
+	})
+	r := httptest.NewRequest("POST", "https://sentry.io/api/1234/store/", strings.NewReader(test.body))
+	r.Header.Set("X-SENTRY-AUTH", test.header)
+	if _, err := FromRequest(r, WithKeyResolver(keys), WithSignatureVerification(true)); err != nil {
+		t.Fatal(err)
+	}
+	if rest, err := io.ReadAll(r.Body); err != nil || string(rest) != test.body {
+		t.Errorf("Expected -- %s -- Got %s", test.body, rest)
+	}
+}
@@ -0,0 +1,91 @@
+package dsn
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+)
+
+// ErrPayloadTooLarge Thrown when a body exceeds the size limit of its endpoint
+var ErrPayloadTooLarge = errors.New("sentry:  payload too large")
+
+// SizeError carries the limit that was exceeded. errors.Is(err, ErrPayloadTooLarge) matches it.
+type SizeError struct {
+	Endpoint Endpoint
+	Limit    int64
+}
+
+func (e *SizeError) Error() string {
+	return fmt.Sprintf("%s: %s accepts at most %d bytes", ErrPayloadTooLarge, e.Endpoint, e.Limit)
+}
+
+func (e *SizeError) Is(target error) bool {
+	return target == ErrPayloadTooLarge
+}
+
+// SizeLimits maps endpoints onto their maximum body size in bytes. Endpoints without an entry are unlimited.
+type SizeLimits map[Endpoint]int64
+
+// DefaultSizeLimits mirror the limits Sentry applies.
+var DefaultSizeLimits = SizeLimits{
+	EndpointStore:       1 << 20,
+	EndpointSecurity:    1 << 20,
+	EndpointCron:        1 << 20,
+	EndpointEnvelope:    20 << 20,
+	EndpointMinidump:    100 << 20,
+	EndpointUnreal:      100 << 20,
+	EndpointAttachments: 100 << 20,
+}
+
+func WithSizeLimits(limits SizeLimits) Option {
+	/*
+		Rejects requests whose Content-Length exceeds the limit of their endpoint with a *SizeError (413 behind the middleware).
+		NewMiddleware also caps the body handed to the next handler, so chunked uploads that grow past the limit
+		fail with a *SizeError on read.
+	*/
+	return func(c *config) {
+		c.sizeLimits = limits
+	}
+}
+
+func (c *config) checkSize(r *http.Request, endpoint Endpoint) error {
+	limit, ok := c.sizeLimits[endpoint]
+	if !ok || r.ContentLength <= limit {
+		return nil
+	}
+	return &SizeError{Endpoint: endpoint, Limit: limit}
+}
+
+func LimitRequestBody(r *http.Request, endpoint Endpoint, limits SizeLimits) {
+	/*
+		Caps r.Body at the limit of endpoint. Reads past it fail with a *SizeError.
+	*/
+	limit, ok := limits[endpoint]
+	if !ok || r.Body == nil || r.Body == http.NoBody {
+		return
+	}
+	r.Body = &limitedBody{ReadCloser: r.Body, remaining: limit, err: &SizeError{Endpoint: endpoint, Limit: limit}}
+}
+
+type limitedBody struct {
+	io.ReadCloser
+	remaining int64
+	err       error
+}
+
+func (b *limitedBody) Read(p []byte) (int, error) {
+	if b.remaining < 0 {
+		return 0, b.err
+	}
+	// read one byte past the limit to tell "exactly at the limit" from "over it"
+	if int64(len(p)) > b.remaining+1 {
+		p = p[:b.remaining+1]
+	}
+	n, err := b.ReadCloser.Read(p)
+	b.remaining -= int64(n)
+	if b.remaining < 0 {
+		return n + int(b.remaining), b.err
+	}
+	return n, err
+}
@@ -0,0 +1,75 @@
+package dsn
+
+import (
+	"errors"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+var testSizeLimits = SizeLimits{EndpointStore: 8, EndpointEnvelope: 16}
+
+type testSize struct {
+	path          string
+	body          string
+	contentLength int64
+	description   string
+	status        int
+	readErr       error
+}
+
+var testTableSize = []testSize{
+	{"/api/1/store/", "12345678", 8, "Testing store at the limit", http.StatusOK, nil},
+	{"/api/1/store/", "123456789", 9, "Testing store over the limit", http.StatusRequestEntityTooLarge, nil},
+	{"/api/1/envelope/", "123456789", 9, "Testing envelope under its own limit", http.StatusOK, nil},
+	{"/api/1/store/", "123456789", -1, "Testing chunked store over the limit", http.StatusOK, ErrPayloadTooLarge},
+	{"/api/1/minidump/", strings.Repeat("x", 64), 64, "Testing endpoint without a limit", http.StatusOK, nil},
+}
+
+func TestSizeLimits(t *testing.T) {
+	for _, test := range testTableSize {
+		var readErr error
+		h := NewMiddleware(WithSizeLimits(testSizeLimits))(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			_, readErr = ioutil.ReadAll(r.Body)
+		}))
+		r := httptest.NewRequest("POST", "https://sentry.io"+test.path+"?sentry_key="+testKeyA, strings.NewReader(test.body))
+		r.ContentLength = test.contentLength
+		w := httptest.NewRecorder()
+		readErr = nil
+		h.ServeHTTP(w, r)
+		if w.Code != test.status {
+			t.Errorf("%s: Expected -- %d -- Got %d", test.description, test.status, w.Code)
+			continue
+		}
+		if !errors.Is(readErr, test.readErr) {
+			t.Errorf("%s: Expected -- %v -- Got %v", test.description, test.readErr, readErr)
+		}
+		if test.status == http.StatusRequestEntityTooLarge && w.Header().Get("X-Sentry-Error") == "" {
+			t.Errorf("%s: Expected -- X-Sentry-Error -- Got none", test.description)
+		}
+	}
+}
+
+func TestSizeError(t *testing.T) {
+	r := httptest.NewRequest("POST", "https://sentry.io/api/1/store/?sentry_key="+testKeyA, strings.NewReader("123456789"))
+	_, err := ParseRequest(r.Context(), r, WithSizeLimits(testSizeLimits))
+	var se *SizeError
+	if !errors.As(err, &se) || se.Endpoint != EndpointStore || se.Limit != 8 {
+		t.Errorf("Expected -- *SizeError for store -- Got %v", err)
+	}
+	if ErrorStatus(err) != http.StatusRequestEntityTooLarge {
+		t.Errorf("Expected -- %d -- Got %d", http.StatusRequestEntityTooLarge, ErrorStatus(err))
+	}
+}
+
+func TestLimitRequestBodyExact(t *testing.T) {
+	r := httptest.NewRequest("POST", "/", strings.NewReader("12345678"))
+	LimitRequestBody(r, EndpointStore, testSizeLimits)
+	b, err := io.ReadAll(r.Body)
+	if err != nil || string(b) != "12345678" {
+		t.Errorf("Expected -- full body -- Got %q %v", b, err)
+	}
+}
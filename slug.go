@@ -0,0 +1,80 @@
+package dsn
+
+import (
+	"context"
+	"net/url"
+	"regexp"
+)
+
+// only consulted after CheckPath failed to find a project ID; all digit slugs are rejected in checkPath
+var projectSlugPattern = regexp.MustCompile(`^/api/([a-z0-9][a-z0-9_-]*)/(store|envelope)/`)
+
+// SlugResolver maps project slugs onto numeric project IDs. Implementations must honour ctx cancellation.
+type SlugResolver interface {
+	ResolveSlug(ctx context.Context, slug string) (projectID string, err error)
+}
+
+// SlugResolverFunc adapts a plain function to SlugResolver.
+type SlugResolverFunc func(ctx context.Context, slug string) (string, error)
+
+func (f SlugResolverFunc) ResolveSlug(ctx context.Context, slug string) (string, error) {
+	return f(ctx, slug)
+}
+
+func WithProjectSlugs() Option {
+	/*
+		Accepts /api/<project-slug>/store/ in addition to numeric IDs. The slug is carried in DSN.ProjectSlug
+		and DSN.ProjectID stays empty unless a SlugResolver is configured as well.
+	*/
+	return func(c *config) {
+		c.slugs = true
+	}
+}
+
+func WithSlugResolver(sr SlugResolver) Option {
+	/*
+		Implies WithProjectSlugs. Slugs are mapped to numeric IDs with sr, for upstreams that require them.
+		Lookups share the resolver timeout with WithKeyResolver.
+	*/
+	return func(c *config) {
+		c.slugs = true
+		c.slugResolver = sr
+	}
+}
+
+func (c *config) checkPath(ctx context.Context, u *url.URL) (projectID string, slug string, endpoint Endpoint, err error) {
+	/*
+		CheckPath plus slug handling when enabled.
+	*/
+	projectID, endpoint, err = parseIngestPath(u.Path)
+	if err != ErrMissingProjectID || !c.slugs {
+		return projectID, "", endpoint, err
+	}
+	m := projectSlugPattern.FindStringSubmatch(u.Path)
+	if m == nil || allDigits(m[1]) {
+		return "", "", "", err
+	}
+	slug, endpoint = m[1], Endpoint(m[2])
+	if c.slugResolver == nil {
+		return "", slug, endpoint, nil
+	}
+	if c.resolverTimeout > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, c.resolverTimeout)
+		defer cancel()
+	}
+	projectID, err = c.slugResolver.ResolveSlug(ctx, slug)
+	if err != nil {
+		return "", "", "", err
+	}
+	return projectID, slug, endpoint, nil
+}
+
+func allDigits(s string) bool {
+	for i := 0; i < len(s); i++ {
+		if s[i] < '0' || s[i] > '9' {
+			return false
+		}
+	}
+	return true
+}
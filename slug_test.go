@@ -0,0 +1,75 @@
+package dsn
+
+import (
+	"context"
+	"net/http/httptest"
+	"testing"
+)
+
+type testSlug struct {
+	url         string
+	opts        []Option
+	description string
+	expectedID  string
+	slug        string
+	err         error
+}
+
+var testSlugs = SlugResolverFunc(func(ctx context.Context, slug string) (string, error) {
+	if slug == "my-project" {
+		return "1234", nil
+	}
+	return "", ErrMissingProjectID
+})
+
+var testTableSlug = []testSlug{
+	{"https://sentry.io/api/my-project/store/?sentry_key=4784fbc50de2473f9977cfce8a9adce5", nil,
+		"Testing slug without option", "", "", ErrMissingProjectID},
+	{"https://sentry.io/api/my-project/store/?sentry_key=4784fbc50de2473f9977cfce8a9adce5", []Option{WithProjectSlugs()},
+		"Testing slug kept unresolved", "", "my-project", nil},
+	{"https://sentry.io/api/my-project/store/?sentry_key=4784fbc50de2473f9977cfce8a9adce5", []Option{WithSlugResolver(testSlugs)},
+		"Testing slug resolved", "1234", "my-project", nil},
+	{"https://sentry.io/api/other/store/?sentry_key=4784fbc50de2473f9977cfce8a9adce5", []Option{WithSlugResolver(testSlugs)},
+		"Testing unknown slug", "", "", ErrMissingProjectID},
+	{"https://sentry.io/api/1234/store/?sentry_key=4784fbc50de2473f9977cfce8a9adce5", []Option{WithProjectSlugs()},
+		"Testing numeric ID with slugs enabled", "1234", "", nil},
+	{"https://sentry.io/api/0/store/?sentry_key=4784fbc50de2473f9977cfce8a9adce5", []Option{WithProjectSlugs()},
+		"Testing Web API path with slugs enabled", "", "", ErrNotIngestEndpoint},
+}
+
+func TestProjectSlugs(t *testing.T) {
+	for _, test := range testTableSlug {
+		r := httptest.NewRequest("POST", test.url, nil)
+		got, err := FromRequestContext(context.Background(), r, test.opts...)
+		if err != test.err {
+			t.Errorf("%s: Expected -- %v -- Got %v", test.description, test.err, err)
+		} else if got != nil && (got.ProjectID != test.expectedID || got.ProjectSlug != test.slug) {
+			t.Errorf("%s: Expected -- %s %s -- Got %s %s", test.description, test.expectedID, test.slug, got.ProjectID, got.ProjectSlug)
+		}
+	}
+}
+
+func TestProjectSlugResolvesKey(t *testing.T) {
+	deny := KeyResolverFunc(func(ctx context.Context, publicKey string) (*KeyInfo, error) {
+		return nil, ErrUnknownKey
+	})
+	allow := KeyResolverFunc(func(ctx context.Context, publicKey string) (*KeyInfo, error) {
+		return &KeyInfo{PublicKey: publicKey, ProjectID: "42"}, nil
+	})
+	r := httptest.NewRequest("POST", testTableSlug[1].url, nil)
+	if _, err := FromRequest(r, WithProjectSlugs(), WithKeyResolver(deny)); err != ErrUnknownKey {
+		t.Errorf("Testing unknown key with a slug: Expected -- %v -- Got %v", ErrUnknownKey, err)
+	}
+	got, err := FromRequest(r, WithProjectSlugs(), WithKeyResolver(allow))
+	if err != nil || got.ProjectID != "" || got.ProjectSlug != "my-project" {
+		t.Errorf("Testing known key with a slug: Expected -- slug my-project without project ID -- Got %+v %v", got, err)
+	}
+}
+
+func TestProjectSlugURL(t *testing.T) {
+	r := httptest.NewRequest("POST", testTableSlug[1].url, nil)
+	got, _ := FromRequest(r, WithProjectSlugs())
+	if expected := "https://4784fbc50de2473f9977cfce8a9adce5@sentry.io/my-project"; got.URL != expected {
+		t.Errorf("Expected -- %s -- Got %s", expected, got.URL)
+	}
+}
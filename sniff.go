@@ -0,0 +1,22 @@
+package dsn
+
+import (
+	"net/http"
+	"strings"
+)
+
+func LooksLikeIngest(r *http.Request) bool {
+	/*
+		Cheap check for multiplexed servers deciding whether a request belongs in the DSN parsing pipeline.
+		True for ingest-shaped paths (see ParsePath), for requests
+		carrying an X-SENTRY-AUTH header and for envelope bodies. It does not validate anything and does not allocate,
+		so a true result can still fail FromRequest.
+	*/
+	if _, _, err := parseIngestPath(r.URL.Path); err == nil {
+		return true
+	}
+	if len(authHeaderValues(r.Header)) > 0 {
+		return true
+	}
+	return strings.HasPrefix(r.Header.Get("Content-Type"), "application/x-sentry-envelope")
+}
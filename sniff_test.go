@@ -0,0 +1,44 @@
+package dsn
+
+import (
+	"net/http/httptest"
+	"testing"
+)
+
+type testSniff struct {
+	url         string
+	header      string
+	value       string
+	description string
+	expected    bool
+}
+
+var testTableSniff = []testSniff{
+	{"https://sentry.io/api/1234/store/", "", "", "Testing store path", true},
+	{"https://sentry.io/api/1234/envelope/", "", "", "Testing envelope path", true},
+	{"https://sentry.io/api/store/", "", "", "Testing legacy path", true},
+	{"https://sentry.io/tunnel", "X-Sentry-Auth", "Sentry sentry_key=4784fbc50de2473f9977cfce8a9adce5", "Testing auth header", true},
+	{"https://sentry.io/tunnel", "Content-Type", "application/x-sentry-envelope", "Testing envelope content type", true},
+	{"https://sentry.io/api/0/projects/", "", "", "Testing Web API", false},
+	{"https://sentry.io/healthz", "Content-Type", "application/json", "Testing unrelated request", false},
+}
+
+func TestLooksLikeIngest(t *testing.T) {
+	for _, test := range testTableSniff {
+		r := httptest.NewRequest("POST", test.url, nil)
+		if len(test.header) > 0 {
+			r.Header.Set(test.header, test.value)
+		}
+		if got := LooksLikeIngest(r); got != test.expected {
+			t.Errorf("%s: Expected -- %t -- Got %t", test.description, test.expected, got)
+		}
+	}
+}
+
+func BenchmarkLooksLikeIngest(b *testing.B) {
+	r := httptest.NewRequest("POST", "https://sentry.io/healthz", nil)
+	b.ReportAllocs()
+	for i := 0; i < b.N; i++ {
+		LooksLikeIngest(r)
+	}
+}
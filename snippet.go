@@ -0,0 +1,138 @@
+package dsn
+
+import (
+	"errors"
+	"fmt"
+	"sort"
+	"strings"
+	"sync"
+	"text/template"
+)
+
+// ErrUnknownSDK Thrown when no snippet template is registered for an SDK
+var ErrUnknownSDK = errors.New("sentry:  no snippet for SDK")
+
+// snippetTemplates are text/template sources keyed by SDK name, rendered with snippetData.
+// Adding an SDK is one entry here or a RegisterSnippet call.
+var snippetTemplates = map[string]string{
+	"javascript": `import * as Sentry from "@sentry/browser";
+
+Sentry.init({
+  dsn: "{{.DSN}}",
+});
+`,
+	"node": `const Sentry = require("@sentry/node");
+
+Sentry.init({
+  dsn: "{{.DSN}}",
+});
+`,
+	"python": `import sentry_sdk
+
+sentry_sdk.init(
+    dsn="{{.DSN}}",
+)
+`,
+	"go": `err := sentry.Init(sentry.ClientOptions{
+	Dsn: "{{.DSN}}",
+})
+`,
+	"ruby": `Sentry.init do |config|
+  config.dsn = "{{.DSN}}"
+end
+`,
+	"php": `\Sentry\init(['dsn' => '{{.DSN}}']);
+`,
+	"java": `Sentry.init(options -> {
+  options.setDsn("{{.DSN}}");
+});
+`,
+	"dotnet": `SentrySdk.Init(options =>
+{
+    options.Dsn = "{{.DSN}}";
+});
+`,
+}
+
+// snippetData is what snippet templates see.
+type snippetData struct {
+	DSN     string //public form: no secret key, no options
+	Host    string
+	Project string
+}
+
+var snippets = struct {
+	sync.Mutex
+	parsed map[string]*template.Template
+}{parsed: map[string]*template.Template{}}
+
+func RegisterSnippet(sdk string, tmpl string) error {
+	/*
+		Adds or replaces the snippet for sdk. tmpl is a text/template seeing .DSN (without secret key and
+		options), .Host and .Project.
+	*/
+	t, err := template.New(sdk).Parse(tmpl)
+	if err != nil {
+		return err
+	}
+	snippets.Lock()
+	defer snippets.Unlock()
+	snippetTemplates[sdk] = tmpl
+	snippets.parsed[sdk] = t
+	return nil
+}
+
+func SnippetSDKs() []string {
+	/*
+		Names accepted by DSN.Snippet, sorted.
+	*/
+	snippets.Lock()
+	defer snippets.Unlock()
+	names := make([]string, 0, len(snippetTemplates))
+	for sdk := range snippetTemplates {
+		names = append(names, sdk)
+	}
+	sort.Strings(names)
+	return names
+}
+
+func (d *DSN) Snippet(sdk string) (string, error) {
+	/*
+		Ready to paste SDK init code for d, e.g. d.Snippet("python"). The secret key is left out: no current
+		SDK needs it and snippets end up in browsers and repositories. Throws ErrUnknownSDK for names
+		missing from SnippetSDKs.
+	*/
+	t, err := snippetTemplate(sdk)
+	if err != nil {
+		return "", err
+	}
+	public := d.Clone()
+	public.SecretKey, public.Options = "", nil
+	project := d.ProjectID
+	if len(project) == 0 {
+		project = d.ProjectSlug
+	}
+	var b strings.Builder
+	if err := t.Execute(&b, snippetData{DSN: public.String(), Host: d.Host, Project: project}); err != nil {
+		return "", err
+	}
+	return b.String(), nil
+}
+
+func snippetTemplate(sdk string) (*template.Template, error) {
+	snippets.Lock()
+	defer snippets.Unlock()
+	if t, ok := snippets.parsed[sdk]; ok {
+		return t, nil
+	}
+	src, ok := snippetTemplates[sdk]
+	if !ok {
+		return nil, fmt.Errorf("%w %q", ErrUnknownSDK, sdk)
+	}
+	t, err := template.New(sdk).Parse(src)
+	if err != nil {
+		return nil, err
+	}
+	snippets.parsed[sdk] = t
+	return t, nil
+}
@@ -0,0 +1,57 @@
+package dsn
+
+import (
+	"errors"
+	"strings"
+	"testing"
+)
+
+type testSnippet struct {
+	sdk         string
+	description string
+	expected    string
+}
+
+var testTableSnippet = []testSnippet{
+	{"javascript", "Testing javascript", `dsn: "https://4784fbc50de2473f9977cfce8a9adce5@o1.ingest.sentry.io/1234",`},
+	{"python", "Testing python", `dsn="https://4784fbc50de2473f9977cfce8a9adce5@o1.ingest.sentry.io/1234",`},
+	{"go", "Testing go", `Dsn: "https://4784fbc50de2473f9977cfce8a9adce5@o1.ingest.sentry.io/1234",`},
+	{"php", "Testing php", `'dsn' => 'https://4784fbc50de2473f9977cfce8a9adce5@o1.ingest.sentry.io/1234'`},
+}
+
+func TestSnippet(t *testing.T) {
+	d, _ := Parse("https://" + testKeyA + ":" + testKeyB + "@o1.ingest.sentry.io/1234?timeout=5s")
+	for _, test := range testTableSnippet {
+		got, err := d.Snippet(test.sdk)
+		if err != nil || !strings.Contains(got, test.expected) {
+			t.Errorf("%s: Expected -- %s -- Got %s %v", test.description, test.expected, got, err)
+		}
+		if strings.Contains(got, testKeyB) || strings.Contains(got, "timeout") {
+			t.Errorf("%s: Expected -- no secret or options -- Got %s", test.description, got)
+		}
+	}
+	if d.SecretKey != testKeyB {
+		t.Errorf("Expected -- DSN untouched -- Got %s", d)
+	}
+	for _, sdk := range SnippetSDKs() {
+		if _, err := d.Snippet(sdk); err != nil {
+			t.Errorf("Testing %s: Expected -- nil -- Got %v", sdk, err)
+		}
+	}
+	if _, err := d.Snippet("cobol"); !errors.Is(err, ErrUnknownSDK) {
+		t.Errorf("Testing unknown SDK: Expected -- %v -- Got %v", ErrUnknownSDK, err)
+	}
+}
+
+func TestRegisterSnippet(t *testing.T) {
+	if err := RegisterSnippet("test-elixir", `config :sentry, dsn: "{{.DSN}}" # project {{.Project}}`); err != nil {
+		t.Fatal(err)
+	}
+	d, _ := Parse("https://" + testKeyA + "@sentry.io/7")
+	if got, err := d.Snippet("test-elixir"); err != nil || got != `config :sentry, dsn: "https://`+testKeyA+`@sentry.io/7" # project 7` {
+		t.Errorf("Expected -- registered snippet -- Got %s %v", got, err)
+	}
+	if err := RegisterSnippet("test-broken", "{{.DSN"); err == nil {
+		t.Errorf("Expected -- template error -- Got nil")
+	}
+}
@@ -0,0 +1,258 @@
+package dsn
+
+import (
+	"errors"
+	"fmt"
+	"math"
+	"sort"
+	"sync"
+	"time"
+)
+
+// ErrSpikeLimited Thrown while a project is over its spike protection limit
+var ErrSpikeLimited = errors.New("sentry:  spike protection limit exceeded")
+
+// SpikeLimitError says until when the limit holds. errors.Is(err, ErrSpikeLimited) matches it, and WriteError
+// sends Retry-After and X-Sentry-Rate-Limits.
+type SpikeLimitError struct {
+	ProjectID string
+	Until     time.Time
+	retry     time.Duration
+}
+
+func (e *SpikeLimitError) Error() string {
+	return fmt.Sprintf("%s for project %s", ErrSpikeLimited, e.ProjectID)
+}
+
+func (e *SpikeLimitError) Is(target error) bool {
+	return target == ErrSpikeLimited
+}
+
+// SpikeEventKind tells spike hooks whether a limit started or ended.
+type SpikeEventKind int
+
+const (
+	SpikeStarted SpikeEventKind = iota
+	SpikeEnded
+)
+
+func (k SpikeEventKind) String() string {
+	if k == SpikeStarted {
+		return "started"
+	}
+	return "ended"
+}
+
+// SpikeEvent is sent to spike hooks.
+type SpikeEvent struct {
+	Kind      SpikeEventKind
+	ProjectID string
+	PublicKey string  //key of the submission that tripped the detection, for SpikeStarted
+	Baseline  float64 //accepted submissions per minute before the spike
+	Rate      int64   //submissions in the minute the spike was detected
+	Limit     int64   //submissions per minute let through while limited
+	Until     time.Time
+}
+
+// SpikeHook is told about spike limits, e.g. to page someone or post to a chat channel.
+// Hooks run on the request goroutine and should hand slow work off.
+type SpikeHook interface {
+	Spike(e SpikeEvent)
+}
+
+// SpikeHookFunc adapts a plain function to SpikeHook.
+type SpikeHookFunc func(e SpikeEvent)
+
+func (f SpikeHookFunc) Spike(e SpikeEvent) {
+	f(e)
+}
+
+// SpikeProtection watches per-project traffic and, when a minute brings Multiple times the baseline of the
+// preceding minutes, limits the project to that threshold for Duration. Set the fields before use.
+// A SpikeProtection is safe for concurrent use.
+type SpikeProtection struct {
+	Multiple float64       //spike threshold as a multiple of the baseline, default 10
+	Floor    int64         //minimum threshold per minute so quiet projects are not limited for a handful of events, default 100
+	Duration time.Duration //how long a limit holds, default 10 minutes
+
+	stats  *ProjectStats
+	window int       //baseline minutes
+	warm   time.Time //no detection before a full baseline was seen
+	clock  Clock
+	logf   func(format string, v ...interface{})
+	mu     sync.Mutex
+	limits map[string]*spikeLimit
+	hooks  []SpikeHook
+}
+
+// spikeLimit is an active limit of one project.
+type spikeLimit struct {
+	event  SpikeEvent
+	minute int64 //Unix minute admitted counts
+	passed int64
+}
+
+func NewSpikeProtection(baseline time.Duration, opts ...Option) *SpikeProtection {
+	/*
+		Compares each minute with the average of the baseline before it, e.g. NewSpikeProtection(time.Hour).
+		Nothing is limited during the first baseline, so a restarted relay does not mistake its regular traffic
+		for a spike. WithClock and WithLogger apply; the logger is told about every limit, like the hooks.
+	*/
+	c := newConfig(opts)
+	window := int((baseline + time.Minute - 1) / time.Minute)
+	if window < 1 {
+		window = 1
+	}
+	clock := c.clock
+	if clock == nil {
+		clock = SystemClock
+	}
+	return &SpikeProtection{
+		Multiple: 10,
+		Floor:    100,
+		Duration: 10 * time.Minute,
+		stats:    NewProjectStats(time.Duration(window+1)*time.Minute, opts...),
+		window:   window,
+		warm:     clock.Now().Add(time.Duration(window) * time.Minute),
+		clock:    clock,
+		logf:     c.logf,
+		limits:   map[string]*spikeLimit{},
+	}
+}
+
+func WithSpikeProtection(sp *SpikeProtection) Option {
+	/*
+		Runs every parsed DSN through sp. Submissions over an active limit fail with a *SpikeLimitError
+		(429 with Retry-After behind the middleware).
+	*/
+	return func(c *config) {
+		c.spikes = sp
+	}
+}
+
+func (sp *SpikeProtection) AddHook(h SpikeHook) {
+	/*
+		h is called whenever a limit starts or ends. Ends are noticed with the next submission of the project.
+	*/
+	sp.mu.Lock()
+	defer sp.mu.Unlock()
+	sp.hooks = append(sp.hooks, h)
+}
+
+func (sp *SpikeProtection) Stats() *ProjectStats {
+	/*
+		The traffic sp bases its decisions on: admitted submissions count as accepted, limited ones as rejected.
+		Register it with a Stats to see it on the debug endpoint.
+	*/
+	return sp.stats
+}
+
+func (sp *SpikeProtection) Limits() []SpikeEvent {
+	/*
+		The active limits as announced by SpikeStarted, sorted by project ID.
+	*/
+	now := sp.clock.Now()
+	sp.mu.Lock()
+	defer sp.mu.Unlock()
+	var active []SpikeEvent
+	for _, l := range sp.limits {
+		if now.Before(l.event.Until) {
+			active = append(active, l.event)
+		}
+	}
+	sort.Slice(active, func(i, j int) bool { return active[i].ProjectID < active[j].ProjectID })
+	return active
+}
+
+func (sp *SpikeProtection) Admit(d *DSN) error {
+	/*
+		Counts a submission for d's project and decides whether it may pass. DSNs without project ID always pass.
+	*/
+	id := d.ProjectID
+	if len(id) == 0 {
+		return nil
+	}
+	now := sp.clock.Now()
+	minute := now.Unix() / 60
+	var events []SpikeEvent
+	sp.mu.Lock()
+	l, limited := sp.limits[id]
+	if limited && !now.Before(l.event.Until) {
+		delete(sp.limits, id)
+		ended := l.event
+		ended.Kind = SpikeEnded
+		events = append(events, ended)
+		limited = false
+	}
+	if !limited && !now.Before(sp.warm) {
+		if l = sp.detect(id, d.PublicKey, now); l != nil {
+			sp.limits[id] = l
+			events = append(events, l.event)
+			limited = true
+		}
+	}
+	var err error
+	if limited {
+		if l.minute != minute {
+			l.minute, l.passed = minute, 0
+		}
+		if l.passed >= l.event.Limit {
+			err = &SpikeLimitError{ProjectID: id, Until: l.event.Until, retry: time.Unix((minute+1)*60, 0).Sub(now)}
+		} else {
+			l.passed++
+		}
+	}
+	hooks := sp.hooks
+	sp.mu.Unlock()
+
+	sp.stats.Record(id, err == nil)
+	for _, e := range events {
+		sp.logf("dsn: spike protection %s for project %s: %.1f/min baseline, %d this minute, limit %d/min until %s",
+			e.Kind, e.ProjectID, e.Baseline, e.Rate, e.Limit, e.Until.Format(time.RFC3339))
+		for _, h := range hooks {
+			h.Spike(e)
+		}
+	}
+	return err
+}
+
+func (sp *SpikeProtection) detect(projectID string, publicKey string, now time.Time) *spikeLimit {
+	/*
+		Whether the current minute, this submission included, is a spike. Called with sp.mu held.
+	*/
+	admitted, limited := sp.stats.Rate(projectID, 1)
+	total, _ := sp.stats.Rate(projectID, sp.window+1)
+	baseline := float64(total-admitted) / float64(sp.window)
+	current := admitted + limited + 1
+	threshold := int64(math.Ceil(sp.Multiple * baseline))
+	if threshold < sp.Floor {
+		threshold = sp.Floor
+	}
+	if current <= threshold {
+		return nil
+	}
+	return &spikeLimit{
+		event: SpikeEvent{
+			Kind:      SpikeStarted,
+			ProjectID: projectID,
+			PublicKey: publicKey,
+			Baseline:  baseline,
+			Rate:      current,
+			Limit:     threshold,
+			Until:     now.Add(sp.Duration),
+		},
+		minute: now.Unix() / 60,
+		passed: admitted,
+	}
+}
+
+func errorRateLimits(err error) RateLimits {
+	/*
+		Rate limits to announce for errors that carry one.
+	*/
+	var serr *SpikeLimitError
+	if !errors.As(err, &serr) {
+		return nil
+	}
+	return RateLimits{{RetryAfter: serr.retry, Scope: "project", ReasonCode: ErrorCode(ErrSpikeLimited)}}
+}
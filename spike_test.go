@@ -0,0 +1,85 @@
+package dsn
+
+import (
+	"bytes"
+	"errors"
+	"log"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestSpikeProtection(t *testing.T) {
+	now := time.Unix(1600000020, 0)
+	var buf bytes.Buffer
+	sp := NewSpikeProtection(10*time.Minute, WithClock(ClockFunc(func() time.Time { return now })), WithLogger(log.New(&buf, "", 0)))
+	sp.Multiple, sp.Floor, sp.Duration = 3, 5, 2*time.Minute
+	var events []SpikeEvent
+	sp.AddHook(SpikeHookFunc(func(e SpikeEvent) { events = append(events, e) }))
+	d, _ := Parse("https://" + testKeyA + "@sentry.io/1")
+
+	// 10 minutes of 10 per minute make a baseline of 10, so the threshold is 30
+	for m := 0; m < 10; m++ {
+		for i := 0; i < 10; i++ {
+			if err := sp.Admit(d); err != nil {
+				t.Fatalf("Expected -- baseline traffic admitted -- Got %v", err)
+			}
+		}
+		now = now.Add(time.Minute)
+	}
+	admitted := 0
+	var err error
+	for i := 0; i < 50; i++ {
+		if err = sp.Admit(d); err == nil {
+			admitted++
+		}
+	}
+	if admitted != 30 || !errors.Is(err, ErrSpikeLimited) {
+		t.Errorf("Expected -- 30 admitted, then %v -- Got %d %v", ErrSpikeLimited, admitted, err)
+	}
+	if len(events) != 1 || events[0].Kind != SpikeStarted || events[0].Limit != 30 || events[0].Baseline != 10 || events[0].Rate != 31 {
+		t.Errorf("Expected -- one started event -- Got %+v", events)
+	}
+	if limits := sp.Limits(); len(limits) != 1 || limits[0].ProjectID != "1" || !strings.Contains(buf.String(), "spike protection started for project 1") {
+		t.Errorf("Expected -- active limit, logged -- Got %+v %s", limits, buf.String())
+	}
+
+	// the limit is per minute
+	now = now.Add(time.Minute)
+	if err := sp.Admit(d); err != nil {
+		t.Errorf("Expected -- admitted in a new minute -- Got %v", err)
+	}
+	// and ends after Duration
+	now = now.Add(2 * time.Minute)
+	if err := sp.Admit(d); err != nil || len(events) != 2 || events[1].Kind != SpikeEnded || len(sp.Limits()) != 0 {
+		t.Errorf("Expected -- limit ended -- Got %v %+v", err, events)
+	}
+	if err := sp.Admit(&DSN{PublicKey: testKeyA}); err != nil {
+		t.Errorf("Expected -- DSNs without project pass -- Got %v", err)
+	}
+}
+
+func TestSpikeProtectionMiddleware(t *testing.T) {
+	now := time.Unix(1600000050, 0)
+	sp := NewSpikeProtection(time.Minute, WithClock(ClockFunc(func() time.Time { return now })))
+	sp.Floor = 1
+	now = now.Add(time.Minute)
+	h := NewMiddleware(WithSpikeProtection(sp))(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {}))
+	var codes []int
+	for i := 0; i < 2; i++ {
+		w := httptest.NewRecorder()
+		h.ServeHTTP(w, httptest.NewRequest(http.MethodPost, "https://sentry.io/api/1/store/?sentry_key="+testKeyA, nil))
+		codes = append(codes, w.Code)
+		if w.Code == http.StatusTooManyRequests && w.Header().Get("Retry-After") != "30" {
+			t.Errorf("Expected -- Retry-After 30 -- Got %q", w.Header().Get("Retry-After"))
+		}
+	}
+	if codes[0] != http.StatusOK || codes[1] != http.StatusTooManyRequests {
+		t.Errorf("Expected -- 200 then 429 -- Got %v", codes)
+	}
+	if ErrorCode(&SpikeLimitError{}) != "spike_limited" {
+		t.Errorf("Expected -- spike_limited -- Got %s", ErrorCode(&SpikeLimitError{}))
+	}
+}
@@ -0,0 +1,400 @@
+package dsn
+
+import (
+	"bytes"
+	"context"
+	"encoding/binary"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// ErrInvalidSpoolFile Thrown when a spooled submission can not be decoded
+var ErrInvalidSpoolFile = errors.New("sentry:  invalid spool file")
+
+const spoolExt = ".sub"
+
+// A file being replayed is renamed to <name>.inflight so no other Replay, in this process or another one
+// on the same dir, sends it too. Claims older than spoolClaimTimeout were left by a crash and are given back.
+const (
+	spoolClaimExt     = ".inflight"
+	spoolClaimTimeout = 5 * time.Minute
+)
+
+// Spool is a Sink that passes submissions on to another Sink and writes them to disk when that fails
+// because the upstream is unreachable (network errors, 429 and 5xx). Replay sends them again, oldest first.
+// Submissions that can never be delivered (a PermanentError or a 4xx) are dropped and counted on Recorder.
+// Retention is bounded by MaxBytes and MaxAge, the oldest files are dropped first. A Spool is safe for concurrent use,
+// and several Spools, even in different processes, may share a dir without sending a file twice.
+type Spool struct {
+	MaxBytes int64            //total size of spooled files, 0 for no limit
+	MaxAge   time.Duration    //0 for no limit
+	Recorder *OutcomeRecorder //optional, records dropped submissions as OutcomeInvalid with ReasonUndeliverable
+
+	sink   Sink
+	dir    string
+	config *config
+	mu     sync.Mutex
+	seq    uint64
+	replay sync.Mutex //one Replay at a time, Run and Shutdown may overlap
+}
+
+func NewSpool(dir string, sink Sink, opts ...Option) (*Spool, error) {
+	/*
+		Spools failed sends to sink into dir, which is created if needed. Files left by an earlier process are replayed too.
+		WithClock dates the files, WithLogger reports replay failures and dropped files. With WithSecretCipher
+		the DSN secret key and the X-Sentry-Auth header are encrypted on disk.
+	*/
+	if err := os.MkdirAll(dir, 0o700); err != nil {
+		return nil, err
+	}
+	return &Spool{sink: sink, dir: dir, config: newConfig(opts)}, nil
+}
+
+func (sp *Spool) Send(ctx context.Context, s *Submission) error {
+	err := sp.sink.Send(ctx, s)
+	if err == nil {
+		return nil
+	}
+	if !retryable(err) {
+		sp.discard(s)
+		return err
+	}
+	if werr := sp.write(s); werr != nil {
+		return fmt.Errorf("%w (spooling failed: %v)", err, werr)
+	}
+	return nil
+}
+
+func (sp *Spool) Accepts(endpoint Endpoint) bool {
+	return sinkAccepts(sp.sink, endpoint)
+}
+
+func retryable(err error) bool {
+	/*
+		Whether a later attempt may succeed. Submissions the upstream refused as invalid, or that can not be sent
+		at all, are not worth keeping.
+	*/
+	var perr *PermanentError
+	if errors.As(err, &perr) {
+		return false
+	}
+	var uerr *UpstreamError
+	if errors.As(err, &uerr) {
+		return uerr.StatusCode == 429 || uerr.StatusCode >= 500
+	}
+	return true
+}
+
+// ReasonUndeliverable is the outcome reason Spool records for submissions it drops.
+const ReasonUndeliverable = "undeliverable"
+
+func (sp *Spool) discard(s *Submission) {
+	if sp.Recorder == nil || s.DSN == nil {
+		return
+	}
+	category := CategoryError
+	if s.Endpoint == EndpointAttachments {
+		category = CategoryAttachment
+	}
+	sp.Recorder.Record(s.DSN, category, OutcomeInvalid, ReasonUndeliverable, 1)
+}
+
+func (sp *Spool) write(s *Submission) error {
+	sp.mu.Lock()
+	sp.seq++
+	name := fmt.Sprintf("%020d-%06d%s", sp.config.now().UnixNano(), sp.seq%1000000, spoolExt)
+	sp.mu.Unlock()
+	b, err := encodeSubmission(s, sp.config.cipher)
+	if err != nil {
+		return err
+	}
+	// write to a temp file first so Replay never sees a half written submission
+	tmp := filepath.Join(sp.dir, "."+name)
+	if err := ioutil.WriteFile(tmp, b, 0o600); err != nil {
+		return err
+	}
+	if err := os.Rename(tmp, filepath.Join(sp.dir, name)); err != nil {
+		return err
+	}
+	sp.prune()
+	return nil
+}
+
+// spoolFile is one spooled submission on disk.
+type spoolFile struct {
+	name    string
+	created time.Time
+	size    int64
+}
+
+func (sp *Spool) files() ([]spoolFile, error) {
+	entries, err := ioutil.ReadDir(sp.dir)
+	if err != nil {
+		return nil, err
+	}
+	var files []spoolFile
+	for _, e := range entries {
+		name := e.Name()
+		if e.IsDir() || strings.HasPrefix(name, ".") || !strings.HasSuffix(name, spoolExt) {
+			continue
+		}
+		i := strings.IndexByte(name, '-')
+		if i <= 0 {
+			continue
+		}
+		nanos, err := strconv.ParseInt(name[:i], 10, 64)
+		if err != nil {
+			continue
+		}
+		files = append(files, spoolFile{name: name, created: time.Unix(0, nanos), size: e.Size()})
+	}
+	sort.Slice(files, func(i, j int) bool { return files[i].name < files[j].name })
+	return files, nil
+}
+
+func (sp *Spool) prune() {
+	/*
+		Drops files past MaxAge, then the oldest files until the spool fits MaxBytes.
+	*/
+	if sp.MaxAge <= 0 && sp.MaxBytes <= 0 {
+		return
+	}
+	files, err := sp.files()
+	if err != nil {
+		return
+	}
+	var total int64
+	for _, f := range files {
+		total += f.size
+	}
+	now := sp.config.now()
+	for _, f := range files {
+		expired := sp.MaxAge > 0 && now.Sub(f.created) > sp.MaxAge
+		if !expired && (sp.MaxBytes <= 0 || total <= sp.MaxBytes) {
+			break
+		}
+		if os.Remove(filepath.Join(sp.dir, f.name)) == nil {
+			total -= f.size
+			sp.config.logf("dsn: dropped spooled submission %s", f.name)
+		}
+	}
+}
+
+func (sp *Spool) Pending() (n int, size int64) {
+	/*
+		Number and total size of spooled submissions.
+	*/
+	files, _ := sp.files()
+	for _, f := range files {
+		size += f.size
+	}
+	return len(files), size
+}
+
+func (sp *Spool) Replay(ctx context.Context) (sent int, err error) {
+	/*
+		Sends spooled submissions oldest first and removes them. Stops at the first retryable failure,
+		so an upstream that is still down is not hammered; submissions it refuses as invalid are dropped.
+		Corrupt files are dropped as well, files whose secrets can not be decrypted stop the replay and stay on disk.
+	*/
+	sp.replay.Lock()
+	defer sp.replay.Unlock()
+	sp.reclaim()
+	sp.prune()
+	files, err := sp.files()
+	if err != nil {
+		return 0, err
+	}
+	for _, f := range files {
+		if err := ctx.Err(); err != nil {
+			return sent, err
+		}
+		path := filepath.Join(sp.dir, f.name)
+		claimed := path + spoolClaimExt
+		if err := os.Rename(path, claimed); os.IsNotExist(err) {
+			continue // replayed or pruned concurrently
+		} else if err != nil {
+			return sent, err
+		}
+		now := sp.config.now()
+		os.Chtimes(claimed, now, now)
+		b, err := ioutil.ReadFile(claimed)
+		if err != nil {
+			os.Rename(claimed, path)
+			return sent, err
+		}
+		s, err := decodeSubmission(b, sp.config.cipher)
+		if errors.Is(err, ErrInvalidSpoolFile) {
+			sp.config.logf("dsn: dropped spooled submission %s: %v", f.name, err)
+			os.Remove(claimed)
+			continue
+		} else if err != nil {
+			// e.g. ErrSealedSecret with the wrong cipher key, the file is fine and must survive a fixed config
+			os.Rename(claimed, path)
+			return sent, fmt.Errorf("%s: %w", f.name, err)
+		}
+		if err := sp.sink.Send(ctx, s); err != nil && retryable(err) {
+			os.Rename(claimed, path)
+			return sent, err
+		} else if err != nil {
+			sp.config.logf("dsn: dropped spooled submission %s: %v", f.name, err)
+			sp.discard(s)
+		} else {
+			sent++
+		}
+		os.Remove(claimed)
+	}
+	return sent, nil
+}
+
+func (sp *Spool) reclaim() {
+	/*
+		Gives back claims past spoolClaimTimeout, their Replay died before finishing.
+	*/
+	entries, err := ioutil.ReadDir(sp.dir)
+	if err != nil {
+		return
+	}
+	now := sp.config.now()
+	for _, e := range entries {
+		name := e.Name()
+		if e.IsDir() || !strings.HasSuffix(name, spoolExt+spoolClaimExt) || now.Sub(e.ModTime()) < spoolClaimTimeout {
+			continue
+		}
+		if os.Rename(filepath.Join(sp.dir, name), filepath.Join(sp.dir, strings.TrimSuffix(name, spoolClaimExt))) == nil {
+			sp.config.logf("dsn: reclaimed spooled submission %s", name)
+		}
+	}
+}
+
+func (sp *Spool) Shutdown(ctx context.Context) error {
+	/*
+		Makes a last Replay attempt within ctx. Whatever can not be sent stays on disk for the next NewSpool
+		on the same dir, so nothing is lost; the error only says the spool is not empty.
+	*/
+	_, err := sp.Replay(ctx)
+	return err
+}
+
+func (sp *Spool) Run(ctx context.Context, interval time.Duration) {
+	/*
+		Calls Replay every interval until ctx is done.
+	*/
+	t := time.NewTicker(interval)
+	defer t.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-t.C:
+			if _, err := sp.Replay(ctx); err != nil && ctx.Err() == nil {
+				sp.config.logf("dsn: replaying spool: %v", err)
+			}
+		}
+	}
+}
+
+func encodeSubmission(s *Submission, c SecretCipher) ([]byte, error) {
+	/*
+		Length prefixed fields: DSN and headers as JSON, endpoint, body, receive time in Unix nanoseconds.
+		Secrets are sealed with c, if any.
+	*/
+	dsn, headers := s.DSN, s.Headers
+	if c != nil {
+		if secret, err := sealSecret(c, dsn.SecretKey); err != nil {
+			return nil, err
+		} else if secret != dsn.SecretKey {
+			dsn = dsn.Clone()
+			dsn.SecretKey = secret
+			dsn.URL = dsn.String()
+		}
+		if auth := headers.Get(HTTP_X_SENTRY_AUTH); len(auth) > 0 {
+			sealed, err := c.Encrypt(auth)
+			if err != nil {
+				return nil, err
+			}
+			headers = headers.Clone()
+			headers.Set(HTTP_X_SENTRY_AUTH, sealed)
+		}
+	}
+	d, err := json.Marshal(dsn)
+	if err != nil {
+		return nil, err
+	}
+	encodedHeaders, err := json.Marshal(headers)
+	if err != nil {
+		return nil, err
+	}
+	var received []byte
+	if !s.Received.IsZero() {
+		received = strconv.AppendInt(nil, s.Received.UnixNano(), 10)
+	}
+	var buf bytes.Buffer
+	for _, field := range [][]byte{d, []byte(s.Endpoint), encodedHeaders, s.Body, received} {
+		var n [4]byte
+		binary.BigEndian.PutUint32(n[:], uint32(len(field)))
+		buf.Write(n[:])
+		buf.Write(field)
+	}
+	return buf.Bytes(), nil
+}
+
+func decodeSubmission(b []byte, c SecretCipher) (*Submission, error) {
+	r := bytes.NewReader(b)
+	fields := make([][]byte, 5)
+	for i := range fields {
+		var n [4]byte
+		if i == 4 && r.Len() == 0 {
+			break //written before receive times were kept
+		}
+		if _, err := io.ReadFull(r, n[:]); err != nil {
+			return nil, ErrInvalidSpoolFile
+		}
+		size := binary.BigEndian.Uint32(n[:])
+		if int64(size) > int64(r.Len()) {
+			return nil, ErrInvalidSpoolFile
+		}
+		fields[i] = make([]byte, size)
+		r.Read(fields[i])
+	}
+	s := &Submission{DSN: &DSN{}, Endpoint: Endpoint(fields[1]), Body: fields[3]}
+	if err := json.Unmarshal(fields[0], s.DSN); err != nil {
+		return nil, ErrInvalidSpoolFile
+	}
+	if err := json.Unmarshal(fields[2], &s.Headers); err != nil {
+		return nil, ErrInvalidSpoolFile
+	}
+	if len(fields[4]) > 0 {
+		received, err := strconv.ParseInt(string(fields[4]), 10, 64)
+		if err != nil {
+			return nil, ErrInvalidSpoolFile
+		}
+		s.Received = time.Unix(0, received)
+	}
+	if IsSealed(s.DSN.SecretKey) {
+		secret, err := openSecret(c, s.DSN.SecretKey)
+		if err != nil {
+			return nil, err
+		}
+		s.DSN.SecretKey = secret
+		s.DSN.URL = s.DSN.String()
+	}
+	if auth := s.Headers.Get(HTTP_X_SENTRY_AUTH); IsSealed(auth) {
+		opened, err := openSecret(c, auth)
+		if err != nil {
+			return nil, err
+		}
+		s.Headers.Set(HTTP_X_SENTRY_AUTH, opened)
+	}
+	return s, nil
+}
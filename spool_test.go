@@ -0,0 +1,206 @@
+package dsn
+
+import (
+	"context"
+	"errors"
+	"io/ioutil"
+	"net/http"
+	"os"
+	"path/filepath"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+// testUpstream is a Sink that fails with err while it is set.
+type testUpstream struct {
+	err  error
+	sent []*Submission
+}
+
+func (u *testUpstream) Send(ctx context.Context, s *Submission) error {
+	if u.err != nil {
+		return u.err
+	}
+	u.sent = append(u.sent, s)
+	return nil
+}
+
+type testSpoolSend struct {
+	err         error
+	description string
+	spooled     int
+	returned    bool
+}
+
+var testTableSpoolSend = []testSpoolSend{
+	{nil, "Testing upstream up", 0, false},
+	{errors.New("connection refused"), "Testing upstream unreachable", 1, false},
+	{&UpstreamError{StatusCode: http.StatusServiceUnavailable}, "Testing upstream 503", 1, false},
+	{&UpstreamError{StatusCode: http.StatusTooManyRequests}, "Testing upstream 429", 1, false},
+	{&UpstreamError{StatusCode: http.StatusBadRequest}, "Testing submission refused", 0, true},
+	{&PermanentError{ErrNoRoute}, "Testing submission without a route", 0, true},
+}
+
+func TestSpoolSend(t *testing.T) {
+	d, _ := Parse("https://" + testKeyA + "@sentry.io/1")
+	for _, test := range testTableSpoolSend {
+		sp, err := NewSpool(t.TempDir(), &testUpstream{err: test.err})
+		if err != nil {
+			t.Fatal(err)
+		}
+		err = sp.Send(context.Background(), &Submission{DSN: d, Endpoint: EndpointStore, Body: []byte(testEvent)})
+		if (err != nil) != test.returned {
+			t.Errorf("%s: Expected -- error %v -- Got %v", test.description, test.returned, err)
+		}
+		if n, _ := sp.Pending(); n != test.spooled {
+			t.Errorf("%s: Expected -- %d spooled -- Got %d", test.description, test.spooled, n)
+		}
+	}
+}
+
+func TestSpoolReplay(t *testing.T) {
+	dir := t.TempDir()
+	up := &testUpstream{err: errors.New("connection refused")}
+	sp, _ := NewSpool(dir, up)
+	d, _ := Parse("https://" + testKeyA + ":" + testKeyB + "@sentry.io/1")
+	for _, body := range []string{"first", "second", "third"} {
+		sp.Send(context.Background(), &Submission{DSN: d, Endpoint: EndpointEnvelope, Headers: http.Header{"Content-Encoding": {"gzip"}}, Body: []byte(body)})
+	}
+	if sent, err := sp.Replay(context.Background()); sent != 0 || err == nil {
+		t.Errorf("Expected -- replay to stop while upstream is down -- Got %d %v", sent, err)
+	}
+	// a new process picks up what the old one left
+	up.err = nil
+	sp, _ = NewSpool(dir, up)
+	if sent, err := sp.Replay(context.Background()); sent != 3 || err != nil {
+		t.Fatalf("Expected -- 3 replayed -- Got %d %v", sent, err)
+	}
+	for i, body := range []string{"first", "second", "third"} {
+		s := up.sent[i]
+		if string(s.Body) != body || s.Endpoint != EndpointEnvelope || s.Headers.Get("Content-Encoding") != "gzip" || s.DSN.SecretKey != testKeyB {
+			t.Errorf("Expected -- %s in order -- Got %+v", body, s)
+		}
+	}
+	if n, _ := sp.Pending(); n != 0 {
+		t.Errorf("Expected -- empty spool -- Got %d", n)
+	}
+}
+
+func TestSpoolNotForwardable(t *testing.T) {
+	rec := NewOutcomeRecorder()
+	sp, _ := NewSpool(t.TempDir(), &HTTPSink{})
+	sp.Recorder = rec
+	d, _ := Parse("https://" + testKeyA + "@sentry.io/1")
+	err := sp.Send(context.Background(), &Submission{DSN: d, Endpoint: EndpointAttachments, Body: []byte("data")})
+	if !errors.Is(err, ErrNotForwardable) {
+		t.Errorf("Expected -- %v -- Got %v", ErrNotForwardable, err)
+	}
+	if n, _ := sp.Pending(); n != 0 {
+		t.Errorf("Expected -- nothing spooled -- Got %d", n)
+	}
+	if got := rec.Snapshot(); len(got) != 1 || got[0].Category != CategoryAttachment || got[0].Outcome != OutcomeInvalid || got[0].Reason != ReasonUndeliverable {
+		t.Errorf("Expected -- one undeliverable attachment -- Got %+v", got)
+	}
+}
+
+func TestSpoolRetention(t *testing.T) {
+	now := time.Unix(1600000000, 0)
+	clock := ClockFunc(func() time.Time { return now })
+	sp, _ := NewSpool(t.TempDir(), &testUpstream{err: errors.New("down")}, WithClock(clock))
+	d, _ := Parse("https://" + testKeyA + "@sentry.io/1")
+	send := func(body string) {
+		sp.Send(context.Background(), &Submission{DSN: d, Body: []byte(body)})
+		now = now.Add(time.Minute)
+	}
+	send("1111")
+	send("2222")
+	_, size := sp.Pending()
+	sp.MaxBytes = size
+	send("3333") // over MaxBytes, the first has to go
+	if n, _ := sp.Pending(); n != 2 {
+		t.Errorf("Expected -- 2 files within MaxBytes -- Got %d", n)
+	}
+	sp.MaxBytes = 0
+	sp.MaxAge = 90 * time.Second
+	send("4444") // the second is 2 minutes old now
+	if n, _ := sp.Pending(); n != 2 {
+		t.Errorf("Expected -- 2 files within MaxAge -- Got %d", n)
+	}
+}
+
+func TestSpoolCorruptFile(t *testing.T) {
+	dir := t.TempDir()
+	ioutil.WriteFile(filepath.Join(dir, "00000000000000000001-000001.sub"), []byte{0, 0, 0, 9, 'x'}, 0o600)
+	up := &testUpstream{}
+	sp, _ := NewSpool(dir, up)
+	if sent, err := sp.Replay(context.Background()); sent != 0 || err != nil {
+		t.Errorf("Expected -- corrupt file dropped -- Got %d %v", sent, err)
+	}
+	if _, err := os.Stat(filepath.Join(dir, "00000000000000000001-000001.sub")); !os.IsNotExist(err) {
+		t.Errorf("Expected -- file removed -- Got %v", err)
+	}
+}
+
+func TestSubmissionEncodingReceived(t *testing.T) {
+	s := &Submission{DSN: &DSN{PublicKey: testKeyA, ProjectID: "1"}, Endpoint: EndpointEnvelope, Body: []byte("{}"), Received: time.Unix(1600000000, 5)}
+	b, _ := encodeSubmission(s, nil)
+	if got, err := decodeSubmission(b, nil); err != nil || !got.Received.Equal(s.Received) {
+		t.Errorf("Expected -- %v -- Got %v %v", s.Received, got, err)
+	}
+	// files written before receive times were kept end after the body
+	s.Received = time.Time{}
+	b, _ = encodeSubmission(s, nil)
+	if got, err := decodeSubmission(b[:len(b)-4], nil); err != nil || !got.Received.IsZero() || string(got.Body) != "{}" {
+		t.Errorf("Expected -- four field file decoded -- Got %v %v", got, err)
+	}
+}
+
+func TestSpoolConcurrentReplay(t *testing.T) {
+	dir := t.TempDir()
+	sp, _ := NewSpool(dir, &testUpstream{err: errors.New("down")})
+	d, _ := Parse("https://" + testKeyA + "@sentry.io/1")
+	for i := 0; i < 20; i++ {
+		sp.Send(context.Background(), &Submission{DSN: d, Body: []byte(testEvent)})
+	}
+	var sent int32
+	up := SinkFunc(func(ctx context.Context, s *Submission) error {
+		atomic.AddInt32(&sent, 1)
+		time.Sleep(time.Millisecond)
+		return nil
+	})
+	// two processes on the same dir
+	var wg sync.WaitGroup
+	for i := 0; i < 2; i++ {
+		other, _ := NewSpool(dir, up)
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			other.Replay(context.Background())
+		}()
+	}
+	wg.Wait()
+	if sent != 20 {
+		t.Errorf("Expected -- 20 sent once each -- Got %d", sent)
+	}
+}
+
+func TestSpoolStaleClaim(t *testing.T) {
+	dir := t.TempDir()
+	up := &testUpstream{}
+	sp, _ := NewSpool(dir, up)
+	d, _ := Parse("https://" + testKeyA + "@sentry.io/1")
+	b, _ := encodeSubmission(&Submission{DSN: d, Body: []byte(testEvent)}, nil)
+	// claimed by a Replay that crashed
+	claimed := filepath.Join(dir, "00000000000000000001-000001"+spoolExt+spoolClaimExt)
+	ioutil.WriteFile(claimed, b, 0o600)
+	if sent, err := sp.Replay(context.Background()); sent != 0 || err != nil {
+		t.Errorf("Expected -- fresh claim left alone -- Got %d %v", sent, err)
+	}
+	old := time.Now().Add(-spoolClaimTimeout)
+	os.Chtimes(claimed, old, old)
+	if sent, err := sp.Replay(context.Background()); sent != 1 || err != nil {
+		t.Errorf("Expected -- stale claim replayed -- Got %d %v", sent, err)
+	}
+}
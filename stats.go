@@ -0,0 +1,147 @@
+package dsn
+
+import (
+	"encoding/json"
+	"net/http"
+	"sync"
+	"time"
+)
+
+// Stats counts parse results for the debug handler. Pass it to WithStats; anything else worth exposing
+// (resolver cache hit rates, rate limiter state) can be added with Add and Register.
+// A Stats is safe for concurrent use.
+type Stats struct {
+	mu        sync.Mutex
+	clock     Clock
+	started   time.Time
+	accepted  int64
+	rejected  int64
+	endpoints map[Endpoint]int64
+	errors    map[string]int64
+	counters  map[string]int64
+	sources   map[string]func() interface{}
+}
+
+func NewStats(opts ...Option) *Stats {
+	/*
+		Only WithClock applies, uptime is measured with it.
+	*/
+	clock := newConfig(opts).clock
+	if clock == nil {
+		clock = SystemClock
+	}
+	return &Stats{
+		clock:     clock,
+		started:   clock.Now(),
+		endpoints: map[Endpoint]int64{},
+		errors:    map[string]int64{},
+		counters:  map[string]int64{},
+		sources:   map[string]func() interface{}{},
+	}
+}
+
+func WithStats(s *Stats) Option {
+	/*
+		Counts every parse in s: accepted requests by endpoint, rejected ones by error kind.
+	*/
+	return func(c *config) {
+		c.stats = s
+	}
+}
+
+func (s *Stats) record(res *ParseResult, err error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if err != nil {
+		s.rejected++
+		s.errors[ErrorCode(err)]++
+		return
+	}
+	s.accepted++
+	s.endpoints[res.Endpoint]++
+}
+
+func (s *Stats) Add(name string, delta int64) {
+	/*
+		Adds delta to a custom counter, e.g. Add("resolver_cache_hit", 1).
+	*/
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.counters[name] += delta
+}
+
+func (s *Stats) Register(name string, fn func() interface{}) {
+	/*
+		Includes the result of fn, marshalled to JSON, under name in every snapshot.
+		fn is called without Stats' lock held.
+	*/
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.sources[name] = fn
+}
+
+// StatsSnapshot is what Stats.Handler serves.
+type StatsSnapshot struct {
+	Status    string                 `json:"status"`
+	Uptime    float64                `json:"uptime_seconds"`
+	Accepted  int64                  `json:"accepted"`
+	Rejected  int64                  `json:"rejected"`
+	Endpoints map[Endpoint]int64     `json:"endpoints"`
+	Errors    map[string]int64       `json:"errors"`
+	Counters  map[string]int64       `json:"counters,omitempty"`
+	Sources   map[string]interface{} `json:"sources,omitempty"`
+}
+
+func (s *Stats) Snapshot() StatsSnapshot {
+	s.mu.Lock()
+	snap := StatsSnapshot{
+		Status:    "ok",
+		Uptime:    s.clock.Now().Sub(s.started).Seconds(),
+		Accepted:  s.accepted,
+		Rejected:  s.rejected,
+		Endpoints: make(map[Endpoint]int64, len(s.endpoints)),
+		Errors:    make(map[string]int64, len(s.errors)),
+		Counters:  make(map[string]int64, len(s.counters)),
+	}
+	for k, v := range s.endpoints {
+		snap.Endpoints[k] = v
+	}
+	for k, v := range s.errors {
+		snap.Errors[k] = v
+	}
+	for k, v := range s.counters {
+		snap.Counters[k] = v
+	}
+	names := make([]string, 0, len(s.sources))
+	fns := make([]func() interface{}, 0, len(s.sources))
+	for name, fn := range s.sources {
+		names = append(names, name)
+		fns = append(fns, fn)
+	}
+	s.mu.Unlock()
+
+	if len(fns) > 0 {
+		snap.Sources = make(map[string]interface{}, len(fns))
+		for i, fn := range fns {
+			snap.Sources[names[i]] = fn()
+		}
+	}
+	return snap
+}
+
+// DEBUG_STATS_PATH is the suggested mount point for Stats.Handler.
+var DEBUG_STATS_PATH = "/_dsn/stats"
+
+func (s *Stats) Handler() http.Handler {
+	/*
+		Serves Snapshot as JSON. Mount it wherever suits, e.g. mux.Handle(dsn.DEBUG_STATS_PATH, stats.Handler()).
+		The "status" field makes it usable as a liveness check too.
+	*/
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		w.Header().Set("Cache-Control", "no-store")
+		enc := json.NewEncoder(w)
+		enc.SetIndent("", "  ")
+		enc.Encode(s.Snapshot())
+	})
+}
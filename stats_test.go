@@ -0,0 +1,43 @@
+package dsn
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestStats(t *testing.T) {
+	stats := NewStats()
+	stats.Register("ratelimit", func() interface{} { return map[string]int{"limited": 2} })
+	requests := []string{
+		"https://sentry.io/api/1/store/?sentry_key=" + testKeyA,
+		"https://sentry.io/api/1/envelope/?sentry_key=" + testKeyA,
+		"https://sentry.io/api/1/envelope/?sentry_key=" + testKeyA,
+		"https://sentry.io/api/1/envelope/",
+		"https://sentry.io/api/0/projects/?sentry_key=" + testKeyA,
+	}
+	for _, u := range requests {
+		FromRequest(httptest.NewRequest("POST", u, nil), WithStats(stats))
+	}
+	stats.Add("resolver_cache_hit", 3)
+
+	w := httptest.NewRecorder()
+	stats.Handler().ServeHTTP(w, httptest.NewRequest("GET", DEBUG_STATS_PATH, nil))
+	var got StatsSnapshot
+	if err := json.Unmarshal(w.Body.Bytes(), &got); err != nil || w.Code != http.StatusOK {
+		t.Fatalf("Expected -- JSON snapshot -- Got %d %v", w.Code, err)
+	}
+	if got.Status != "ok" || got.Accepted != 3 || got.Rejected != 2 {
+		t.Errorf("Expected -- 3 accepted, 2 rejected -- Got %+v", got)
+	}
+	if got.Endpoints[EndpointEnvelope] != 2 || got.Endpoints[EndpointStore] != 1 {
+		t.Errorf("Expected -- endpoint counts -- Got %v", got.Endpoints)
+	}
+	if got.Errors["missing_public_key"] != 1 || got.Errors["unsupported_endpoint"] != 1 {
+		t.Errorf("Expected -- error kinds -- Got %v", got.Errors)
+	}
+	if got.Counters["resolver_cache_hit"] != 3 || got.Sources["ratelimit"] == nil {
+		t.Errorf("Expected -- custom counters and sources -- Got %v %v", got.Counters, got.Sources)
+	}
+}
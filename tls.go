@@ -0,0 +1,119 @@
+package dsn
+
+import (
+	"errors"
+	"net/http"
+	"strings"
+)
+
+// ErrInsecureTransport Thrown by TLSReject for requests that reached the relay over plaintext HTTP
+var ErrInsecureTransport = errors.New("sentry:  plaintext ingestion not allowed")
+
+// ErrInsecureScheme Thrown by WithRequireTLS for http:// DSNs
+var ErrInsecureScheme = errors.New("sentry:  DSN does not use https")
+
+// WarningInsecureTransport is the Warning code TLSWarn attaches to plaintext requests.
+const WarningInsecureTransport = "insecure_transport"
+
+// TLSPolicy decides what happens to requests that did not arrive over TLS.
+type TLSPolicy int
+
+const (
+	TLSAllow  TLSPolicy = iota //default, plaintext is accepted silently
+	TLSWarn                    //accepted, with a Warning and a message through the Logger
+	TLSReject                  //rejected with ErrInsecureTransport
+)
+
+func WithTLSPolicy(p TLSPolicy) Option {
+	/*
+		Applies p to requests that arrived over plaintext. Behind a TLS terminating load balancer the
+		X-Forwarded-Proto or Forwarded proto= header decides, but only from a peer listed in WithTrustedProxies;
+		without trusted proxies anyone could send them, so only the connection itself counts.
+	*/
+	return func(c *config) {
+		c.tlsPolicy = p
+	}
+}
+
+func WithRequireTLS() ValidateOption {
+	/*
+		Fails http:// DSNs with ErrInsecureScheme.
+	*/
+	return func(c *validateConfig) {
+		c.requireTLS = true
+	}
+}
+
+func (c *config) checkTLS(r *http.Request, res *ParseResult) error {
+	if c.tlsPolicy == TLSAllow || c.secureTransport(r) {
+		return nil
+	}
+	if c.tlsPolicy == TLSReject {
+		return ErrInsecureTransport
+	}
+	res.Warnings = append(res.Warnings, Warning{WarningInsecureTransport, "request arrived over plaintext HTTP"})
+	c.logf("dsn: plaintext request from %s (client %q)", r.RemoteAddr, r.UserAgent())
+	return nil
+}
+
+func (c *config) secureTransport(r *http.Request) bool {
+	/*
+		Whether r arrived over TLS, at the relay itself or at the proxy in front of it.
+	*/
+	if r.TLS != nil {
+		return true
+	}
+	if peer := parseHop(r.RemoteAddr); peer == nil || !c.trustedProxies.Contains(peer) {
+		return false
+	}
+	return strings.EqualFold(c.forwardedProto(r.Header), "https")
+}
+
+func (c *config) forwardedProto(h http.Header) string {
+	/*
+		The protocol the client used to reach the first trusted proxy. Proxies append to Forwarded and
+		X-Forwarded-Proto, so like ClientIP the hops are walked from the nearest proxy outwards and the entry of
+		the first untrusted hop counts; entries before it were written by the client. X-Forwarded-Proto entries
+		are matched with X-Forwarded-For hops from the end, without those only the last entry is believed.
+	*/
+	if values := h.Values("Forwarded"); len(values) > 0 {
+		var elems []string
+		for _, v := range values {
+			elems = append(elems, strings.Split(v, ",")...)
+		}
+		proto := ""
+		for i := len(elems) - 1; i >= 0; i-- {
+			var hop string
+			proto = ""
+			for _, pair := range strings.Split(elems[i], ";") {
+				if name, value, ok := cut(strings.TrimSpace(pair), "="); ok && strings.EqualFold(name, "proto") {
+					proto = strings.Trim(value, `"`)
+				} else if ok && strings.EqualFold(name, "for") {
+					hop = strings.Trim(value, `"`)
+				}
+			}
+			if ip := parseHop(hop); ip == nil || !c.trustedProxies.Contains(ip) {
+				break
+			}
+		}
+		return proto
+	}
+	var protos, hops []string
+	for _, v := range h.Values("X-Forwarded-Proto") {
+		protos = append(protos, strings.Split(v, ",")...)
+	}
+	for _, v := range h.Values("X-Forwarded-For") {
+		hops = append(hops, strings.Split(v, ",")...)
+	}
+	proto := ""
+	for k := 1; k <= len(protos); k++ {
+		proto = strings.TrimSpace(protos[len(protos)-k])
+		if k > len(hops) {
+			break
+		}
+		if ip := parseHop(strings.TrimSpace(hops[len(hops)-k])); ip == nil || !c.trustedProxies.Contains(ip) {
+			break
+		}
+	}
+	return proto
+}
@@ -0,0 +1,94 @@
+package dsn
+
+import (
+	"bytes"
+	"crypto/tls"
+	"errors"
+	"log"
+	"net/http/httptest"
+	"testing"
+)
+
+type testTLS struct {
+	url         string
+	headers     map[string]string
+	remote      string
+	opts        []Option
+	description string
+	err         error
+	warned      bool
+}
+
+// httptest requests come from 192.0.2.1
+var testProxyOpts = []Option{WithTLSPolicy(TLSReject), WithTrustedProxies(MustParseTrustedProxies("192.0.2.0/24"))}
+
+var testTableTLS = []testTLS{
+	{"https://sentry.io/api/1/store/", nil, "", []Option{WithTLSPolicy(TLSReject)},
+		"Testing TLS connection", nil, false},
+	{"http://sentry.io/api/1/store/", nil, "", []Option{WithTLSPolicy(TLSReject)},
+		"Testing plaintext rejected", ErrInsecureTransport, false},
+	{"http://sentry.io/api/1/store/", nil, "", []Option{WithTLSPolicy(TLSWarn)},
+		"Testing plaintext warned", nil, true},
+	{"http://sentry.io/api/1/store/", nil, "", nil,
+		"Testing plaintext allowed by default", nil, false},
+	{"http://sentry.io/api/1/store/", map[string]string{"X-Forwarded-Proto": "https"}, "", testProxyOpts,
+		"Testing terminated at proxy", nil, false},
+	{"http://sentry.io/api/1/store/", map[string]string{"X-Forwarded-Proto": "https, http", "X-Forwarded-For": "198.51.100.7"}, "", testProxyOpts,
+		"Testing https sent by the client and appended to", ErrInsecureTransport, false},
+	{"http://sentry.io/api/1/store/", map[string]string{"X-Forwarded-Proto": "https, http", "X-Forwarded-For": "198.51.100.7, 192.0.2.5"}, "", testProxyOpts,
+		"Testing chain of trusted proxies", nil, false},
+	{"http://sentry.io/api/1/store/", map[string]string{"X-Forwarded-Proto": "https, http", "X-Forwarded-For": "192.0.2.5, 198.51.100.7"}, "", testProxyOpts,
+		"Testing chain with a spoofed trusted hop", ErrInsecureTransport, false},
+	{"http://sentry.io/api/1/store/", map[string]string{"Forwarded": `for=192.0.2.9;proto=https, for=198.51.100.7;proto=http`}, "", testProxyOpts,
+		"Testing spoofed Forwarded element", ErrInsecureTransport, false},
+	{"http://sentry.io/api/1/store/", map[string]string{"Forwarded": `for=198.51.100.7;proto=https, for=192.0.2.5;proto=http`}, "", testProxyOpts,
+		"Testing Forwarded chain of trusted proxies", nil, false},
+	{"http://sentry.io/api/1/store/", map[string]string{"X-Forwarded-Proto": "http"}, "", testProxyOpts,
+		"Testing plaintext at proxy", ErrInsecureTransport, false},
+	{"http://sentry.io/api/1/store/", map[string]string{"Forwarded": `for=198.51.100.7;proto="https"`}, "", testProxyOpts,
+		"Testing Forwarded proto", nil, false},
+	{"http://sentry.io/api/1/store/", map[string]string{"X-Forwarded-Proto": "https"}, "", []Option{WithTLSPolicy(TLSReject)},
+		"Testing spoofed header without trusted proxies", ErrInsecureTransport, false},
+	{"http://sentry.io/api/1/store/", map[string]string{"Forwarded": "proto=https"}, "", []Option{WithTLSPolicy(TLSReject)},
+		"Testing spoofed Forwarded without trusted proxies", ErrInsecureTransport, false},
+	{"http://sentry.io/api/1/store/", map[string]string{"X-Forwarded-Proto": "https"}, "203.0.113.9:4000",
+		[]Option{WithTLSPolicy(TLSReject), WithTrustedProxies(MustParseTrustedProxies("10.0.0.0/8"))},
+		"Testing header from untrusted peer", ErrInsecureTransport, false},
+	{"http://sentry.io/api/1/store/", map[string]string{"X-Forwarded-Proto": "https"}, "10.1.2.3:4000",
+		[]Option{WithTLSPolicy(TLSReject), WithTrustedProxies(MustParseTrustedProxies("10.0.0.0/8"))},
+		"Testing header from trusted peer", nil, false},
+}
+
+func TestTLSPolicy(t *testing.T) {
+	for _, test := range testTableTLS {
+		r := httptest.NewRequest("POST", test.url+"?sentry_key="+testKeyA, nil)
+		if r.URL.Scheme == "http" {
+			r.TLS = nil
+		} else {
+			r.TLS = &tls.ConnectionState{}
+		}
+		for k, v := range test.headers {
+			r.Header.Set(k, v)
+		}
+		if len(test.remote) > 0 {
+			r.RemoteAddr = test.remote
+		}
+		var buf bytes.Buffer
+		res, err := ParseRequest(r.Context(), r, append(test.opts, WithLogger(log.New(&buf, "", 0)))...)
+		if err != test.err {
+			t.Errorf("%s: Expected -- %v -- Got %v", test.description, test.err, err)
+			continue
+		}
+		warned := err == nil && len(res.Warnings) == 1 && res.Warnings[0].Code == WarningInsecureTransport
+		if warned != test.warned || (buf.Len() > 0) != test.warned {
+			t.Errorf("%s: Expected -- warning %v -- Got %v %q", test.description, test.warned, res.Warnings, buf.String())
+		}
+	}
+}
+
+func TestValidateRequireTLS(t *testing.T) {
+	results := ValidateAll([]string{"https://" + testKeyA + "@sentry.io/1", "http://" + testKeyA + "@localhost:9000/1"}, WithRequireTLS())
+	if !results[0].Valid || !errors.Is(results[1].Err, ErrInsecureScheme) || results[1].Code != "insecure_scheme" {
+		t.Errorf("Expected -- https valid, http rejected -- Got %+v", results)
+	}
+}
@@ -0,0 +1,134 @@
+package dsn
+
+import (
+	"fmt"
+	"net/http"
+	"strings"
+)
+
+// TraceStep is one decision of the parse, e.g. {"credentials", "header: no sentry_key, skipped"}.
+type TraceStep struct {
+	Stage   string `json:"stage"` //method, tls, credentials, conflicts, path, key, tenant, signature, dsn, warning, result
+	Message string `json:"message"`
+}
+
+// Trace is the ordered list of decisions ParseRequest took, see WithTrace. It marshals to a JSON array.
+type Trace []TraceStep
+
+func (t Trace) String() string {
+	/*
+		One "stage: message" line per step, for logs and bug reports.
+	*/
+	var b strings.Builder
+	for _, s := range t {
+		b.WriteString(s.Stage)
+		b.WriteString(": ")
+		b.WriteString(s.Message)
+		b.WriteString("\n")
+	}
+	return b.String()
+}
+
+// TraceError is returned instead of the parse error WithTrace, carrying the decisions up to the failure.
+// errors.Is and ErrorCode see through it.
+type TraceError struct {
+	Err   error
+	Trace Trace
+}
+
+func (e *TraceError) Error() string {
+	return e.Err.Error()
+}
+
+func (e *TraceError) Unwrap() error {
+	return e.Err
+}
+
+func WithTrace() Option {
+	/*
+		Records every decision in ParseResult.Trace: which credential sources were tried and why they were
+		skipped, which endpoint the path matched, what the resolver and registry said. Failed parses return
+		a *TraceError and the middleware sends their trace to the Logger. Secret keys are left out.
+		Meant for debugging sessions with SDK authors, it allocates on every request.
+	*/
+	return func(c *config) {
+		c.trace = true
+	}
+}
+
+func (c *config) tracef(res *ParseResult, stage string, format string, v ...interface{}) {
+	/*
+		Callers passing anything but constants check c.trace first, boxing the arguments would allocate.
+	*/
+	if c.trace {
+		res.Trace = append(res.Trace, TraceStep{stage, fmt.Sprintf(format, v...)})
+	}
+}
+
+func (c *config) traceSignature(res *ParseResult, auth *Auth, err error) {
+	switch {
+	case err != nil:
+		c.tracef(res, "signature", "rejected: %v", err)
+	case len(auth.Signature) == 0:
+		c.tracef(res, "signature", "no sentry_signature, not required")
+	default:
+		c.tracef(res, "signature", "verified")
+	}
+}
+
+func (c *config) traceSource(r *http.Request, res *ParseResult, s Source, found Auth, ok bool) {
+	/*
+		Why credential source s did or did not supply a key.
+	*/
+	if ok {
+		msg := "sentry_key " + redactKey(found.PublicKey) + " found"
+		if len(found.SecretKey) > 0 {
+			msg += " with sentry_secret"
+		}
+		c.tracef(res, "credentials", "%s: %s", s, msg)
+		return
+	}
+	if s != SourceHeader {
+		c.tracef(res, "credentials", "%s: no valid sentry_key, skipped", s)
+		return
+	}
+	name := c.authHeader
+	if len(name) == 0 {
+		name = HTTP_X_SENTRY_AUTH
+	}
+	switch h := c.authHeaderValues(r.Header); {
+	case len(h) == 0:
+		c.tracef(res, "credentials", "%s: no %s header, skipped", s, name)
+	case !strings.Contains(h[0], "sentry_key"):
+		c.tracef(res, "credentials", "%s: %s has no sentry_key, skipped", s, name)
+	case len(h[0]) < 7 || !strings.EqualFold(h[0][:7], "sentry "):
+		c.tracef(res, "credentials", "%s: %s missing \"Sentry \" prefix and no valid sentry_key, skipped", s, name)
+	default:
+		c.tracef(res, "credentials", "%s: sentry_key in %s is not a 32 character hex key, skipped", s, name)
+	}
+}
+
+func (c *config) finishTrace(res *ParseResult, err *error) {
+	/*
+		Deferred by parseRequest WithTrace: records the outcome and wraps a failure in a *TraceError.
+	*/
+	if *err == nil {
+		c.tracef(res, "result", "accepted %s", res.DSN.Redacted())
+		return
+	}
+	c.tracef(res, "result", "rejected: %v (%s)", *err, ErrorCode(*err))
+	*err = &TraceError{Err: *err, Trace: res.Trace}
+}
+
+func (c *config) tracePath(res *ParseResult, path string, projectID string, slug string, endpoint Endpoint, err error) {
+	switch {
+	case err != nil:
+		c.tracef(res, "path", "%s not recognized: %v", path, err)
+	case len(projectID) == 0 && len(slug) > 0:
+		c.tracef(res, "path", "%s matched %s endpoint, project slug %s", path, endpoint, slug)
+	case len(projectID) == 0:
+		c.tracef(res, "path", "%s matched legacy %s endpoint without project ID", path, endpoint)
+	default:
+		c.tracef(res, "path", "%s matched %s endpoint, project %s", path, endpoint, projectID)
+	}
+}
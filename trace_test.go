@@ -0,0 +1,108 @@
+package dsn
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"log"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+type testTrace struct {
+	header      string
+	target      string
+	opts        []Option
+	description string
+	expected    []string //substrings of Trace.String(), in order
+	err         error
+}
+
+var testTableTrace = []testTrace{
+	{"Sentry sentry_key=" + testKeyA, "/api/1/envelope/", nil, "Testing header credentials",
+		[]string{"method: POST /api/1/envelope/", "credentials: header: sentry_key 4784*** found", "path: /api/1/envelope/ matched envelope endpoint, project 1", "result: accepted https://" + testKeyA + "@sentry.io/1"}, nil},
+	{"sentry_key=" + testKeyA + "X", "/api/1/store/?sentry_key=" + testKeyA + "&sentry_secret=" + testKeyB, nil, "Testing fallback to the query string",
+		[]string{"header: X-Sentry-Auth missing \"Sentry \" prefix and no valid sentry_key, skipped", "query: sentry_key 4784*** found with sentry_secret", "warning: secret_in_query"}, nil},
+	{"", "/api/1/store/", nil, "Testing missing credentials",
+		[]string{"header: no X-Sentry-Auth header, skipped", "query: no valid sentry_key, skipped", "result: rejected: sentry:  missing public key (missing_public_key)"}, ErrMissingUser},
+	{"Sentry sentry_version=7", "/api/1/store/?sentry_key=" + testKeyA, []Option{WithCredentialMerge()}, "Testing merged sources",
+		[]string{"header: X-Sentry-Auth has no sentry_key, skipped", "merged: sentry_key from query, sentry_secret from none"}, nil},
+	{"Sentry sentry_key=" + testKeyA, "/api/store/", nil, "Testing legacy endpoint",
+		[]string{"/api/store/ matched legacy store endpoint without project ID", "warning: legacy_endpoint"}, nil},
+	{"Sentry sentry_key=" + testKeyA, "/api/nope/store/", nil, "Testing unknown path",
+		[]string{"path: /api/nope/store/ not recognized", "result: rejected"}, ErrMissingProjectID},
+	{"Sentry sentry_key=" + testKeyA, "/api/1/store/?sentry_key=" + testKeyB, []Option{WithConflictPolicy(ConflictPreferQuery)}, "Testing conflict resolution",
+		[]string{"conflicts: sentry_key aaaa*** taken from query"}, nil},
+	{"Sentry sentry_key=" + testKeyA, "/api/1/store/", []Option{WithSignatureVerification(false)}, "Testing unsigned request",
+		[]string{"signature: no sentry_signature, not required", "result: accepted"}, nil},
+	{"Sentry sentry_key=" + testKeyA, "/api/1/store/", []Option{WithSignatureVerification(true)}, "Testing missing signature",
+		[]string{"signature: rejected: " + ErrMissingSignature.Error(), "result: rejected"}, ErrMissingSignature},
+	{testSignedHeader, "/api/1/store/", []Option{WithSignatureVerification(false)}, "Testing signature without a stored secret",
+		[]string{"signature: rejected: " + ErrInvalidSignature.Error(), "result: rejected"}, ErrInvalidSignature},
+	{testSignedHeader, "/api/1/store/", []Option{WithSignatureVerification(false), WithKeyResolver(testSecretResolver)}, "Testing verified signature",
+		[]string{"signature: verified", "result: accepted"}, nil},
+}
+
+// testSignedHeader signs an empty body with testSecret, which testSecretResolver stores for every key.
+var testSignedHeader = "Sentry sentry_key=" + testKeyA + ", sentry_timestamp=1614144877, sentry_signature=" + Signature(testSecret, "1614144877", nil)
+
+var testSecretResolver = KeyResolverFunc(func(ctx context.Context, publicKey string) (*KeyInfo, error) {
+	return &KeyInfo{PublicKey: publicKey, SecretKey: testSecret, ProjectID: "1"}, nil
+})
+
+func TestTrace(t *testing.T) {
+	for _, test := range testTableTrace {
+		r := httptest.NewRequest(http.MethodPost, "https://sentry.io"+test.target, nil)
+		if len(test.header) > 0 {
+			r.Header.Set("X-Sentry-Auth", test.header)
+		}
+		res, err := ParseRequest(context.Background(), r, append(test.opts, WithTrace())...)
+		var trace Trace
+		var terr *TraceError
+		switch {
+		case errors.As(err, &terr):
+			trace = terr.Trace
+		case err == nil:
+			trace = res.Trace
+		}
+		if !errors.Is(err, test.err) || (err == nil) != (test.err == nil) {
+			t.Errorf("%s: Expected -- %v -- Got %v", test.description, test.err, err)
+		}
+		text := trace.String()
+		for _, s := range test.expected {
+			i := strings.Index(text, s)
+			if i < 0 {
+				t.Errorf("%s: Expected -- %q in trace -- Got %s", test.description, s, text)
+				break
+			}
+			text = text[i+len(s):]
+		}
+	}
+}
+
+func TestTraceOff(t *testing.T) {
+	r := httptest.NewRequest(http.MethodPost, "https://sentry.io/api/1/store/", nil)
+	res, err := ParseRequest(context.Background(), r)
+	if res != nil || err != ErrMissingUser {
+		t.Errorf("Expected -- unwrapped %v -- Got %v", ErrMissingUser, err)
+	}
+	r.Header.Set("X-Sentry-Auth", "Sentry sentry_key="+testKeyA)
+	if res, _ := ParseRequest(context.Background(), r); res.Trace != nil {
+		t.Errorf("Expected -- no trace -- Got %s", res.Trace)
+	}
+}
+
+func TestTraceMiddleware(t *testing.T) {
+	var logged bytes.Buffer
+	h := NewParser(WithTrace(), WithLogger(log.New(&logged, "", 0))).Middleware(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {}))
+	w := httptest.NewRecorder()
+	h.ServeHTTP(w, httptest.NewRequest(http.MethodPost, "https://sentry.io/api/1/store/", nil))
+	if w.Code != http.StatusUnauthorized || !strings.Contains(logged.String(), "credentials: header: no X-Sentry-Auth header") {
+		t.Errorf("Expected -- rejection with logged trace -- Got %d %q", w.Code, logged.String())
+	}
+	if ErrorCode(&TraceError{Err: ErrMissingUser}) != "missing_public_key" {
+		t.Errorf("Expected -- ErrorCode through TraceError -- Got %s", ErrorCode(&TraceError{Err: ErrMissingUser}))
+	}
+}
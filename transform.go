@@ -0,0 +1,190 @@
+package dsn
+
+import (
+	"encoding/json"
+	"net/http"
+	"time"
+)
+
+// Transformer rewrites a submission on its way to upstream, the DSN it is forwarded to. HTTPSink runs its
+// Transformers after routing on a copy of the submission, so whatever else holds it (a Spool) keeps the original.
+type Transformer interface {
+	Transform(s *Submission, upstream *DSN) error
+}
+
+// TransformerFunc adapts a function to Transformer.
+type TransformerFunc func(s *Submission, upstream *DSN) error
+
+func (f TransformerFunc) Transform(s *Submission, upstream *DSN) error {
+	return f(s, upstream)
+}
+
+// FieldsFunc edits the top level fields of a JSON object in place. Values are raw JSON, so fields it leaves
+// alone are forwarded byte for byte.
+type FieldsFunc func(fields map[string]json.RawMessage, upstream *DSN) error
+
+func EnvelopeHeaderTransformer(fn FieldsFunc) Transformer {
+	/*
+		Runs fn on the header line of envelope submissions. Bodies fn did not change are forwarded as received;
+		changed gzip or deflate bodies are forwarded uncompressed. Envelopes whose header is not a JSON object
+		are left for upstream to reject.
+	*/
+	return TransformerFunc(func(s *Submission, upstream *DSN) error {
+		if s.Endpoint != EndpointEnvelope {
+			return nil
+		}
+		return transformBody(s, func(body []byte) ([]byte, error) {
+			line, rest := cutLine(body)
+			header, err := transformFields(line, fn, upstream)
+			if header == nil || err != nil {
+				return nil, err
+			}
+			return append(append(header, '\n'), rest...), nil
+		})
+	})
+}
+
+func StorePayloadTransformer(fn FieldsFunc) Transformer {
+	/*
+		Runs fn on the event of store submissions, like EnvelopeHeaderTransformer. Payloads that are not a JSON
+		object (base64 encoded events of old SDKs) are forwarded as received.
+	*/
+	return TransformerFunc(func(s *Submission, upstream *DSN) error {
+		if s.Endpoint != EndpointStore && len(s.Endpoint) > 0 {
+			return nil
+		}
+		return transformBody(s, func(body []byte) ([]byte, error) {
+			return transformFields(body, fn, upstream)
+		})
+	})
+}
+
+// DSNRewriteTransformer replaces the dsn of envelope headers with the upstream DSN, secret key left out.
+// Tunneled envelopes name the inbound DSN, which upstream would reject or attribute to the wrong project.
+var DSNRewriteTransformer = EnvelopeHeaderTransformer(func(header map[string]json.RawMessage, upstream *DSN) error {
+	if _, ok := header["dsn"]; !ok {
+		return nil
+	}
+	return setPublicDSN(header, upstream)
+})
+
+func setPublicDSN(header map[string]json.RawMessage, upstream *DSN) error {
+	public := upstream.Clone()
+	public.SecretKey = ""
+	v, err := json.Marshal(public.String())
+	if err != nil {
+		return err
+	}
+	header["dsn"] = v
+	return nil
+}
+
+// sentAtLayout is how Sentry SDKs write sent_at, RFC 3339 with microseconds in UTC.
+const sentAtLayout = "2006-01-02T15:04:05.000000Z07:00"
+
+func NewEnvelopeNormalizer(opts ...Option) Transformer {
+	/*
+		Normalizes envelope headers the way Relay does before forwarding. A dsn that parses is replaced with
+		the upstream DSN, secret key left out, and one that does not is removed. sent_at is set to now when
+		missing or unparseable; otherwise it is moved forward by the time the submission spent in this relay
+		(queue, retries, spool), so upstream clock drift correction measures the SDK's clock, not the delay.
+		WithClock applies.
+	*/
+	c := newConfig(opts)
+	return TransformerFunc(func(s *Submission, upstream *DSN) error {
+		now := c.now().UTC()
+		return EnvelopeHeaderTransformer(func(header map[string]json.RawMessage, upstream *DSN) error {
+			return normalizeEnvelopeHeader(header, upstream, s.Received, now)
+		}).Transform(s, upstream)
+	})
+}
+
+func normalizeEnvelopeHeader(header map[string]json.RawMessage, upstream *DSN, received time.Time, now time.Time) error {
+	if raw, ok := header["dsn"]; ok {
+		var old string
+		if json.Unmarshal(raw, &old) != nil {
+			delete(header, "dsn")
+		} else if _, err := Parse(old); err != nil {
+			delete(header, "dsn")
+		} else if err := setPublicDSN(header, upstream); err != nil {
+			return err
+		}
+	}
+	sentAt := now
+	var raw string
+	if json.Unmarshal(header["sent_at"], &raw) == nil {
+		if t, err := time.Parse(time.RFC3339Nano, raw); err == nil {
+			sentAt = t.UTC()
+			if !received.IsZero() && now.After(received) {
+				sentAt = sentAt.Add(now.Sub(received))
+			}
+		}
+	}
+	v, err := json.Marshal(sentAt.Format(sentAtLayout))
+	if err != nil {
+		return err
+	}
+	header["sent_at"] = v
+	return nil
+}
+
+func transformFields(object []byte, fn FieldsFunc, upstream *DSN) ([]byte, error) {
+	/*
+		object with fn applied, nil when it is not a JSON object or fn changed nothing.
+	*/
+	var fields map[string]json.RawMessage
+	if err := json.Unmarshal(object, &fields); err != nil || fields == nil {
+		return nil, nil
+	}
+	before := make(map[string]string, len(fields))
+	for k, v := range fields {
+		before[k] = string(v)
+	}
+	if err := fn(fields, upstream); err != nil {
+		return nil, err
+	}
+	changed := len(before) != len(fields)
+	for k, v := range fields {
+		if old, ok := before[k]; !ok || old != string(v) {
+			changed = true
+		}
+	}
+	if !changed {
+		return nil, nil
+	}
+	return json.Marshal(fields)
+}
+
+func transformBody(s *Submission, fn func(body []byte) ([]byte, error)) error {
+	/*
+		Runs fn on the decoded body of s. When fn returns a new body it replaces s.Body, uncompressed.
+	*/
+	body, err := decodeBounded(s.Headers.Get("Content-Encoding"), s.Body, s.Endpoint)
+	if err != nil {
+		return err
+	}
+	out, err := fn(body)
+	if out == nil || err != nil {
+		return err
+	}
+	s.Body = out
+	s.Headers.Del("Content-Encoding")
+	return nil
+}
+
+func transform(s *Submission, upstream *DSN, transformers []Transformer) (*Submission, error) {
+	/*
+		Copy of s with every transformer applied in order.
+	*/
+	c := *s
+	c.Headers = s.Headers.Clone()
+	if c.Headers == nil {
+		c.Headers = http.Header{}
+	}
+	for _, t := range transformers {
+		if err := t.Transform(&c, upstream); err != nil {
+			return nil, err
+		}
+	}
+	return &c, nil
+}
@@ -0,0 +1,137 @@
+package dsn
+
+import (
+	"bytes"
+	"compress/gzip"
+	"context"
+	"encoding/json"
+	"errors"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+)
+
+type testTransform struct {
+	transformer Transformer
+	endpoint    Endpoint
+	body        string
+	description string
+	expected    string
+	err         error
+}
+
+var testTransformUpstream = &DSN{Scheme: "https", Host: "upstream.example.com", PublicKey: testKeyB, SecretKey: testKeyB, ProjectID: "42"}
+
+var testTableTransform = []testTransform{
+	{DSNRewriteTransformer, EndpointEnvelope, `{"event_id":"9ec79c33ec9942ab8353589fcb2e04dc","dsn":"https://` + testKeyA + `@sentry.io/1"}` + "\n" + `{"type":"event"}` + "\n{}\n",
+		"Testing envelope DSN rewritten", `{"dsn":"https://` + testKeyB + `@upstream.example.com/42","event_id":"9ec79c33ec9942ab8353589fcb2e04dc"}` + "\n" + `{"type":"event"}` + "\n{}\n", nil},
+	{DSNRewriteTransformer, EndpointEnvelope, `{"event_id":"9ec79c33ec9942ab8353589fcb2e04dc"}` + "\n{}\n",
+		"Testing envelope without DSN", `{"event_id":"9ec79c33ec9942ab8353589fcb2e04dc"}` + "\n{}\n", nil},
+	{DSNRewriteTransformer, EndpointEnvelope, "not json\n{}\n", "Testing invalid envelope left alone", "not json\n{}\n", nil},
+	{DSNRewriteTransformer, EndpointStore, `{"dsn":"x"}`, "Testing store untouched by envelope transformer", `{"dsn":"x"}`, nil},
+	{StorePayloadTransformer(func(f map[string]json.RawMessage, up *DSN) error {
+		f["environment"] = json.RawMessage(`"relayed"`)
+		delete(f, "server_name")
+		return nil
+	}), EndpointStore, `{"server_name":"db1","message":"hi"}`, "Testing store payload fields", `{"environment":"relayed","message":"hi"}`, nil},
+	{StorePayloadTransformer(func(f map[string]json.RawMessage, up *DSN) error { return errors.New("boom") }),
+		EndpointStore, `{}`, "Testing transformer error", `{}`, errors.New("boom")},
+}
+
+func TestTransformers(t *testing.T) {
+	for _, test := range testTableTransform {
+		s := &Submission{Endpoint: test.endpoint, Body: []byte(test.body)}
+		got, err := transform(s, testTransformUpstream, []Transformer{test.transformer})
+		if (err != nil) != (test.err != nil) {
+			t.Errorf("%s: Expected -- %v -- Got %v", test.description, test.err, err)
+			continue
+		}
+		if err == nil && string(got.Body) != test.expected {
+			t.Errorf("%s: Expected -- %s -- Got %s", test.description, test.expected, got.Body)
+		}
+		if string(s.Body) != test.body {
+			t.Errorf("%s: Expected -- original submission untouched -- Got %s", test.description, s.Body)
+		}
+	}
+}
+
+type testEnvelopeNormalizer struct {
+	header      string
+	received    time.Time
+	description string
+	expected    string
+}
+
+var testTableEnvelopeNormalizer = []testEnvelopeNormalizer{
+	{`{"event_id":"9ec79c33ec9942ab8353589fcb2e04dc"}`, time.Time{}, "Testing sent_at injected",
+		`{"event_id":"9ec79c33ec9942ab8353589fcb2e04dc","sent_at":"2020-09-13T12:26:40.000000Z"}`},
+	{`{"sent_at":"2020-09-13T12:26:00.5Z"}`, time.Unix(1600000000, 0).Add(-10 * time.Second), "Testing sent_at moved by the relay delay",
+		`{"sent_at":"2020-09-13T12:26:10.500000Z"}`},
+	{`{"sent_at":"2020-09-13T14:26:00+02:00"}`, time.Time{}, "Testing sent_at kept without receive time",
+		`{"sent_at":"2020-09-13T12:26:00.000000Z"}`},
+	{`{"sent_at":"yesterday"}`, time.Time{}, "Testing unparseable sent_at replaced", `{"sent_at":"2020-09-13T12:26:40.000000Z"}`},
+	{`{"dsn":"https://` + testKeyA + `@sentry.io/1","sent_at":"2020-09-13T12:26:40.000000Z"}`, time.Time{}, "Testing dsn replaced",
+		`{"dsn":"https://` + testKeyB + `@upstream.example.com/42","sent_at":"2020-09-13T12:26:40.000000Z"}`},
+	{`{"dsn":"not a dsn","sent_at":"2020-09-13T12:26:40.000000Z"}`, time.Time{}, "Testing stale dsn removed", `{"sent_at":"2020-09-13T12:26:40.000000Z"}`},
+	{`{"dsn":1}`, time.Time{}, "Testing non string dsn removed", `{"sent_at":"2020-09-13T12:26:40.000000Z"}`},
+}
+
+func TestEnvelopeNormalizer(t *testing.T) {
+	now := time.Unix(1600000000, 0)
+	normalizer := NewEnvelopeNormalizer(WithClock(ClockFunc(func() time.Time { return now })))
+	for _, test := range testTableEnvelopeNormalizer {
+		s := &Submission{Endpoint: EndpointEnvelope, Body: []byte(test.header + "\n{}\n"), Received: test.received}
+		got, err := transform(s, testTransformUpstream, []Transformer{normalizer})
+		if err != nil || string(got.Body) != test.expected+"\n{}\n" {
+			t.Errorf("%s: Expected -- %s -- Got %s %v", test.description, test.expected, got.Body, err)
+		}
+	}
+	s := &Submission{Endpoint: EndpointStore, Body: []byte(`{}`)}
+	if got, _ := transform(s, testTransformUpstream, []Transformer{normalizer}); string(got.Body) != `{}` {
+		t.Errorf("Expected -- store untouched -- Got %s", got.Body)
+	}
+}
+
+func TestTransformCompressed(t *testing.T) {
+	var gz bytes.Buffer
+	w := gzip.NewWriter(&gz)
+	w.Write([]byte(`{"dsn":"https://` + testKeyA + `@sentry.io/1"}` + "\n{}\n"))
+	w.Close()
+	s := &Submission{Endpoint: EndpointEnvelope, Headers: http.Header{"Content-Encoding": {"gzip"}}, Body: gz.Bytes()}
+	got, err := transform(s, testTransformUpstream, []Transformer{DSNRewriteTransformer})
+	if err != nil || got.Headers.Get("Content-Encoding") != "" || !strings.Contains(string(got.Body), "upstream.example.com") {
+		t.Errorf("Expected -- rewritten and uncompressed -- Got %q %v %v", got.Body, got.Headers, err)
+	}
+	if s.Headers.Get("Content-Encoding") != "gzip" {
+		t.Errorf("Expected -- original headers untouched -- Got %v", s.Headers)
+	}
+	// unchanged bodies stay compressed
+	up, _ := Parse("https://" + testKeyA + "@sentry.io/1")
+	if got, _ := transform(s, up, []Transformer{DSNRewriteTransformer}); got.Headers.Get("Content-Encoding") != "gzip" || !bytes.Equal(got.Body, s.Body) {
+		t.Errorf("Expected -- unchanged body forwarded as received -- Got %v", got.Headers)
+	}
+}
+
+func TestHTTPSinkTransformers(t *testing.T) {
+	var body string
+	upstream := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		b, _ := ioutil.ReadAll(r.Body)
+		body = string(b)
+	}))
+	defer upstream.Close()
+	up, _ := Parse(strings.Replace(upstream.URL, "://", "://"+testKeyB+"@", 1) + "/42")
+	in, _ := Parse("https://" + testKeyA + "@sentry.io/1")
+	rt := NewRouter()
+	rt.SetDefault(up)
+	sink := &HTTPSink{Client: upstream.Client(), Router: rt, Transformers: []Transformer{DSNRewriteTransformer}}
+	s := &Submission{DSN: in, Endpoint: EndpointEnvelope, Body: []byte(`{"dsn":"` + in.String() + `"}` + "\n{}\n")}
+	if err := sink.Send(context.Background(), s); err != nil {
+		t.Fatal(err)
+	}
+	if !strings.HasPrefix(body, `{"dsn":"`+up.String()+`"}`) {
+		t.Errorf("Expected -- upstream DSN in the envelope header -- Got %s", body)
+	}
+}
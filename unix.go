@@ -0,0 +1,137 @@
+package dsn
+
+import (
+	"context"
+	"errors"
+	"net"
+	"net/http"
+	"net/url"
+	"path/filepath"
+	"strings"
+	"sync"
+	"time"
+)
+
+// SchemeHTTPUnix is the scheme of upstream DSNs reached over a unix domain socket, e.g.
+// http+unix://key@sentry/1?socket=/run/sentry/ingest.sock. The host is only sent as the Host header.
+// Parse refuses it since the socket comes from the DSN string; ParseUpstream accepts sockets in the
+// directories given to WithUnixSockets, and DSNs built in code (WithSocket, RewriteTarget) are trusted.
+const SchemeHTTPUnix = "http+unix"
+
+// socketOption is the query parameter carrying DSN.Socket in DSN strings.
+const socketOption = "socket"
+
+var (
+	// ErrMissingSocket Thrown when an http+unix DSN does not name its socket
+	ErrMissingSocket = errors.New("sentry:  missing unix socket path")
+	// ErrSocketNotAllowed Thrown when an http+unix DSN string names a socket outside the WithUnixSockets directories
+	ErrSocketNotAllowed = errors.New("sentry:  unix socket not allowed")
+)
+
+func WithUnixSockets(allowedDirs ...string) Option {
+	/*
+		Lets ParseUpstream, Registry tenants and Config upstreams use http+unix DSNs whose socket lies
+		below one of allowedDirs. Without it, and for relative or unclean socket paths, they fail with ErrSocketNotAllowed.
+	*/
+	return func(c *config) {
+		c.unixSocketDirs = nil
+		for _, dir := range allowedDirs {
+			c.unixSocketDirs = append(c.unixSocketDirs, filepath.Clean(dir))
+		}
+	}
+}
+
+func ParseUpstream(s string, opts ...Option) (*DSN, error) {
+	/*
+		Parse for upstream DSNs from configuration, which may be http+unix if WithUnixSockets allows the socket.
+	*/
+	return parseDSN(s, newConfig(opts).unixSocketDirs)
+}
+
+func allowSocket(path string, dirs []string) error {
+	if !filepath.IsAbs(path) || filepath.Clean(path) != path {
+		return ErrSocketNotAllowed
+	}
+	for _, dir := range dirs {
+		if strings.HasPrefix(path, strings.TrimSuffix(dir, string(filepath.Separator))+string(filepath.Separator)) {
+			return nil
+		}
+	}
+	return ErrSocketNotAllowed
+}
+
+func WithSocket(path string) BuildOption {
+	/*
+		Forwards over the unix domain socket at path, switching the scheme to http+unix.
+	*/
+	return func(d *DSN) {
+		d.Scheme = SchemeHTTPUnix
+		d.Socket = path
+	}
+}
+
+func (d *DSN) query() string {
+	/*
+		Options plus the socket of http+unix DSNs, encoded.
+	*/
+	if len(d.Socket) == 0 {
+		return d.Options.Encode()
+	}
+	q := url.Values{socketOption: {d.Socket}}
+	for k, v := range d.Options {
+		q[k] = v
+	}
+	return q.Encode()
+}
+
+func (d *DSN) transportBase() string {
+	/*
+		EndpointBase as dialed: http+unix DSNs speak plain HTTP over their socket.
+	*/
+	if d.Scheme == SchemeHTTPUnix {
+		base := *d
+		base.Scheme = "http"
+		return base.EndpointBase()
+	}
+	return d.EndpointBase()
+}
+
+// maxUnixTransports bounds unixTransports; past it a transport is evicted and its idle connections closed.
+const maxUnixTransports = 64
+
+// unixTransports holds one *http.Transport per socket path. Connection pools are keyed by host,
+// so sockets serving the same logical host must not share a transport.
+var unixTransports = struct {
+	sync.Mutex
+	m map[string]*http.Transport
+}{m: map[string]*http.Transport{}}
+
+func unixClient(c *http.Client, socket string) *http.Client {
+	/*
+		Copy of c dialing socket instead of the request host. c's own Transport does not apply.
+	*/
+	unixTransports.Lock()
+	t, ok := unixTransports.m[socket]
+	if !ok {
+		if len(unixTransports.m) >= maxUnixTransports {
+			for path, old := range unixTransports.m {
+				old.CloseIdleConnections()
+				delete(unixTransports.m, path)
+				break
+			}
+		}
+		dialer := &net.Dialer{Timeout: 30 * time.Second}
+		t = &http.Transport{
+			DialContext: func(ctx context.Context, _, _ string) (net.Conn, error) {
+				return dialer.DialContext(ctx, "unix", socket)
+			},
+			MaxIdleConnsPerHost: 100,
+			IdleConnTimeout:     90 * time.Second,
+		}
+		unixTransports.m[socket] = t
+	}
+	unixTransports.Unlock()
+	u := *c
+	u.Transport = t
+	return &u
+}
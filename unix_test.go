@@ -0,0 +1,133 @@
+package dsn
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"net"
+	"net/http"
+	"path/filepath"
+	"testing"
+)
+
+type testUnixDSN struct {
+	dsn         string
+	description string
+	socket      string
+	expected    string
+	err         error
+}
+
+var testTableUnixDSN = []testUnixDSN{
+	{"http+unix://" + testKeyA + "@sentry/1?socket=/run/sentry.sock", "Testing unix socket DSN", "/run/sentry.sock",
+		"http+unix://" + testKeyA + "@sentry/1?socket=%2Frun%2Fsentry.sock", nil},
+	{"http+unix://" + testKeyA + "@sentry/1?socket=/run/sentry.sock&region=eu", "Testing options kept apart from the socket", "/run/sentry.sock",
+		"http+unix://" + testKeyA + "@sentry/1?region=eu&socket=%2Frun%2Fsentry.sock", nil},
+	{"http+unix://" + testKeyA + "@sentry/1", "Testing missing socket", "", "", ErrMissingSocket},
+	{"ftp+unix://" + testKeyA + "@sentry/1?socket=/run/sentry.sock", "Testing other unix scheme", "", "", ErrInvalidScheme},
+	{"http+unix://" + testKeyA + "@sentry/1?socket=/var/run/docker.sock", "Testing socket outside the allowed dirs", "", "", ErrSocketNotAllowed},
+	{"http+unix://" + testKeyA + "@sentry/1?socket=/run/../var/run/docker.sock", "Testing escape from the allowed dirs", "", "", ErrSocketNotAllowed},
+	{"http+unix://" + testKeyA + "@sentry/1?socket=run/sentry.sock", "Testing relative socket", "", "", ErrSocketNotAllowed},
+	{"http+unix://" + testKeyA + "@sentry/1?socket=/run", "Testing the allowed dir itself", "", "", ErrSocketNotAllowed},
+}
+
+func TestParseUnixDSN(t *testing.T) {
+	for _, test := range testTableUnixDSN {
+		d, err := ParseUpstream(test.dsn, WithUnixSockets("/run/"))
+		if err != test.err {
+			t.Errorf("%s: Expected -- %v -- Got %v", test.description, test.err, err)
+			continue
+		}
+		if err != nil {
+			continue
+		}
+		if d.Socket != test.socket || d.String() != test.expected || d.Options.Get(socketOption) != "" {
+			t.Errorf("%s: Expected -- %s %s -- Got %s %+v", test.description, test.socket, test.expected, d, d)
+		}
+		if again, _ := ParseUpstream(d.String(), WithUnixSockets("/run")); again == nil || again.Socket != d.Socket {
+			t.Errorf("%s: Expected -- round trip -- Got %+v", test.description, again)
+		}
+		// the socket of a plain Parse comes from whoever wrote the string
+		if _, err := Parse(test.dsn); err != ErrSocketNotAllowed {
+			t.Errorf("%s: Expected -- %v from Parse -- Got %v", test.description, ErrSocketNotAllowed, err)
+		}
+	}
+}
+
+func TestRegistryUnixUpstream(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "tenants.json")
+	ioutil.WriteFile(path, []byte(`{"tenants": [{"public_key": "`+testKeyA+`", "upstream": "http+unix://`+testKeyA+`@sentry/1?socket=/run/sentry.sock"}]}`), 0o600)
+	if _, err := LoadRegistry(path); !errors.Is(err, ErrSocketNotAllowed) {
+		t.Errorf("Expected -- %v -- Got %v", ErrSocketNotAllowed, err)
+	}
+	reg, err := LoadRegistry(path, WithUnixSockets("/run"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	tenant, _ := reg.Lookup(testKeyA)
+	if tenant == nil || tenant.UpstreamDSN().Socket != "/run/sentry.sock" {
+		t.Fatalf("Expected -- unix upstream -- Got %+v", tenant)
+	}
+	// results carrying the tenant decode without the reader opting into sockets itself
+	res := &ParseResult{DSN: &DSN{PublicKey: testKeyA, Host: "sentry.io", ProjectID: "1"}, Tenant: tenant}
+	b, _ := res.MarshalBinary()
+	j, _ := res.MarshalJSON()
+	var fromBinary, fromJSON ParseResult
+	if err := fromBinary.UnmarshalBinary(b); err != nil || fromBinary.Tenant.UpstreamDSN().Socket != "/run/sentry.sock" {
+		t.Errorf("Testing binary: Expected -- unix upstream -- Got %v", err)
+	}
+	if err := fromJSON.UnmarshalJSON(j); err != nil || fromJSON.Tenant.UpstreamDSN().Socket != "/run/sentry.sock" {
+		t.Errorf("Testing JSON: Expected -- unix upstream -- Got %v", err)
+	}
+}
+
+func TestUnixTransportsBounded(t *testing.T) {
+	for i := 0; i < maxUnixTransports+10; i++ {
+		unixClient(http.DefaultClient, fmt.Sprintf("/run/test-%d.sock", i))
+	}
+	unixTransports.Lock()
+	n := len(unixTransports.m)
+	unixTransports.Unlock()
+	if n > maxUnixTransports {
+		t.Errorf("Expected -- at most %d transports -- Got %d", maxUnixTransports, n)
+	}
+}
+
+func TestNewUnixDSN(t *testing.T) {
+	d, err := New(WithPublicKey(testKeyA), WithHost("sentry"), WithProjectID("1"), WithSocket("/run/sentry.sock"))
+	if err != nil || d.URL != "http+unix://"+testKeyA+"@sentry/1?socket=%2Frun%2Fsentry.sock" {
+		t.Errorf("Expected -- http+unix DSN -- Got %v %v", d, err)
+	}
+	if _, err := New(WithPublicKey(testKeyA), WithHost("sentry"), WithProjectID("1"), WithScheme(SchemeHTTPUnix)); err != ErrMissingSocket {
+		t.Errorf("Expected -- %v -- Got %v", ErrMissingSocket, err)
+	}
+}
+
+func TestHTTPSinkUnixSocket(t *testing.T) {
+	socket := filepath.Join(t.TempDir(), "ingest.sock")
+	l, err := net.Listen("unix", socket)
+	if err != nil {
+		t.Skipf("unix sockets unavailable: %v", err)
+	}
+	var path, host string
+	srv := &http.Server{Handler: http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		path, host = r.URL.Path, r.Host
+	})}
+	go srv.Serve(l)
+	defer srv.Close()
+
+	in, _ := Parse("https://" + testKeyA + "@sentry.io/1")
+	rw := NewRewriter(HostMapRule(map[string]RewriteTarget{"sentry.io": {Host: "ingest", Socket: socket}}))
+	up, _ := rw.Rewrite(in)
+	if up.Scheme != SchemeHTTPUnix || up.Socket != socket {
+		t.Errorf("Expected -- rewritten to the socket -- Got %+v", up)
+	}
+	rt := NewRouter()
+	rt.SetDefault(up)
+	sink := &HTTPSink{Router: rt, Breaker: NewCircuitBreaker(3, 0)}
+	s := &Submission{DSN: in, Endpoint: EndpointEnvelope, Headers: http.Header{}, Body: []byte("{}\n")}
+	if err := sink.Send(context.Background(), s); err != nil || path != "/api/1/envelope/" || host != "ingest" {
+		t.Errorf("Expected -- forwarded over the socket -- Got %s %s %v", path, host, err)
+	}
+}
@@ -0,0 +1,183 @@
+package dsn
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net"
+	"net/http"
+	"runtime"
+	"sync"
+	"time"
+)
+
+var (
+	// ErrHostNotFound Thrown by WithDNSCheck when the DSN host does not resolve
+	ErrHostNotFound = errors.New("sentry:  DSN host does not resolve")
+	// ErrEndpointUnreachable Thrown by WithEndpointCheck when the ingest endpoint does not answer
+	ErrEndpointUnreachable = errors.New("sentry:  DSN ingest endpoint unreachable")
+)
+
+// HostResolver looks up host names. *net.Resolver is one.
+type HostResolver interface {
+	LookupHost(ctx context.Context, host string) ([]string, error)
+}
+
+// ValidationResult is the outcome for one entry of ValidateAll.
+type ValidationResult struct {
+	Index   int    `json:"index"` //position in the input
+	Input   string `json:"input"`
+	Valid   bool   `json:"valid"`
+	DSN     *DSN   `json:"dsn,omitempty"` //also set when only a network check failed
+	Err     error  `json:"-"`
+	Code    string `json:"code,omitempty"`  //ErrorCode of Err
+	Message string `json:"error,omitempty"` //Err as text
+}
+
+// ValidateOption configures ValidateAll.
+type ValidateOption func(*validateConfig)
+
+type validateConfig struct {
+	workers     int
+	resolver    HostResolver
+	client      *http.Client
+	requireTLS  bool
+	dnsTimeout  time.Duration //0 for none
+	httpTimeout time.Duration
+}
+
+func WithValidationWorkers(n int) ValidateOption {
+	/*
+		Validates n entries at a time. The default is GOMAXPROCS.
+	*/
+	return func(c *validateConfig) {
+		c.workers = n
+	}
+}
+
+func WithDNSCheck(resolver HostResolver, timeout time.Duration) ValidateOption {
+	/*
+		Also confirms that the host of every valid DSN resolves, failing with ErrHostNotFound otherwise.
+		A nil resolver uses net.DefaultResolver. IP address hosts are not looked up.
+	*/
+	return func(c *validateConfig) {
+		if resolver == nil {
+			resolver = net.DefaultResolver
+		}
+		c.resolver, c.dnsTimeout = resolver, timeout
+	}
+}
+
+func WithEndpointCheck(client *http.Client, timeout time.Duration) ValidateOption {
+	/*
+		Also sends an OPTIONS request to the envelope endpoint of every valid DSN, failing with
+		ErrEndpointUnreachable when there is no answer or a 5xx one. Any other status counts: the
+		server is there, and credentials are not sent. A nil client uses http.DefaultClient.
+	*/
+	return func(c *validateConfig) {
+		if client == nil {
+			client = http.DefaultClient
+		}
+		c.client, c.httpTimeout = client, timeout
+	}
+}
+
+func Validate(s string, opts ...ValidateOption) ValidationResult {
+	/*
+		ValidateAll for a single DSN.
+	*/
+	c := &validateConfig{}
+	for _, opt := range opts {
+		opt(c)
+	}
+	return c.validate(0, s)
+}
+
+func ValidateAll(dsns []string, opts ...ValidateOption) []ValidationResult {
+	/*
+		Parses every DSN concurrently and reports each one, in input order, for CI checks of large
+		multi-tenant configuration files. Invalid entries do not stop the others.
+	*/
+	c := &validateConfig{workers: runtime.GOMAXPROCS(0)}
+	for _, opt := range opts {
+		opt(c)
+	}
+	if c.workers < 1 {
+		c.workers = 1
+	}
+	results := make([]ValidationResult, len(dsns))
+	jobs := make(chan int)
+	var wg sync.WaitGroup
+	for w := 0; w < c.workers && w < len(dsns); w++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for i := range jobs {
+				results[i] = c.validate(i, dsns[i])
+			}
+		}()
+	}
+	for i := range dsns {
+		jobs <- i
+	}
+	close(jobs)
+	wg.Wait()
+	return results
+}
+
+func (c *validateConfig) validate(i int, s string) ValidationResult {
+	res := ValidationResult{Index: i, Input: s}
+	d, err := Parse(s)
+	if err != nil {
+		res.Err, res.Code, res.Message = err, ErrorCode(err), err.Error()
+		return res
+	}
+	if err := c.check(d); err != nil {
+		res.DSN, res.Err, res.Code, res.Message = d, err, ErrorCode(err), err.Error()
+		return res
+	}
+	res.Valid, res.DSN = true, d
+	return res
+}
+
+func (c *validateConfig) check(d *DSN) error {
+	/*
+		Checks beyond Parse. The network ones are each bounded by their own timeout.
+	*/
+	if c.requireTLS && d.Scheme == "http" {
+		return fmt.Errorf("%w: %s", ErrInsecureScheme, d.EndpointBase())
+	}
+	if c.resolver != nil && net.ParseIP(d.Host) == nil {
+		ctx, cancel := withTimeout(c.dnsTimeout)
+		_, err := c.resolver.LookupHost(ctx, d.Host)
+		cancel()
+		if err != nil {
+			return fmt.Errorf("%w: %s: %v", ErrHostNotFound, d.Host, err)
+		}
+	}
+	if c.client != nil {
+		u, _ := ingestURL(d, EndpointEnvelope)
+		ctx, cancel := withTimeout(c.httpTimeout)
+		defer cancel()
+		req, err := http.NewRequestWithContext(ctx, http.MethodOptions, u, nil)
+		if err != nil {
+			return fmt.Errorf("%w: %s: %v", ErrEndpointUnreachable, u, err)
+		}
+		resp, err := c.client.Do(req)
+		if err != nil {
+			return fmt.Errorf("%w: %s: %v", ErrEndpointUnreachable, u, err)
+		}
+		resp.Body.Close()
+		if resp.StatusCode >= 500 {
+			return fmt.Errorf("%w: %s: %s", ErrEndpointUnreachable, u, resp.Status)
+		}
+	}
+	return nil
+}
+
+func withTimeout(timeout time.Duration) (context.Context, context.CancelFunc) {
+	if timeout > 0 {
+		return context.WithTimeout(context.Background(), timeout)
+	}
+	return context.WithCancel(context.Background())
+}
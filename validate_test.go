@@ -0,0 +1,99 @@
+package dsn
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestValidateAll(t *testing.T) {
+	vectors := TestVectors().DSNs
+	inputs := make([]string, len(vectors))
+	for i, v := range vectors {
+		inputs[i] = v.Input
+	}
+	for _, workers := range []int{0, 1, 3, 100} {
+		results := ValidateAll(inputs, WithValidationWorkers(workers))
+		if len(results) != len(vectors) {
+			t.Fatalf("Testing %d workers: Expected -- %d results -- Got %d", workers, len(vectors), len(results))
+		}
+		for i, res := range results {
+			v := vectors[i]
+			if res.Index != i || res.Input != v.Input || res.Valid != (v.Error == "") || res.Code != v.Error {
+				t.Errorf("Testing %d workers, %s: Expected -- %d %q -- Got %+v", workers, v.Description, i, v.Error, res)
+			}
+			if res.Valid && (res.DSN == nil || res.DSN.URL != v.Expected) {
+				t.Errorf("Testing %d workers, %s: Expected -- %s -- Got %v", workers, v.Description, v.Expected, res.DSN)
+			}
+			if !res.Valid && (res.Err == nil || res.Message != res.Err.Error()) {
+				t.Errorf("Testing %d workers, %s: Expected -- error -- Got %+v", workers, v.Description, res)
+			}
+		}
+	}
+	if results := ValidateAll(nil); len(results) != 0 {
+		t.Errorf("Testing empty input: Expected -- no results -- Got %v", results)
+	}
+}
+
+type testResolver map[string]bool
+
+func (r testResolver) LookupHost(ctx context.Context, host string) ([]string, error) {
+	if !r[host] {
+		return nil, errors.New("no such host")
+	}
+	return []string{"192.0.2.1"}, nil
+}
+
+func TestValidateDNSCheck(t *testing.T) {
+	resolver := testResolver{"sentry.example.com": true}
+	results := ValidateAll([]string{
+		"https://" + testKeyA + "@sentry.example.com/1",
+		"https://" + testKeyA + "@sentry.exmaple.com/1",
+		"https://" + testKeyA + "@192.0.2.7/1",
+	}, WithDNSCheck(resolver, time.Second))
+	if !results[0].Valid || !results[2].Valid {
+		t.Errorf("Expected -- resolvable host and IP valid -- Got %+v %+v", results[0], results[2])
+	}
+	if res := results[1]; res.Valid || !errors.Is(res.Err, ErrHostNotFound) || res.DSN == nil || !strings.Contains(res.Message, "sentry.exmaple.com") {
+		t.Errorf("Expected -- %v naming the host -- Got %+v", ErrHostNotFound, res)
+	}
+}
+
+func TestValidateEndpointCheck(t *testing.T) {
+	var method, path, auth string
+	status := http.StatusOK
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		method, path, auth = r.Method, r.URL.Path, r.Header.Get("X-Sentry-Auth")
+		w.WriteHeader(status)
+	}))
+	d := strings.Replace(srv.URL, "://", "://"+testKeyA+"@", 1) + "/sentry/1"
+	if res := Validate(d, WithEndpointCheck(srv.Client(), time.Second)); !res.Valid {
+		t.Errorf("Expected -- valid -- Got %+v", res)
+	}
+	if method != http.MethodOptions || path != "/sentry/api/1/envelope/" || len(auth) > 0 {
+		t.Errorf("Expected -- OPTIONS /sentry/api/1/envelope/ without credentials -- Got %s %s %s", method, path, auth)
+	}
+	status = http.StatusBadGateway
+	if res := Validate(d, WithEndpointCheck(srv.Client(), time.Second)); !errors.Is(res.Err, ErrEndpointUnreachable) {
+		t.Errorf("Testing 502: Expected -- %v -- Got %+v", ErrEndpointUnreachable, res)
+	}
+	srv.Close()
+	if res := Validate(d, WithEndpointCheck(nil, time.Second)); res.Code != "endpoint_unreachable" {
+		t.Errorf("Testing closed server: Expected -- endpoint_unreachable -- Got %+v", res)
+	}
+}
+
+func BenchmarkValidateAll(b *testing.B) {
+	inputs := make([]string, 1000)
+	for i := range inputs {
+		inputs[i] = fmt.Sprintf("https://%s@o1.ingest.sentry.io/%d", testKeyA, i+1)
+	}
+	for i := 0; i < b.N; i++ {
+		ValidateAll(inputs)
+	}
+}
@@ -0,0 +1,59 @@
+package dsn
+
+import (
+	_ "embed"
+	"encoding/json"
+)
+
+//go:embed vectors.json
+var vectorsJSON []byte
+
+// Vectors is the canonical set of inputs this package accepts and rejects, with the results it gives.
+// Errors are named by their ErrorCode so implementations in other languages can check them too.
+type Vectors struct {
+	DSNs        []DSNVector        `json:"dsns"`
+	AuthHeaders []AuthHeaderVector `json:"auth_headers"`
+	Paths       []PathVector       `json:"paths"`
+}
+
+// DSNVector is a DSN string for Parse and the canonical String() of the result.
+type DSNVector struct {
+	Description string `json:"description"`
+	Input       string `json:"input"`
+	Expected    string `json:"expected,omitempty"`
+	Error       string `json:"error,omitempty"` //ErrorCode, empty for valid input
+}
+
+// AuthHeaderVector is an X-Sentry-Auth value for ParseAuthHeaderValue.
+type AuthHeaderVector struct {
+	Description string `json:"description"`
+	Input       string `json:"input"`
+	Expected    Auth   `json:"expected"`
+	Error       string `json:"error,omitempty"`
+}
+
+// PathVector is a request path for ParsePath.
+type PathVector struct {
+	Description string   `json:"description"`
+	Input       string   `json:"input"`
+	Expected    PathInfo `json:"expected"`
+	Error       string   `json:"error,omitempty"`
+}
+
+func TestVectors() *Vectors {
+	/*
+		Decodes the embedded vectors. Every call returns a fresh copy, so callers may modify it.
+	*/
+	v := &Vectors{}
+	if err := json.Unmarshal(vectorsJSON, v); err != nil {
+		panic("dsn: embedded test vectors: " + err.Error())
+	}
+	return v
+}
+
+func TestVectorsJSON() []byte {
+	/*
+		The vectors as JSON, for implementations outside Go.
+	*/
+	return append([]byte(nil), vectorsJSON...)
+}
@@ -0,0 +1,39 @@
+package dsn
+
+import (
+	"bytes"
+	"encoding/json"
+	"testing"
+)
+
+func TestVectorsWellFormed(t *testing.T) {
+	dec := json.NewDecoder(bytes.NewReader(TestVectorsJSON()))
+	dec.DisallowUnknownFields()
+	var v Vectors
+	if err := dec.Decode(&v); err != nil {
+		t.Fatalf("Expected -- strict decode -- Got %v", err)
+	}
+	known := map[string]bool{"": true}
+	for _, c := range errorCodes {
+		known[c.code] = true
+	}
+	check := func(kind, description, code string) {
+		if !known[code] {
+			t.Errorf("Testing %s %s: Expected -- known error code -- Got %q", kind, description, code)
+		}
+	}
+	for _, d := range v.DSNs {
+		check("dsn", d.Description, d.Error)
+	}
+	for _, a := range v.AuthHeaders {
+		check("auth header", a.Description, a.Error)
+	}
+	for _, p := range v.Paths {
+		check("path", p.Description, p.Error)
+	}
+	mutated := TestVectors()
+	mutated.DSNs[0].Input = ""
+	if TestVectors().DSNs[0].Input == "" {
+		t.Errorf("Expected -- fresh copy per call -- Got shared vectors")
+	}
+}
@@ -0,0 +1,59 @@
+package dsn
+
+import (
+	"math"
+	"net/http"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Codes of the warnings ParseRequest attaches to accepted requests.
+const (
+	WarningLegacyEndpoint   = "legacy_endpoint"    //the legacy /api/store/ without a project ID
+	WarningSecretInQuery    = "secret_in_query"    //sentry_secret in the query string, see WithQuerySecretPolicy
+	WarningStaleTimestamp   = "stale_timestamp"    //sentry_timestamp further than WarningTimestampSkew from now
+	WarningUnknownAuthField = "unknown_auth_field" //sentry_* fields of the auth header this package does not know, see Auth.Extra
+)
+
+// WarningTimestampSkew is how far sentry_timestamp may be from the clock before WarningStaleTimestamp.
+var WarningTimestampSkew = 10 * time.Minute
+
+// Warning is a non-fatal issue with an accepted request, for monitoring traffic that strict options would reject.
+type Warning struct {
+	Code    string `json:"code"`
+	Message string `json:"message"`
+}
+
+func (w Warning) String() string {
+	return w.Code + ": " + w.Message
+}
+
+func (c *config) warnings(r *http.Request, res *ParseResult) {
+	/*
+		Fills res.Warnings. Allocates only when there is something to report.
+	*/
+	if res.Endpoint == EndpointStore && res.DSN != nil && len(res.DSN.ProjectID) == 0 {
+		res.Warnings = append(res.Warnings, Warning{WarningLegacyEndpoint, "legacy /api/store/ endpoint without project ID"})
+	}
+	if strings.Contains(r.URL.RawQuery, "sentry_secret") {
+		if q, _ := parseAuthQuery(r.URL.RawQuery); len(q.SecretKey) > 0 {
+			res.Warnings = append(res.Warnings, Warning{WarningSecretInQuery, "sentry_secret sent in the query string"})
+		}
+	}
+	if ts := res.Auth.Timestamp; len(ts) > 0 {
+		sent, err := strconv.ParseFloat(ts, 64)
+		if err != nil || math.Abs(c.now().Sub(time.Unix(0, int64(sent*1e9))).Seconds()) > WarningTimestampSkew.Seconds() {
+			res.Warnings = append(res.Warnings, Warning{WarningStaleTimestamp, "sentry_timestamp " + ts + " is not current"})
+		}
+	}
+	if extra := res.Auth.extraFields(); len(extra) > 0 {
+		fields := make([]string, 0, len(extra))
+		for f := range extra {
+			fields = append(fields, f)
+		}
+		sort.Strings(fields)
+		res.Warnings = append(res.Warnings, Warning{WarningUnknownAuthField, "unknown auth fields " + strings.Join(fields, ", ")})
+	}
+}
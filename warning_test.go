@@ -0,0 +1,54 @@
+package dsn
+
+import (
+	"net/http/httptest"
+	"reflect"
+	"testing"
+	"time"
+)
+
+type testWarning struct {
+	url         string
+	header      string
+	description string
+	expected    []string
+}
+
+var testTableWarning = []testWarning{
+	{"https://sentry.io/api/1/store/", "Sentry sentry_version=7, sentry_key=" + testKeyA + ", sentry_timestamp=1600000000.5",
+		"Testing clean request", nil},
+	{"https://sentry.io/api/store/", "Sentry sentry_key=" + testKeyA,
+		"Testing legacy endpoint", []string{WarningLegacyEndpoint}},
+	{"https://sentry.io/api/1/store/?sentry_key=" + testKeyA + "&sentry_secret=" + testKeyB, "",
+		"Testing secret in query", []string{WarningSecretInQuery}},
+	{"https://sentry.io/api/1/store/", "Sentry sentry_key=" + testKeyA + ", sentry_timestamp=1599990000",
+		"Testing stale timestamp", []string{WarningStaleTimestamp}},
+	{"https://sentry.io/api/1/store/", "Sentry sentry_key=" + testKeyA + ", sentry_timestamp=yesterday",
+		"Testing unparseable timestamp", []string{WarningStaleTimestamp}},
+	{"https://sentry.io/api/1/store/", "Sentry sentry_key=" + testKeyA + ", sentry_flavor=mint, other=1",
+		"Testing unknown auth field", []string{WarningUnknownAuthField}},
+	{"https://sentry.io/api/store/?sentry_secret=" + testKeyB, "Sentry sentry_flavor=mint, sentry_key=" + testKeyA,
+		"Testing several", []string{WarningLegacyEndpoint, WarningSecretInQuery, WarningUnknownAuthField}},
+}
+
+func TestWarnings(t *testing.T) {
+	now := time.Unix(1600000000, 0)
+	for _, test := range testTableWarning {
+		r := httptest.NewRequest("POST", test.url, nil)
+		if len(test.header) > 0 {
+			r.Header.Set("X-SENTRY-AUTH", test.header)
+		}
+		res, err := ParseRequest(r.Context(), r, WithClock(ClockFunc(func() time.Time { return now })))
+		if err != nil {
+			t.Errorf("%s: Expected -- nil -- Got %v", test.description, err)
+			continue
+		}
+		var got []string
+		for _, w := range res.Warnings {
+			got = append(got, w.Code)
+		}
+		if !reflect.DeepEqual(got, test.expected) {
+			t.Errorf("%s: Expected -- %v -- Got %v", test.description, test.expected, res.Warnings)
+		}
+	}
+}
@@ -0,0 +1,72 @@
+package dsn
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"strings"
+)
+
+// EndpointTunnel marks requests parsed with FromUpgradeRequest: a WebSocket carrying envelopes.
+const EndpointTunnel Endpoint = "tunnel"
+
+// ErrNotUpgrade Thrown when FromUpgradeRequest gets a request that is not a WebSocket upgrade
+var ErrNotUpgrade = errors.New("sentry:  not a WebSocket upgrade request")
+
+// Browsers can not set headers on WebSocket requests, so tunnels outside /api/<project_id>/ name the
+// project in this query parameter (or the X-Sentry-Project header for other clients).
+var WEBSOCKET_SENTRY_PROJECT = "sentry_project"
+var HTTP_X_SENTRY_PROJECT = "X-Sentry-Project"
+
+func IsWebSocketUpgrade(r *http.Request) bool {
+	/*
+		GET with Connection: upgrade and Upgrade: websocket (RFC 6455).
+	*/
+	return r.Method == http.MethodGet && headerHasToken(r.Header, "Connection", "upgrade") &&
+		headerHasToken(r.Header, "Upgrade", "websocket")
+}
+
+func headerHasToken(h http.Header, name, token string) bool {
+	for _, v := range h.Values(name) {
+		for _, t := range strings.Split(v, ",") {
+			if strings.EqualFold(strings.TrimSpace(t), token) {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+func FromUpgradeRequest(ctx context.Context, r *http.Request, opts ...Option) (*ParseResult, error) {
+	/*
+		Parses the upgrade request of a WebSocket tunnel before it is accepted. Credentials come from the
+		usual sources (sentry_key in the query for browsers, X-Sentry-Auth otherwise). The project comes from an
+		ingest path such as /api/1/envelope/ or, on any other path, from WEBSOCKET_SENTRY_PROJECT or HTTP_X_SENTRY_PROJECT.
+		The method check is skipped since upgrades are always GET and carry no body.
+		res.Endpoint is EndpointTunnel.
+	*/
+	if !IsWebSocketUpgrade(r) {
+		return nil, ErrNotUpgrade
+	}
+	c := *parserFor(opts).config
+	c.methods = nil
+	u := *r.URL
+	if _, _, err := parseIngestPath(u.Path); err != nil {
+		project := r.URL.Query().Get(WEBSOCKET_SENTRY_PROJECT)
+		if len(project) == 0 {
+			project = r.Header.Get(HTTP_X_SENTRY_PROJECT)
+		}
+		if strings.ContainsAny(project, "/?#") {
+			project = ""
+		}
+		u.Path = "/api/" + project + "/envelope/"
+	}
+	tunnel := r.WithContext(ctx)
+	tunnel.URL = &u
+	res := &ParseResult{}
+	if err := parseRequest(ctx, tunnel, &c, res); err != nil {
+		return nil, err
+	}
+	res.Endpoint = EndpointTunnel
+	return res, nil
+}
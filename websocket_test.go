@@ -0,0 +1,67 @@
+package dsn
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func newUpgradeRequest(target string) *http.Request {
+	r := httptest.NewRequest("GET", target, nil)
+	r.Header.Set("Connection", "keep-alive, Upgrade")
+	r.Header.Set("Upgrade", "websocket")
+	r.Header.Set("Sec-WebSocket-Version", "13")
+	return r
+}
+
+type testUpgrade struct {
+	target      string
+	header      map[string]string
+	description string
+	project     string
+	err         error
+}
+
+var testTableUpgrade = []testUpgrade{
+	{"https://sentry.io/api/1/envelope/?sentry_key=" + testKeyA, nil, "Testing ingest path", "1", nil},
+	{"https://relay.example.com/tunnel?sentry_key=" + testKeyA + "&sentry_project=42", nil, "Testing project in query", "42", nil},
+	{"https://relay.example.com/tunnel", map[string]string{"X-Sentry-Auth": "Sentry sentry_key=" + testKeyA, "X-Sentry-Project": "7"}, "Testing headers", "7", nil},
+	{"https://relay.example.com/tunnel?sentry_key=" + testKeyA + "&sentry_project=1/../2", nil, "Testing project with slashes", "", ErrMissingProjectID},
+	{"https://relay.example.com/tunnel?sentry_project=42", nil, "Testing missing key", "", ErrMissingUser},
+}
+
+func TestFromUpgradeRequest(t *testing.T) {
+	for _, test := range testTableUpgrade {
+		r := newUpgradeRequest(test.target)
+		for k, v := range test.header {
+			r.Header.Set(k, v)
+		}
+		res, err := FromUpgradeRequest(context.Background(), r, WithMethodCheck(false))
+		if err != test.err {
+			t.Errorf("%s: Expected -- %v -- Got %v", test.description, test.err, err)
+			continue
+		}
+		if err == nil && (res.DSN.ProjectID != test.project || res.DSN.PublicKey != testKeyA || res.Endpoint != EndpointTunnel) {
+			t.Errorf("%s: Expected -- project %s tunnel -- Got %s %s", test.description, test.project, res.DSN.ProjectID, res.Endpoint)
+		}
+	}
+	if r := newUpgradeRequest("https://relay.example.com/tunnel?sentry_project=1&sentry_key=" + testKeyA); r.URL.Path != "/tunnel" {
+		t.Errorf("Expected -- request left alone -- Got %s", r.URL.Path)
+	}
+}
+
+func TestFromUpgradeRequestNotUpgrade(t *testing.T) {
+	r := httptest.NewRequest("GET", "https://sentry.io/api/1/envelope/?sentry_key="+testKeyA, nil)
+	if _, err := FromUpgradeRequest(context.Background(), r); err != ErrNotUpgrade {
+		t.Errorf("Expected -- %v -- Got %v", ErrNotUpgrade, err)
+	}
+}
+
+func TestFromRequestUpgrade(t *testing.T) {
+	// plain FromRequest copes with upgrade requests on ingest paths: GET, no body
+	d, err := FromRequest(newUpgradeRequest("https://sentry.io/api/1/envelope/?sentry_key=" + testKeyA))
+	if err != nil || d.ProjectID != "1" {
+		t.Errorf("Expected -- project 1 -- Got %v %v", d, err)
+	}
+}
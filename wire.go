@@ -0,0 +1,304 @@
+package dsn
+
+import (
+	"bytes"
+	"encoding/binary"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"path/filepath"
+	"sort"
+	"time"
+)
+
+// WireVersion is the version of the ParseResult wire format written by MarshalJSON and MarshalBinary.
+// Readers accept every earlier version too and reject those they do not know with ErrWireVersion.
+// Version 2 appended the warnings, version 3 the unknown auth fields.
+const WireVersion = 3
+
+var (
+	// ErrWireVersion Thrown when a serialized ParseResult has a version this package can not read
+	ErrWireVersion = errors.New("sentry:  unsupported parse result version")
+	// ErrInvalidWire Thrown when a serialized ParseResult is malformed
+	ErrInvalidWire = errors.New("sentry:  invalid serialized parse result")
+)
+
+// wireAuth is Auth with JSON names.
+type wireAuth struct {
+	PublicKey string            `json:"public_key,omitempty"`
+	SecretKey string            `json:"secret_key,omitempty"`
+	Version   string            `json:"version,omitempty"`
+	Client    string            `json:"client,omitempty"`
+	Timestamp string            `json:"timestamp,omitempty"`
+	Signature string            `json:"signature,omitempty"`
+	Extra     map[string]string `json:"extra,omitempty"`
+}
+
+func (a wireAuth) auth() Auth {
+	auth := Auth{PublicKey: a.PublicKey, SecretKey: a.SecretKey, Version: a.Version, Client: a.Client,
+		Timestamp: a.Timestamp, Signature: a.Signature}
+	for k, v := range a.Extra {
+		auth.setExtra(k, v)
+	}
+	return auth
+}
+
+// wireResult is the JSON form of a ParseResult.
+type wireResult struct {
+	Version        int            `json:"v"`
+	DSN            *DSN           `json:"dsn,omitempty"`
+	Auth           wireAuth       `json:"auth"`
+	Endpoint       Endpoint       `json:"endpoint,omitempty"`
+	KeySource      Source         `json:"key_source"`
+	SecretSource   Source         `json:"secret_source"`
+	Tenant         *Tenant        `json:"tenant,omitempty"`
+	IdempotencyKey string         `json:"idempotency_key,omitempty"`
+	Category       string         `json:"category,omitempty"`
+	KeyExpires     *time.Time     `json:"key_expires,omitempty"`
+	ItemCounts     map[string]int `json:"item_counts,omitempty"`
+	ClientIP       net.IP         `json:"client_ip,omitempty"`
+	BodySize       int64          `json:"body_size"`
+	Warnings       []Warning      `json:"warnings,omitempty"`
+	Payload        []byte         `json:"payload,omitempty"`
+}
+
+func (res *ParseResult) wire() *wireResult {
+	a := res.Auth
+	w := &wireResult{
+		Version:        WireVersion,
+		DSN:            res.DSN,
+		Auth:           wireAuth{a.PublicKey, a.SecretKey, a.Version, a.Client, a.Timestamp, a.Signature, a.extraFields()},
+		Endpoint:       res.Endpoint,
+		KeySource:      res.KeySource,
+		SecretSource:   res.SecretSource,
+		Tenant:         res.Tenant,
+		IdempotencyKey: res.IdempotencyKey,
+		Category:       res.Category,
+		ItemCounts:     res.ItemCounts,
+		ClientIP:       res.ClientIP,
+		BodySize:       res.BodySize,
+		Warnings:       res.Warnings,
+		Payload:        res.payload,
+	}
+	if !res.KeyExpires.IsZero() {
+		w.KeyExpires = &res.KeyExpires
+	}
+	return w
+}
+
+// wireSocketDirs accepts every socket when decoding: the registry that produced the tenant already applied
+// its WithUnixSockets, and a reader need not repeat the writer's configuration.
+var wireSocketDirs = []string{string(filepath.Separator)}
+
+func (w *wireResult) result(res *ParseResult) error {
+	if w.Version < 1 || w.Version > WireVersion {
+		return fmt.Errorf("%w %d", ErrWireVersion, w.Version)
+	}
+	if w.Tenant != nil {
+		if err := w.Tenant.prepare(wireSocketDirs); err != nil {
+			return ErrInvalidWire
+		}
+	}
+	*res = ParseResult{
+		DSN:            w.DSN,
+		Auth:           w.Auth.auth(),
+		Endpoint:       w.Endpoint,
+		KeySource:      w.KeySource,
+		SecretSource:   w.SecretSource,
+		Tenant:         w.Tenant,
+		IdempotencyKey: w.IdempotencyKey,
+		Category:       w.Category,
+		ItemCounts:     w.ItemCounts,
+		ClientIP:       w.ClientIP,
+		BodySize:       w.BodySize,
+		Warnings:       w.Warnings,
+	}
+	if w.KeyExpires != nil {
+		res.KeyExpires = *w.KeyExpires
+	}
+	if w.Payload != nil {
+		res.Payload, res.payload = bytes.NewReader(w.Payload), w.Payload
+	}
+	return nil
+}
+
+func (res *ParseResult) MarshalJSON() ([]byte, error) {
+	/*
+		Versioned JSON, for queues and logs where readability matters more than size. The secret key is included:
+		downstream workers forwarding the submission need it, so treat the output like the request itself.
+	*/
+	return json.Marshal(res.wire())
+}
+
+func (res *ParseResult) UnmarshalJSON(b []byte) error {
+	var w wireResult
+	if err := json.Unmarshal(b, &w); err != nil {
+		return ErrInvalidWire
+	}
+	return w.result(res)
+}
+
+func (res *ParseResult) MarshalBinary() ([]byte, error) {
+	/*
+		Compact form of MarshalJSON: a version byte followed by length prefixed fields.
+	*/
+	w := res.wire()
+	var buf bytes.Buffer
+	buf.WriteByte(WireVersion)
+	put := func(b []byte) {
+		var n [binary.MaxVarintLen64]byte
+		buf.Write(n[:binary.PutUvarint(n[:], uint64(len(b)))])
+		buf.Write(b)
+	}
+	putInt := func(i int64) {
+		var n [binary.MaxVarintLen64]byte
+		buf.Write(n[:binary.PutVarint(n[:], i)])
+	}
+	d, err := json.Marshal(w.DSN)
+	if err != nil {
+		return nil, err
+	}
+	put(d)
+	for _, s := range []string{w.Auth.PublicKey, w.Auth.SecretKey, w.Auth.Version, w.Auth.Client, w.Auth.Timestamp, w.Auth.Signature,
+		string(w.Endpoint), w.IdempotencyKey, w.Category} {
+		put([]byte(s))
+	}
+	buf.WriteByte(byte(w.KeySource))
+	buf.WriteByte(byte(w.SecretSource))
+	var expires int64
+	if w.KeyExpires != nil {
+		expires = w.KeyExpires.UnixNano()
+	}
+	putInt(expires)
+	types := make([]string, 0, len(w.ItemCounts))
+	for t := range w.ItemCounts {
+		types = append(types, t)
+	}
+	sort.Strings(types)
+	putInt(int64(len(types)))
+	for _, t := range types {
+		put([]byte(t))
+		putInt(int64(w.ItemCounts[t]))
+	}
+	put(w.ClientIP)
+	putInt(w.BodySize)
+	var tenant []byte
+	if w.Tenant != nil {
+		if tenant, err = json.Marshal(w.Tenant); err != nil {
+			return nil, err
+		}
+	}
+	put(tenant)
+	put(w.Payload)
+	putInt(int64(len(w.Warnings)))
+	for _, warning := range w.Warnings {
+		put([]byte(warning.Code))
+		put([]byte(warning.Message))
+	}
+	extra := make([]string, 0, len(w.Auth.Extra))
+	for k := range w.Auth.Extra {
+		extra = append(extra, k)
+	}
+	sort.Strings(extra)
+	putInt(int64(len(extra)))
+	for _, k := range extra {
+		put([]byte(k))
+		put([]byte(w.Auth.Extra[k]))
+	}
+	return buf.Bytes(), nil
+}
+
+func (res *ParseResult) UnmarshalBinary(b []byte) error {
+	r := bytes.NewReader(b)
+	version, err := r.ReadByte()
+	if err != nil {
+		return ErrInvalidWire
+	}
+	w := wireResult{Version: int(version)}
+	if w.Version < 1 || w.Version > WireVersion {
+		return fmt.Errorf("%w %d", ErrWireVersion, w.Version)
+	}
+	var bad bool
+	get := func() []byte {
+		n, err := binary.ReadUvarint(r)
+		if err != nil || n > uint64(r.Len()) {
+			bad = true
+			return nil
+		}
+		field := make([]byte, n)
+		io.ReadFull(r, field)
+		return field
+	}
+	getInt := func() int64 {
+		i, err := binary.ReadVarint(r)
+		bad = bad || err != nil
+		return i
+	}
+	getByte := func() byte {
+		c, err := r.ReadByte()
+		bad = bad || err != nil
+		return c
+	}
+	if d := get(); !bad && string(d) != "null" {
+		w.DSN = &DSN{}
+		bad = json.Unmarshal(d, w.DSN) != nil
+	}
+	strs := make([]string, 9)
+	for i := range strs {
+		strs[i] = string(get())
+	}
+	w.Auth = wireAuth{strs[0], strs[1], strs[2], strs[3], strs[4], strs[5], nil}
+	w.Endpoint, w.IdempotencyKey, w.Category = Endpoint(strs[6]), strs[7], strs[8]
+	w.KeySource, w.SecretSource = Source(getByte()), Source(getByte())
+	if expires := getInt(); expires != 0 {
+		t := time.Unix(0, expires).UTC()
+		w.KeyExpires = &t
+	}
+	if n := getInt(); n > 0 && n <= int64(r.Len()) {
+		w.ItemCounts = make(map[string]int, n)
+		for i := int64(0); i < n && !bad; i++ {
+			t := string(get())
+			w.ItemCounts[t] = int(getInt())
+		}
+	} else if n != 0 {
+		bad = true
+	}
+	if ip := get(); len(ip) > 0 {
+		w.ClientIP = net.IP(ip)
+	}
+	w.BodySize = getInt()
+	if tenant := get(); len(tenant) > 0 && !bad {
+		w.Tenant = &Tenant{}
+		bad = json.Unmarshal(tenant, w.Tenant) != nil
+	}
+	if payload := get(); len(payload) > 0 {
+		w.Payload = payload
+	}
+	if w.Version >= 2 {
+		if n := getInt(); n > 0 && n <= int64(r.Len()) {
+			w.Warnings = make([]Warning, n)
+			for i := range w.Warnings {
+				w.Warnings[i] = Warning{string(get()), string(get())}
+			}
+		} else if n != 0 {
+			bad = true
+		}
+	}
+	if w.Version >= 3 {
+		if n := getInt(); n > 0 && n <= int64(r.Len()) {
+			w.Auth.Extra = make(map[string]string, n)
+			for i := int64(0); i < n && !bad; i++ {
+				k := string(get())
+				w.Auth.Extra[k] = string(get())
+			}
+		} else if n != 0 {
+			bad = true
+		}
+	}
+	if bad || r.Len() != 0 {
+		return ErrInvalidWire
+	}
+	return w.result(res)
+}
@@ -0,0 +1,126 @@
+package dsn
+
+import (
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"io/ioutil"
+	"net"
+	"net/http/httptest"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+)
+
+func testWireResult(t *testing.T) *ParseResult {
+	reg, _ := NewRegistry(Tenant{PublicKey: testKeyA, RateLimit: 60, Upstream: "https://" + testKeyB + "@sentry.io/2"})
+	r := httptest.NewRequest("GET", "https://relay.example.com/api/1/store/?sentry_version=7&sentry_key="+testKeyA+
+		"&sentry_data="+strings.Replace(testEvent, `"`, "%22", -1), nil)
+	res, err := ParseRequest(r.Context(), r, WithRegistry(reg), WithGetSubmissions(), WithIdempotencyKey(), WithClassification())
+	if err != nil {
+		t.Fatal(err)
+	}
+	res.KeyExpires = time.Date(2030, 1, 1, 0, 0, 0, 0, time.UTC)
+	res.ItemCounts = map[string]int{"event": 1, "attachment": 2}
+	res.ClientIP = net.ParseIP("198.51.100.7")
+	res.Warnings = []Warning{{WarningLegacyEndpoint, "legacy"}}
+	res.Auth.setExtra("sentry_flavor", "mint")
+	return res
+}
+
+type testWire struct {
+	description string
+	marshal     func(*ParseResult) ([]byte, error)
+	unmarshal   func(*ParseResult, []byte) error
+}
+
+var testTableWire = []testWire{
+	{"Testing binary", (*ParseResult).MarshalBinary, (*ParseResult).UnmarshalBinary},
+	{"Testing JSON", (*ParseResult).MarshalJSON, (*ParseResult).UnmarshalJSON},
+}
+
+func TestWireRoundTrip(t *testing.T) {
+	for _, test := range testTableWire {
+		for _, res := range []*ParseResult{testWireResult(t), {BodySize: -1}} {
+			b, err := test.marshal(res)
+			if err != nil {
+				t.Fatalf("%s: %v", test.description, err)
+			}
+			var got ParseResult
+			if err := test.unmarshal(&got, b); err != nil {
+				t.Errorf("%s: Expected -- nil -- Got %v", test.description, err)
+				continue
+			}
+			if got.Payload != nil {
+				payload, _ := ioutil.ReadAll(got.Payload)
+				if string(payload) != testEvent {
+					t.Errorf("%s: Expected -- payload %s -- Got %s", test.description, testEvent, payload)
+				}
+			}
+			if got.Tenant != nil && got.Tenant.UpstreamDSN() == nil {
+				t.Errorf("%s: Expected -- tenant upstream parsed -- Got nil", test.description)
+			}
+			want := *res
+			want.Payload, got.Payload = nil, nil
+			want.KeyExpires, got.KeyExpires = want.KeyExpires.UTC(), got.KeyExpires.UTC()
+			if !reflect.DeepEqual(&want, &got) {
+				t.Errorf("%s: Expected -- %+v -- Got %+v", test.description, want, got)
+			}
+		}
+	}
+}
+
+func TestWireErrors(t *testing.T) {
+	b, _ := testWireResult(t).MarshalBinary()
+	var res ParseResult
+	if err := res.UnmarshalBinary(b[:len(b)-3]); err != ErrInvalidWire {
+		t.Errorf("Testing truncated binary: Expected -- %v -- Got %v", ErrInvalidWire, err)
+	}
+	b[0] = 9
+	if err := res.UnmarshalBinary(b); !errors.Is(err, ErrWireVersion) {
+		t.Errorf("Testing binary version: Expected -- %v -- Got %v", ErrWireVersion, err)
+	}
+	if err := json.Unmarshal([]byte(`{"v": 9, "key_source": "query"}`), &res); !errors.Is(err, ErrWireVersion) {
+		t.Errorf("Testing JSON version: Expected -- %v -- Got %v", ErrWireVersion, err)
+	}
+	if err := json.Unmarshal([]byte(`{"v": 1, "key_source": "carrier pigeon"}`), &res); err != ErrInvalidWire {
+		t.Errorf("Testing JSON source: Expected -- %v -- Got %v", ErrInvalidWire, err)
+	}
+	j, _ := json.Marshal(testWireResult(t))
+	if !strings.Contains(string(j), `"v":3`) || !strings.Contains(string(j), `"key_source":"query"`) {
+		t.Errorf("Expected -- versioned JSON with source names -- Got %s", j)
+	}
+}
+
+// written by WireVersion 1, before warnings
+const (
+	testWireV1Binary = "01a3017b2275726c223a2268747470733a2f2f34373834666263353064653234373366393937376366636538613961646365354073656e7472792e" +
+		"696f2f31222c22736368656d65223a226874747073222c22686f7374223a2273656e7472792e696f222c2270726f6a6563745f6964223a2231222c2270756" +
+		"26c69635f6b6579223a223437383466626335306465323437336639393737636663653861396164636535227d203437383466626335306465323437336639" +
+		"3937376366636538613961646365350001370f73656e7472792e676f2f302e392e30000008656e76656c6f7065000001000002056576656e740204c6336407540000"
+	testWireV1JSON = `{"v":1,"dsn":{"url":"https://4784fbc50de2473f9977cfce8a9adce5@sentry.io/1","scheme":"https","host":"sentry.io",` +
+		`"project_id":"1","public_key":"4784fbc50de2473f9977cfce8a9adce5"},"auth":{"public_key":"4784fbc50de2473f9977cfce8a9adce5",` +
+		`"version":"7","client":"sentry.go/0.9.0"},"endpoint":"envelope","key_source":"header","secret_source":"none",` +
+		`"item_counts":{"event":1},"client_ip":"198.51.100.7","body_size":42}`
+)
+
+func TestWireV1(t *testing.T) {
+	b, _ := hex.DecodeString(testWireV1Binary)
+	for _, test := range testTableWire {
+		in := b
+		if test.description == "Testing JSON" {
+			in = []byte(testWireV1JSON)
+		}
+		var got ParseResult
+		if err := test.unmarshal(&got, in); err != nil {
+			t.Errorf("%s v1: Expected -- nil -- Got %v", test.description, err)
+			continue
+		}
+		if got.DSN == nil || got.DSN.PublicKey != testKeyA || got.Auth.Client != "sentry.go/0.9.0" || got.Endpoint != EndpointEnvelope ||
+			got.KeySource != SourceHeader || got.ItemCounts["event"] != 1 || !got.ClientIP.Equal(net.ParseIP("198.51.100.7")) ||
+			got.BodySize != 42 || got.Warnings != nil {
+			t.Errorf("%s v1: Expected -- the v1 result -- Got %+v", test.description, got)
+		}
+	}
+}